@@ -3,12 +3,23 @@ package main
 import (
 	"context"
 	_ "gw-currency-wallet/docs" //для запуска swagger
+	"gw-currency-wallet/internal/audit"
 	"gw-currency-wallet/internal/auth"
+	"gw-currency-wallet/internal/cache"
 	"gw-currency-wallet/internal/config"
 	"gw-currency-wallet/internal/handlers"
+	"gw-currency-wallet/internal/holds"
 	"gw-currency-wallet/internal/notifications"
+	"gw-currency-wallet/internal/orders"
+	"gw-currency-wallet/internal/outbox"
 	"gw-currency-wallet/internal/proto/proto/exchange"
+	"gw-currency-wallet/internal/reconcile"
+	"gw-currency-wallet/internal/scheduler"
+	"gw-currency-wallet/internal/snapshots"
+	"gw-currency-wallet/internal/storages"
 	"gw-currency-wallet/internal/storages/db/postgres"
+	"gw-currency-wallet/internal/storages/memory"
+	"gw-currency-wallet/internal/wallet"
 	"gw-currency-wallet/pkg/logging"
 	"log"
 	"net/http"
@@ -44,8 +55,16 @@ func main() {
 	cfg := config.GetConfig()
 	logger.Infof("Config loaded %v", cfg)
 
+	// Подкоманды: gw-currency-wallet migrate up|down|status, gw-currency-wallet reconcile
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		os.Exit(runMigrate(ctx, cfg, logger, os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "reconcile" {
+		os.Exit(runReconcile(ctx, cfg, logger, os.Args[2:]))
+	}
+
 	//2. Подключение к бд
-	storage, closeDB := postgres.NewPostgresRepository(ctx, &cfg.Storage, logger)
+	storage, closeDB := newStorage(ctx, &cfg.Storage, logger)
 	defer closeDB()
 
 	exchangerConn, err := grpc.NewClient(cfg.ExchangerAddr, grpc.WithTransportCredentials(insecure.NewCredentials()))
@@ -56,16 +75,39 @@ func main() {
 
 	exchangerClient := exchange.NewExchangeServiceClient(exchangerConn)
 
-	authService := auth.NewService(storage, cfg.JWTSecret, exchangerClient, logger)
+	routing := cache.Routing{Mode: cfg.Rates.Routing, Base: cfg.Rates.BaseCurrency, MaxLegs: cfg.Rates.MaxLegs}
+	authService := auth.NewService(storage, cfg.JWTSecret, exchangerClient, routing, logger)
 
 	notificationService := notifications.NewNotificationService(cfg.KafkaBroker, cfg.KafkaTopic)
 	defer notificationService.Close()
 
+	wallets := wallet.NewService(storage, authService.Currencies(), authService, cfg.LimitsBaseCurrency, cfg.QuoteTTL)
+
+	// Лимитные заявки исполняются по всем курсам, которые получает сервис
+	matcher := orders.NewMatcher(storage, wallets, routing, logger)
+	authService.OnRatesFetched(matcher.Feed)
+
+	// Фоновые задачи: закрытие просроченных холдов, исполнение заявок, операции по расписанию,
+	// снимки остатков на конец дня, сверка балансов, доставка уведомлений из outbox
+	// и запечатывание журнала аудита
+	workersCtx, stopWorkers := context.WithCancel(ctx)
+	defer stopWorkers()
+	go holds.RunExpirer(workersCtx, storage, cfg.HoldsExpireInterval, logger)
+	go matcher.Run(workersCtx, authService, cfg.OrdersMatchInterval)
+	go scheduler.Run(workersCtx, storage, wallets, cfg.SchedulerInterval, logger)
+	go snapshots.Run(workersCtx, storage, cfg.SnapshotInterval, logger)
+	go outbox.Run(workersCtx, storage, notificationService, cfg.OutboxInterval, logger)
+	go audit.Run(workersCtx, storage, cfg.AuditSealInterval, logger)
+	if cfg.Reconcile.Interval > 0 {
+		go reconcile.Run(workersCtx, storage, cfg.Reconcile.Interval, cfg.Reconcile.ReportDir,
+			reconcile.Options{Freeze: cfg.Reconcile.Freeze}, logger)
+	}
+
 	//3. Создание сервера
 	router := gin.Default()
 
 	// Настройка маршрутов
-	handlers.SetupRoutes(router, storage, authService, notificationService)
+	handlers.SetupRoutes(router, storage, authService, wallets)
 
 	// Swagger
 	router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
@@ -93,6 +135,7 @@ func main() {
 	signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
 	<-quit
 	logger.Info("Shutting down server...")
+	stopWorkers()
 
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
@@ -103,3 +146,17 @@ func main() {
 
 	logger.Info("Server exited gracefully")
 }
+
+// newStorage выбирает реализацию хранилища по storage.driver
+func newStorage(ctx context.Context, cfg *config.StorageConfig, logger *logging.Logger) (storages.Repository, func()) {
+	switch cfg.Driver {
+	case "memory":
+		logger.Warn("using in-memory storage: data will be lost on restart")
+		return memory.NewMemoryRepository(), func() {}
+	case "", "postgres":
+		return postgres.NewPostgresRepository(ctx, cfg, logger)
+	default:
+		logger.Fatalf("unknown storage driver %q", cfg.Driver)
+		return nil, nil
+	}
+}
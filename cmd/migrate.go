@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"gw-currency-wallet/internal/config"
+	"gw-currency-wallet/internal/storages/db/postgres"
+	"gw-currency-wallet/pkg/logging"
+	"os"
+	"strconv"
+	"text/tabwriter"
+)
+
+const migrateUsage = "usage: gw-currency-wallet migrate up | down [steps] | status"
+
+// runMigrate выполняет подкоманду migrate и возвращает код завершения процесса
+func runMigrate(ctx context.Context, cfg *config.Config, logger *logging.Logger, args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		return 2
+	}
+
+	if cfg.Storage.Driver == "memory" {
+		fmt.Fprintln(os.Stderr, "migrations are not applicable to the memory storage driver")
+		return 2
+	}
+
+	// Проверка схемы при подключении помешала бы её обновить
+	storageCfg := cfg.Storage
+	storageCfg.CheckSchema = false
+	repo, closeDB := postgres.NewPostgresRepository(ctx, &storageCfg, logger)
+	defer closeDB()
+	storage := repo.(*postgres.Postgres)
+
+	switch args[0] {
+	case "up":
+		applied, err := storage.MigrateUp(ctx)
+		if err != nil {
+			logger.Errorf("migrate up: %v", err)
+			return 1
+		}
+		logger.Infof("applied %d migration(s)", len(applied))
+
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil || n < 1 {
+				fmt.Fprintln(os.Stderr, migrateUsage)
+				return 2
+			}
+			steps = n
+		}
+		reverted, err := storage.MigrateDown(ctx, steps)
+		if err != nil {
+			logger.Errorf("migrate down: %v", err)
+			return 1
+		}
+		logger.Infof("reverted %d migration(s)", len(reverted))
+
+	case "status":
+		statuses, err := storage.MigrationStatus(ctx)
+		if err != nil {
+			logger.Errorf("migrate status: %v", err)
+			return 1
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED AT")
+		for _, s := range statuses {
+			appliedAt := "pending"
+			if s.AppliedAt != nil {
+				appliedAt = s.AppliedAt.Format("2006-01-02 15:04:05 MST")
+			}
+			fmt.Fprintf(w, "%04d\t%s\t%s\n", s.Version, s.Name, appliedAt)
+		}
+		w.Flush()
+
+	default:
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		return 2
+	}
+	return 0
+}
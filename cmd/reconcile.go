@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"gw-currency-wallet/internal/config"
+	"gw-currency-wallet/internal/reconcile"
+	"gw-currency-wallet/pkg/logging"
+	"io"
+	"os"
+	"strconv"
+)
+
+const reconcileUsage = "usage: gw-currency-wallet reconcile [-freeze] [-out report.json] [-batch N] | unfreeze USER_ID CURRENCY"
+
+// Коды завершения reconcile: 3 — найдены расхождения, чтобы запуск из cron было видно без разбора отчёта
+const exitMismatches = 3
+
+// runReconcile сверяет балансы с журналом и пишет отчёт в stdout или файл,
+// либо снимает заморозку со счёта. Возвращает код завершения процесса
+func runReconcile(ctx context.Context, cfg *config.Config, logger *logging.Logger, args []string) int {
+	if len(args) > 0 && args[0] == "unfreeze" {
+		return runUnfreeze(ctx, cfg, logger, args[1:])
+	}
+
+	flags := flag.NewFlagSet("reconcile", flag.ContinueOnError)
+	freeze := flags.Bool("freeze", cfg.Reconcile.Freeze, "freeze accounts with mismatches")
+	out := flags.String("out", "", "report file (default stdout)")
+	batch := flags.Int("batch", 0, "users per read transaction")
+	if err := flags.Parse(args); err != nil || flags.NArg() > 0 {
+		fmt.Fprintln(os.Stderr, reconcileUsage)
+		return 2
+	}
+
+	storage, closeDB := newStorage(ctx, &cfg.Storage, logger)
+	defer closeDB()
+
+	report, err := reconcile.Reconcile(ctx, storage, reconcile.Options{BatchSize: *batch, Freeze: *freeze})
+	if err != nil {
+		logger.Errorf("reconcile: %v", err)
+		return 1
+	}
+
+	var w io.Writer = os.Stdout
+	if *out != "" {
+		file, err := os.Create(*out)
+		if err != nil {
+			logger.Errorf("reconcile: %v", err)
+			return 1
+		}
+		defer file.Close()
+		w = file
+	}
+	if err = reconcile.WriteReport(w, report); err != nil {
+		logger.Errorf("reconcile: failed to write report: %v", err)
+		return 1
+	}
+
+	logger.Infof("checked %d accounts of %d users, %d mismatched", report.Accounts, report.Users, len(report.Mismatches))
+	if len(report.Mismatches) > 0 {
+		return exitMismatches
+	}
+	return 0
+}
+
+func runUnfreeze(ctx context.Context, cfg *config.Config, logger *logging.Logger, args []string) int {
+	if len(args) != 2 {
+		fmt.Fprintln(os.Stderr, reconcileUsage)
+		return 2
+	}
+	userID, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, reconcileUsage)
+		return 2
+	}
+
+	storage, closeDB := newStorage(ctx, &cfg.Storage, logger)
+	defer closeDB()
+
+	if err = storage.SetAccountFrozen(ctx, userID, args[1], false); err != nil {
+		logger.Errorf("reconcile unfreeze: %v", err)
+		return 1
+	}
+	logger.Infof("account %d %s unfrozen", userID, args[1])
+	return 0
+}
@@ -0,0 +1,80 @@
+package audit
+
+import (
+	"context"
+	"gw-currency-wallet/internal/storages"
+	"gw-currency-wallet/pkg/logging"
+	"time"
+)
+
+// batchSize — сколько записей запечатывается или проверяется за один запрос к хранилищу
+const batchSize = 500
+
+// Verification — результат проверки цепочки журнала аудита
+type Verification struct {
+	Valid   bool `json:"valid"`
+	Checked int  `json:"checked"` // сколько запечатанных записей проверено
+	// BrokenSeq — номер первой записи, хеш или ссылка на предыдущую запись которой не сходится
+	BrokenSeq int64 `json:"broken_seq,omitempty"`
+	// Head — хеш последней записи цепочки; сохранённый вне БД, он позволяет заметить
+	// и пересчёт всей цепочки после подмены
+	Head string `json:"head,omitempty"`
+}
+
+// Run раз в interval включает новые записи журнала аудита в цепочку хешей. Блокируется до отмены ctx
+func Run(ctx context.Context, storage storages.Repository, interval time.Duration, logger *logging.Logger) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := Seal(ctx, storage); err != nil {
+				logger.Errorf("failed to seal audit log: %v", err)
+			}
+		}
+	}
+}
+
+// Seal запечатывает все записи, добавленные к этому моменту, и возвращает их число
+func Seal(ctx context.Context, storage storages.Repository) (int, error) {
+	total := 0
+	for {
+		sealed, err := storage.SealAudit(ctx, batchSize)
+		if err != nil {
+			return total, err
+		}
+		total += sealed
+		if sealed < batchSize {
+			return total, nil
+		}
+	}
+}
+
+// Verify проходит цепочку от первой записи и пересчитывает хеши. Проверка останавливается
+// на первой записи, которая не сходится: последующие записи ссылаются на неё
+func Verify(ctx context.Context, storage storages.Repository) (Verification, error) {
+	result := Verification{Valid: true}
+	var afterSeq int64
+	for {
+		entries, err := storage.AuditChain(ctx, afterSeq, batchSize)
+		if err != nil {
+			return result, err
+		}
+		for _, e := range entries {
+			if e.Seq != afterSeq+1 || e.PrevHash != result.Head || e.Hash != e.ComputeHash() {
+				result.Valid = false
+				result.BrokenSeq = afterSeq + 1
+				return result, nil
+			}
+			result.Checked++
+			result.Head = e.Hash
+			afterSeq = e.Seq
+		}
+		if len(entries) < batchSize {
+			return result, nil
+		}
+	}
+}
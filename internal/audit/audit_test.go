@@ -0,0 +1,74 @@
+package audit
+
+import (
+	"context"
+	"gw-currency-wallet/internal/storages"
+	"gw-currency-wallet/internal/storages/memory"
+	"gw-currency-wallet/pkg/money"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// tamperedStorage подменяет сумму в одной записи цепочки при чтении
+type tamperedStorage struct {
+	*memory.Memory
+	seq int64
+}
+
+func (s tamperedStorage) AuditChain(ctx context.Context, afterSeq int64, limit int) ([]storages.AuditEntry, error) {
+	entries, err := s.Memory.AuditChain(ctx, afterSeq, limit)
+	for i := range entries {
+		if entries[i].Seq == s.seq {
+			entries[i].After = []byte(`[{"wallet_id": 1, "currency": "USD", "amount": 1000000.00}]`)
+		}
+	}
+	return entries, err
+}
+
+func TestSealAndVerify(t *testing.T) {
+	ctx := storages.WithAuditMeta(context.Background(), storages.AuditMeta{ActorID: 7, RequestID: "req-1"})
+	storage := memory.NewMemoryRepository()
+
+	userID, err := storage.CreateUser(ctx, "test@example.com", "hash")
+	require.NoError(t, err)
+	_, err = storage.Credit(ctx, userID, "USD", money.New(100, 0))
+	require.NoError(t, err)
+	_, err = storage.Debit(ctx, userID, "USD", money.New(30, 0))
+	require.NoError(t, err)
+	require.NoError(t, storage.SetAccountFrozen(ctx, userID, "USD", true))
+
+	// Незапечатанные записи в проверку не входят
+	result, err := Verify(ctx, storage)
+	require.NoError(t, err)
+	assert.Equal(t, Verification{Valid: true}, result)
+
+	sealed, err := Seal(ctx, storage)
+	require.NoError(t, err)
+	assert.Equal(t, 3, sealed)
+	sealed, err = Seal(ctx, storage)
+	require.NoError(t, err)
+	assert.Zero(t, sealed)
+
+	entries, err := storage.AuditChain(ctx, 0, 10)
+	require.NoError(t, err)
+	require.Len(t, entries, 3)
+	assert.Equal(t, storages.AuditAction("balance.withdraw"), entries[1].Action)
+	assert.Equal(t, int64(7), entries[1].ActorID)
+	assert.Equal(t, "req-1", entries[1].RequestID)
+	assert.Equal(t, entries[0].Hash, entries[1].PrevHash)
+	assert.Equal(t, storages.AuditFreeze, entries[2].Action)
+
+	result, err = Verify(ctx, storage)
+	require.NoError(t, err)
+	assert.True(t, result.Valid)
+	assert.Equal(t, 3, result.Checked)
+	assert.Equal(t, entries[2].Hash, result.Head)
+
+	result, err = Verify(ctx, tamperedStorage{Memory: storage, seq: 2})
+	require.NoError(t, err)
+	assert.False(t, result.Valid)
+	assert.Equal(t, int64(2), result.BrokenSeq)
+	assert.Equal(t, 1, result.Checked)
+}
@@ -1,6 +1,8 @@
 package auth
 
 import (
+	"errors"
+	"gw-currency-wallet/internal/storages"
 	"net/http"
 	"strings"
 
@@ -23,6 +25,32 @@ func JWTMiddleware(authService *Service) gin.HandlerFunc {
 		}
 
 		c.Set("userID", userID)
+		// Действия запроса записываются в журнал аудита от имени пользователя токена
+		meta := storages.AuditMetaFrom(c.Request.Context())
+		meta.ActorID = userID
+		c.Request = c.Request.WithContext(storages.WithAuditMeta(c.Request.Context(), meta))
+		c.Next()
+	}
+}
+
+// AdminMiddleware пропускает только пользователей с доступом к административному API.
+// Ставится после JWTMiddleware
+func AdminMiddleware(authService *Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := GetUserID(c)
+		if !ok {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "missing Authorization header"})
+			return
+		}
+		admin, err := authService.IsAdmin(c.Request.Context(), userID)
+		if err != nil && !errors.Is(err, storages.ErrUserNotFound) {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "failed to check access"})
+			return
+		}
+		if !admin {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "admin access required"})
+			return
+		}
 		c.Next()
 	}
 }
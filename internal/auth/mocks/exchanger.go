@@ -0,0 +1,29 @@
+package mocks
+
+import (
+	"context"
+	"gw-currency-wallet/internal/proto/proto/exchange"
+
+	"google.golang.org/grpc"
+)
+
+// MockExchangerClient — заглушка gRPC-клиента обменника с фиксированными курсами
+type MockExchangerClient struct{}
+
+func (m *MockExchangerClient) GetExchangeRates(ctx context.Context, in *exchange.Empty, opts ...grpc.CallOption) (*exchange.ExchangeRatesResponse, error) {
+	return &exchange.ExchangeRatesResponse{
+		Rates: map[string]float32{
+			"USD_RUB": 90.0,
+			"EUR_RUB": 100.0,
+			"USD_EUR": 0.9,
+		},
+	}, nil
+}
+
+func (m *MockExchangerClient) GetExchangeRateForCurrency(ctx context.Context, in *exchange.CurrencyRequest, opts ...grpc.CallOption) (*exchange.ExchangeRateResponse, error) {
+	return &exchange.ExchangeRateResponse{
+		FromCurrency: in.FromCurrency,
+		ToCurrency:   in.ToCurrency,
+		Rate:         90.0,
+	}, nil
+}
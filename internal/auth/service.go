@@ -2,12 +2,17 @@ package auth
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"gw-currency-wallet/internal/cache"
+	"gw-currency-wallet/internal/currencies"
 	"gw-currency-wallet/internal/proto/proto/exchange"
 	"gw-currency-wallet/internal/storages"
 	"gw-currency-wallet/pkg/logging"
+	"gw-currency-wallet/pkg/money"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
@@ -18,38 +23,105 @@ type Service struct {
 	storage         storages.Repository
 	jwtSecret       string
 	rateCache       *cache.RateCache
+	currencies      *currencies.Catalog
 	logger          logging.Logger
 	exchangerClient exchange.ExchangeServiceClient
+	// routing — как искать курс пары, которой нет у exchanger
+	routing cache.Routing
+
+	ratesMu        sync.RWMutex
+	ratesListeners []func(map[string]money.Decimal)
 }
 
-func NewService(storage storages.Repository, jwtSecret string, exClient exchange.ExchangeServiceClient, logger *logging.Logger) *Service {
+func NewService(storage storages.Repository, jwtSecret string, exClient exchange.ExchangeServiceClient, routing cache.Routing, logger *logging.Logger) *Service {
 	return &Service{
 		storage:         storage,
 		jwtSecret:       jwtSecret,
 		rateCache:       cache.NewRateCache(30 * time.Second),
+		currencies:      currencies.NewCatalog(storage, 30*time.Second),
 		logger:          *logger,
 		exchangerClient: exClient,
+		routing:         routing,
 	}
 }
+
+// Currencies — справочник валют, общий для проверки запросов и курсов
+func (s *Service) Currencies() *currencies.Catalog {
+	return s.currencies
+}
+
+// OnRatesFetched подписывает fn на все курсы, полученные FetchAndCacheAllRates.
+// fn вызывается синхронно и не должна блокироваться
+func (s *Service) OnRatesFetched(fn func(rates map[string]money.Decimal)) {
+	s.ratesMu.Lock()
+	defer s.ratesMu.Unlock()
+	s.ratesListeners = append(s.ratesListeners, fn)
+}
+
 func (s *Service) Register(ctx context.Context, email, password string) error {
 	passwordHash := hashPassword(password)
-	_, err := s.storage.CreateUser(ctx, email, passwordHash)
-	return err
+	userID, err := s.storage.CreateUser(ctx, email, passwordHash)
+	if err != nil {
+		return err
+	}
+
+	s.audit(ctx, storages.AuditEntry{
+		ActorID: userID,
+		UserID:  userID,
+		Action:  storages.AuditRegister,
+		Target:  fmt.Sprintf("user:%d", userID),
+		After:   auditDetails(map[string]string{"email": email}),
+	})
+	return nil
 }
 
+// Login выдаёт токен. Успешные и неудачные попытки входа записываются в журнал аудита
 func (s *Service) Login(ctx context.Context, email, password string) (string, error) {
 	user, err := s.storage.GetUserByEmail(ctx, email)
 	if err != nil {
+		s.audit(ctx, storages.AuditEntry{
+			Action: storages.AuditLoginFailed,
+			Target: "email:" + email,
+			After:  auditDetails(map[string]string{"reason": "user not found"}),
+		})
 		return "", errors.New("user not found")
 	}
 
+	entry := storages.AuditEntry{UserID: user.ID, Action: storages.AuditLogin, Target: fmt.Sprintf("user:%d", user.ID)}
 	if !checkPassword(password, user.PasswordHash) {
+		entry.Action = storages.AuditLoginFailed
+		entry.After = auditDetails(map[string]string{"reason": "invalid password"})
+		s.audit(ctx, entry)
 		return "", errors.New("invalid password")
 	}
 
+	entry.ActorID = user.ID
+	s.audit(ctx, entry)
 	return s.generateToken(user.ID)
 }
 
+// IsAdmin — есть ли у пользователя доступ к административному API
+func (s *Service) IsAdmin(ctx context.Context, userID int64) (bool, error) {
+	user, err := s.storage.GetUser(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	return user.Admin, nil
+}
+
+// audit записывает событие входа или регистрации. Ошибка записи не отменяет
+// уже выполненное действие и только попадает в лог
+func (s *Service) audit(ctx context.Context, entry storages.AuditEntry) {
+	if err := s.storage.AppendAudit(ctx, entry); err != nil {
+		s.logger.Errorf("failed to write audit entry %s: %v", entry.Action, err)
+	}
+}
+
+func auditDetails(details map[string]string) json.RawMessage {
+	data, _ := json.Marshal(details)
+	return data
+}
+
 func (s *Service) ParseToken(tokenStr string) (int64, error) {
 	token, err := jwt.ParseWithClaims(tokenStr, &Claims{}, func(token *jwt.Token) (interface{}, error) {
 		return []byte(s.jwtSecret), nil
@@ -66,36 +138,103 @@ func (s *Service) ParseToken(tokenStr string) (int64, error) {
 	return 0, errors.New("invalid token claims")
 }
 
-func (s *Service) GetExchangeRateWithCache(from, to string) (float32, error) {
+func (s *Service) GetExchangeRateWithCache(from, to string) (money.Decimal, error) {
+	path, err := s.GetRatePath(from, to)
+	if err != nil {
+		return money.Decimal{}, err
+	}
+	return path.Rate, nil
+}
+
+// GetRatePath возвращает курс from→to и шаги, из которых он получен. Сначала прямая пара ищется
+// в кэше, затем запрашивается у exchanger. Только если у exchanger нет такой пары, курс выводится
+// из кэша (обратный или цепочкой по routing), а при его отсутствии — из всех курсов exchanger
+func (s *Service) GetRatePath(from, to string) (cache.RatePath, error) {
+	for _, code := range []string{from, to} {
+		if _, err := s.currencies.Lookup(context.Background(), code); err != nil {
+			return cache.RatePath{}, err
+		}
+	}
+
 	// Сначала пробуем кэш
 	if rate, ok := s.rateCache.GetRate(from, to); ok {
 		s.logger.Infof("Get Rate from cache %v", rate)
-		return rate, nil
+		return cache.DirectPath(from, to, rate), nil
 	}
 
 	resp, err := s.exchangerClient.GetExchangeRateForCurrency(context.Background(), &exchange.CurrencyRequest{
 		FromCurrency: from,
 		ToCurrency:   to,
 	})
-	if err != nil {
-		return 0, fmt.Errorf("failed to get exchange rate: %w", err)
+	if err == nil {
+		s.logger.Infof("Get Rate from exchangerClient %v", resp.Rate)
+		rate, err := money.FromFloat32(resp.Rate)
+		if err != nil {
+			return cache.RatePath{}, err
+		}
+		s.saveRates([]storages.RateSample{{
+			FromCurrency: from, ToCurrency: to, Rate: rate, Source: storages.RateSourcePair, FetchedAt: time.Now().UTC(),
+		}})
+		return cache.DirectPath(from, to, rate), nil
 	}
 
-	s.logger.Infof("Get Rate from exchangerClient %v", resp.Rate)
-	return resp.Rate, nil
+	// Пары у exchanger нет — выводим курс из других пар
+	if path, ok := s.rateCache.Resolve(from, to, s.routing); ok {
+		s.logger.Infof("Derived rate from cache %v via %s", path.Rate, path)
+		return path, nil
+	}
+	rates, fetchErr := s.FetchAndCacheAllRates()
+	if fetchErr != nil {
+		return cache.RatePath{}, fmt.Errorf("failed to get exchange rate: %w", err)
+	}
+	path, ok := cache.FindPath(rates, from, to, s.routing)
+	if !ok {
+		return cache.RatePath{}, fmt.Errorf("failed to get exchange rate: no path from %s to %s: %w", from, to, err)
+	}
+	s.logger.Infof("Derived rate %v via %s", path.Rate, path)
+	return path, nil
 }
 
 // FetchAndCacheAllRates — вызывается при /exchange/rates
-func (s *Service) FetchAndCacheAllRates() (map[string]float32, error) {
+func (s *Service) FetchAndCacheAllRates() (map[string]money.Decimal, error) {
 	resp, err := s.exchangerClient.GetExchangeRates(context.Background(), &exchange.Empty{})
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch all rates: %w", err)
 	}
 
-	// Сохраняем в кэш
-	s.logger.Infof("Save all rates to cache %v", resp.Rates)
-	s.rateCache.SetAllRates(resp.Rates)
-	return resp.Rates, nil
+	// gRPC отдаёт курсы во float32 — переводим в десятичные один раз на входе
+	// Курсы валют, которых нет в справочнике или которые отключены, не показываем
+	rates := make(map[string]money.Decimal, len(resp.Rates))
+	for pair, rate := range resp.Rates {
+		from, to, _ := strings.Cut(pair, "_")
+		if !s.currencies.IsEnabled(context.Background(), from) || !s.currencies.IsEnabled(context.Background(), to) {
+			continue
+		}
+		if rates[pair], err = money.FromFloat32(rate); err != nil {
+			return nil, fmt.Errorf("invalid rate for %s: %w", pair, err)
+		}
+	}
+
+	// Сохраняем в кэш и в историю курсов
+	s.logger.Infof("Save all rates to cache %v", rates)
+	s.rateCache.SetAllRates(rates)
+
+	fetchedAt := time.Now().UTC()
+	samples := make([]storages.RateSample, 0, len(rates))
+	for pair, rate := range rates {
+		from, to, _ := strings.Cut(pair, "_")
+		samples = append(samples, storages.RateSample{
+			FromCurrency: from, ToCurrency: to, Rate: rate, Source: storages.RateSourceList, FetchedAt: fetchedAt,
+		})
+	}
+	s.saveRates(samples)
+
+	s.ratesMu.RLock()
+	defer s.ratesMu.RUnlock()
+	for _, fn := range s.ratesListeners {
+		fn(rates)
+	}
+	return rates, nil
 }
 
 func (s *Service) generateToken(userId int64) (string, error) {
@@ -119,3 +258,11 @@ func checkPassword(password, hash string) bool {
 	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
 	return err == nil
 }
+
+// saveRates записывает курсы в историю. Курс уже получен и используется, поэтому ошибка записи
+// только логируется и не мешает обмену
+func (s *Service) saveRates(samples []storages.RateSample) {
+	if err := s.storage.SaveRates(context.Background(), samples); err != nil {
+		s.logger.Errorf("failed to save rate history: %v", err)
+	}
+}
@@ -2,68 +2,117 @@ package auth
 
 import (
 	"context"
-	"gw-currency-wallet/internal/storages"
+	"errors"
+	"gw-currency-wallet/internal/cache"
+	"gw-currency-wallet/internal/proto/proto/exchange"
+	"gw-currency-wallet/internal/storages/memory"
 	"gw-currency-wallet/pkg/logging"
 	"testing"
 
 	"golang.org/x/crypto/bcrypt"
+	"google.golang.org/grpc"
 
 	"github.com/stretchr/testify/assert"
-	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 )
 
-type MockStorage struct {
-	mock.Mock
-}
-
-func (m *MockStorage) CreateUser(ctx context.Context, email, passwordHash string) (int64, error) {
-	args := m.Called(ctx, email, passwordHash)
-	return args.Get(0).(int64), args.Error(1)
-}
-
-func (m *MockStorage) GetUserByEmail(ctx context.Context, email string) (storages.User, error) {
-	args := m.Called(ctx, email)
-	return args.Get(0).(storages.User), args.Error(1)
-}
-
-func (m *MockStorage) GetBalance(ctx context.Context, userID int64, currency string) (float32, error) {
-	args := m.Called(ctx, userID, currency)
-	return args.Get(0).(float32), args.Error(1)
-}
-
-func (m *MockStorage) GetAllBalances(ctx context.Context, userID int64) (map[string]float32, error) {
-	return make(map[string]float32), nil
-}
-
-func (m *MockStorage) UpdateBalance(ctx context.Context, userID int64, currency string, amount float32) error {
-	args := m.Called(ctx, userID, currency, amount)
-	return args.Error(0)
-}
-
 func TestAuth_Register(t *testing.T) {
-	storage := new(MockStorage)
-	storage.On("CreateUser", mock.Anything, "test2@example.com", mock.Anything).Return(int64(1), nil)
+	storage := memory.NewMemoryRepository()
 	logger := logging.GetLogger()
-	service := NewService(storage, "secret", nil, logger)
+	service := NewService(storage, "secret", nil, cache.Routing{}, logger)
 	err := service.Register(context.Background(), "test2@example.com", "password")
 
 	assert.NoError(t, err)
-	storage.AssertExpectations(t)
+
+	user, err := storage.GetUserByEmail(context.Background(), "test2@example.com")
+	assert.NoError(t, err)
+	assert.NoError(t, bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte("password")))
+
+	// Повторная регистрация с тем же email отклоняется
+	err = service.Register(context.Background(), "test2@example.com", "password")
+	assert.Error(t, err)
 }
 
 func TestAuth_Login(t *testing.T) {
-	storage := new(MockStorage)
+	storage := memory.NewMemoryRepository()
 
 	// Создаем корректный хеш для пароля "password"
 	passwordHash, err := bcrypt.GenerateFromPassword([]byte("password"), bcrypt.DefaultCost)
 	assert.NoError(t, err)
 
-	user := storages.User{ID: 1, Email: "test2@example.com", PasswordHash: string(passwordHash)}
-	storage.On("GetUserByEmail", mock.Anything, "test2@example.com").Return(user, nil)
+	userID, err := storage.CreateUser(context.Background(), "test2@example.com", string(passwordHash))
+	assert.NoError(t, err)
 	logger := logging.GetLogger()
-	service := NewService(storage, "secret", nil, logger)
+	service := NewService(storage, "secret", nil, cache.Routing{}, logger)
 	token, err := service.Login(context.Background(), "test2@example.com", "password")
 
 	assert.NoError(t, err)
 	assert.NotEmpty(t, token)
+
+	parsedID, err := service.ParseToken(token)
+	assert.NoError(t, err)
+	assert.Equal(t, userID, parsedID)
+}
+
+// pairlessExchanger отдаёт только общий список курсов: запрос отдельной пары всегда неудачен
+type pairlessExchanger struct {
+	rates map[string]float32
+}
+
+func (e pairlessExchanger) GetExchangeRates(ctx context.Context, in *exchange.Empty, opts ...grpc.CallOption) (*exchange.ExchangeRatesResponse, error) {
+	return &exchange.ExchangeRatesResponse{Rates: e.rates}, nil
+}
+
+func (pairlessExchanger) GetExchangeRateForCurrency(ctx context.Context, in *exchange.CurrencyRequest, opts ...grpc.CallOption) (*exchange.ExchangeRateResponse, error) {
+	return nil, errors.New("pair not found")
+}
+
+func TestAuth_GetRatePath(t *testing.T) {
+	storage := memory.NewMemoryRepository()
+	exchanger := pairlessExchanger{rates: map[string]float32{"USD_RUB": 90, "USD_EUR": 0.9}}
+	service := NewService(storage, "secret", exchanger, cache.Routing{Mode: cache.RoutingBase, Base: "USD"}, logging.GetLogger())
+
+	// EUR→RUB у exchanger нет: EUR→USD — обратный к USD_EUR, затем USD→RUB
+	path, err := service.GetRatePath("EUR", "RUB")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"EUR", "USD", "RUB"}, path.Currencies())
+	assert.True(t, path.Legs[0].Inverse)
+	assert.Equal(t, "99.99999990", path.Rate.String())
+
+	// Повторный запрос обслуживается из кэша тем же путём
+	cached, err := service.GetRatePath("EUR", "RUB")
+	require.NoError(t, err)
+	assert.True(t, cached.Rate.Equal(path.Rate))
+
+	_, err = service.GetRatePath("EUR", "GBP")
+	assert.Error(t, err)
+}
+
+// pairExchanger отвечает на запрос любой пары курсом pair
+type pairExchanger struct {
+	pairlessExchanger
+	pair float32
+}
+
+func (e pairExchanger) GetExchangeRateForCurrency(ctx context.Context, in *exchange.CurrencyRequest, opts ...grpc.CallOption) (*exchange.ExchangeRateResponse, error) {
+	return &exchange.ExchangeRateResponse{FromCurrency: in.FromCurrency, ToCurrency: in.ToCurrency, Rate: e.pair}, nil
+}
+
+func TestAuth_GetRatePath_PrefersExchangerPair(t *testing.T) {
+	storage := memory.NewMemoryRepository()
+	exchanger := pairExchanger{pairlessExchanger: pairlessExchanger{rates: map[string]float32{"USD_EUR": 0.9}}, pair: 1.2}
+	service := NewService(storage, "secret", exchanger, cache.Routing{Mode: cache.RoutingBase, Base: "USD"}, logging.GetLogger())
+	_, err := service.FetchAndCacheAllRates()
+	require.NoError(t, err)
+
+	// Прямая пара в кэше есть — exchanger не запрашивается
+	path, err := service.GetRatePath("USD", "EUR")
+	require.NoError(t, err)
+	assert.Equal(t, "0.9", path.Rate.String())
+
+	// В кэше только обратная пара: курс exchanger важнее выведенного
+	path, err = service.GetRatePath("EUR", "USD")
+	require.NoError(t, err)
+	assert.True(t, path.Direct())
+	assert.Equal(t, "1.2", path.Rate.String())
 }
@@ -1,42 +1,54 @@
 package cache
 
 import (
+	"gw-currency-wallet/pkg/money"
 	"sync"
 	"time"
 )
 
 type RateCache struct {
 	mu         sync.RWMutex
-	rates      map[string]float32 // ключ: "USD_RUB"
+	rates      map[string]money.Decimal // ключ: "USD_RUB"
 	lastUpdate time.Time
 	ttl        time.Duration
 }
 
 func NewRateCache(ttl time.Duration) *RateCache {
 	return &RateCache{
-		rates: make(map[string]float32),
+		rates: make(map[string]money.Decimal),
 		ttl:   ttl,
 	}
 }
 
 // GetRate возвращает курс, если он есть и не устарел
-func (c *RateCache) GetRate(from, to string) (float32, bool) {
+func (c *RateCache) GetRate(from, to string) (money.Decimal, bool) {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 
 	if time.Since(c.lastUpdate) > c.ttl {
-		return 0, false // устарело
+		return money.Decimal{}, false // устарело
 	}
 
 	key := from + "_" + to
 	if rate, ok := c.rates[key]; ok {
 		return rate, true
 	}
-	return 0, false
+	return money.Decimal{}, false
+}
+
+// Resolve ищет курс from→to среди неустаревших курсов: прямой, обратный или цепочкой по routing
+func (c *RateCache) Resolve(from, to string, routing Routing) (RatePath, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if time.Since(c.lastUpdate) > c.ttl {
+		return RatePath{}, false
+	}
+	return FindPath(c.rates, from, to, routing)
 }
 
 // SetAllRates сохраняет все курсы из ответа exchanger а
-func (c *RateCache) SetAllRates(rates map[string]float32) {
+func (c *RateCache) SetAllRates(rates map[string]money.Decimal) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 	c.rates = rates
@@ -1,6 +1,7 @@
 package cache
 
 import (
+	"gw-currency-wallet/pkg/money"
 	"testing"
 	"time"
 
@@ -9,16 +10,16 @@ import (
 
 func TestRateCache_SetAndGet(t *testing.T) {
 	cache := NewRateCache(1 * time.Second)
-	cache.SetAllRates(map[string]float32{"USD_RUB": 90.5})
+	cache.SetAllRates(map[string]money.Decimal{"USD_RUB": money.MustParse("90.5")})
 
 	rate, ok := cache.GetRate("USD", "RUB")
 	assert.True(t, ok)
-	assert.Equal(t, float32(90.5), rate)
+	assert.Equal(t, "90.5", rate.String())
 }
 
 func TestRateCache_Expired(t *testing.T) {
 	cache := NewRateCache(100 * time.Millisecond)
-	cache.SetAllRates(map[string]float32{"USD_RUB": 90.5})
+	cache.SetAllRates(map[string]money.Decimal{"USD_RUB": money.MustParse("90.5")})
 
 	time.Sleep(150 * time.Millisecond)
 
@@ -0,0 +1,176 @@
+package cache
+
+import (
+	"gw-currency-wallet/pkg/money"
+	"sort"
+	"strings"
+)
+
+// Способы поиска курса пары, которой нет у exchanger
+const (
+	RoutingBase     = "base"     // через базовую валюту: from→Base→to
+	RoutingCheapest = "cheapest" // лучший курс по цепочке до MaxLegs шагов
+)
+
+// Routing — настройки поиска курса. Прямой и обратный курс пары используются при любом Mode;
+// пустой Mode — только они
+type Routing struct {
+	Mode    string
+	Base    string
+	MaxLegs int
+}
+
+// Leg — шаг пересчёта From→To по курсу Rate. Inverse — курс получен как 1/(курс To→From)
+type Leg struct {
+	From    string        `json:"from"`
+	To      string        `json:"to"`
+	Rate    money.Decimal `json:"rate" swaggertype:"number"`
+	Inverse bool          `json:"inverse,omitempty"`
+}
+
+// RatePath — итоговый курс и шаги, из которых он получен. Курс шага и произведение курсов
+// округляются к нулю до money.RateScale знаков, поэтому клиент не получает больше курса exchanger
+type RatePath struct {
+	Rate money.Decimal `json:"rate" swaggertype:"number"`
+	Legs []Leg         `json:"legs"`
+}
+
+// Direct — курс получен одной парой exchanger без пересчёта
+func (p RatePath) Direct() bool {
+	return len(p.Legs) == 1 && !p.Legs[0].Inverse
+}
+
+// Currencies — валюты пути по порядку: ["EUR", "USD", "RUB"]
+func (p RatePath) Currencies() []string {
+	if len(p.Legs) == 0 {
+		return nil
+	}
+	codes := []string{p.Legs[0].From}
+	for _, leg := range p.Legs {
+		codes = append(codes, leg.To)
+	}
+	return codes
+}
+
+func (p RatePath) String() string {
+	return strings.Join(p.Currencies(), "→")
+}
+
+// DirectPath — путь из одной пары exchanger
+func DirectPath(from, to string, rate money.Decimal) RatePath {
+	return RatePath{Rate: rate, Legs: []Leg{{From: from, To: to, Rate: rate}}}
+}
+
+// FindPath ищет курс from→to в rates (ключи вида "USD_RUB"): прямой, обратный, затем по routing
+func FindPath(rates map[string]money.Decimal, from, to string, routing Routing) (RatePath, bool) {
+	if leg, ok := findLeg(rates, from, to); ok {
+		return RatePath{Rate: leg.Rate, Legs: []Leg{leg}}, true
+	}
+
+	switch routing.Mode {
+	case RoutingBase:
+		if routing.Base == from || routing.Base == to {
+			return RatePath{}, false
+		}
+		first, ok := findLeg(rates, from, routing.Base)
+		if !ok {
+			return RatePath{}, false
+		}
+		second, ok := findLeg(rates, routing.Base, to)
+		if !ok {
+			return RatePath{}, false
+		}
+		return newPath([]Leg{first, second})
+	case RoutingCheapest:
+		return cheapestPath(rates, from, to, max(routing.MaxLegs, 2))
+	}
+	return RatePath{}, false
+}
+
+// findLeg — прямой курс пары или обратный к курсу противоположной пары
+func findLeg(rates map[string]money.Decimal, from, to string) (Leg, bool) {
+	if rate, ok := rates[from+"_"+to]; ok && rate.Sign() > 0 {
+		return Leg{From: from, To: to, Rate: rate}, true
+	}
+	if rate, ok := rates[to+"_"+from]; ok && rate.Sign() > 0 {
+		inverse, err := money.New(1, 0).Div(rate, money.RateScale, money.RoundDown)
+		if err != nil || inverse.IsZero() {
+			return Leg{}, false
+		}
+		return Leg{From: from, To: to, Rate: inverse, Inverse: true}, true
+	}
+	return Leg{}, false
+}
+
+func newPath(legs []Leg) (RatePath, bool) {
+	rate := money.New(1, 0)
+	for _, leg := range legs {
+		var err error
+		if rate, err = rate.Mul(leg.Rate, money.RateScale, money.RoundDown); err != nil {
+			return RatePath{}, false
+		}
+	}
+	if rate.IsZero() {
+		return RatePath{}, false
+	}
+	return RatePath{Rate: rate, Legs: legs}, true
+}
+
+// cheapestPath перебирает цепочки без повторов валют длиной до maxLegs и выбирает лучший курс;
+// при равном курсе — более короткую цепочку. Пар у exchanger немного, поэтому перебор дёшев
+func cheapestPath(rates map[string]money.Decimal, from, to string, maxLegs int) (RatePath, bool) {
+	linked := make(map[string]map[string]bool)
+	link := func(a, b string) {
+		if linked[a] == nil {
+			linked[a] = make(map[string]bool)
+		}
+		linked[a][b] = true
+	}
+	for pair := range rates {
+		a, b, ok := strings.Cut(pair, "_")
+		if !ok || a == b {
+			continue
+		}
+		link(a, b)
+		link(b, a)
+	}
+	// Соседи по алфавиту — при равных курсах и длине выбор не зависит от порядка обхода map
+	neighbours := make(map[string][]string, len(linked))
+	for code, set := range linked {
+		for next := range set {
+			neighbours[code] = append(neighbours[code], next)
+		}
+		sort.Strings(neighbours[code])
+	}
+
+	var best RatePath
+	found := false
+	visited := map[string]bool{from: true}
+	var walk func(current string, legs []Leg)
+	walk = func(current string, legs []Leg) {
+		if current == to {
+			path, ok := newPath(append([]Leg(nil), legs...))
+			if ok && (!found || path.Rate.Cmp(best.Rate) > 0 || path.Rate.Equal(best.Rate) && len(path.Legs) < len(best.Legs)) {
+				best, found = path, true
+			}
+			return
+		}
+		if len(legs) == maxLegs {
+			return
+		}
+		for _, next := range neighbours[current] {
+			if visited[next] {
+				continue
+			}
+			leg, ok := findLeg(rates, current, next)
+			if !ok {
+				continue
+			}
+			visited[next] = true
+			walk(next, append(legs, leg))
+			visited[next] = false
+		}
+	}
+	walk(from, nil)
+	return best, found
+}
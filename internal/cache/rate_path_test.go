@@ -0,0 +1,46 @@
+package cache
+
+import (
+	"gw-currency-wallet/pkg/money"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFindPath(t *testing.T) {
+	rates := map[string]money.Decimal{
+		"USD_RUB": money.MustParse("90"),
+		"USD_EUR": money.MustParse("0.9"),
+		"GBP_EUR": money.MustParse("1.2"),
+		"GBP_RUB": money.MustParse("125"),
+	}
+
+	// Прямой курс и обратный к нему
+	path, ok := FindPath(rates, "USD", "RUB", Routing{})
+	require.True(t, ok)
+	assert.True(t, path.Direct())
+	path, ok = FindPath(rates, "EUR", "USD", Routing{})
+	require.True(t, ok)
+	assert.Equal(t, "1.11111111", path.Rate.String())
+	assert.True(t, path.Legs[0].Inverse)
+
+	_, ok = FindPath(rates, "EUR", "RUB", Routing{})
+	assert.False(t, ok)
+
+	// Через базовую валюту: EUR→USD (обратный курс) и USD→RUB
+	path, ok = FindPath(rates, "EUR", "RUB", Routing{Mode: RoutingBase, Base: "USD"})
+	require.True(t, ok)
+	assert.Equal(t, []string{"EUR", "USD", "RUB"}, path.Currencies())
+	assert.Equal(t, "99.99999990", path.Rate.String())
+
+	// Лучший курс: EUR→GBP→RUB даёт больше, чем через USD
+	path, ok = FindPath(rates, "EUR", "RUB", Routing{Mode: RoutingCheapest, MaxLegs: 3})
+	require.True(t, ok)
+	assert.Equal(t, "EUR→GBP→RUB", path.String())
+	assert.Equal(t, "104.16666625", path.Rate.String())
+	assert.Len(t, path.Legs, 2)
+
+	_, ok = FindPath(rates, "EUR", "JPY", Routing{Mode: RoutingCheapest, MaxLegs: 3})
+	assert.False(t, ok)
+}
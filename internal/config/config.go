@@ -3,6 +3,7 @@ package config
 import (
 	"gw-currency-wallet/pkg/logging"
 	"sync"
+	"time"
 
 	"github.com/ilyakaznacheev/cleanenv"
 )
@@ -15,14 +16,55 @@ type Config struct {
 	KafkaBroker   string        `yaml:"kafka_broker" env-default:"localhost:9092"`
 	KafkaTopic    string        `yaml:"kafka_topic" env-default:"notification"`
 	Storage       StorageConfig `yaml:"storage"`
+	// LimitsBaseCurrency — валюта лимитов, заданных без валюты: суммы операций пересчитываются в неё
+	LimitsBaseCurrency string `yaml:"limits_base_currency" env-default:"USD"`
+	// QuoteTTL — сколько действует котировка обмена из POST /exchange/quote
+	QuoteTTL time.Duration `yaml:"quote_ttl" env-default:"30s"`
+	// HoldsExpireInterval — как часто закрывать просроченные холды
+	HoldsExpireInterval time.Duration `yaml:"holds_expire_interval" env-default:"1m"`
+	// OrdersMatchInterval — как часто запрашивать курсы для исполнения лимитных заявок
+	OrdersMatchInterval time.Duration `yaml:"orders_match_interval" env-default:"30s"`
+	// SchedulerInterval — как часто выполнять наступившие операции по расписанию
+	SchedulerInterval time.Duration `yaml:"scheduler_interval" env-default:"30s"`
+	// SnapshotInterval — как часто проверять, снят ли остаток на конец прошедшего дня
+	SnapshotInterval time.Duration `yaml:"snapshot_interval" env-default:"1h"`
+	// OutboxInterval — как часто доставлять уведомления из outbox в Kafka
+	OutboxInterval time.Duration `yaml:"outbox_interval" env-default:"1s"`
+	// AuditSealInterval — как часто включать новые записи журнала аудита в цепочку хешей
+	AuditSealInterval time.Duration `yaml:"audit_seal_interval" env-default:"5s"`
+	// Rates — поиск курса пары, которой нет у exchanger
+	Rates RatesConfig `yaml:"rates"`
+	// Reconcile — фоновая сверка балансов с журналом
+	Reconcile ReconcileConfig `yaml:"reconcile"`
+}
+
+type RatesConfig struct {
+	// Routing — base (через BaseCurrency), cheapest (лучший курс по цепочке до MaxLegs пар)
+	// или пусто (только прямой и обратный курс)
+	Routing      string `yaml:"routing" env-default:"base"`
+	BaseCurrency string `yaml:"base_currency" env-default:"USD"`
+	MaxLegs      int    `yaml:"max_legs" env-default:"3"`
+}
+
+type ReconcileConfig struct {
+	// Interval — как часто сверять балансы; 0 — фоновая сверка выключена
+	Interval time.Duration `yaml:"interval" env-default:"24h"`
+	// ReportDir — каталог для отчётов reconcile-<время>.json
+	ReportDir string `yaml:"report_dir" env-default:"reports"`
+	// Freeze — замораживать счета с расхождениями
+	Freeze bool `yaml:"freeze" env-default:"false"`
 }
 
 type StorageConfig struct {
+	// Driver — postgres или memory (данные в памяти процесса, для разработки и тестов)
+	Driver   string `yaml:"driver" env-default:"postgres"`
 	Host     string `yaml:"host" env-default:"localhost"`
 	Port     string `yaml:"port" env-default:"5432"`
 	User     string `yaml:"username" env-default:"wallet_user"`
 	Password string `yaml:"password" env-default:"123"`
 	Name     string `yaml:"database" env-default:"wallet_db"`
+	// CheckSchema — при старте убедиться, что все миграции применены
+	CheckSchema bool `yaml:"check_schema" env-default:"false"`
 }
 
 var instance *Config
@@ -0,0 +1,95 @@
+package currencies
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gw-currency-wallet/internal/storages"
+	"sync"
+	"time"
+)
+
+var ErrUnsupportedCurrency = errors.New("unsupported currency")
+
+// Catalog — справочник валют из хранилища с кэшированием на ttl.
+// Новая валюта или её отключение вступают в силу не позже чем через ttl без перезапуска
+type Catalog struct {
+	storage storages.Repository
+	ttl     time.Duration
+
+	mu         sync.RWMutex
+	currencies map[string]storages.Currency
+	lastUpdate time.Time
+}
+
+func NewCatalog(storage storages.Repository, ttl time.Duration) *Catalog {
+	return &Catalog{
+		storage: storage,
+		ttl:     ttl,
+	}
+}
+
+// Lookup возвращает включённую валюту по коду ISO 4217
+func (c *Catalog) Lookup(ctx context.Context, code string) (storages.Currency, error) {
+	currencies, err := c.load(ctx)
+	if err != nil {
+		return storages.Currency{}, err
+	}
+
+	currency, ok := currencies[code]
+	if !ok || !currency.Enabled {
+		return storages.Currency{}, fmt.Errorf("%w: %s", ErrUnsupportedCurrency, code)
+	}
+	return currency, nil
+}
+
+// IsEnabled — удобная проверка для фильтрации; ошибка хранилища считается отказом
+func (c *Catalog) IsEnabled(ctx context.Context, code string) bool {
+	_, err := c.Lookup(ctx, code)
+	return err == nil
+}
+
+// Enabled возвращает включённые валюты в порядке кодов
+func (c *Catalog) Enabled(ctx context.Context) ([]storages.Currency, error) {
+	all, err := c.storage.ListCurrencies(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	enabled := make([]storages.Currency, 0, len(all))
+	for _, currency := range all {
+		if currency.Enabled {
+			enabled = append(enabled, currency)
+		}
+	}
+	c.store(all)
+	return enabled, nil
+}
+
+func (c *Catalog) load(ctx context.Context) (map[string]storages.Currency, error) {
+	c.mu.RLock()
+	if c.currencies != nil && time.Since(c.lastUpdate) <= c.ttl {
+		defer c.mu.RUnlock()
+		return c.currencies, nil
+	}
+	c.mu.RUnlock()
+
+	all, err := c.storage.ListCurrencies(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load currencies: %w", err)
+	}
+	return c.store(all), nil
+}
+
+func (c *Catalog) store(all []storages.Currency) map[string]storages.Currency {
+	currencies := make(map[string]storages.Currency, len(all))
+	for _, currency := range all {
+		currencies[currency.Code] = currency
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.currencies = currencies
+	c.lastUpdate = time.Now()
+	return currencies
+}
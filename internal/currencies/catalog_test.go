@@ -0,0 +1,44 @@
+package currencies
+
+import (
+	"context"
+	"gw-currency-wallet/internal/storages"
+	"gw-currency-wallet/internal/storages/memory"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCatalog_Lookup(t *testing.T) {
+	storage := memory.NewMemoryRepository()
+	catalog := NewCatalog(storage, time.Minute)
+
+	usd, err := catalog.Lookup(context.Background(), "USD")
+	assert.NoError(t, err)
+	assert.Equal(t, int32(2), usd.MinorUnits)
+
+	_, err = catalog.Lookup(context.Background(), "usd")
+	assert.ErrorIs(t, err, ErrUnsupportedCurrency)
+
+	require.NoError(t, storage.SetCurrency(storages.Currency{Code: "CNY", Name: "Yuan Renminbi", MinorUnits: 2}))
+	_, err = catalog.Lookup(context.Background(), "CNY")
+	assert.ErrorIs(t, err, ErrUnsupportedCurrency)
+}
+
+func TestCatalog_Expired(t *testing.T) {
+	storage := memory.NewMemoryRepository()
+	catalog := NewCatalog(storage, 100*time.Millisecond)
+	assert.False(t, catalog.IsEnabled(context.Background(), "GBP"))
+
+	require.NoError(t, storage.SetCurrency(storages.Currency{Code: "GBP", Name: "Pound Sterling", MinorUnits: 2, Enabled: true}))
+	assert.False(t, catalog.IsEnabled(context.Background(), "GBP"))
+
+	time.Sleep(150 * time.Millisecond)
+	assert.True(t, catalog.IsEnabled(context.Background(), "GBP"))
+
+	enabled, err := catalog.Enabled(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, enabled, 4)
+}
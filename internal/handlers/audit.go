@@ -0,0 +1,135 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"gw-currency-wallet/internal/audit"
+	"gw-currency-wallet/internal/storages"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// requestIDHeader — заголовок с id запроса; без него id генерируется и возвращается в ответе
+const requestIDHeader = "X-Request-ID"
+
+// RequestMeta передаёт IP, User-Agent и id запроса в context для журнала аудита
+func RequestMeta() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestID := c.GetHeader(requestIDHeader)
+		if requestID == "" || len(requestID) > 128 {
+			requestID = newRequestID()
+		}
+		c.Header(requestIDHeader, requestID)
+
+		meta := storages.AuditMetaFrom(c.Request.Context())
+		meta.IP = c.ClientIP()
+		meta.UserAgent = c.Request.UserAgent()
+		meta.RequestID = requestID
+		c.Request = c.Request.WithContext(storages.WithAuditMeta(c.Request.Context(), meta))
+		c.Next()
+	}
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+type AuditQuery struct {
+	UserID int64     `form:"user_id" binding:"omitempty,min=1"`
+	Action string    `form:"action"`
+	From   time.Time `form:"from" time_format:"2006-01-02T15:04:05Z07:00"`
+	To     time.Time `form:"to" time_format:"2006-01-02T15:04:05Z07:00"`
+	Cursor string    `form:"cursor"`
+	Limit  int       `form:"limit" binding:"omitempty,min=1,max=100"`
+}
+
+type AuditResponse struct {
+	Entries    []storages.AuditEntry `json:"entries"`
+	NextCursor string                `json:"next_cursor,omitempty"`
+}
+
+// @Summary Search the audit log
+// @Description Entries are returned newest first. user_id matches both the actor and the affected user
+// @Tags admin
+// @Security ApiKeyAuth
+// @Produce json
+// @Param user_id query int false "Actor or affected user ID"
+// @Param action query string false "Action, e.g. user.login_failed or balance.withdraw"
+// @Param from query string false "Start of period, RFC 3339 (inclusive)"
+// @Param to query string false "End of period, RFC 3339 (exclusive)"
+// @Param cursor query string false "Cursor from the previous page"
+// @Param limit query int false "Page size (1-100, default 20)"
+// @Success 200 {object} AuditResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Router /admin/audit [get]
+func ListAudit(storage storages.Repository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var query AuditQuery
+		if err := c.ShouldBindQuery(&query); err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		beforeID, err := decodeCursor(query.Cursor)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid cursor"})
+			return
+		}
+
+		limit := query.Limit
+		if limit == 0 {
+			limit = defaultPageSize
+		}
+
+		// Запрашиваем на одну запись больше, чтобы узнать, есть ли следующая страница
+		entries, err := storage.ListAudit(c.Request.Context(), storages.AuditFilter{
+			UserID:   query.UserID,
+			Action:   storages.AuditAction(query.Action),
+			From:     query.From,
+			To:       query.To,
+			BeforeID: beforeID,
+			Limit:    limit + 1,
+		})
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "failed to get audit log"})
+			return
+		}
+
+		resp := AuditResponse{Entries: entries}
+		if len(entries) > limit {
+			resp.Entries = entries[:limit]
+			resp.NextCursor = encodeCursor(entries[limit-1].ID)
+		}
+		if resp.Entries == nil {
+			resp.Entries = []storages.AuditEntry{}
+		}
+
+		c.JSON(http.StatusOK, resp)
+	}
+}
+
+// @Summary Verify the audit log hash chain
+// @Description Recomputes the hash of every sealed entry. Entries are sealed in the background shortly after they are written
+// @Tags admin
+// @Security ApiKeyAuth
+// @Produce json
+// @Success 200 {object} audit.Verification
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Router /admin/audit/verify [get]
+func VerifyAudit(storage storages.Repository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		result, err := audit.Verify(c.Request.Context(), storage)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "failed to verify audit log"})
+			return
+		}
+		c.JSON(http.StatusOK, result)
+	}
+}
@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"encoding/json"
+	"gw-currency-wallet/internal/audit"
+	"gw-currency-wallet/internal/storages"
+	"gw-currency-wallet/internal/storages/memory"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// serveAs выполняет запрос с токеном и заголовками клиента
+func serveAs(router *gin.Engine, token, method, path, body string, headers map[string]string) *httptest.ResponseRecorder {
+	all := map[string]string{"Content-Type": "application/json"}
+	if token != "" {
+		all["Authorization"] = "Bearer " + token
+	}
+	for k, v := range headers {
+		all[k] = v
+	}
+	return serveWith(router, method, path, body, all)
+}
+
+func TestAuditHandler_RecordsAndQueries(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	storage := memory.NewMemoryRepository()
+	authService, wallets := newTestServices(storage)
+	router := gin.New()
+	SetupRoutes(router, storage, authService, wallets)
+
+	client := map[string]string{"User-Agent": "audit-test", "X-Request-ID": "req-42"}
+	w := serveAs(router, "", "POST", "/api/v1/register", `{"email": "user@example.com", "password": "secret1"}`, client)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	assert.Equal(t, "req-42", w.Header().Get("X-Request-ID"))
+	w = serveAs(router, "", "POST", "/api/v1/login", `{"email": "user@example.com", "password": "wrong"}`, client)
+	require.Equal(t, http.StatusUnauthorized, w.Code)
+	w = serveAs(router, "", "POST", "/api/v1/login", `{"email": "user@example.com", "password": "secret1"}`, client)
+	require.Equal(t, http.StatusOK, w.Code)
+	var login LoginResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &login))
+
+	w = serveAs(router, login.Token, "POST", "/api/v1/wallet/deposit", `{"currency": "USD", "amount": 25}`, client)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	// Журнал доступен только администратору
+	w = serveAs(router, login.Token, "GET", "/api/v1/admin/audit", "", nil)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	user, err := storage.GetUserByEmail(t.Context(), "user@example.com")
+	require.NoError(t, err)
+	require.NoError(t, storage.SetUserAdmin(user.ID, true))
+
+	w = serveAs(router, login.Token, "GET", "/api/v1/admin/audit?user_id="+strconv.FormatInt(user.ID, 10), "", nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var resp AuditResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	require.Len(t, resp.Entries, 4)
+	actions := []storages.AuditAction{}
+	for _, e := range resp.Entries {
+		actions = append(actions, e.Action)
+	}
+	assert.Equal(t, []storages.AuditAction{"balance.deposit", storages.AuditLogin, storages.AuditLoginFailed, storages.AuditRegister}, actions)
+
+	deposit := resp.Entries[0]
+	assert.Equal(t, user.ID, deposit.ActorID)
+	assert.Equal(t, "audit-test", deposit.UserAgent)
+	assert.Equal(t, "req-42", deposit.RequestID)
+	assert.NotEmpty(t, deposit.IP)
+	assert.JSONEq(t, `[{"wallet_id": 1, "currency": "USD", "amount": 0.00}]`, string(deposit.Before))
+	assert.JSONEq(t, `[{"wallet_id": 1, "currency": "USD", "amount": 25.00}]`, string(deposit.After))
+	assert.Zero(t, resp.Entries[2].ActorID, "failed login has no actor")
+
+	w = serveAs(router, login.Token, "GET", "/api/v1/admin/audit?action=user.login_failed&limit=1", "", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	require.Len(t, resp.Entries, 1)
+	assert.Empty(t, resp.NextCursor)
+
+	// После запечатывания цепочка сходится
+	_, err = audit.Seal(t.Context(), storage)
+	require.NoError(t, err)
+	w = serveAs(router, login.Token, "GET", "/api/v1/admin/audit/verify", "", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	var verification audit.Verification
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &verification))
+	assert.True(t, verification.Valid)
+	assert.Equal(t, 4, verification.Checked)
+	assert.Len(t, verification.Head, 64)
+}
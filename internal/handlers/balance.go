@@ -2,8 +2,11 @@ package handlers
 
 import (
 	"gw-currency-wallet/internal/auth"
+	"gw-currency-wallet/internal/currencies"
 	"gw-currency-wallet/internal/storages"
+	"gw-currency-wallet/pkg/money"
 	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
@@ -11,12 +14,17 @@ import (
 // @Summary Get user balance for currency
 // @Tags wallet
 // @Security ApiKeyAuth
-// @Param currency path string true "Currency code (USD, RUB, EUR)"
+// @Description The ETag header identifies the wallet, currency and balance version; pass it in If-Match to withdraw or exchange only from an unchanged balance
+// @Param wallet_id path int false "Wallet ID, only in /wallets/{wallet_id}/balance/{currency}; default wallet otherwise"
+// @Param currency path string true "Currency code from /currencies"
 // @Success 200 {object} map[string]interface{}
+// @Header 200 {string} ETag "Wallet, currency and balance version"
 // @Failure 400 {object} map[string]string
 // @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
 // @Router /balance/{currency} [get]
-func GetBalance(storage storages.Repository) gin.HandlerFunc {
+// @Router /wallets/{wallet_id}/balance/{currency} [get]
+func GetBalance(storage storages.Repository, catalog *currencies.Catalog) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		userID, ok := auth.GetUserID(c)
 		if !ok {
@@ -24,28 +32,81 @@ func GetBalance(storage storages.Repository) gin.HandlerFunc {
 			return
 		}
 
+		walletID, ok := walletParam(c)
+		if !ok {
+			return
+		}
 		currency := c.Param("currency")
 		if currency == "" {
 			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "currency is required"})
 			return
 		}
 
-		balance, err := storage.GetBalance(c.Request.Context(), userID, currency)
+		info, err := catalog.Lookup(c.Request.Context(), currency)
 		if err != nil {
-			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "balance not found for this currency"})
+			amountError(c, err)
 			return
 		}
 
+		balances, err := storage.GetWalletBalances(c.Request.Context(), userID, walletID)
+		if err != nil {
+			if !walletNotFound(c, err) {
+				c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "failed to get balance"})
+			}
+			return
+		}
+
+		// Валюта добавлена после открытия кошелька: счёт откроется при первой операции
+		balance := storages.Balance{
+			UserID:   userID,
+			Currency: currency,
+			Amount:   money.New(0, info.MinorUnits),
+			Held:     money.New(0, info.MinorUnits),
+			Version:  1,
+		}
+		for _, b := range balances {
+			if b.Currency == currency {
+				balance = b
+			}
+		}
+		if balance.WalletID == 0 {
+			w, err := storage.GetWallet(c.Request.Context(), userID, walletID)
+			if err != nil {
+				if !walletNotFound(c, err) {
+					c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "failed to get balance"})
+				}
+				return
+			}
+			balance.WalletID = w.ID
+		}
+
+		available, err := balance.Available()
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "failed to get balance"})
+			return
+		}
+
+		// balance оставлен для совместимости и совпадает с total
+		c.Header("ETag", balanceETag(balance))
 		c.JSON(http.StatusOK, gin.H{
-			"currency": currency,
-			"balance":  balance,
+			"currency":  currency,
+			"balance":   balance.Amount,
+			"total":     balance.Amount,
+			"available": available,
+			"held":      balance.Held,
 		})
 	}
 }
 
+type BalanceQuery struct {
+	AsOf time.Time `form:"as_of" time_format:"2006-01-02T15:04:05Z07:00"`
+}
+
 // @Summary Get user total balance for all currencies
+// @Description Balances of the default wallet. With as_of returns its ledger balances at that moment: available and held are not kept historically.
 // @Tags wallet
 // @Security ApiKeyAuth
+// @Param as_of query string false "Point in time, RFC 3339"
 // @Success 200 {object} map[string]interface{}
 // @Failure 400 {object} map[string]string
 // @Failure 401 {object} map[string]string
@@ -58,14 +119,66 @@ func GetTotalBalance(storage storages.Repository) gin.HandlerFunc {
 			return
 		}
 
-		balances, err := storage.GetAllBalances(c.Request.Context(), userID)
+		var query BalanceQuery
+		if err := c.ShouldBindQuery(&query); err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if !query.AsOf.IsZero() && query.AsOf.After(time.Now()) {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "as_of must not be in the future"})
+			return
+		}
+
+		balances, err := storage.GetBalances(c.Request.Context(), userID)
 		if err != nil {
 			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "failed to get balances"})
 			return
 		}
 
+		if !query.AsOf.IsZero() {
+			balanceAsOf(c, storage, userID, 0, query.AsOf, balances)
+			return
+		}
+
+		total := make(map[string]money.Decimal, len(balances))
+		available := make(map[string]money.Decimal, len(balances))
+		for _, b := range balances {
+			total[b.Currency] = b.Amount
+			if available[b.Currency], err = b.Available(); err != nil {
+				c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "failed to get balances"})
+				return
+			}
+		}
+
+		// balance оставлен для совместимости и совпадает с total
 		c.JSON(http.StatusOK, gin.H{
-			"balance": balances,
+			"balance":   total,
+			"total":     total,
+			"available": available,
 		})
 	}
 }
+
+// balanceAsOf отвечает учётными остатками кошелька на момент asOf. Валюты текущих счетов без движений
+// к тому моменту показываются с нулём, чтобы набор валют совпадал с обычным ответом
+func balanceAsOf(c *gin.Context, storage storages.Repository, userID, walletID int64, asOf time.Time, current []storages.Balance) {
+	historical, err := storage.GetBalancesAsOf(c.Request.Context(), userID, walletID, asOf)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "failed to get balances"})
+		return
+	}
+
+	total := make(map[string]money.Decimal, len(current))
+	for _, b := range current {
+		total[b.Currency] = money.New(0, b.Amount.Scale())
+	}
+	for currency, amount := range historical {
+		total[currency] = amount
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"as_of":   asOf,
+		"balance": total,
+		"total":   total,
+	})
+}
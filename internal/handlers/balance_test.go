@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"gw-currency-wallet/internal/storages"
+	"gw-currency-wallet/pkg/money"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetTotalBalance_AsOf(t *testing.T) {
+	storage, userID := newTestStorage(t, nil)
+	txn, err := storage.Credit(context.Background(), userID, "USD", money.New(100, 0))
+	require.NoError(t, err)
+	_, err = storage.Credit(context.Background(), userID, "USD", money.New(50, 0))
+	require.NoError(t, err)
+
+	router := newTestRouter(t, storage, userID)
+
+	get := func(asOf time.Time) (int, map[string]json.Number) {
+		query := url.Values{"as_of": {asOf.UTC().Format(time.RFC3339Nano)}}
+		w := serve(router, "GET", "/balance?"+query.Encode(), "")
+		var resp struct {
+			Total map[string]json.Number `json:"total"`
+		}
+		decoder := json.NewDecoder(w.Body)
+		decoder.UseNumber()
+		_ = decoder.Decode(&resp)
+		return w.Code, resp.Total
+	}
+
+	// До первой операции все счета нулевые, после неё учтена только она
+	code, total := get(txn.CreatedAt.Add(-time.Nanosecond))
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, json.Number("0.00"), total["USD"])
+	assert.Equal(t, json.Number("0.00"), total["EUR"])
+
+	code, total = get(txn.CreatedAt.Add(time.Nanosecond))
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, json.Number("100.00"), total["USD"])
+
+	code, _ = get(time.Now().Add(time.Hour))
+	assert.Equal(t, http.StatusBadRequest, code)
+}
+
+func TestGetTotalBalance_AsOfSubWallet(t *testing.T) {
+	storage, userID := newTestStorage(t, map[string]money.Decimal{"USD": money.New(100, 0)})
+	savings, err := storage.CreateWallet(context.Background(), userID, "savings")
+	require.NoError(t, err)
+	_, err = storage.PostTransaction(context.Background(), userID, storages.OperationMove,
+		storages.Posting{Currency: "USD", Amount: money.New(-40, 0)},
+		storages.Posting{WalletID: savings.ID, Currency: "USD", Amount: money.New(40, 0)})
+	require.NoError(t, err)
+
+	router := newTestRouter(t, storage, userID)
+	total := func(path string) map[string]string {
+		w := serve(router, "GET", path, "")
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var resp struct {
+			Total map[string]json.Number `json:"total"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		result := make(map[string]string, len(resp.Total))
+		for currency, amount := range resp.Total {
+			result[currency] = amount.String()
+		}
+		return result
+	}
+
+	// Остаток на текущий момент совпадает с балансом кошелька по умолчанию, без подкошелька
+	current := total("/balance")
+	assert.Equal(t, "60.00", current["USD"])
+	query := url.Values{"as_of": {time.Now().UTC().Format(time.RFC3339Nano)}}
+	assert.Equal(t, current, total("/balance?"+query.Encode()))
+}
@@ -0,0 +1,27 @@
+package handlers
+
+import (
+	"gw-currency-wallet/internal/currencies"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// @Summary Get supported currencies
+// @Tags wallet
+// @Security ApiKeyAuth
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Failure 401 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /currencies [get]
+func ListCurrencies(catalog *currencies.Catalog) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		enabled, err := catalog.Enabled(c.Request.Context())
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "failed to get currencies"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"currencies": enabled})
+	}
+}
@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"gw-currency-wallet/internal/storages"
+	"gw-currency-wallet/internal/wallet"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const codeVersionMismatch = "version_mismatch"
+
+// balanceETag — сильный ETag баланса: кошелёк, валюта и версия. ETag одного баланса
+// не проходит If-Match на другом, даже если версии совпали
+func balanceETag(balance storages.Balance) string {
+	return fmt.Sprintf(`"%d-%s-%d"`, balance.WalletID, balance.Currency, balance.Version)
+}
+
+// ifMatch разбирает заголовок If-Match с ETag из GET /balance/{currency}.
+// Нет заголовка или "*" — версия не проверяется. На неверный заголовок отвечает 400
+func ifMatch(c *gin.Context) (wallet.IfMatch, bool) {
+	header := strings.TrimSpace(c.GetHeader("If-Match"))
+	if header == "" || header == "*" {
+		return wallet.IfMatch{}, true
+	}
+
+	// Слабые ETag не годятся для условного изменения (RFC 9110, 13.1.1)
+	unquoted, ok := strings.CutPrefix(header, `"`)
+	if ok {
+		unquoted, ok = strings.CutSuffix(unquoted, `"`)
+	}
+	parts := strings.Split(unquoted, "-")
+	if !ok || len(parts) != 3 || parts[1] == "" {
+		return wallet.IfMatch{}, badIfMatch(c)
+	}
+	walletID, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil || walletID <= 0 {
+		return wallet.IfMatch{}, badIfMatch(c)
+	}
+	version, err := strconv.ParseInt(parts[2], 10, 64)
+	if err != nil || version <= 0 {
+		return wallet.IfMatch{}, badIfMatch(c)
+	}
+	return wallet.IfMatch{WalletID: walletID, Currency: parts[1], Version: version}, true
+}
+
+func badIfMatch(c *gin.Context) bool {
+	c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "If-Match must be a balance ETag or *"})
+	return false
+}
+
+// versionMismatch отвечает 412, если баланс изменился после чтения ETag
+func versionMismatch(c *gin.Context, err error) bool {
+	if !errors.Is(err, storages.ErrVersionMismatch) {
+		return false
+	}
+	c.AbortWithStatusJSON(http.StatusPreconditionFailed, gin.H{"error": "balance has changed", "code": codeVersionMismatch})
+	return true
+}
@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"encoding/json"
+	"gw-currency-wallet/internal/storages"
+	"gw-currency-wallet/pkg/money"
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBalanceETag_IfMatch(t *testing.T) {
+	storage, userID := newTestStorage(t, map[string]money.Decimal{"USD": money.New(100, 0)})
+	router := newTestRouter(t, storage, userID)
+
+	w := serve(router, "GET", "/balance/USD", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	etag := w.Header().Get("ETag")
+	require.NotEmpty(t, etag)
+
+	w = serveWith(router, "POST", "/wallet/withdraw", `{"currency": "USD", "amount": 10}`, map[string]string{"If-Match": etag})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	// Баланс изменился после чтения ETag: ни вывод, ни обмен не проходят
+	w = serveWith(router, "POST", "/wallet/withdraw", `{"currency": "USD", "amount": 10}`, map[string]string{"If-Match": etag})
+	require.Equal(t, http.StatusPreconditionFailed, w.Code, w.Body.String())
+	assert.Contains(t, w.Body.String(), `"code":"version_mismatch"`)
+	w = serveWith(router, "POST", "/exchange", `{"from_currency": "USD", "to_currency": "RUB", "amount": 10}`, map[string]string{"If-Match": etag})
+	require.Equal(t, http.StatusPreconditionFailed, w.Code, w.Body.String())
+
+	w = serve(router, "GET", "/balance/USD", "")
+	assert.NotEqual(t, etag, w.Header().Get("ETag"))
+	w = serveWith(router, "POST", "/exchange", `{"from_currency": "USD", "to_currency": "RUB", "amount": 10}`, map[string]string{"If-Match": w.Header().Get("ETag")})
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	w = serveWith(router, "POST", "/wallet/withdraw", `{"currency": "USD", "amount": 10}`, map[string]string{"If-Match": "*"})
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	for _, header := range []string{`W/"1"`, `"1"`, `"1-USD"`, `"x-USD-1"`} {
+		w = serveWith(router, "POST", "/wallet/withdraw", `{"currency": "USD", "amount": 10}`, map[string]string{"If-Match": header})
+		assert.Equal(t, http.StatusBadRequest, w.Code, header)
+	}
+
+	balance, err := storage.GetBalance(t.Context(), userID, "USD")
+	require.NoError(t, err)
+	assert.Equal(t, "70.00", balance.String())
+}
+
+func TestBalanceETag_OtherResource(t *testing.T) {
+	storage, userID := newTestStorage(t, map[string]money.Decimal{"USD": money.New(100, 0), "EUR": money.New(100, 0)})
+	router := newTestRouter(t, storage, userID)
+	defaultWallet, err := storage.GetWallet(t.Context(), userID, 0)
+	require.NoError(t, err)
+
+	w := serve(router, "POST", "/wallets", `{"name": "Savings"}`)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var savings storages.Wallet
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &savings))
+	path := "/wallets/" + strconv.FormatInt(savings.ID, 10)
+	w = serve(router, "POST", path+"/deposit", `{"currency": "USD", "amount": 50}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	etag := func(url string) string {
+		w := serve(router, "GET", url, "")
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		return w.Header().Get("ETag")
+	}
+	usd, eur, savingsUSD := etag("/balance/USD"), etag("/balance/EUR"), etag(path+"/balance/USD")
+	assert.NotEqual(t, usd, eur)
+	assert.NotEqual(t, usd, savingsUSD)
+
+	// ETag другой валюты или другого кошелька не проходит If-Match, даже если версии совпадают
+	for _, tc := range []struct{ url, body, etag string }{
+		{"/wallet/withdraw", `{"currency": "USD", "amount": 10}`, eur},
+		{"/wallet/withdraw", `{"currency": "USD", "amount": 10}`, savingsUSD},
+		{path + "/withdraw", `{"currency": "USD", "amount": 10}`, usd},
+		{"/exchange", `{"from_currency": "EUR", "to_currency": "RUB", "amount": 10}`, usd},
+	} {
+		w = serveWith(router, "POST", tc.url, tc.body, map[string]string{"If-Match": tc.etag})
+		assert.Equal(t, http.StatusPreconditionFailed, w.Code, tc.url+" "+tc.etag)
+	}
+
+	// Кошелёк по умолчанию по явному id — тот же ресурс, что и /balance/USD
+	w = serveWith(router, "POST", "/wallets/"+strconv.FormatInt(defaultWallet.ID, 10)+"/withdraw", `{"currency": "USD", "amount": 10}`,
+		map[string]string{"If-Match": usd})
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	w = serveWith(router, "POST", path+"/withdraw", `{"currency": "USD", "amount": 10}`, map[string]string{"If-Match": savingsUSD})
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+}
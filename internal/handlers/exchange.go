@@ -1,102 +1,128 @@
 package handlers
 
 import (
-	"context"
+	"errors"
 	"gw-currency-wallet/internal/auth"
-	"gw-currency-wallet/internal/notifications"
 	"gw-currency-wallet/internal/storages"
+	"gw-currency-wallet/internal/wallet"
+	"gw-currency-wallet/pkg/money"
 	"net/http"
-	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
+// ExchangeRequest — обмен по текущему курсу или по котировке QuoteID; с котировкой валюты и сумма
+// берутся из неё и в запросе не указываются
 type ExchangeRequest struct {
-	FromCurrency string  `json:"from_currency" binding:"required"`
-	ToCurrency   string  `json:"to_currency" binding:"required"`
-	Amount       float32 `json:"amount" binding:"required,gt=0"`
+	FromCurrency string        `json:"from_currency" binding:"required_without=QuoteID,excluded_with=QuoteID"`
+	ToCurrency   string        `json:"to_currency" binding:"required_without=QuoteID,excluded_with=QuoteID"`
+	Amount       money.Decimal `json:"amount" binding:"required_without=QuoteID,excluded_with=QuoteID" swaggertype:"number"`
+	QuoteID      int64         `json:"quote_id" binding:"omitempty,min=1"`
 }
 
 // @Summary Exchange currencies
+// @Description With quote_id the exchange is executed at exactly the quoted rate and fee; an expired quote fails with code quote_expired, a used one with quote_used
 // @Tags exchange
 // @Security ApiKeyAuth
 // @Accept json
 // @Produce json
+// @Param wallet_id path int false "Wallet ID, only in /wallets/{wallet_id}/exchange; default wallet otherwise"
 // @Param request body ExchangeRequest true "Exchange request"
+// @Param Idempotency-Key header string false "Key for safe retries"
+// @Param If-Match header string false "ETag from GET /balance/{from_currency}"
 // @Success 200 {object} map[string]interface{}
+// @Success 202 {object} map[string]interface{} "Waiting for another member's approval"
 // @Failure 400 {object} map[string]string
 // @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]interface{}
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 412 {object} map[string]string
 // @Failure 500 {object} map[string]string
 // @Router /exchange [post]
-func Exchange(storage storages.Repository, authService *auth.Service, notificationService *notifications.NotificationService) gin.HandlerFunc {
+// @Router /wallets/{wallet_id}/exchange [post]
+func Exchange(wallets *wallet.Service) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		userID, ok := auth.GetUserID(c)
 		if !ok {
 			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "user not found"})
 			return
 		}
+		walletID, ok := walletParam(c)
+		if !ok {
+			return
+		}
 
 		var req ExchangeRequest
 		if err := c.ShouldBindJSON(&req); err != nil {
 			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
-
-		// 1. Проверяем баланс
-		balance, err := storage.GetBalance(c.Request.Context(), userID, req.FromCurrency)
-		if err != nil {
-			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "balance not found"})
+		if req.QuoteID == 0 && !positiveAmount(c, req.Amount) {
 			return
 		}
-		if balance < req.Amount {
-			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "insufficient funds"})
+		precondition, ok := ifMatch(c)
+		if !ok {
 			return
 		}
 
-		// 2. Получаем курс
-		rate, err := authService.GetExchangeRateWithCache(req.FromCurrency, req.ToCurrency)
-		if err != nil {
-			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "failed to get exchange rate"})
-			return
+		// Курс, пересчёт и обе стороны обмена — в сервисе кошелька, в одной транзакции
+		var result wallet.ExchangeResult
+		var err error
+		if req.QuoteID != 0 {
+			result, err = wallets.ExchangeQuote(c.Request.Context(), userID, walletID, req.QuoteID, precondition)
+		} else {
+			result, err = wallets.Exchange(c.Request.Context(), userID, walletID, req.FromCurrency, req.ToCurrency, req.Amount, precondition)
 		}
-
-		receivedAmount := req.Amount * rate
-
-		// 3. Атомарное обновление балансов
-		err = storage.UpdateBalance(c.Request.Context(), userID, req.FromCurrency, -req.Amount)
 		if err != nil {
-			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "failed to deduct balance"})
+			switch {
+			case wallet.IsValidationError(err):
+				amountError(c, err)
+			case quoteFailed(c, err), approvalRequired(c, err), limitExceeded(c, err), accountFrozen(c, err), versionMismatch(c, err),
+				walletNotFound(c, err), roleForbidden(c, err):
+			case errors.Is(err, wallet.ErrRateUnavailable):
+				c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "failed to get exchange rate"})
+			case errors.Is(err, storages.ErrInsufficientFunds):
+				c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "insufficient funds"})
+			case errors.Is(err, storages.ErrBalanceNotFound):
+				c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "balance not found"})
+			default:
+				c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "failed to exchange currencies"})
+			}
 			return
 		}
 
-		err = storage.UpdateBalance(c.Request.Context(), userID, req.ToCurrency, receivedAmount)
-		if err != nil {
-			// Откат в реальном проекте требует транзакций!
-			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "failed to add balance"})
-			return
+		resp := exchangeResponse(result)
+		resp["transaction_id"] = result.Transaction.ID
+		if req.QuoteID != 0 {
+			resp["quote_id"] = req.QuoteID
 		}
+		c.JSON(http.StatusOK, resp)
+	}
+}
 
-		// 4. Проверка на крупный перевод (≥30 000)
-		if req.Amount >= 30000 {
-			//отправить в Kafka
-			go func() {
-				ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-				defer cancel()
-				err = notificationService.SendLargeTransfer(ctx, userID, req.Amount, req.FromCurrency)
-				if err != nil {
-					return
-				}
-			}()
+// exchangeResponse — суммы, курс и комиссия обмена. Комиссия удерживается из суммы списания
+// до пересчёта по курсу. rate_path — пары exchanger, из которых получен курс
+func exchangeResponse(result wallet.ExchangeResult) gin.H {
+	resp := gin.H{
+		"from_currency":   result.FromCurrency,
+		"to_currency":     result.ToCurrency,
+		"sent_amount":     result.Amount,
+		"received_amount": result.Received,
+		"rate":            result.Rate,
+		"fee": gin.H{
+			"amount":   result.Fee,
+			"currency": result.FromCurrency,
+			"percent":  result.FeePercent,
+		},
+	}
+	if len(result.Path.Legs) > 0 {
+		resp["rate_path"] = gin.H{
+			"path": result.Path.Currencies(),
+			"legs": result.Path.Legs,
 		}
-
-		c.JSON(http.StatusOK, gin.H{
-			"from_currency":   req.FromCurrency,
-			"to_currency":     req.ToCurrency,
-			"sent_amount":     req.Amount,
-			"received_amount": receivedAmount,
-			"rate":            rate,
-		})
 	}
+	return resp
 }
 
 // @Summary Get current exchange rates
@@ -4,71 +4,119 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
-	"gw-currency-wallet/internal/auth"
-	"gw-currency-wallet/internal/auth/mocks"
 	"gw-currency-wallet/internal/storages"
-	"gw-currency-wallet/pkg/logging"
+	"gw-currency-wallet/pkg/money"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
-	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
-type MockAuthService struct{}
+func TestExchangeHandler_Success(t *testing.T) {
+	storage, userID := newTestStorage(t, map[string]money.Decimal{"USD": money.New(1000, 0)})
+	router := newTestRouter(t, storage, userID)
 
-func (m *MockAuthService) GetExchangeRateWithCache(from, to string) (float32, error) {
-	return 90.0, nil
-}
+	reqBody := ExchangeRequest{
+		FromCurrency: "USD",
+		ToCurrency:   "RUB",
+		Amount:       money.New(100, 0),
+	}
+	jsonBody, _ := json.Marshal(reqBody)
 
-type MockStorage struct{}
+	req := httptest.NewRequest("POST", "/exchange", bytes.NewBuffer(jsonBody))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
 
-func (m *MockStorage) CreateUser(ctx context.Context, email, passwordHash string) (int64, error) {
-	return 1, nil
-}
+	router.ServeHTTP(w, req)
 
-func (m *MockStorage) GetUserByEmail(ctx context.Context, email string) (storages.User, error) {
-	return storages.User{ID: 1, Email: email}, nil
-}
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "received_amount")
 
-func (m *MockStorage) GetBalance(ctx context.Context, userID int64, currency string) (float32, error) {
-	return 1000.0, nil
-}
-func (m *MockStorage) GetAllBalances(ctx context.Context, userID int64) (map[string]float32, error) {
-	return make(map[string]float32), nil
+	rub, err := storage.GetBalance(context.Background(), userID, "RUB")
+	assert.NoError(t, err)
+	assert.Equal(t, "9000.00", rub.String())
 }
 
-func (m *MockStorage) UpdateBalance(ctx context.Context, userID int64, currency string, amount float32) error {
-	return nil
+func TestExchangeHandler_Fees(t *testing.T) {
+	storage, userID := newTestStorage(t, map[string]money.Decimal{"USD": money.New(1000, 0)})
+	router := newTestRouter(t, storage, userID)
+	require.NoError(t, storage.SetFeeRule(storages.FeeRule{Tier: storages.DefaultTier, Percent: money.New(1, 0)}))
+	require.NoError(t, storage.SetFeeRule(storages.FeeRule{
+		Tier: storages.DefaultTier, FromCurrency: "USD", ToCurrency: "RUB", Percent: money.New(5, 1), MinFee: money.New(2, 0),
+	}))
+
+	// 0.5% от 100 USD меньше минимальной комиссии: удерживается 2 USD, по курсу пересчитываются 98
+	w := serve(router, "POST", "/exchange", `{"from_currency": "USD", "to_currency": "RUB", "amount": 100}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Contains(t, w.Body.String(), `"fee":{"amount":2.00,"currency":"USD","percent":0.5}`)
+	assert.Contains(t, w.Body.String(), `"received_amount":8820.00`)
+
+	usd, err := storage.GetBalance(context.Background(), userID, "USD")
+	require.NoError(t, err)
+	assert.Equal(t, "900.00", usd.String())
+
+	// Комиссия — отдельная проводка на счёт доходов
+	revenue, err := storage.GetFeeRevenue(context.Background(), time.Time{}, time.Time{})
+	require.NoError(t, err)
+	require.Len(t, revenue, 1)
+	assert.Equal(t, "USD", revenue[0].Currency)
+	assert.Equal(t, "2.00", revenue[0].Amount.String())
+	assert.Equal(t, int64(1), revenue[0].Exchanges)
+
+	// Правило пользователя перекрывает тарифные
+	require.NoError(t, storage.SetFeeRule(storages.FeeRule{UserID: userID}))
+	w = serve(router, "POST", "/exchange", `{"from_currency": "USD", "to_currency": "RUB", "amount": 100}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Contains(t, w.Body.String(), `"received_amount":9000.00`)
+
+	// Комиссия не меньше суммы обмена — обмен отклоняется
+	require.NoError(t, storage.SetFeeRule(storages.FeeRule{UserID: userID, FromCurrency: "USD", MinFee: money.New(5, 0)}))
+	w = serve(router, "POST", "/exchange", `{"from_currency": "USD", "to_currency": "RUB", "amount": 5}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
 }
 
-func TestExchangeHandler_Success(t *testing.T) {
-	gin.SetMode(gin.TestMode)
-	router := gin.New()
+func TestExchangeHandler_InsufficientFunds(t *testing.T) {
+	storage, userID := newTestStorage(t, nil)
+	router := newTestRouter(t, storage, userID)
 
 	reqBody := ExchangeRequest{
 		FromCurrency: "USD",
 		ToCurrency:   "RUB",
-		Amount:       100,
+		Amount:       money.New(100, 0),
 	}
 	jsonBody, _ := json.Marshal(reqBody)
 
-	router.POST("/exchange", func(c *gin.Context) {
-		c.Set("userID", int64(1))
-		mockStorage := &MockStorage{} // с реализованными методами
-		mockClient := &mocks.MockExchangerClient{}
-		authService := auth.NewService(mockStorage, "test-secret", mockClient, logging.GetLogger())
-
-		Exchange(mockStorage, authService, nil)(c)
-	})
-
 	req := httptest.NewRequest("POST", "/exchange", bytes.NewBuffer(jsonBody))
 	req.Header.Set("Content-Type", "application/json")
 	w := httptest.NewRecorder()
 
 	router.ServeHTTP(w, req)
 
-	assert.Equal(t, http.StatusOK, w.Code)
-	assert.Contains(t, w.Body.String(), "received_amount")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "insufficient funds")
+}
+
+func TestExchangeHandler_InvalidAmount(t *testing.T) {
+	storage, userID := newTestStorage(t, map[string]money.Decimal{"USD": money.New(1000, 0)})
+	router := newTestRouter(t, storage, userID)
+
+	// Лишние знаки после запятой отклоняются, а не округляются; валюты — только из справочника
+	for _, body := range []string{
+		`{"from_currency": "USD", "to_currency": "RUB", "amount": 10.005}`,
+		`{"from_currency": "USD", "to_currency": "XXX", "amount": 10}`,
+		`{"from_currency": "XXX", "to_currency": "RUB", "amount": 10}`,
+		`{"from_currency": "USD", "to_currency": "RUB", "amount": -5}`,
+		`{"from_currency": "USD", "to_currency": "RUB"}`,
+	} {
+		req := httptest.NewRequest("POST", "/exchange", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code, body)
+	}
 }
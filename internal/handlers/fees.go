@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"gw-currency-wallet/internal/storages"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type FeeRevenueQuery struct {
+	From time.Time `form:"from" time_format:"2006-01-02T15:04:05Z07:00"`
+	To   time.Time `form:"to" time_format:"2006-01-02T15:04:05Z07:00"`
+}
+
+// @Summary Exchange fee revenue
+// @Description Fees credited to the house revenue account, per currency
+// @Tags admin
+// @Security ApiKeyAuth
+// @Produce json
+// @Param from query string false "Start of period, RFC 3339 (inclusive)"
+// @Param to query string false "End of period, RFC 3339 (exclusive)"
+// @Success 200 {array} storages.FeeRevenue
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /admin/revenue [get]
+func GetFeeRevenue(storage storages.Repository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var query FeeRevenueQuery
+		if err := c.ShouldBindQuery(&query); err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		revenue, err := storage.GetFeeRevenue(c.Request.Context(), query.From, query.To)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "failed to get fee revenue"})
+			return
+		}
+		if revenue == nil {
+			revenue = []storages.FeeRevenue{}
+		}
+		c.JSON(http.StatusOK, revenue)
+	}
+}
@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"gw-currency-wallet/internal/auth"
+	"gw-currency-wallet/internal/auth/mocks"
+	"gw-currency-wallet/internal/cache"
+	"gw-currency-wallet/internal/storages/memory"
+	"gw-currency-wallet/internal/wallet"
+	"gw-currency-wallet/pkg/logging"
+	"gw-currency-wallet/pkg/money"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestStorage создаёт хранилище в памяти с одним пользователем и его начальным балансом
+func newTestStorage(t *testing.T, balances map[string]money.Decimal) (*memory.Memory, int64) {
+	t.Helper()
+	storage := memory.NewMemoryRepository()
+	userID, err := storage.CreateUser(context.Background(), "test@example.com", "hash")
+	require.NoError(t, err)
+	for currency, amount := range balances {
+		_, err = storage.Credit(context.Background(), userID, currency, amount)
+		require.NoError(t, err)
+	}
+	return storage, userID
+}
+
+// newTestServices — сервисы авторизации и кошелька поверх хранилища с мок-курсами exchanger
+func newTestServices(storage *memory.Memory) (*auth.Service, *wallet.Service) {
+	authService := auth.NewService(storage, "test-secret", &mocks.MockExchangerClient{}, cache.Routing{}, logging.GetLogger())
+	return authService, wallet.NewService(storage, authService.Currencies(), authService, "USD", time.Minute)
+}
+
+// newTestRouter регистрирует все маршруты пользователя, как SetupRoutes, но без префикса /api/v1
+// и без JWT: запросы выполняются от имени userID
+func newTestRouter(t *testing.T, storage *memory.Memory, userID int64) *gin.Engine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	authService, wallets := newTestServices(storage)
+
+	router := gin.New()
+	protected := router.Group("")
+	protected.Use(func(c *gin.Context) {
+		c.Set("userID", userID)
+	})
+	setupProtectedRoutes(protected, storage, authService, wallets)
+	return router
+}
+
+// serve выполняет запрос; тело, если есть, передаётся как JSON
+func serve(router *gin.Engine, method, path, body string) *httptest.ResponseRecorder {
+	return serveWith(router, method, path, body, nil)
+}
+
+// serveWith — serve с дополнительными заголовками запроса
+func serveWith(router *gin.Engine, method, path, body string, headers map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
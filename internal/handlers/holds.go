@@ -0,0 +1,224 @@
+package handlers
+
+import (
+	"errors"
+	"gw-currency-wallet/internal/auth"
+	"gw-currency-wallet/internal/currencies"
+	"gw-currency-wallet/internal/storages"
+	"gw-currency-wallet/internal/wallet"
+	"gw-currency-wallet/pkg/money"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultHoldTTL = 7 * 24 * time.Hour
+	maxHoldTTL     = 30 * 24 * time.Hour
+)
+
+type PlaceHoldRequest struct {
+	Currency string        `json:"currency" binding:"required"`
+	Amount   money.Decimal `json:"amount" swaggertype:"number"`
+	// ExpiresIn — время жизни холда в секундах (по умолчанию 7 дней, не больше 30)
+	ExpiresIn int64 `json:"expires_in" binding:"omitempty,min=1"`
+}
+
+// CaptureHoldRequest — без amount списывается вся сумма холда
+type CaptureHoldRequest struct {
+	Amount *money.Decimal `json:"amount" swaggertype:"number"`
+}
+
+// @Summary Place a hold on wallet funds
+// @Tags holds
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param request body PlaceHoldRequest true "Hold request"
+// @Param Idempotency-Key header string false "Key for safe retries"
+// @Success 201 {object} storages.Hold
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /holds [post]
+func PlaceHold(storage storages.Repository, catalog *currencies.Catalog) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := auth.GetUserID(c)
+		if !ok {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "user not found"})
+			return
+		}
+
+		var req PlaceHoldRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if !positiveAmount(c, req.Amount) {
+			return
+		}
+
+		amount, err := wallet.ValidateAmount(c.Request.Context(), catalog, req.Amount, req.Currency)
+		if err != nil {
+			amountError(c, err)
+			return
+		}
+
+		ttl := defaultHoldTTL
+		if req.ExpiresIn > 0 {
+			ttl = time.Duration(req.ExpiresIn) * time.Second
+		}
+		if ttl > maxHoldTTL {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "expires_in is too long"})
+			return
+		}
+
+		hold, err := storage.PlaceHold(c.Request.Context(), userID, req.Currency, amount, time.Now().Add(ttl))
+		if err != nil {
+			if accountFrozen(c, err) {
+				return
+			}
+			if errors.Is(err, storages.ErrInsufficientFunds) || errors.Is(err, storages.ErrBalanceNotFound) {
+				c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "insufficient funds"})
+				return
+			}
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "failed to place hold"})
+			return
+		}
+
+		c.JSON(http.StatusCreated, hold)
+	}
+}
+
+// @Summary Get hold
+// @Tags holds
+// @Security ApiKeyAuth
+// @Produce json
+// @Param id path int true "Hold ID"
+// @Success 200 {object} storages.Hold
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /holds/{id} [get]
+func GetHold(storage storages.Repository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, holdID, ok := holdParams(c)
+		if !ok {
+			return
+		}
+
+		hold, err := storage.GetHold(c.Request.Context(), userID, holdID)
+		if err != nil {
+			holdError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, hold)
+	}
+}
+
+// @Summary Capture a hold in full or in part
+// @Tags holds
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "Hold ID"
+// @Param request body CaptureHoldRequest false "Capture amount (full hold if omitted)"
+// @Param Idempotency-Key header string false "Key for safe retries"
+// @Success 200 {object} storages.Hold
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /holds/{id}/capture [post]
+func CaptureHold(wallets *wallet.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, holdID, ok := holdParams(c)
+		if !ok {
+			return
+		}
+
+		var req CaptureHoldRequest
+		if c.Request.ContentLength != 0 {
+			if err := c.ShouldBindJSON(&req); err != nil {
+				c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+		}
+
+		hold, err := wallets.CaptureHold(c.Request.Context(), userID, holdID, req.Amount)
+		if err != nil {
+			if wallet.IsValidationError(err) {
+				amountError(c, err)
+				return
+			}
+			if limitExceeded(c, err) {
+				return
+			}
+			holdError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, hold)
+	}
+}
+
+// @Summary Void a hold
+// @Tags holds
+// @Security ApiKeyAuth
+// @Produce json
+// @Param id path int true "Hold ID"
+// @Param Idempotency-Key header string false "Key for safe retries"
+// @Success 200 {object} storages.Hold
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /holds/{id}/void [post]
+func VoidHold(storage storages.Repository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, holdID, ok := holdParams(c)
+		if !ok {
+			return
+		}
+
+		hold, err := storage.VoidHold(c.Request.Context(), userID, holdID)
+		if err != nil {
+			holdError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, hold)
+	}
+}
+
+func holdParams(c *gin.Context) (int64, int64, bool) {
+	userID, ok := auth.GetUserID(c)
+	if !ok {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "user not found"})
+		return 0, 0, false
+	}
+
+	holdID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid hold id"})
+		return 0, 0, false
+	}
+	return userID, holdID, true
+}
+
+// holdError: закрытый или просроченный холд — конфликт состояния (409), превышение суммы — 400
+func holdError(c *gin.Context, err error) {
+	switch {
+	case accountFrozen(c, err):
+	case errors.Is(err, storages.ErrHoldNotFound):
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "hold not found"})
+	case errors.Is(err, storages.ErrHoldNotActive), errors.Is(err, storages.ErrHoldExpired):
+		c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, storages.ErrCaptureExceedsHold):
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "capture amount exceeds hold"})
+	default:
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "failed to update hold"})
+	}
+}
@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"encoding/json"
+	"gw-currency-wallet/internal/storages"
+	"gw-currency-wallet/pkg/money"
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHoldsHandler_CaptureFlow(t *testing.T) {
+	storage, userID := newTestStorage(t, map[string]money.Decimal{"USD": money.New(100, 0)})
+	router := newTestRouter(t, storage, userID)
+
+	w := serve(router, "POST", "/holds", `{"currency": "USD", "amount": 40}`)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var hold storages.Hold
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &hold))
+
+	w = serve(router, "GET", "/balance/USD", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"currency": "USD", "balance": 100.00, "total": 100.00, "available": 60.00, "held": 40.00}`, w.Body.String())
+
+	path := "/holds/" + strconv.FormatInt(hold.ID, 10)
+	w = serve(router, "POST", path+"/capture", `{"amount": 50}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = serve(router, "POST", path+"/capture", `{"amount": 15.5}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	w = serve(router, "GET", "/balance/USD", "")
+	assert.JSONEq(t, `{"currency": "USD", "balance": 84.50, "total": 84.50, "available": 84.50, "held": 0.00}`, w.Body.String())
+
+	// Закрытый холд нельзя закрыть повторно
+	w = serve(router, "POST", path+"/void", "")
+	assert.Equal(t, http.StatusConflict, w.Code)
+}
+
+func TestHoldsHandler_FullCaptureAndVoid(t *testing.T) {
+	storage, userID := newTestStorage(t, map[string]money.Decimal{"EUR": money.New(10, 0)})
+	router := newTestRouter(t, storage, userID)
+
+	w := serve(router, "POST", "/holds", `{"currency": "EUR", "amount": 11}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w = serve(router, "POST", "/holds", `{"currency": "EUR", "amount": 1, "expires_in": 99999999}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = serve(router, "POST", "/holds", `{"currency": "EUR", "amount": 4}`)
+	require.Equal(t, http.StatusCreated, w.Code)
+	w = serve(router, "POST", "/holds/1/capture", "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Contains(t, w.Body.String(), `"captured_amount":4.00`)
+
+	w = serve(router, "POST", "/holds", `{"currency": "EUR", "amount": 6}`)
+	require.Equal(t, http.StatusCreated, w.Code)
+	w = serve(router, "POST", "/holds/2/void", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"status":"voided"`)
+
+	w = serve(router, "POST", "/holds/3/void", "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"gw-currency-wallet/internal/auth"
+	"gw-currency-wallet/internal/storages"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	idempotencyKeyHeader     = "Idempotency-Key"
+	idempotentReplayedHeader = "Idempotent-Replayed"
+	maxIdempotencyKeyLength  = 255
+	// idempotencyLease — на сколько запрос занимает ключ. Ключ упавшего процесса по истечении
+	// захвата получает повтор клиента; захват с запасом длиннее любого запроса
+	idempotencyLease = time.Minute
+)
+
+// responseRecorder дублирует тело ответа, чтобы сохранить его для повторов
+type responseRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *responseRecorder) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *responseRecorder) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// Idempotency — middleware для изменяющих запросов с заголовком Idempotency-Key.
+// Первый запрос выполняется и его ответ сохраняется; повтор с тем же телом получает
+// сохранённый ответ, а тот же ключ с другим телом отклоняется с 409. Если запрос завершился
+// ошибкой 5xx или паникой, ключ освобождается, и клиент может повторить запрос
+func Idempotency(storage storages.Repository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(idempotencyKeyHeader)
+		if key == "" {
+			c.Next()
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "idempotency key is too long"})
+			return
+		}
+
+		userID, ok := auth.GetUserID(c)
+		if !ok {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "user not found"})
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "failed to read request body"})
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		// Путь берём фактический, а не шаблон маршрута: запросы к разным кошелькам или холдам различаются
+		fingerprint := requestFingerprint(c.Request.Method, c.Request.URL.Path, body)
+		record, reserved, err := storage.ReserveIdempotencyKey(c.Request.Context(), userID, key, fingerprint, time.Now(), idempotencyLease)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "failed to check idempotency key"})
+			return
+		}
+
+		if !reserved {
+			switch {
+			case record.Fingerprint != fingerprint:
+				c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": "idempotency key was used with a different request"})
+			case record.StatusCode == 0:
+				c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": "request with this idempotency key is still in progress"})
+			default:
+				c.Header(idempotentReplayedHeader, "true")
+				c.Data(record.StatusCode, "application/json; charset=utf-8", record.Response)
+				c.Abort()
+			}
+			return
+		}
+
+		// Ответ сохраняем даже если клиент уже отключился
+		ctx := context.WithoutCancel(c.Request.Context())
+		defer func() {
+			if r := recover(); r != nil {
+				_ = storage.ReleaseIdempotencyKey(ctx, userID, key)
+				panic(r)
+			}
+		}()
+
+		recorder := &responseRecorder{ResponseWriter: c.Writer}
+		c.Writer = recorder
+		c.Next()
+
+		if recorder.Status() >= http.StatusInternalServerError {
+			// Операция не выполнена — даём клиенту повторить её с тем же ключом
+			_ = storage.ReleaseIdempotencyKey(ctx, userID, key)
+			return
+		}
+		_ = storage.SaveIdempotentResponse(ctx, userID, key, recorder.Status(), recorder.body.Bytes())
+	}
+}
+
+// requestFingerprint считает отпечаток запроса. JSON-тело приводится к каноническому виду,
+// чтобы порядок полей и пробелы не делали повтор «другим» запросом
+func requestFingerprint(method, path string, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(method + " " + path + "\n"))
+	h.Write(canonicalJSON(body))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func canonicalJSON(body []byte) []byte {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+
+	var value any
+	if err := decoder.Decode(&value); err != nil {
+		return body
+	}
+	canonical, err := json.Marshal(value)
+	if err != nil {
+		return body
+	}
+	return canonical
+}
@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIdempotency_ReplaysDeposit(t *testing.T) {
+	storage, userID := newTestStorage(t, nil)
+	router := newTestRouter(t, storage, userID)
+
+	send := func(body string) *httptest.ResponseRecorder {
+		return serveWith(router, "POST", "/wallet/deposit", body, map[string]string{"Idempotency-Key": "key-1"})
+	}
+
+	first := send(`{"amount": 100, "currency": "USD"}`)
+	assert.Equal(t, http.StatusOK, first.Code)
+
+	// Тот же запрос с другим порядком полей — повтор, баланс не пополняется второй раз
+	replay := send(`{"currency": "USD",  "amount": 100}`)
+	assert.Equal(t, http.StatusOK, replay.Code)
+	assert.Equal(t, "true", replay.Header().Get("Idempotent-Replayed"))
+	assert.Equal(t, first.Body.String(), replay.Body.String())
+
+	conflict := send(`{"amount": 200, "currency": "USD"}`)
+	assert.Equal(t, http.StatusConflict, conflict.Code)
+
+	balance, err := storage.GetBalance(context.Background(), userID, "USD")
+	assert.NoError(t, err)
+	assert.Equal(t, "100.00", balance.String())
+}
+
+func TestIdempotency_ReleasesKeyOnPanic(t *testing.T) {
+	storage, userID := newTestStorage(t, nil)
+	gin.SetMode(gin.TestMode)
+
+	calls := 0
+	router := gin.New()
+	router.Use(gin.Recovery(), func(c *gin.Context) {
+		c.Set("userID", userID)
+	})
+	router.POST("/operation", Idempotency(storage), func(c *gin.Context) {
+		calls++
+		if calls == 1 {
+			panic("operation failed")
+		}
+		c.JSON(http.StatusOK, gin.H{"calls": calls})
+	})
+
+	send := func() *httptest.ResponseRecorder {
+		return serveWith(router, "POST", "/operation", `{}`, map[string]string{"Idempotency-Key": "key-1"})
+	}
+
+	// Паника не оставляет ключ занятым: повтор выполняется, а не получает 409
+	assert.Equal(t, http.StatusInternalServerError, send().Code)
+	retry := send()
+	assert.Equal(t, http.StatusOK, retry.Code, retry.Body.String())
+	replay := send()
+	assert.Equal(t, "true", replay.Header().Get("Idempotent-Replayed"))
+	assert.Equal(t, 2, calls)
+}
@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"errors"
+	"gw-currency-wallet/internal/auth"
+	"gw-currency-wallet/internal/storages"
+	"gw-currency-wallet/internal/wallet"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+const codeLimitExceeded = "limit_exceeded"
+
+// @Summary Get operation limits and remaining allowance
+// @Description Limits with normalized=true apply to all currencies converted to the base currency
+// @Tags limits
+// @Security ApiKeyAuth
+// @Produce json
+// @Success 200 {array} storages.LimitStatus
+// @Failure 401 {object} map[string]string
+// @Router /limits [get]
+func GetLimits(wallets *wallet.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := auth.GetUserID(c)
+		if !ok {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "user not found"})
+			return
+		}
+
+		limits, err := wallets.Limits(c.Request.Context(), userID)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "failed to get limits"})
+			return
+		}
+		c.JSON(http.StatusOK, limits)
+	}
+}
+
+// limitExceeded отвечает 403 с периодом и остатком, если операция превышает лимит
+func limitExceeded(c *gin.Context, err error) bool {
+	var limitErr *storages.LimitError
+	if !errors.As(err, &limitErr) {
+		return false
+	}
+	c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+		"error":     err.Error(),
+		"code":      codeLimitExceeded,
+		"period":    limitErr.Period,
+		"limit":     limitErr.Limit,
+		"remaining": limitErr.Remaining,
+	})
+	return true
+}
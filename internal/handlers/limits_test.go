@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"gw-currency-wallet/internal/storages"
+	"gw-currency-wallet/pkg/money"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLimitsHandler_WithdrawWithinLimits(t *testing.T) {
+	storage, userID := newTestStorage(t, map[string]money.Decimal{
+		"USD": money.New(300000, 0),
+		"RUB": money.New(10000, 0),
+	})
+	daily, perTransaction := money.New(500, 0), money.New(50000, 0)
+	require.NoError(t, storage.SetLimit(storages.LimitRule{
+		UserID: userID, Operation: storages.OperationWithdraw, Currency: "RUB", Daily: &daily,
+	}))
+	require.NoError(t, storage.SetLimit(storages.LimitRule{
+		UserID: userID, Operation: storages.OperationWithdraw, PerTransaction: &perTransaction,
+	}))
+	router := newTestRouter(t, storage, userID)
+
+	// Тарифный лимит в валюте операции: не больше 100 000 USD за операцию
+	w := serve(router, "POST", "/wallet/withdraw", `{"currency": "USD", "amount": 150000}`)
+	require.Equal(t, http.StatusForbidden, w.Code, w.Body.String())
+	assert.Contains(t, w.Body.String(), `"code":"limit_exceeded"`)
+	assert.Contains(t, w.Body.String(), `"period":"transaction"`)
+
+	w = serve(router, "POST", "/wallet/withdraw", `{"currency": "RUB", "amount": 400}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	w = serve(router, "POST", "/wallet/withdraw", `{"currency": "RUB", "amount": 150}`)
+	require.Equal(t, http.StatusForbidden, w.Code, w.Body.String())
+	assert.Contains(t, w.Body.String(), `"remaining":100.00`)
+
+	// Лимит пользователя без валюты — 50 000 USD за операцию, в какой бы валюте она ни была
+	w = serve(router, "POST", "/wallet/withdraw", `{"currency": "USD", "amount": 60000}`)
+	require.Equal(t, http.StatusForbidden, w.Code, w.Body.String())
+
+	w = serve(router, "GET", "/limits", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	var limits []storages.LimitStatus
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &limits))
+
+	found := 0
+	for _, l := range limits {
+		if l.Operation != storages.OperationWithdraw {
+			continue
+		}
+		switch {
+		case l.Normalized:
+			found++
+			assert.Equal(t, "USD", l.Currency)
+			assert.Equal(t, "36000.00", l.DailyUsed.String())
+		case l.Currency == "RUB":
+			found++
+			assert.Equal(t, "100.00", l.DailyRemaining.String())
+		}
+	}
+	assert.Equal(t, 2, found)
+}
+
+func TestLimitsHandler_DefaultLimitsNeedNoRate(t *testing.T) {
+	storage, userID := newTestStorage(t, nil)
+	router := newTestRouter(t, storage, userID)
+
+	w := serve(router, "GET", "/limits", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	var limits []storages.LimitStatus
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &limits))
+	require.NotEmpty(t, limits)
+	for _, l := range limits {
+		assert.False(t, l.Normalized, "%s %s", l.Operation, l.Currency)
+	}
+}
+
+func TestLimitsHandler_TransferAndCaptureCountAsWithdraw(t *testing.T) {
+	storage, userID := newTestStorage(t, map[string]money.Decimal{"USD": money.New(1000, 0)})
+	recipientID, err := storage.CreateUser(context.Background(), "recipient@example.com", "hash")
+	require.NoError(t, err)
+	daily := money.New(100, 0)
+	require.NoError(t, storage.SetLimit(storages.LimitRule{
+		UserID: userID, Operation: storages.OperationWithdraw, Currency: "USD", Daily: &daily,
+	}))
+	router := newTestRouter(t, storage, userID)
+
+	w := serve(router, "POST", "/wallet/transfer", fmt.Sprintf(`{"to_user_id": %d, "currency": "USD", "amount": 60}`, recipientID))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	w = serve(router, "POST", "/holds", `{"currency": "USD", "amount": 50}`)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var hold storages.Hold
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &hold))
+
+	// Из суточного лимита 100 USD перевод израсходовал 60
+	w = serve(router, "POST", fmt.Sprintf("/holds/%d/capture", hold.ID), "")
+	require.Equal(t, http.StatusForbidden, w.Code, w.Body.String())
+	assert.Contains(t, w.Body.String(), `"remaining":40.00`)
+	w = serve(router, "POST", fmt.Sprintf("/holds/%d/capture", hold.ID), `{"amount": 40}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	w = serve(router, "POST", "/wallet/transfer", fmt.Sprintf(`{"to_user_id": %d, "currency": "USD", "amount": 1}`, recipientID))
+	require.Equal(t, http.StatusForbidden, w.Code, w.Body.String())
+	assert.Contains(t, w.Body.String(), `"code":"limit_exceeded"`)
+}
+
+func TestWithdraw_AccountFrozen(t *testing.T) {
+	storage, userID := newTestStorage(t, map[string]money.Decimal{"USD": money.New(100, 0)})
+	require.NoError(t, storage.SetAccountFrozen(context.Background(), userID, "USD", true))
+	router := newTestRouter(t, storage, userID)
+
+	w := serve(router, "POST", "/wallet/withdraw", `{"currency": "USD", "amount": 10}`)
+	require.Equal(t, http.StatusForbidden, w.Code, w.Body.String())
+	assert.Contains(t, w.Body.String(), `"code":"account_frozen"`)
+
+	require.NoError(t, storage.SetAccountFrozen(context.Background(), userID, "USD", false))
+	w = serve(router, "POST", "/wallet/withdraw", `{"currency": "USD", "amount": 10}`)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+}
@@ -0,0 +1,230 @@
+package handlers
+
+import (
+	"errors"
+	"gw-currency-wallet/internal/auth"
+	"gw-currency-wallet/internal/currencies"
+	"gw-currency-wallet/internal/storages"
+	"gw-currency-wallet/internal/wallet"
+	"gw-currency-wallet/pkg/money"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultOrderTTL = 7 * 24 * time.Hour
+	maxOrderTTL     = 30 * 24 * time.Hour
+)
+
+// PlaceOrderRequest — продать amount в from_currency за to_currency, когда курс не ниже limit_rate.
+// Без good_until заявка действует 7 дней, дольше 30 дней — нельзя
+type PlaceOrderRequest struct {
+	FromCurrency string        `json:"from_currency" binding:"required"`
+	ToCurrency   string        `json:"to_currency" binding:"required"`
+	Amount       money.Decimal `json:"amount" swaggertype:"number"`
+	LimitRate    money.Decimal `json:"limit_rate" swaggertype:"number"`
+	GoodUntil    *time.Time    `json:"good_until"`
+}
+
+// @Summary Place a limit order
+// @Description Funds are reserved until the order is filled, cancelled or expires
+// @Tags orders
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param request body PlaceOrderRequest true "Limit order"
+// @Param Idempotency-Key header string false "Key for safe retries"
+// @Success 201 {object} storages.Order
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /orders [post]
+func PlaceOrder(storage storages.Repository, catalog *currencies.Catalog) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := auth.GetUserID(c)
+		if !ok {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "user not found"})
+			return
+		}
+
+		var req PlaceOrderRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if !positiveAmount(c, req.Amount) {
+			return
+		}
+		if req.FromCurrency == req.ToCurrency {
+			amountError(c, wallet.ErrSameCurrency)
+			return
+		}
+
+		amount, err := wallet.ValidateAmount(c.Request.Context(), catalog, req.Amount, req.FromCurrency)
+		if err != nil {
+			amountError(c, err)
+			return
+		}
+		if _, err = catalog.Lookup(c.Request.Context(), req.ToCurrency); err != nil {
+			amountError(c, err)
+			return
+		}
+		if req.LimitRate.Sign() <= 0 {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "limit_rate must be positive"})
+			return
+		}
+		limitRate, err := req.LimitRate.Rescale(money.RateScale)
+		if err != nil {
+			amountError(c, err)
+			return
+		}
+
+		now := time.Now()
+		expiresAt := now.Add(defaultOrderTTL)
+		if req.GoodUntil != nil {
+			expiresAt = *req.GoodUntil
+		}
+		if !expiresAt.After(now) {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "good_until must be in the future"})
+			return
+		}
+		if expiresAt.Sub(now) > maxOrderTTL {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "good_until is too far"})
+			return
+		}
+
+		order, err := storage.PlaceOrder(c.Request.Context(), storages.Order{
+			UserID:       userID,
+			FromCurrency: req.FromCurrency,
+			ToCurrency:   req.ToCurrency,
+			Amount:       amount,
+			LimitRate:    limitRate,
+			ExpiresAt:    expiresAt,
+		})
+		if err != nil {
+			if accountFrozen(c, err) {
+				return
+			}
+			if errors.Is(err, storages.ErrInsufficientFunds) || errors.Is(err, storages.ErrBalanceNotFound) {
+				c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "insufficient funds"})
+				return
+			}
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "failed to place order"})
+			return
+		}
+
+		c.JSON(http.StatusCreated, order)
+	}
+}
+
+// @Summary List limit orders
+// @Tags orders
+// @Security ApiKeyAuth
+// @Produce json
+// @Success 200 {array} storages.Order
+// @Failure 401 {object} map[string]string
+// @Router /orders [get]
+func ListOrders(storage storages.Repository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := auth.GetUserID(c)
+		if !ok {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "user not found"})
+			return
+		}
+
+		orders, err := storage.ListOrders(c.Request.Context(), userID)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "failed to list orders"})
+			return
+		}
+		if orders == nil {
+			orders = []storages.Order{}
+		}
+		c.JSON(http.StatusOK, orders)
+	}
+}
+
+// @Summary Get limit order
+// @Tags orders
+// @Security ApiKeyAuth
+// @Produce json
+// @Param id path int true "Order ID"
+// @Success 200 {object} storages.Order
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /orders/{id} [get]
+func GetOrder(storage storages.Repository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, orderID, ok := orderParams(c)
+		if !ok {
+			return
+		}
+
+		order, err := storage.GetOrder(c.Request.Context(), userID, orderID)
+		if err != nil {
+			orderError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, order)
+	}
+}
+
+// @Summary Cancel a limit order
+// @Tags orders
+// @Security ApiKeyAuth
+// @Produce json
+// @Param id path int true "Order ID"
+// @Param Idempotency-Key header string false "Key for safe retries"
+// @Success 200 {object} storages.Order
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /orders/{id}/cancel [post]
+func CancelOrder(storage storages.Repository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, orderID, ok := orderParams(c)
+		if !ok {
+			return
+		}
+
+		order, err := storage.CancelOrder(c.Request.Context(), userID, orderID)
+		if err != nil {
+			orderError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, order)
+	}
+}
+
+func orderParams(c *gin.Context) (int64, int64, bool) {
+	userID, ok := auth.GetUserID(c)
+	if !ok {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "user not found"})
+		return 0, 0, false
+	}
+
+	orderID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid order id"})
+		return 0, 0, false
+	}
+	return userID, orderID, true
+}
+
+// orderError: исполненная, отменённая или просроченная заявка — конфликт состояния (409)
+func orderError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, storages.ErrOrderNotFound):
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "order not found"})
+	case errors.Is(err, storages.ErrOrderNotOpen):
+		c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "failed to update order"})
+	}
+}
@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"encoding/json"
+	"gw-currency-wallet/internal/storages"
+	"gw-currency-wallet/pkg/money"
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOrdersHandler_PlaceAndCancel(t *testing.T) {
+	storage, userID := newTestStorage(t, map[string]money.Decimal{"USD": money.New(100, 0)})
+	router := newTestRouter(t, storage, userID)
+
+	w := serve(router, "POST", "/orders", `{"from_currency": "USD", "to_currency": "RUB", "amount": 40, "limit_rate": 95.5}`)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var order storages.Order
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &order))
+	assert.Equal(t, storages.OrderOpen, order.Status)
+
+	w = serve(router, "GET", "/balance/USD", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"currency": "USD", "balance": 100.00, "total": 100.00, "available": 60.00, "held": 40.00}`, w.Body.String())
+
+	path := "/orders/" + strconv.FormatInt(order.ID, 10)
+	w = serve(router, "POST", path+"/cancel", "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Contains(t, w.Body.String(), `"status":"cancelled"`)
+
+	w = serve(router, "POST", path+"/cancel", "")
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	w = serve(router, "GET", "/orders", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	var orders []storages.Order
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &orders))
+	assert.Len(t, orders, 1)
+}
+
+func TestOrdersHandler_Validation(t *testing.T) {
+	storage, userID := newTestStorage(t, map[string]money.Decimal{"USD": money.New(100, 0)})
+	router := newTestRouter(t, storage, userID)
+
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"same currency", `{"from_currency": "USD", "to_currency": "USD", "amount": 10, "limit_rate": 1}`},
+		{"non-positive rate", `{"from_currency": "USD", "to_currency": "RUB", "amount": 10, "limit_rate": -1}`},
+		{"rate precision", `{"from_currency": "USD", "to_currency": "RUB", "amount": 10, "limit_rate": 95.123456789}`},
+		{"expired", `{"from_currency": "USD", "to_currency": "RUB", "amount": 10, "limit_rate": 95, "good_until": "2020-01-01T00:00:00Z"}`},
+		{"insufficient funds", `{"from_currency": "USD", "to_currency": "RUB", "amount": 101, "limit_rate": 95}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serve(router, "POST", "/orders", tt.body)
+			assert.Equal(t, http.StatusBadRequest, w.Code, w.Body.String())
+		})
+	}
+}
@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"errors"
+	"gw-currency-wallet/internal/auth"
+	"gw-currency-wallet/internal/storages"
+	"gw-currency-wallet/internal/wallet"
+	"gw-currency-wallet/pkg/money"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	codeQuoteNotFound = "quote_not_found"
+	codeQuoteExpired  = "quote_expired"
+	codeQuoteUsed     = "quote_used"
+)
+
+type QuoteRequest struct {
+	FromCurrency string        `json:"from_currency" binding:"required"`
+	ToCurrency   string        `json:"to_currency" binding:"required"`
+	Amount       money.Decimal `json:"amount" swaggertype:"number"`
+}
+
+// @Summary Quote an exchange
+// @Description Locks the current rate and fee for quote_ttl. Pass quote_id to POST /exchange to execute at exactly this quote; each quote can be used once
+// @Tags exchange
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param wallet_id path int false "Wallet ID, only in /wallets/{wallet_id}/exchange/quote; default wallet otherwise"
+// @Param request body QuoteRequest true "Quote request"
+// @Success 201 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]interface{}
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /exchange/quote [post]
+// @Router /wallets/{wallet_id}/exchange/quote [post]
+func CreateQuote(wallets *wallet.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := auth.GetUserID(c)
+		if !ok {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "user not found"})
+			return
+		}
+		walletID, ok := walletParam(c)
+		if !ok {
+			return
+		}
+
+		var req QuoteRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if !positiveAmount(c, req.Amount) {
+			return
+		}
+
+		quote, path, err := wallets.Quote(c.Request.Context(), userID, walletID, req.FromCurrency, req.ToCurrency, req.Amount)
+		if err != nil {
+			switch {
+			case wallet.IsValidationError(err):
+				amountError(c, err)
+			case walletNotFound(c, err), roleForbidden(c, err):
+			case errors.Is(err, wallet.ErrRateUnavailable):
+				c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "failed to get exchange rate"})
+			default:
+				c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "failed to create quote"})
+			}
+			return
+		}
+
+		resp := exchangeResponse(wallet.ExchangeResult{
+			FromCurrency: quote.FromCurrency,
+			ToCurrency:   quote.ToCurrency,
+			Amount:       quote.Amount,
+			Fee:          quote.Fee,
+			FeePercent:   quote.FeePercent,
+			Received:     quote.Received,
+			Rate:         quote.Rate,
+			Path:         path,
+		})
+		resp["quote_id"] = quote.ID
+		resp["expires_at"] = quote.ExpiresAt
+		c.JSON(http.StatusCreated, resp)
+	}
+}
+
+// quoteFailed отвечает на обмен по неизвестной, просроченной или уже использованной котировке
+func quoteFailed(c *gin.Context, err error) bool {
+	switch {
+	case errors.Is(err, storages.ErrQuoteNotFound):
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "quote not found", "code": codeQuoteNotFound})
+	case errors.Is(err, storages.ErrQuoteExpired):
+		c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": "quote has expired", "code": codeQuoteExpired})
+	case errors.Is(err, storages.ErrQuoteUsed):
+		c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": "quote has already been used", "code": codeQuoteUsed})
+	default:
+		return false
+	}
+	return true
+}
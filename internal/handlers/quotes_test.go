@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"encoding/json"
+	"gw-currency-wallet/internal/storages"
+	"gw-currency-wallet/pkg/money"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQuoteHandler_ExecuteOnce(t *testing.T) {
+	storage, userID := newTestStorage(t, map[string]money.Decimal{"USD": money.New(1000, 0)})
+	router := newTestRouter(t, storage, userID)
+
+	require.NoError(t, storage.SetFeeRule(storages.FeeRule{Tier: storages.DefaultTier, Percent: money.New(1, 0)}))
+	w := serve(router, "POST", "/exchange/quote", `{"from_currency": "USD", "to_currency": "RUB", "amount": 100}`)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var quote struct {
+		QuoteID   int64     `json:"quote_id"`
+		ExpiresAt time.Time `json:"expires_at"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &quote))
+	assert.Contains(t, w.Body.String(), `"received_amount":8910.00`)
+	assert.True(t, quote.ExpiresAt.After(time.Now()))
+
+	// Обмен по котировке проводится с зафиксированной комиссией, даже если правило изменилось
+	require.NoError(t, storage.SetFeeRule(storages.FeeRule{Tier: storages.DefaultTier, Percent: money.New(5, 0)}))
+	body := `{"quote_id": ` + strconv.FormatInt(quote.QuoteID, 10) + `}`
+	w = serve(router, "POST", "/exchange", body)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Contains(t, w.Body.String(), `"received_amount":8910.00`)
+	assert.Contains(t, w.Body.String(), `"fee":{"amount":1.00,"currency":"USD","percent":1}`)
+
+	rub, err := storage.GetBalance(t.Context(), userID, "RUB")
+	require.NoError(t, err)
+	assert.Equal(t, "8910.00", rub.String())
+
+	w = serve(router, "POST", "/exchange", body)
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Contains(t, w.Body.String(), `"code":"quote_used"`)
+
+	// С котировкой валюты и сумма в запросе не указываются
+	w = serve(router, "POST", "/exchange", `{"quote_id": 1, "amount": 100}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w = serve(router, "POST", "/exchange", `{"quote_id": 99}`)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	expired, err := storage.CreateQuote(t.Context(), storages.Quote{
+		UserID: userID, FromCurrency: "USD", ToCurrency: "RUB", Amount: money.New(10, 0),
+		Received: money.New(900, 0), Rate: money.New(90, 0), ExpiresAt: time.Now().Add(-time.Second),
+	})
+	require.NoError(t, err)
+	w = serve(router, "POST", "/exchange", `{"quote_id": `+strconv.FormatInt(expired.ID, 10)+`}`)
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Contains(t, w.Body.String(), `"code":"quote_expired"`)
+}
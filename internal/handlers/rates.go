@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"gw-currency-wallet/internal/currencies"
+	"gw-currency-wallet/internal/storages"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultCandleInterval = "1h"
+	defaultRateHistory    = 24 * time.Hour
+	// maxCandles ограничивает ответ: длинный период запрашивается с крупным интервалом
+	maxCandles = 1000
+)
+
+// candleIntervals — допустимые интервалы свечей
+var candleIntervals = map[string]time.Duration{
+	"1m":  time.Minute,
+	"5m":  5 * time.Minute,
+	"15m": 15 * time.Minute,
+	"30m": 30 * time.Minute,
+	"1h":  time.Hour,
+	"4h":  4 * time.Hour,
+	"1d":  24 * time.Hour,
+}
+
+type RateHistoryQuery struct {
+	Pair     string    `form:"pair" binding:"required"`
+	From     time.Time `form:"from" time_format:"2006-01-02T15:04:05Z07:00"`
+	To       time.Time `form:"to" time_format:"2006-01-02T15:04:05Z07:00"`
+	Interval string    `form:"interval"`
+}
+
+// @Summary Exchange rate history
+// @Description Open/high/low/close candles of the rates received from the exchanger for a pair.
+// @Description Candles are aligned to the Unix epoch in UTC; intervals without rates are omitted.
+// @Tags exchange
+// @Security ApiKeyAuth
+// @Produce json
+// @Param pair query string true "Currency pair, e.g. USD_RUB"
+// @Param from query string false "Start of period, RFC 3339 (inclusive, default 24 hours before to)"
+// @Param to query string false "End of period, RFC 3339 (exclusive, default now)"
+// @Param interval query string false "1m, 5m, 15m, 30m, 1h, 4h or 1d (default 1h)"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /exchange/rates/history [get]
+func GetRateHistory(storage storages.Repository, catalog *currencies.Catalog) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var query RateHistoryQuery
+		if err := c.ShouldBindQuery(&query); err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		from, to, ok := strings.Cut(query.Pair, "_")
+		if !ok || from == "" || to == "" || from == to {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "pair must look like USD_RUB"})
+			return
+		}
+		for _, code := range []string{from, to} {
+			if _, err := catalog.Lookup(c.Request.Context(), code); err != nil {
+				amountError(c, err)
+				return
+			}
+		}
+
+		if query.Interval == "" {
+			query.Interval = defaultCandleInterval
+		}
+		interval, ok := candleIntervals[query.Interval]
+		if !ok {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "interval must be one of 1m, 5m, 15m, 30m, 1h, 4h, 1d"})
+			return
+		}
+		if query.To.IsZero() {
+			query.To = time.Now()
+		}
+		if query.From.IsZero() {
+			query.From = query.To.Add(-defaultRateHistory)
+		}
+		if !query.From.Before(query.To) {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "from must be before to"})
+			return
+		}
+		if query.To.Sub(query.From)/interval >= maxCandles {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "period is too long for the interval, use a larger interval"})
+			return
+		}
+
+		candles, err := storage.GetRateCandles(c.Request.Context(), from, to, query.From, query.To, interval)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "failed to get rate history"})
+			return
+		}
+		if candles == nil {
+			candles = []storages.RateCandle{}
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"pair":     query.Pair,
+			"interval": query.Interval,
+			"from":     query.From.UTC(),
+			"to":       query.To.UTC(),
+			"candles":  candles,
+		})
+	}
+}
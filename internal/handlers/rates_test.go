@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"encoding/json"
+	"gw-currency-wallet/internal/storages"
+	"gw-currency-wallet/pkg/money"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRateHistoryHandler_Candles(t *testing.T) {
+	storage, userID := newTestStorage(t, nil)
+	router := newTestRouter(t, storage, userID)
+
+	hour := time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC)
+	var samples []storages.RateSample
+	for _, s := range []struct {
+		minutes int
+		rate    int64
+	}{{5, 90}, {20, 92}, {40, 89}, {55, 91}, {70, 93}} {
+		samples = append(samples, storages.RateSample{
+			FromCurrency: "USD", ToCurrency: "RUB", Rate: money.New(s.rate, 0),
+			Source: storages.RateSourceList, FetchedAt: hour.Add(time.Duration(s.minutes) * time.Minute),
+		})
+	}
+	require.NoError(t, storage.SaveRates(t.Context(), samples))
+
+	w := serve(router, "GET", "/exchange/rates/history?pair=USD_RUB&from=2025-03-01T10:00:00Z&to=2025-03-01T12:00:00Z&interval=1h", "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var resp struct {
+		Interval string                `json:"interval"`
+		Candles  []storages.RateCandle `json:"candles"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, "1h", resp.Interval)
+	require.Len(t, resp.Candles, 2)
+	first := resp.Candles[0]
+	assert.True(t, first.Start.Equal(hour))
+	assert.Equal(t, []string{"90", "92", "89", "91"}, []string{first.Open.String(), first.High.String(), first.Low.String(), first.Close.String()})
+	assert.Equal(t, int64(4), first.Samples)
+	assert.Equal(t, "93", resp.Candles[1].Close.String())
+
+	// Курсы, полученные сервисом от exchanger, попадают в историю
+	w = serve(router, "GET", "/exchange/rates", "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	w = serve(router, "GET", "/exchange/rates/history?pair=USD_EUR&interval=1d", "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Contains(t, w.Body.String(), `"samples":1`)
+
+	for _, query := range []string{
+		"pair=USDRUB",
+		"pair=USD_XXX",
+		"pair=USD_RUB&interval=2h",
+		"pair=USD_RUB&from=2025-03-02T00:00:00Z&to=2025-03-01T00:00:00Z",
+		"pair=USD_RUB&from=2024-01-01T00:00:00Z&to=2025-01-01T00:00:00Z&interval=1m",
+	} {
+		w = serve(router, "GET", "/exchange/rates/history?"+query, "")
+		assert.Equal(t, http.StatusBadRequest, w.Code, query)
+	}
+}
@@ -2,14 +2,17 @@ package handlers
 
 import (
 	"gw-currency-wallet/internal/auth"
-	"gw-currency-wallet/internal/notifications"
 	"gw-currency-wallet/internal/storages"
+	"gw-currency-wallet/internal/wallet"
 
 	"github.com/gin-gonic/gin"
 )
 
 // SetupRoutes настраивает все маршруты приложения
-func SetupRoutes(router *gin.Engine, storage storages.Repository, authService *auth.Service, notificationService *notifications.NotificationService) {
+func SetupRoutes(router *gin.Engine, storage storages.Repository, authService *auth.Service, wallets *wallet.Service) {
+	// IP, User-Agent и id запроса для журнала аудита
+	router.Use(RequestMeta())
+
 	// Публичные маршруты
 	router.POST("/api/v1/register", Register(authService))
 	router.POST("/api/v1/login", Login(authService))
@@ -20,12 +23,75 @@ func SetupRoutes(router *gin.Engine, storage storages.Repository, authService *a
 	// Защищённые маршруты
 	protected := router.Group("/api/v1")
 	protected.Use(auth.JWTMiddleware(authService)) // middleware для JWT
+	setupProtectedRoutes(protected, storage, authService, wallets)
+
+	// Административные маршруты: доступны пользователям с users.is_admin
+	admin := protected.Group("/admin")
+	admin.Use(auth.AdminMiddleware(authService))
+	{
+		admin.GET("/audit", ListAudit(storage))
+		admin.GET("/audit/verify", VerifyAudit(storage))
+		admin.GET("/revenue", GetFeeRevenue(storage))
+	}
+}
+
+// setupProtectedRoutes регистрирует маршруты пользователя в группе, которая уже определяет userID.
+// Тесты регистрируют те же маршруты без JWT
+func setupProtectedRoutes(protected *gin.RouterGroup, storage storages.Repository, authService *auth.Service, wallets *wallet.Service) {
+	idempotent := Idempotency(storage) // повтор по Idempotency-Key не выполняет операцию дважды
+	catalog := wallets.Catalog()
 	{
-		protected.GET("/balance/:currency", GetBalance(storage))
+		protected.GET("/currencies", ListCurrencies(catalog))
+		protected.GET("/balance/:currency", GetBalance(storage, catalog))
 		protected.GET("/balance", GetTotalBalance(storage))
-		protected.POST("/exchange", Exchange(storage, authService, notificationService))
+		protected.POST("/exchange", idempotent, Exchange(wallets))
+		protected.POST("/exchange/quote", CreateQuote(wallets))
 		protected.GET("/exchange/rates", GetExchangeRates(authService))
-		protected.POST("/wallet/deposit", Deposit(storage))
-		protected.POST("/wallet/withdraw", Withdraw(storage))
+		protected.GET("/exchange/rates/history", GetRateHistory(storage, catalog))
+		protected.POST("/wallet/deposit", idempotent, Deposit(storage, wallets))
+		protected.POST("/wallet/withdraw", idempotent, Withdraw(storage, wallets))
+		protected.POST("/wallet/transfer", idempotent, Transfer(storage, wallets))
+		protected.GET("/wallets", ListWallets(storage))
+		protected.POST("/wallets", idempotent, CreateWallet(storage))
+		protected.GET("/wallets/:wallet_id", GetWallet(storage))
+		protected.GET("/wallets/:wallet_id/balance/:currency", GetBalance(storage, catalog))
+		protected.GET("/wallets/:wallet_id/transactions", ListTransactions(storage))
+		protected.POST("/wallets/:wallet_id/deposit", idempotent, Deposit(storage, wallets))
+		protected.POST("/wallets/:wallet_id/withdraw", idempotent, Withdraw(storage, wallets))
+		protected.POST("/wallets/:wallet_id/exchange", idempotent, Exchange(wallets))
+		protected.POST("/wallets/:wallet_id/exchange/quote", CreateQuote(wallets))
+		protected.POST("/wallets/:wallet_id/move", idempotent, MoveFunds(wallets))
+		protected.POST("/wallets/:wallet_id/invitations", idempotent, InviteMember(wallets))
+		protected.GET("/wallets/:wallet_id/members", ListMembers(wallets))
+		protected.PUT("/wallets/:wallet_id/members/:user_id", SetMemberRole(wallets))
+		protected.DELETE("/wallets/:wallet_id/members/:user_id", RemoveMember(wallets))
+		protected.GET("/wallets/:wallet_id/approval-rules", ListApprovalRules(wallets))
+		protected.PUT("/wallets/:wallet_id/approval-rules", SetApprovalRule(wallets))
+		protected.DELETE("/wallets/:wallet_id/approval-rules", DeleteApprovalRule(wallets))
+		protected.GET("/wallets/:wallet_id/approvals", ListApprovals(wallets))
+		protected.POST("/wallets/:wallet_id/approvals/:id/approve", idempotent, ApproveRequest(wallets))
+		protected.POST("/wallets/:wallet_id/approvals/:id/reject", idempotent, RejectRequest(wallets))
+		protected.GET("/invitations", ListInvitations(storage))
+		protected.POST("/invitations/:id/accept", AcceptInvitation(storage))
+		protected.POST("/invitations/:id/decline", DeclineInvitation(storage))
+		protected.GET("/limits", GetLimits(wallets))
+		protected.POST("/holds", idempotent, PlaceHold(storage, catalog))
+		protected.GET("/holds/:id", GetHold(storage))
+		protected.POST("/holds/:id/capture", idempotent, CaptureHold(wallets))
+		protected.POST("/holds/:id/void", idempotent, VoidHold(storage))
+		protected.POST("/orders", idempotent, PlaceOrder(storage, catalog))
+		protected.GET("/orders", ListOrders(storage))
+		protected.GET("/orders/:id", GetOrder(storage))
+		protected.POST("/orders/:id/cancel", idempotent, CancelOrder(storage))
+		protected.POST("/schedules", idempotent, CreateSchedule(storage, wallets))
+		protected.GET("/schedules", ListSchedules(storage))
+		protected.GET("/schedules/:id", GetSchedule(storage))
+		protected.GET("/schedules/:id/runs", ListScheduleRuns(storage))
+		protected.POST("/schedules/:id/pause", PauseSchedule(storage))
+		protected.POST("/schedules/:id/resume", ResumeSchedule(storage))
+		protected.DELETE("/schedules/:id", DeleteSchedule(storage))
+		protected.GET("/transactions", ListTransactions(storage))
+		protected.GET("/transactions/:id", GetTransaction(storage))
+		protected.GET("/statements", GetStatement(storage))
 	}
 }
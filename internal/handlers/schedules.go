@@ -0,0 +1,358 @@
+package handlers
+
+import (
+	"errors"
+	"gw-currency-wallet/internal/auth"
+	"gw-currency-wallet/internal/storages"
+	"gw-currency-wallet/internal/wallet"
+	"gw-currency-wallet/pkg/money"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// scheduleRunsLimit — сколько последних срабатываний отдаёт GET /schedules/:id/runs
+const scheduleRunsLimit = 50
+
+// CreateScheduleRequest — для exchange нужен to_currency, для transfer — to_user_id или to_email,
+// для move — to_wallet_id. Без wallet_id операция выполняется в кошельке по умолчанию; перевод —
+// всегда в нём. Без start_at первое срабатывание — сразу, без recurrence расписание разовое
+type CreateScheduleRequest struct {
+	Operation  storages.OperationType `json:"operation" binding:"required,oneof=deposit withdraw exchange transfer move"`
+	WalletID   int64                  `json:"wallet_id"`
+	ToWalletID int64                  `json:"to_wallet_id"`
+	Currency   string                 `json:"currency" binding:"required"`
+	ToCurrency string                 `json:"to_currency"`
+	ToUserID   int64                  `json:"to_user_id"`
+	ToEmail    string                 `json:"to_email"`
+	Amount     money.Decimal          `json:"amount" swaggertype:"number"`
+	Recurrence storages.Recurrence    `json:"recurrence" binding:"omitempty,oneof=once daily weekly monthly"`
+	StartAt    *time.Time             `json:"start_at"`
+}
+
+// @Summary Create a scheduled operation
+// @Tags schedules
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param request body CreateScheduleRequest true "Schedule"
+// @Param Idempotency-Key header string false "Key for safe retries"
+// @Success 201 {object} storages.Schedule
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /schedules [post]
+func CreateSchedule(storage storages.Repository, wallets *wallet.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := auth.GetUserID(c)
+		if !ok {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "user not found"})
+			return
+		}
+
+		var req CreateScheduleRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if !positiveAmount(c, req.Amount) {
+			return
+		}
+
+		amount, err := wallet.ValidateAmount(c.Request.Context(), wallets.Catalog(), req.Amount, req.Currency)
+		if err != nil {
+			amountError(c, err)
+			return
+		}
+
+		if req.WalletID != 0 {
+			if _, err = storage.GetWallet(c.Request.Context(), userID, req.WalletID); walletNotFound(c, err) {
+				return
+			} else if err != nil {
+				c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "failed to get wallet"})
+				return
+			}
+		}
+
+		schedule := storages.Schedule{
+			UserID:     userID,
+			Operation:  req.Operation,
+			WalletID:   req.WalletID,
+			Currency:   req.Currency,
+			Amount:     amount,
+			Recurrence: req.Recurrence,
+			StartAt:    time.Now().UTC().Truncate(time.Second),
+		}
+		if schedule.Recurrence == "" {
+			schedule.Recurrence = storages.RecurrenceOnce
+		}
+		if req.StartAt != nil {
+			schedule.StartAt = req.StartAt.UTC()
+		}
+
+		hasRecipient := req.ToUserID != 0 || req.ToEmail != ""
+		if req.ToWalletID != 0 && req.Operation != storages.OperationMove {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "to_wallet_id applies only to move"})
+			return
+		}
+		switch req.Operation {
+		case storages.OperationMove:
+			if req.ToWalletID == 0 || req.ToCurrency != "" || hasRecipient {
+				c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "move requires to_wallet_id only"})
+				return
+			}
+			to, err := storage.GetWallet(c.Request.Context(), userID, req.ToWalletID)
+			if walletNotFound(c, err) {
+				return
+			} else if err != nil {
+				c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "failed to get wallet"})
+				return
+			}
+			if req.ToWalletID == req.WalletID || req.WalletID == 0 && to.Default {
+				amountError(c, wallet.ErrSameWallet)
+				return
+			}
+			schedule.ToWalletID = req.ToWalletID
+		case storages.OperationExchange:
+			if req.ToCurrency == "" || hasRecipient {
+				c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "exchange requires to_currency only"})
+				return
+			}
+			if req.ToCurrency == req.Currency {
+				amountError(c, wallet.ErrSameCurrency)
+				return
+			}
+			if _, err = wallets.Catalog().Lookup(c.Request.Context(), req.ToCurrency); err != nil {
+				amountError(c, err)
+				return
+			}
+			schedule.ToCurrency = req.ToCurrency
+		case storages.OperationTransfer:
+			if (req.ToUserID == 0) == (req.ToEmail == "") || req.ToCurrency != "" {
+				c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "exactly one of to_user_id or to_email is required"})
+				return
+			}
+			if req.WalletID != 0 {
+				c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "transfer uses the default wallet"})
+				return
+			}
+			schedule.ToUserID = req.ToUserID
+			if req.ToEmail != "" {
+				recipient, err := storage.GetUserByEmail(c.Request.Context(), req.ToEmail)
+				if err != nil {
+					if errors.Is(err, storages.ErrUserNotFound) {
+						c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "recipient not found", "code": codeRecipientNotFound})
+						return
+					}
+					c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "failed to get recipient"})
+					return
+				}
+				schedule.ToUserID = recipient.ID
+			}
+			if schedule.ToUserID == userID {
+				c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "cannot transfer to yourself", "code": codeSelfTransfer})
+				return
+			}
+		default:
+			if req.ToCurrency != "" || hasRecipient {
+				c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "to_currency and recipient apply only to exchange and transfer"})
+				return
+			}
+		}
+
+		schedule, err = storage.CreateSchedule(c.Request.Context(), schedule)
+		if err != nil {
+			if walletNotFound(c, err) {
+				return
+			}
+			if errors.Is(err, storages.ErrUserNotFound) {
+				c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "recipient not found", "code": codeRecipientNotFound})
+				return
+			}
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "failed to create schedule"})
+			return
+		}
+		c.JSON(http.StatusCreated, schedule)
+	}
+}
+
+// @Summary List scheduled operations
+// @Tags schedules
+// @Security ApiKeyAuth
+// @Produce json
+// @Success 200 {array} storages.Schedule
+// @Failure 401 {object} map[string]string
+// @Router /schedules [get]
+func ListSchedules(storage storages.Repository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := auth.GetUserID(c)
+		if !ok {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "user not found"})
+			return
+		}
+
+		schedules, err := storage.ListSchedules(c.Request.Context(), userID)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "failed to list schedules"})
+			return
+		}
+		if schedules == nil {
+			schedules = []storages.Schedule{}
+		}
+		c.JSON(http.StatusOK, schedules)
+	}
+}
+
+// @Summary Get scheduled operation
+// @Tags schedules
+// @Security ApiKeyAuth
+// @Produce json
+// @Param id path int true "Schedule ID"
+// @Success 200 {object} storages.Schedule
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /schedules/{id} [get]
+func GetSchedule(storage storages.Repository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, scheduleID, ok := scheduleParams(c)
+		if !ok {
+			return
+		}
+
+		schedule, err := storage.GetSchedule(c.Request.Context(), userID, scheduleID)
+		if err != nil {
+			scheduleError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, schedule)
+	}
+}
+
+// @Summary List recent runs of a scheduled operation
+// @Tags schedules
+// @Security ApiKeyAuth
+// @Produce json
+// @Param id path int true "Schedule ID"
+// @Success 200 {array} storages.ScheduleRun
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /schedules/{id}/runs [get]
+func ListScheduleRuns(storage storages.Repository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, scheduleID, ok := scheduleParams(c)
+		if !ok {
+			return
+		}
+
+		runs, err := storage.ListScheduleRuns(c.Request.Context(), userID, scheduleID, scheduleRunsLimit)
+		if err != nil {
+			scheduleError(c, err)
+			return
+		}
+		if runs == nil {
+			runs = []storages.ScheduleRun{}
+		}
+		c.JSON(http.StatusOK, runs)
+	}
+}
+
+// @Summary Pause a scheduled operation
+// @Tags schedules
+// @Security ApiKeyAuth
+// @Produce json
+// @Param id path int true "Schedule ID"
+// @Success 200 {object} storages.Schedule
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /schedules/{id}/pause [post]
+func PauseSchedule(storage storages.Repository) gin.HandlerFunc {
+	return setScheduleStatus(storage, storages.SchedulePaused)
+}
+
+// @Summary Resume a paused scheduled operation
+// @Description Runs missed while paused are skipped
+// @Tags schedules
+// @Security ApiKeyAuth
+// @Produce json
+// @Param id path int true "Schedule ID"
+// @Success 200 {object} storages.Schedule
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /schedules/{id}/resume [post]
+func ResumeSchedule(storage storages.Repository) gin.HandlerFunc {
+	return setScheduleStatus(storage, storages.ScheduleActive)
+}
+
+// @Summary Delete a scheduled operation
+// @Tags schedules
+// @Security ApiKeyAuth
+// @Param id path int true "Schedule ID"
+// @Success 204
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /schedules/{id} [delete]
+func DeleteSchedule(storage storages.Repository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, scheduleID, ok := scheduleParams(c)
+		if !ok {
+			return
+		}
+
+		if err := storage.DeleteSchedule(c.Request.Context(), userID, scheduleID); err != nil {
+			scheduleError(c, err)
+			return
+		}
+		c.Status(http.StatusNoContent)
+	}
+}
+
+func setScheduleStatus(storage storages.Repository, status storages.ScheduleStatus) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, scheduleID, ok := scheduleParams(c)
+		if !ok {
+			return
+		}
+
+		schedule, err := storage.SetScheduleStatus(c.Request.Context(), userID, scheduleID, status, time.Now())
+		if err != nil {
+			scheduleError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, schedule)
+	}
+}
+
+func scheduleParams(c *gin.Context) (int64, int64, bool) {
+	userID, ok := auth.GetUserID(c)
+	if !ok {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "user not found"})
+		return 0, 0, false
+	}
+
+	scheduleID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid schedule id"})
+		return 0, 0, false
+	}
+	return userID, scheduleID, true
+}
+
+func scheduleError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, storages.ErrScheduleNotFound):
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "schedule not found"})
+	case errors.Is(err, storages.ErrScheduleCompleted):
+		c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "failed to update schedule"})
+	}
+}
@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"gw-currency-wallet/internal/storages"
+	"gw-currency-wallet/pkg/money"
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSchedulesHandler_Lifecycle(t *testing.T) {
+	storage, userID := newTestStorage(t, map[string]money.Decimal{"USD": money.New(1000, 0)})
+	router := newTestRouter(t, storage, userID)
+
+	w := serve(router, "POST", "/schedules",
+		`{"operation": "exchange", "currency": "USD", "to_currency": "EUR", "amount": 100, "recurrence": "weekly", "start_at": "2030-01-07T09:00:00Z"}`)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var schedule storages.Schedule
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &schedule))
+	assert.Equal(t, storages.ScheduleActive, schedule.Status)
+	assert.Equal(t, "2030-01-07T09:00:00Z", schedule.NextRunAt.Format("2006-01-02T15:04:05Z07:00"))
+
+	path := "/schedules/" + strconv.FormatInt(schedule.ID, 10)
+	w = serve(router, "POST", path+"/pause", "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Contains(t, w.Body.String(), `"status":"paused"`)
+
+	w = serve(router, "POST", path+"/resume", "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Contains(t, w.Body.String(), `"status":"active"`)
+
+	w = serve(router, "GET", "/schedules", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	var schedules []storages.Schedule
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &schedules))
+	assert.Len(t, schedules, 1)
+
+	w = serve(router, "GET", path+"/runs", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `[]`, w.Body.String())
+
+	w = serve(router, "DELETE", path, "")
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	w = serve(router, "GET", path, "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestSchedulesHandler_Validation(t *testing.T) {
+	storage, userID := newTestStorage(t, map[string]money.Decimal{"USD": money.New(1000, 0)})
+	router := newTestRouter(t, storage, userID)
+	savings, err := storage.CreateWallet(context.Background(), userID, "savings")
+	require.NoError(t, err)
+	savingsID := strconv.FormatInt(savings.ID, 10)
+
+	tests := []struct {
+		name string
+		body string
+		code int
+	}{
+		{"exchange without target", `{"operation": "exchange", "currency": "USD", "amount": 10}`, http.StatusBadRequest},
+		{"same currency", `{"operation": "exchange", "currency": "USD", "to_currency": "USD", "amount": 10}`, http.StatusBadRequest},
+		{"unsupported currency", `{"operation": "deposit", "currency": "XYZ", "amount": 10}`, http.StatusBadRequest},
+		{"bad recurrence", `{"operation": "deposit", "currency": "USD", "amount": 10, "recurrence": "hourly"}`, http.StatusBadRequest},
+		{"self transfer", `{"operation": "transfer", "currency": "USD", "amount": 10, "to_user_id": ` + strconv.FormatInt(userID, 10) + `}`, http.StatusBadRequest},
+		{"unknown recipient", `{"operation": "transfer", "currency": "USD", "amount": 10, "to_email": "nobody@example.com"}`, http.StatusNotFound},
+		{"deposit with recipient", `{"operation": "deposit", "currency": "USD", "amount": 10, "to_user_id": 5}`, http.StatusBadRequest},
+		{"unknown wallet", `{"operation": "deposit", "currency": "USD", "amount": 10, "wallet_id": 999}`, http.StatusNotFound},
+		{"transfer from wallet", `{"operation": "transfer", "currency": "USD", "amount": 10, "to_email": "nobody@example.com", "wallet_id": ` + savingsID + `}`, http.StatusBadRequest},
+		{"move without target", `{"operation": "move", "currency": "USD", "amount": 10}`, http.StatusBadRequest},
+		{"move to same wallet", `{"operation": "move", "currency": "USD", "amount": 10, "wallet_id": ` + savingsID + `, "to_wallet_id": ` + savingsID + `}`, http.StatusBadRequest},
+		{"move to unknown wallet", `{"operation": "move", "currency": "USD", "amount": 10, "to_wallet_id": 999}`, http.StatusNotFound},
+		{"deposit with target wallet", `{"operation": "deposit", "currency": "USD", "amount": 10, "to_wallet_id": ` + savingsID + `}`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serve(router, "POST", "/schedules", tt.body)
+			assert.Equal(t, tt.code, w.Code, w.Body.String())
+		})
+	}
+}
+
+func TestSchedulesHandler_Move(t *testing.T) {
+	storage, userID := newTestStorage(t, map[string]money.Decimal{"USD": money.New(1000, 0)})
+	router := newTestRouter(t, storage, userID)
+	savings, err := storage.CreateWallet(context.Background(), userID, "savings")
+	require.NoError(t, err)
+
+	w := serve(router, "POST", "/schedules",
+		`{"operation": "move", "currency": "USD", "amount": 100, "to_wallet_id": `+strconv.FormatInt(savings.ID, 10)+`, "recurrence": "monthly"}`)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var schedule storages.Schedule
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &schedule))
+	assert.Equal(t, storages.OperationMove, schedule.Operation)
+	assert.Zero(t, schedule.WalletID)
+	assert.Equal(t, savings.ID, schedule.ToWalletID)
+
+	w = serve(router, "POST", "/schedules",
+		`{"operation": "deposit", "currency": "USD", "amount": 100, "wallet_id": `+strconv.FormatInt(savings.ID, 10)+`}`)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &schedule))
+	assert.Equal(t, savings.ID, schedule.WalletID)
+}
@@ -0,0 +1,498 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"gw-currency-wallet/internal/auth"
+	"gw-currency-wallet/internal/storages"
+	"gw-currency-wallet/internal/wallet"
+	"gw-currency-wallet/pkg/money"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type InviteRequest struct {
+	Email string              `json:"email" binding:"required,email"`
+	Role  storages.WalletRole `json:"role" binding:"required,oneof=owner spender viewer" swaggertype:"string"`
+}
+
+type MemberRoleRequest struct {
+	Role storages.WalletRole `json:"role" binding:"required,oneof=owner spender viewer" swaggertype:"string"`
+}
+
+type ApprovalRuleRequest struct {
+	Operation storages.OperationType `json:"operation" binding:"required,oneof=withdraw exchange move" swaggertype:"string"`
+	Currency  string                 `json:"currency" binding:"required"`
+	Threshold money.Decimal          `json:"threshold" swaggertype:"number"`
+}
+
+const (
+	codeRoleForbidden    = "wallet_role_forbidden"
+	codeApprovalRequired = "approval_required"
+	codeSelfApproval     = "self_approval"
+)
+
+// @Summary Invite a user to a shared wallet by email
+// @Tags sharing
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param wallet_id path int true "Wallet ID"
+// @Param request body InviteRequest true "Invitation"
+// @Param Idempotency-Key header string false "Key for safe retries"
+// @Success 201 {object} storages.WalletInvitation
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /wallets/{wallet_id}/invitations [post]
+func InviteMember(wallets *wallet.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, walletID, ok := sharingParams(c)
+		if !ok {
+			return
+		}
+
+		var req InviteRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		invitation, err := wallets.Invite(c.Request.Context(), userID, walletID, req.Email, req.Role)
+		if err != nil {
+			sharingError(c, err)
+			return
+		}
+		c.JSON(http.StatusCreated, invitation)
+	}
+}
+
+// @Summary List pending invitations to my email
+// @Tags sharing
+// @Security ApiKeyAuth
+// @Produce json
+// @Success 200 {array} storages.WalletInvitation
+// @Failure 401 {object} map[string]string
+// @Router /invitations [get]
+func ListInvitations(storage storages.Repository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := auth.GetUserID(c)
+		if !ok {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "user not found"})
+			return
+		}
+
+		invitations, err := storage.ListInvitations(c.Request.Context(), userID)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "failed to list invitations"})
+			return
+		}
+		if invitations == nil {
+			invitations = []storages.WalletInvitation{}
+		}
+		c.JSON(http.StatusOK, invitations)
+	}
+}
+
+// @Summary Accept an invitation
+// @Tags sharing
+// @Security ApiKeyAuth
+// @Produce json
+// @Param id path int true "Invitation ID"
+// @Success 200 {object} storages.WalletInvitation
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /invitations/{id}/accept [post]
+func AcceptInvitation(storage storages.Repository) gin.HandlerFunc {
+	return respondInvitation(storage, true)
+}
+
+// @Summary Decline an invitation
+// @Tags sharing
+// @Security ApiKeyAuth
+// @Produce json
+// @Param id path int true "Invitation ID"
+// @Success 200 {object} storages.WalletInvitation
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /invitations/{id}/decline [post]
+func DeclineInvitation(storage storages.Repository) gin.HandlerFunc {
+	return respondInvitation(storage, false)
+}
+
+func respondInvitation(storage storages.Repository, accept bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := auth.GetUserID(c)
+		if !ok {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "user not found"})
+			return
+		}
+		invitationID, ok := idParam(c, "id", "invalid invitation id")
+		if !ok {
+			return
+		}
+
+		invitation, err := storage.RespondInvitation(c.Request.Context(), userID, invitationID, accept)
+		if err != nil {
+			sharingError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, invitation)
+	}
+}
+
+// @Summary List wallet members
+// @Tags sharing
+// @Security ApiKeyAuth
+// @Produce json
+// @Param wallet_id path int true "Wallet ID"
+// @Success 200 {array} storages.WalletMember
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /wallets/{wallet_id}/members [get]
+func ListMembers(wallets *wallet.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, walletID, ok := sharingParams(c)
+		if !ok {
+			return
+		}
+
+		members, err := wallets.Members(c.Request.Context(), userID, walletID)
+		if err != nil {
+			sharingError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, members)
+	}
+}
+
+// @Summary Change a member's role
+// @Tags sharing
+// @Security ApiKeyAuth
+// @Accept json
+// @Param wallet_id path int true "Wallet ID"
+// @Param user_id path int true "Member user ID"
+// @Param request body MemberRoleRequest true "New role"
+// @Success 204
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /wallets/{wallet_id}/members/{user_id} [put]
+func SetMemberRole(wallets *wallet.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, walletID, ok := sharingParams(c)
+		if !ok {
+			return
+		}
+		memberID, ok := idParam(c, "user_id", "invalid user id")
+		if !ok {
+			return
+		}
+
+		var req MemberRoleRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if err := wallets.SetMemberRole(c.Request.Context(), userID, walletID, memberID, req.Role); err != nil {
+			sharingError(c, err)
+			return
+		}
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// @Summary Remove a member or leave a wallet
+// @Tags sharing
+// @Security ApiKeyAuth
+// @Param wallet_id path int true "Wallet ID"
+// @Param user_id path int true "Member user ID; own ID to leave"
+// @Success 204
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /wallets/{wallet_id}/members/{user_id} [delete]
+func RemoveMember(wallets *wallet.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, walletID, ok := sharingParams(c)
+		if !ok {
+			return
+		}
+		memberID, ok := idParam(c, "user_id", "invalid user id")
+		if !ok {
+			return
+		}
+
+		if err := wallets.RemoveMember(c.Request.Context(), userID, walletID, memberID); err != nil {
+			sharingError(c, err)
+			return
+		}
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// @Summary List approval rules
+// @Tags sharing
+// @Security ApiKeyAuth
+// @Produce json
+// @Param wallet_id path int true "Wallet ID"
+// @Success 200 {array} storages.ApprovalRule
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /wallets/{wallet_id}/approval-rules [get]
+func ListApprovalRules(wallets *wallet.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, walletID, ok := sharingParams(c)
+		if !ok {
+			return
+		}
+
+		rules, err := wallets.ApprovalRules(c.Request.Context(), userID, walletID)
+		if err != nil {
+			sharingError(c, err)
+			return
+		}
+		if rules == nil {
+			rules = []storages.ApprovalRule{}
+		}
+		c.JSON(http.StatusOK, rules)
+	}
+}
+
+// @Summary Set an approval rule
+// @Description Operations of this type and currency above threshold wait for another member's approval
+// @Tags sharing
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param wallet_id path int true "Wallet ID"
+// @Param request body ApprovalRuleRequest true "Rule"
+// @Success 200 {object} storages.ApprovalRule
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /wallets/{wallet_id}/approval-rules [put]
+func SetApprovalRule(wallets *wallet.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, walletID, ok := sharingParams(c)
+		if !ok {
+			return
+		}
+
+		var req ApprovalRuleRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if !positiveAmount(c, req.Threshold) {
+			return
+		}
+
+		rule, err := wallets.SetApprovalRule(c.Request.Context(), userID, walletID, storages.ApprovalRule{
+			Operation: req.Operation,
+			Currency:  req.Currency,
+			Threshold: req.Threshold,
+		})
+		if err != nil {
+			sharingError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, rule)
+	}
+}
+
+// @Summary Delete an approval rule
+// @Tags sharing
+// @Security ApiKeyAuth
+// @Param wallet_id path int true "Wallet ID"
+// @Param operation query string true "withdraw, exchange or move"
+// @Param currency query string true "Currency code"
+// @Success 204
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /wallets/{wallet_id}/approval-rules [delete]
+func DeleteApprovalRule(wallets *wallet.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, walletID, ok := sharingParams(c)
+		if !ok {
+			return
+		}
+
+		operation, currency := storages.OperationType(c.Query("operation")), c.Query("currency")
+		if operation == "" || currency == "" {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "operation and currency are required"})
+			return
+		}
+
+		if err := wallets.DeleteApprovalRule(c.Request.Context(), userID, walletID, operation, currency); err != nil {
+			sharingError(c, err)
+			return
+		}
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// @Summary List approval requests
+// @Tags sharing
+// @Security ApiKeyAuth
+// @Produce json
+// @Param wallet_id path int true "Wallet ID"
+// @Success 200 {array} storages.Approval
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /wallets/{wallet_id}/approvals [get]
+func ListApprovals(wallets *wallet.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, walletID, ok := sharingParams(c)
+		if !ok {
+			return
+		}
+
+		approvals, err := wallets.Approvals(c.Request.Context(), userID, walletID)
+		if err != nil {
+			sharingError(c, err)
+			return
+		}
+		if approvals == nil {
+			approvals = []storages.Approval{}
+		}
+		c.JSON(http.StatusOK, approvals)
+	}
+}
+
+// @Summary Approve a request and execute the operation
+// @Description The operation runs at the current rate and balance; if it fails, the request becomes failed
+// @Tags sharing
+// @Security ApiKeyAuth
+// @Produce json
+// @Param wallet_id path int true "Wallet ID"
+// @Param id path int true "Approval ID"
+// @Param Idempotency-Key header string false "Key for safe retries"
+// @Success 200 {object} storages.Approval
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /wallets/{wallet_id}/approvals/{id}/approve [post]
+func ApproveRequest(wallets *wallet.Service) gin.HandlerFunc {
+	return decideApproval(wallets.Approve)
+}
+
+// @Summary Reject a request
+// @Description The requester can reject their own request to cancel it
+// @Tags sharing
+// @Security ApiKeyAuth
+// @Produce json
+// @Param wallet_id path int true "Wallet ID"
+// @Param id path int true "Approval ID"
+// @Success 200 {object} storages.Approval
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /wallets/{wallet_id}/approvals/{id}/reject [post]
+func RejectRequest(wallets *wallet.Service) gin.HandlerFunc {
+	return decideApproval(wallets.Reject)
+}
+
+func decideApproval(decide func(ctx context.Context, userID, walletID, approvalID int64) (storages.Approval, error)) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, walletID, ok := sharingParams(c)
+		if !ok {
+			return
+		}
+		approvalID, ok := idParam(c, "id", "invalid approval id")
+		if !ok {
+			return
+		}
+
+		approval, err := decide(c.Request.Context(), userID, walletID, approvalID)
+		if err != nil {
+			sharingError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, approval)
+	}
+}
+
+func sharingParams(c *gin.Context) (int64, int64, bool) {
+	userID, ok := auth.GetUserID(c)
+	if !ok {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "user not found"})
+		return 0, 0, false
+	}
+	walletID, ok := walletParam(c)
+	return userID, walletID, ok
+}
+
+func idParam(c *gin.Context, name, message string) (int64, bool) {
+	id, err := strconv.ParseInt(c.Param(name), 10, 64)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": message})
+		return 0, false
+	}
+	return id, true
+}
+
+// roleForbidden отвечает 403, если роли пользователя в кошельке недостаточно для операции
+func roleForbidden(c *gin.Context, err error) bool {
+	if !errors.Is(err, wallet.ErrRoleForbidden) {
+		return false
+	}
+	c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": err.Error(), "code": codeRoleForbidden})
+	return true
+}
+
+// approvalRequired отвечает 202 с созданным запросом, если операция ждёт подтверждения
+func approvalRequired(c *gin.Context, err error) bool {
+	var approvalErr *wallet.ApprovalRequiredError
+	if !errors.As(err, &approvalErr) {
+		return false
+	}
+	c.JSON(http.StatusAccepted, gin.H{
+		"message":  "Operation requires approval by another member",
+		"code":     codeApprovalRequired,
+		"approval": approvalErr.Approval,
+	})
+	return true
+}
+
+func sharingError(c *gin.Context, err error) {
+	switch {
+	case wallet.IsValidationError(err):
+		amountError(c, err)
+	case walletNotFound(c, err), roleForbidden(c, err):
+	case errors.Is(err, storages.ErrSelfApproval):
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": err.Error(), "code": codeSelfApproval})
+	case errors.Is(err, storages.ErrMemberExists),
+		errors.Is(err, storages.ErrInvitationNotOpen),
+		errors.Is(err, storages.ErrApprovalNotPending):
+		c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, storages.ErrMemberNotFound),
+		errors.Is(err, storages.ErrInvitationNotFound),
+		errors.Is(err, storages.ErrApprovalRuleMissing),
+		errors.Is(err, storages.ErrApprovalNotFound):
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	default:
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "failed to update wallet sharing"})
+	}
+}
@@ -0,0 +1,133 @@
+package handlers
+
+import (
+	"encoding/json"
+	"gw-currency-wallet/internal/storages"
+	"gw-currency-wallet/pkg/money"
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// joinWallet приглашает email в кошелёк от имени owner и принимает приглашение от имени member
+func joinWallet(t *testing.T, owner, member *gin.Engine, path, email, role string) {
+	t.Helper()
+	w := serve(owner, "POST", path+"/invitations", `{"email": "`+email+`", "role": "`+role+`"}`)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+
+	w = serve(member, "GET", "/invitations", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	var invitations []storages.WalletInvitation
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &invitations))
+	require.Len(t, invitations, 1)
+
+	w = serve(member, "POST", "/invitations/"+strconv.FormatInt(invitations[0].ID, 10)+"/accept", "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+}
+
+func TestSharingHandler_RolesAndApprovals(t *testing.T) {
+	storage, ownerID := newTestStorage(t, nil)
+	partnerID, err := storage.CreateUser(t.Context(), "partner@example.com", "hash")
+	require.NoError(t, err)
+	kidID, err := storage.CreateUser(t.Context(), "kid@example.com", "hash")
+	require.NoError(t, err)
+	family, err := storage.CreateWallet(t.Context(), ownerID, "Family")
+	require.NoError(t, err)
+	_, err = storage.PostTransaction(t.Context(), ownerID, storages.OperationDeposit,
+		storages.Posting{WalletID: family.ID, Currency: "USD", Amount: money.New(100, 0)})
+	require.NoError(t, err)
+
+	owner := newTestRouter(t, storage, ownerID)
+	partner := newTestRouter(t, storage, partnerID)
+	kid := newTestRouter(t, storage, kidID)
+	path := "/wallets/" + strconv.FormatInt(family.ID, 10)
+
+	joinWallet(t, owner, partner, path, "partner@example.com", "spender")
+	joinWallet(t, owner, kid, path, "kid@example.com", "viewer")
+	w := serve(owner, "POST", path+"/invitations", `{"email": "kid@example.com", "role": "viewer"}`)
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	w = serve(partner, "GET", "/wallets", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"name":"Family","default":false,"created_at"`)
+	assert.Contains(t, w.Body.String(), `"role":"spender"`)
+
+	// viewer видит баланс, но не может списывать и приглашать
+	w = serve(kid, "GET", path+"/balance/USD", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"total":100.00`)
+	w = serve(kid, "POST", path+"/withdraw", `{"currency": "USD", "amount": 1}`)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Contains(t, w.Body.String(), `"code":"wallet_role_forbidden"`)
+	w = serve(kid, "POST", path+"/invitations", `{"email": "friend@example.com", "role": "viewer"}`)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	w = serve(partner, "PUT", path+"/approval-rules", `{"operation": "withdraw", "currency": "USD", "threshold": 30}`)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	w = serve(owner, "PUT", path+"/approval-rules", `{"operation": "withdraw", "currency": "USD", "threshold": 30}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	// Сумма не выше порога списывается сразу, выше — ждёт подтверждения второго участника
+	w = serve(partner, "POST", path+"/withdraw", `{"currency": "USD", "amount": 20}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	w = serve(partner, "POST", path+"/withdraw", `{"currency": "USD", "amount": 50}`)
+	require.Equal(t, http.StatusAccepted, w.Code, w.Body.String())
+	var pending struct {
+		Code     string            `json:"code"`
+		Approval storages.Approval `json:"approval"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &pending))
+	assert.Equal(t, codeApprovalRequired, pending.Code)
+	assert.Equal(t, storages.ApprovalPending, pending.Approval.Status)
+	approve := path + "/approvals/" + strconv.FormatInt(pending.Approval.ID, 10) + "/approve"
+
+	w = serve(owner, "GET", path+"/balance/USD", "")
+	assert.Contains(t, w.Body.String(), `"total":80.00`)
+
+	w = serve(partner, "POST", approve, "")
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Contains(t, w.Body.String(), `"code":"self_approval"`)
+	w = serve(kid, "POST", approve, "")
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	w = serve(owner, "POST", approve, "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var approved storages.Approval
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &approved))
+	assert.Equal(t, storages.ApprovalApproved, approved.Status)
+	assert.Equal(t, ownerID, approved.DecidedBy)
+	assert.NotZero(t, approved.TransactionID)
+	w = serve(owner, "POST", approve, "")
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	w = serve(owner, "GET", path+"/balance/USD", "")
+	assert.Contains(t, w.Body.String(), `"total":30.00`)
+
+	// Участник видит все операции по общему кошельку, в том числе проведённые другими
+	w = serve(kid, "GET", path+"/transactions?type=withdraw", "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var history TransactionsResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &history))
+	assert.Len(t, history.Transactions, 2)
+	for _, txn := range history.Transactions {
+		for _, e := range txn.Entries {
+			assert.Equal(t, family.ID, e.WalletID)
+		}
+	}
+	stranger, err := storage.CreateUser(t.Context(), "stranger@example.com", "hash")
+	require.NoError(t, err)
+	w = serve(newTestRouter(t, storage, stranger), "GET", path+"/transactions", "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w = serve(kid, "GET", path+"/members", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	var members []storages.WalletMember
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &members))
+	require.Len(t, members, 3)
+	assert.Equal(t, ownerID, members[0].UserID)
+	assert.Equal(t, storages.RoleOwner, members[0].Role)
+}
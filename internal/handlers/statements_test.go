@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"context"
+	"encoding/csv"
+	"gw-currency-wallet/pkg/money"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newStatementsRouter(t *testing.T) (*gin.Engine, time.Time) {
+	t.Helper()
+	storage, userID := newTestStorage(t, nil)
+	before, err := storage.Credit(context.Background(), userID, "USD", money.New(100, 0))
+	require.NoError(t, err)
+	_, err = storage.Debit(context.Background(), userID, "USD", money.New(30, 0))
+	require.NoError(t, err)
+	_, err = storage.Credit(context.Background(), userID, "EUR", money.New(5, 0))
+	require.NoError(t, err)
+
+	return newTestRouter(t, storage, userID), before.CreatedAt.Add(time.Nanosecond)
+}
+
+func TestGetStatement_CSV(t *testing.T) {
+	router, from := newStatementsRouter(t)
+
+	w := httptest.NewRecorder()
+	query := url.Values{"from": {from.UTC().Format(time.RFC3339Nano)}, "format": {"csv"}, "currency": {"USD"}}
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/statements?"+query.Encode(), nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "text/csv; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.True(t, strings.HasPrefix(w.Header().Get("Content-Disposition"), "attachment;"))
+
+	records, err := csv.NewReader(w.Body).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, records, 4)
+	// Пополнение до начала периода вошло во входящий остаток
+	assert.Equal(t, []string{"opening_balance", "100.00"}, []string{records[1][3], records[1][6]})
+	assert.Equal(t, []string{"withdraw", "-30.00", "70.00"}, []string{records[2][3], records[2][5], records[2][6]})
+	assert.Equal(t, []string{"closing_balance", "70.00"}, []string{records[3][3], records[3][6]})
+}
+
+func TestGetStatement_InvalidQuery(t *testing.T) {
+	router, _ := newStatementsRouter(t)
+
+	for _, query := range []string{
+		"",
+		"?from=yesterday",
+		"?from=2026-01-01T00:00:00Z&format=pdf",
+		"?from=2026-02-01T00:00:00Z&to=2026-01-01T00:00:00Z",
+	} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", "/statements"+query, nil))
+		assert.Equal(t, http.StatusBadRequest, w.Code, query)
+	}
+}
@@ -0,0 +1,169 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"errors"
+	"gw-currency-wallet/internal/auth"
+	"gw-currency-wallet/internal/storages"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+type TransactionsQuery struct {
+	Currency string    `form:"currency"`
+	Type     string    `form:"type" binding:"omitempty,oneof=deposit withdraw exchange transfer capture move"`
+	From     time.Time `form:"from" time_format:"2006-01-02T15:04:05Z07:00"`
+	To       time.Time `form:"to" time_format:"2006-01-02T15:04:05Z07:00"`
+	Cursor   string    `form:"cursor"`
+	Limit    int       `form:"limit" binding:"omitempty,min=1,max=100"`
+}
+
+type TransactionsResponse struct {
+	Transactions []storages.Transaction `json:"transactions"`
+	NextCursor   string                 `json:"next_cursor,omitempty"`
+}
+
+// @Summary Get wallet transaction history
+// @Description Without wallet_id — operations made by the user. With wallet_id — every operation on that wallet,
+// @Description available to its owner and members
+// @Tags wallet
+// @Security ApiKeyAuth
+// @Produce json
+// @Param wallet_id path int false "Wallet ID, only in /wallets/{wallet_id}/transactions"
+// @Param currency query string false "Currency code"
+// @Param type query string false "Operation type" Enums(deposit, withdraw, exchange, transfer, capture, move)
+// @Param from query string false "Start of period, RFC 3339 (inclusive)"
+// @Param to query string false "End of period, RFC 3339 (exclusive)"
+// @Param cursor query string false "Cursor from the previous page"
+// @Param limit query int false "Page size (1-100, default 20)"
+// @Success 200 {object} TransactionsResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /transactions [get]
+// @Router /wallets/{wallet_id}/transactions [get]
+func ListTransactions(storage storages.Repository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := auth.GetUserID(c)
+		if !ok {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "user not found"})
+			return
+		}
+		walletID, ok := walletParam(c)
+		if !ok {
+			return
+		}
+
+		var query TransactionsQuery
+		if err := c.ShouldBindQuery(&query); err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		beforeID, err := decodeCursor(query.Cursor)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid cursor"})
+			return
+		}
+
+		limit := query.Limit
+		if limit == 0 {
+			limit = defaultPageSize
+		}
+
+		// Запрашиваем на одну запись больше, чтобы узнать, есть ли следующая страница
+		txns, err := storage.ListTransactions(c.Request.Context(), userID, storages.TransactionFilter{
+			Currency: query.Currency,
+			Type:     storages.OperationType(query.Type),
+			From:     query.From,
+			To:       query.To,
+			BeforeID: beforeID,
+			Limit:    limit + 1,
+			WalletID: walletID,
+		})
+		if err != nil {
+			if !walletNotFound(c, err) {
+				c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "failed to get transactions"})
+			}
+			return
+		}
+
+		resp := TransactionsResponse{Transactions: txns}
+		if len(txns) > limit {
+			resp.Transactions = txns[:limit]
+			resp.NextCursor = encodeCursor(txns[limit-1].ID)
+		}
+		if resp.Transactions == nil {
+			resp.Transactions = []storages.Transaction{}
+		}
+
+		c.JSON(http.StatusOK, resp)
+	}
+}
+
+// @Summary Get wallet transaction details
+// @Description Own operations come with all entries; an operation on a shared wallet is visible to its members with the entries of wallets they can access.
+// @Tags wallet
+// @Security ApiKeyAuth
+// @Produce json
+// @Param id path int true "Transaction ID"
+// @Success 200 {object} storages.Transaction
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /transactions/{id} [get]
+func GetTransaction(storage storages.Repository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := auth.GetUserID(c)
+		if !ok {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "user not found"})
+			return
+		}
+
+		transactionID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid transaction id"})
+			return
+		}
+
+		txn, err := storage.GetTransaction(c.Request.Context(), userID, transactionID)
+		if err != nil {
+			if errors.Is(err, storages.ErrTransactionNotFound) {
+				c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "transaction not found"})
+				return
+			}
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "failed to get transaction"})
+			return
+		}
+
+		c.JSON(http.StatusOK, txn)
+	}
+}
+
+// Курсор непрозрачен для клиента: это id последней выданной операции
+func encodeCursor(id int64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatInt(id, 10)))
+}
+
+func decodeCursor(cursor string) (int64, error) {
+	if cursor == "" {
+		return 0, nil
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, err
+	}
+	id, err := strconv.ParseInt(string(raw), 10, 64)
+	if err != nil || id <= 0 {
+		return 0, errors.New("invalid cursor")
+	}
+	return id, nil
+}
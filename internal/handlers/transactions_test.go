@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"gw-currency-wallet/internal/storages"
+	"gw-currency-wallet/pkg/money"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListTransactions_Pagination(t *testing.T) {
+	storage, userID := newTestStorage(t, nil)
+	var want []int64
+	for i := 0; i < 5; i++ {
+		txn, err := storage.Credit(context.Background(), userID, "USD", money.New(10, 0))
+		require.NoError(t, err)
+		want = append([]int64{txn.ID}, want...)
+	}
+	router := newTestRouter(t, storage, userID)
+
+	var seen []int64
+	cursor := ""
+	for {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", "/transactions?limit=2&cursor="+cursor, nil))
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var resp TransactionsResponse
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		for _, txn := range resp.Transactions {
+			seen = append(seen, txn.ID)
+		}
+		if resp.NextCursor == "" {
+			break
+		}
+		cursor = resp.NextCursor
+	}
+
+	assert.Equal(t, want, seen)
+}
+
+func TestListTransactions_InvalidQuery(t *testing.T) {
+	storage, userID := newTestStorage(t, nil)
+	router := newTestRouter(t, storage, userID)
+
+	for _, query := range []string{"?type=refund", "?cursor=!!", "?limit=1000", "?from=yesterday"} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", "/transactions"+query, nil))
+		assert.Equal(t, http.StatusBadRequest, w.Code, query)
+	}
+}
+
+func TestGetTransaction_NotFound(t *testing.T) {
+	storage, userID := newTestStorage(t, nil)
+	otherID, err := storage.CreateUser(context.Background(), "other@example.com", "hash")
+	require.NoError(t, err)
+	txn, err := storage.Credit(context.Background(), otherID, "USD", money.New(10, 0))
+	require.NoError(t, err)
+
+	router := newTestRouter(t, storage, userID)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/transactions/"+strconv.FormatInt(txn.ID, 10), nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestGetTransaction_WalletMember(t *testing.T) {
+	storage, ownerID := newTestStorage(t, nil)
+	memberID, err := storage.CreateUser(context.Background(), "member@example.com", "hash")
+	require.NoError(t, err)
+	family, err := storage.CreateWallet(context.Background(), ownerID, "Family")
+	require.NoError(t, err)
+	shared, err := storage.PostTransaction(context.Background(), ownerID, storages.OperationDeposit,
+		storages.Posting{WalletID: family.ID, Currency: "USD", Amount: money.New(100, 0)})
+	require.NoError(t, err)
+	private, err := storage.Credit(context.Background(), ownerID, "USD", money.New(10, 0))
+	require.NoError(t, err)
+
+	owner := newTestRouter(t, storage, ownerID)
+	member := newTestRouter(t, storage, memberID)
+	path := "/wallets/" + strconv.FormatInt(family.ID, 10)
+	joinWallet(t, owner, member, path, "member@example.com", "viewer")
+
+	// Операция из истории общего кошелька открывается и по id — с проводками только по нему
+	w := serve(member, "GET", path+"/transactions", "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Contains(t, w.Body.String(), `"id":`+strconv.FormatInt(shared.ID, 10)+`,`)
+
+	w = serve(member, "GET", "/transactions/"+strconv.FormatInt(shared.ID, 10), "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var txn storages.Transaction
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &txn))
+	assert.Equal(t, shared.ID, txn.ID)
+	require.Len(t, txn.Entries, 1)
+	assert.Equal(t, family.ID, txn.Entries[0].WalletID)
+
+	// Операция по кошельку, к которому у участника нет доступа, не видна
+	w = serve(member, "GET", "/transactions/"+strconv.FormatInt(private.ID, 10), "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
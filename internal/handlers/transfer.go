@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"errors"
+	"gw-currency-wallet/internal/auth"
+	"gw-currency-wallet/internal/storages"
+	"gw-currency-wallet/internal/wallet"
+	"gw-currency-wallet/pkg/money"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TransferRequest — получатель задаётся либо to_user_id, либо to_email
+type TransferRequest struct {
+	ToUserID int64         `json:"to_user_id"`
+	ToEmail  string        `json:"to_email"`
+	Currency string        `json:"currency" binding:"required"`
+	Amount   money.Decimal `json:"amount" swaggertype:"number"`
+}
+
+// Коды ошибок перевода — для клиентов, которым мало HTTP-статуса
+const (
+	codeSelfTransfer      = "self_transfer"
+	codeRecipientNotFound = "recipient_not_found"
+	codeInsufficientFunds = "insufficient_funds"
+)
+
+// @Summary Transfer funds to another user
+// @Tags wallet
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param request body TransferRequest true "Transfer request"
+// @Param Idempotency-Key header string false "Key for safe retries"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /wallet/transfer [post]
+func Transfer(storage storages.Repository, wallets *wallet.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := auth.GetUserID(c)
+		if !ok {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "user not found"})
+			return
+		}
+
+		var req TransferRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if !positiveAmount(c, req.Amount) {
+			return
+		}
+		if (req.ToUserID == 0) == (req.ToEmail == "") {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "exactly one of to_user_id or to_email is required"})
+			return
+		}
+
+		// Сумму проверяем до поиска получателя, чтобы не раскрывать наличие email при ошибочном запросе
+		if _, err := wallet.ValidateAmount(c.Request.Context(), wallets.Catalog(), req.Amount, req.Currency); err != nil {
+			amountError(c, err)
+			return
+		}
+
+		recipientID := req.ToUserID
+		if req.ToEmail != "" {
+			recipient, err := storage.GetUserByEmail(c.Request.Context(), req.ToEmail)
+			if err != nil {
+				if errors.Is(err, storages.ErrUserNotFound) {
+					c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "recipient not found", "code": codeRecipientNotFound})
+					return
+				}
+				c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "failed to get recipient"})
+				return
+			}
+			recipientID = recipient.ID
+		}
+
+		// Списание у отправителя и зачисление получателю выполняются в одной транзакции
+		txn, err := wallets.Transfer(c.Request.Context(), userID, recipientID, req.Currency, req.Amount)
+		if err != nil {
+			switch {
+			case wallet.IsValidationError(err):
+				amountError(c, err)
+			case limitExceeded(c, err), accountFrozen(c, err):
+			case errors.Is(err, storages.ErrSelfTransfer):
+				c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "cannot transfer to yourself", "code": codeSelfTransfer})
+			case errors.Is(err, storages.ErrUserNotFound):
+				c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "recipient not found", "code": codeRecipientNotFound})
+			case errors.Is(err, storages.ErrInsufficientFunds), errors.Is(err, storages.ErrBalanceNotFound):
+				c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "insufficient funds", "code": codeInsufficientFunds})
+			case errors.Is(err, money.ErrOverflow):
+				c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "recipient balance limit exceeded"})
+			default:
+				c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "failed to transfer funds"})
+			}
+			return
+		}
+
+		balances, _ := storage.GetAllBalances(c.Request.Context(), userID)
+		c.JSON(http.StatusOK, gin.H{
+			"message":        "Transfer successful",
+			"transaction_id": txn.ID,
+			"to_user_id":     recipientID,
+			"new_balance":    balances,
+		})
+	}
+}
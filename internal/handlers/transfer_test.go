@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"gw-currency-wallet/internal/storages"
+	"gw-currency-wallet/pkg/money"
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTransferHandler_Success(t *testing.T) {
+	storage, senderID := newTestStorage(t, map[string]money.Decimal{"USD": money.New(100, 0)})
+	recipientID, err := storage.CreateUser(context.Background(), "recipient@example.com", "hash")
+	require.NoError(t, err)
+	router := newTestRouter(t, storage, senderID)
+
+	w := serve(router, "POST", "/wallet/transfer", `{"to_email": "recipient@example.com", "currency": "USD", "amount": 30.25}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	sender, _ := storage.GetBalance(context.Background(), senderID, "USD")
+	recipient, _ := storage.GetBalance(context.Background(), recipientID, "USD")
+	assert.Equal(t, "69.75", sender.String())
+	assert.Equal(t, "30.25", recipient.String())
+
+	// Перевод виден в истории обоих участников
+	for userID, counterpartyID := range map[int64]int64{senderID: recipientID, recipientID: senderID} {
+		txns, err := storage.ListTransactions(context.Background(), userID, storages.TransactionFilter{
+			Type:  storages.OperationTransfer,
+			Limit: 10,
+		})
+		require.NoError(t, err)
+		require.Len(t, txns, 1)
+		assert.Equal(t, counterpartyID, txns[0].CounterpartyID)
+	}
+
+	w = serve(router, "POST", "/wallet/transfer", `{"to_user_id": `+strconv.FormatInt(recipientID, 10)+`, "currency": "USD", "amount": 9.75}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestTransferHandler_Errors(t *testing.T) {
+	storage, senderID := newTestStorage(t, map[string]money.Decimal{"USD": money.New(10, 0)})
+	_, err := storage.CreateUser(context.Background(), "recipient@example.com", "hash")
+	require.NoError(t, err)
+	router := newTestRouter(t, storage, senderID)
+
+	for _, tc := range []struct {
+		body   string
+		status int
+		code   string
+	}{
+		{`{"to_user_id": ` + strconv.FormatInt(senderID, 10) + `, "currency": "USD", "amount": 1}`, http.StatusBadRequest, codeSelfTransfer},
+		{`{"to_email": "test@example.com", "currency": "USD", "amount": 1}`, http.StatusBadRequest, codeSelfTransfer},
+		{`{"to_email": "nobody@example.com", "currency": "USD", "amount": 1}`, http.StatusNotFound, codeRecipientNotFound},
+		{`{"to_user_id": 999, "currency": "USD", "amount": 1}`, http.StatusNotFound, codeRecipientNotFound},
+		{`{"to_email": "recipient@example.com", "currency": "USD", "amount": 11}`, http.StatusBadRequest, codeInsufficientFunds},
+		{`{"to_email": "recipient@example.com", "to_user_id": 2, "currency": "USD", "amount": 1}`, http.StatusBadRequest, ""},
+		{`{"to_email": "recipient@example.com", "currency": "XXX", "amount": 1}`, http.StatusBadRequest, ""},
+	} {
+		w := serve(router, "POST", "/wallet/transfer", tc.body)
+		assert.Equal(t, tc.status, w.Code, tc.body)
+
+		var resp map[string]string
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		assert.Equal(t, tc.code, resp["code"], tc.body)
+	}
+
+	// Ни одна из ошибочных попыток не изменила баланс
+	balance, _ := storage.GetBalance(context.Background(), senderID, "USD")
+	assert.Equal(t, "10.00", balance.String())
+}
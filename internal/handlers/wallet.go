@@ -1,16 +1,49 @@
 package handlers
 
 import (
+	"errors"
 	"gw-currency-wallet/internal/auth"
 	"gw-currency-wallet/internal/storages"
+	"gw-currency-wallet/internal/wallet"
+	"gw-currency-wallet/pkg/money"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
 )
 
 type WalletOperation struct {
-	Amount   float32 `json:"amount" binding:"required,gt=0"`
-	Currency string  `json:"currency" binding:"required,oneof=USD RUB EUR"`
+	Amount   money.Decimal `json:"amount" swaggertype:"number"`
+	Currency string        `json:"currency" binding:"required"`
+}
+
+// amountError отвечает 400 на ошибку проверки суммы и 500, если справочник недоступен
+func amountError(c *gin.Context, err error) {
+	if wallet.IsValidationError(err) {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "failed to load currencies"})
+}
+
+// positiveAmount отвечает 400, если сумма не указана или не больше нуля. binding:"required"
+// на money.Decimal ничего не проверяет: валидатор не применяет его к полям-структурам
+func positiveAmount(c *gin.Context, amount money.Decimal) bool {
+	if amount.Sign() > 0 {
+		return true
+	}
+	amountError(c, wallet.ErrNonPositiveAmount)
+	return false
+}
+
+const codeAccountFrozen = "account_frozen"
+
+// accountFrozen отвечает 403, если счёт операции заморожен сверкой балансов
+func accountFrozen(c *gin.Context, err error) bool {
+	if !errors.Is(err, storages.ErrAccountFrozen) {
+		return false
+	}
+	c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "account is frozen", "code": codeAccountFrozen})
+	return true
 }
 
 // @Summary Deposit funds to wallet
@@ -18,31 +51,52 @@ type WalletOperation struct {
 // @Security ApiKeyAuth
 // @Accept json
 // @Produce json
+// @Param wallet_id path int false "Wallet ID, only in /wallets/{wallet_id}/deposit; default wallet otherwise"
 // @Param request body WalletOperation true "Deposit request"
+// @Param Idempotency-Key header string false "Key for safe retries"
 // @Success 200 {object} map[string]interface{}
 // @Failure 400 {object} map[string]string
 // @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]interface{}
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
 // @Router /wallet/deposit [post]
-func Deposit(storage storages.Repository) gin.HandlerFunc {
+// @Router /wallets/{wallet_id}/deposit [post]
+func Deposit(storage storages.Repository, wallets *wallet.Service) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		userID, _ := auth.GetUserID(c)
+		walletID, ok := walletParam(c)
+		if !ok {
+			return
+		}
 
 		var req WalletOperation
 		if err := c.ShouldBindJSON(&req); err != nil {
 			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Invalid amount or currency"})
 			return
 		}
+		if !positiveAmount(c, req.Amount) {
+			return
+		}
 
-		err := storage.UpdateBalance(c.Request.Context(), userID, req.Currency, req.Amount)
+		txn, err := wallets.Deposit(c.Request.Context(), userID, walletID, req.Currency, req.Amount)
 		if err != nil {
+			if wallet.IsValidationError(err) {
+				amountError(c, err)
+				return
+			}
+			if limitExceeded(c, err) || accountFrozen(c, err) || walletNotFound(c, err) || roleForbidden(c, err) {
+				return
+			}
 			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Failed to update balance"})
 			return
 		}
 
-		balances, _ := storage.GetAllBalances(c.Request.Context(), userID)
+		balances := walletAmounts(c, storage, userID, walletID)
 		c.JSON(http.StatusOK, gin.H{
-			"message":     "Account topped up successfully",
-			"new_balance": balances,
+			"message":        "Account topped up successfully",
+			"transaction_id": txn.ID,
+			"new_balance":    balances,
 		})
 	}
 }
@@ -52,38 +106,65 @@ func Deposit(storage storages.Repository) gin.HandlerFunc {
 // @Security ApiKeyAuth
 // @Accept json
 // @Produce json
+// @Param wallet_id path int false "Wallet ID, only in /wallets/{wallet_id}/withdraw; default wallet otherwise"
 // @Param request body WalletOperation true "Withdraw request"
+// @Param Idempotency-Key header string false "Key for safe retries"
+// @Param If-Match header string false "ETag from GET /balance/{currency}"
 // @Success 200 {object} map[string]interface{}
+// @Success 202 {object} map[string]interface{} "Waiting for another member's approval"
 // @Failure 400 {object} map[string]string
 // @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]interface{}
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 412 {object} map[string]string
 // @Router /wallet/withdraw [post]
-func Withdraw(storage storages.Repository) gin.HandlerFunc {
+// @Router /wallets/{wallet_id}/withdraw [post]
+func Withdraw(storage storages.Repository, wallets *wallet.Service) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		userID, _ := auth.GetUserID(c)
+		walletID, ok := walletParam(c)
+		if !ok {
+			return
+		}
 
 		var req WalletOperation
 		if err := c.ShouldBindJSON(&req); err != nil {
 			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Invalid amount or currency"})
 			return
 		}
-
-		// Проверяем баланс
-		current, err := storage.GetBalance(c.Request.Context(), userID, req.Currency)
-		if err != nil || current < req.Amount {
-			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Insufficient funds or invalid amount"})
+		if !positiveAmount(c, req.Amount) {
+			return
+		}
+		precondition, ok := ifMatch(c)
+		if !ok {
 			return
 		}
 
-		err = storage.UpdateBalance(c.Request.Context(), userID, req.Currency, -req.Amount)
+		// Проверка версии, средств и списание выполняются атомарно
+		txn, err := wallets.Withdraw(c.Request.Context(), userID, walletID, req.Currency, req.Amount, precondition)
 		if err != nil {
+			if wallet.IsValidationError(err) {
+				amountError(c, err)
+				return
+			}
+			if approvalRequired(c, err) || limitExceeded(c, err) || accountFrozen(c, err) || versionMismatch(c, err) ||
+				walletNotFound(c, err) || roleForbidden(c, err) {
+				return
+			}
+			if errors.Is(err, storages.ErrInsufficientFunds) || errors.Is(err, storages.ErrBalanceNotFound) {
+				c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Insufficient funds or invalid amount"})
+				return
+			}
 			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Failed to update balance"})
 			return
 		}
 
-		balances, _ := storage.GetAllBalances(c.Request.Context(), userID)
+		balances := walletAmounts(c, storage, userID, walletID)
 		c.JSON(http.StatusOK, gin.H{
-			"message":     "Withdrawal successful",
-			"new_balance": balances,
+			"message":        "Withdrawal successful",
+			"transaction_id": txn.ID,
+			"new_balance":    balances,
 		})
 	}
 }
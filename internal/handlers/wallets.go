@@ -0,0 +1,237 @@
+package handlers
+
+import (
+	"errors"
+	"gw-currency-wallet/internal/auth"
+	"gw-currency-wallet/internal/storages"
+	"gw-currency-wallet/internal/wallet"
+	"gw-currency-wallet/pkg/money"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+type CreateWalletRequest struct {
+	Name string `json:"name" binding:"required,max=64"`
+}
+
+type MoveRequest struct {
+	ToWalletID int64         `json:"to_wallet_id" binding:"required"`
+	Currency   string        `json:"currency" binding:"required"`
+	Amount     money.Decimal `json:"amount" swaggertype:"number"`
+}
+
+const codeWalletNotFound = "wallet_not_found"
+
+// @Summary Create a named wallet
+// @Tags wallets
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param request body CreateWalletRequest true "Wallet name, unique per user"
+// @Param Idempotency-Key header string false "Key for safe retries"
+// @Success 201 {object} storages.Wallet
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /wallets [post]
+func CreateWallet(storage storages.Repository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := auth.GetUserID(c)
+		if !ok {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "user not found"})
+			return
+		}
+
+		var req CreateWalletRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		name := strings.TrimSpace(req.Name)
+		if name == "" {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "name must not be blank"})
+			return
+		}
+
+		created, err := storage.CreateWallet(c.Request.Context(), userID, name)
+		if err != nil {
+			if errors.Is(err, storages.ErrWalletExists) {
+				c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": "wallet with this name already exists"})
+				return
+			}
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "failed to create wallet"})
+			return
+		}
+		c.JSON(http.StatusCreated, created)
+	}
+}
+
+// @Summary List wallets
+// @Tags wallets
+// @Security ApiKeyAuth
+// @Produce json
+// @Success 200 {array} storages.Wallet
+// @Failure 401 {object} map[string]string
+// @Router /wallets [get]
+func ListWallets(storage storages.Repository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := auth.GetUserID(c)
+		if !ok {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "user not found"})
+			return
+		}
+
+		list, err := storage.ListWallets(c.Request.Context(), userID)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "failed to list wallets"})
+			return
+		}
+		if list == nil {
+			list = []storages.Wallet{}
+		}
+		c.JSON(http.StatusOK, list)
+	}
+}
+
+// @Summary Get wallet with balances
+// @Tags wallets
+// @Security ApiKeyAuth
+// @Produce json
+// @Param wallet_id path int true "Wallet ID"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /wallets/{wallet_id} [get]
+func GetWallet(storage storages.Repository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := auth.GetUserID(c)
+		if !ok {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "user not found"})
+			return
+		}
+		walletID, ok := walletParam(c)
+		if !ok {
+			return
+		}
+
+		found, err := storage.GetWallet(c.Request.Context(), userID, walletID)
+		if err != nil {
+			if !walletNotFound(c, err) {
+				c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "failed to get wallet"})
+			}
+			return
+		}
+		balances, err := storage.GetWalletBalances(c.Request.Context(), userID, found.ID)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "failed to get balances"})
+			return
+		}
+		if balances == nil {
+			balances = []storages.Balance{}
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"wallet":   found,
+			"balances": balances,
+		})
+	}
+}
+
+// @Summary Move funds between own wallets
+// @Tags wallets
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param wallet_id path int true "Source wallet ID"
+// @Param request body MoveRequest true "Move request"
+// @Param Idempotency-Key header string false "Key for safe retries"
+// @Success 200 {object} map[string]interface{}
+// @Success 202 {object} map[string]interface{} "Waiting for another member's approval"
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]interface{}
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /wallets/{wallet_id}/move [post]
+func MoveFunds(wallets *wallet.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := auth.GetUserID(c)
+		if !ok {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "user not found"})
+			return
+		}
+		walletID, ok := walletParam(c)
+		if !ok {
+			return
+		}
+
+		var req MoveRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if !positiveAmount(c, req.Amount) {
+			return
+		}
+
+		txn, err := wallets.Move(c.Request.Context(), userID, walletID, req.ToWalletID, req.Currency, req.Amount)
+		if err != nil {
+			switch {
+			case wallet.IsValidationError(err):
+				amountError(c, err)
+			case approvalRequired(c, err), walletNotFound(c, err), roleForbidden(c, err), accountFrozen(c, err):
+			case errors.Is(err, storages.ErrInsufficientFunds), errors.Is(err, storages.ErrBalanceNotFound):
+				c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "insufficient funds"})
+			default:
+				c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "failed to move funds"})
+			}
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"transaction_id": txn.ID,
+			"from_wallet_id": walletID,
+			"to_wallet_id":   req.ToWalletID,
+			"currency":       req.Currency,
+			"amount":         txn.Entries[0].Amount,
+		})
+	}
+}
+
+// walletParam разбирает id кошелька из пути. Маршруты без :wallet_id работают с кошельком
+// по умолчанию (0). На неверный id отвечает 400
+func walletParam(c *gin.Context) (int64, bool) {
+	raw := c.Param("wallet_id")
+	if raw == "" {
+		return 0, true
+	}
+	walletID, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil || walletID <= 0 {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid wallet id"})
+		return 0, false
+	}
+	return walletID, true
+}
+
+// walletNotFound отвечает 404 на несуществующий или чужой кошелёк
+func walletNotFound(c *gin.Context, err error) bool {
+	if !errors.Is(err, storages.ErrWalletNotFound) {
+		return false
+	}
+	c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "wallet not found", "code": codeWalletNotFound})
+	return true
+}
+
+// walletAmounts — учётные балансы кошелька для ответа на операцию
+func walletAmounts(c *gin.Context, storage storages.Repository, userID, walletID int64) map[string]money.Decimal {
+	balances, _ := storage.GetWalletBalances(c.Request.Context(), userID, walletID)
+	amounts := make(map[string]money.Decimal, len(balances))
+	for _, b := range balances {
+		amounts[b.Currency] = b.Amount
+	}
+	return amounts
+}
@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"encoding/json"
+	"gw-currency-wallet/internal/storages"
+	"gw-currency-wallet/pkg/money"
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWalletsHandler_DepositAndMove(t *testing.T) {
+	storage, userID := newTestStorage(t, map[string]money.Decimal{"USD": money.New(100, 0)})
+	router := newTestRouter(t, storage, userID)
+
+	w := serve(router, "POST", "/wallets", `{"name": "Savings"}`)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var savings storages.Wallet
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &savings))
+	path := "/wallets/" + strconv.FormatInt(savings.ID, 10)
+
+	w = serve(router, "POST", "/wallets", `{"name": "Savings"}`)
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	// Сумма обязательна и должна быть больше нуля
+	for _, body := range []string{`{"currency": "EUR"}`, `{"currency": "EUR", "amount": 0}`, `{"currency": "EUR", "amount": ""1"}`} {
+		w = serve(router, "POST", path+"/deposit", body)
+		assert.Equal(t, http.StatusBadRequest, w.Code, body)
+	}
+
+	w = serve(router, "POST", path+"/deposit", `{"currency": "EUR", "amount": 50}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Contains(t, w.Body.String(), `"EUR":50.00`)
+
+	// Кошелёк по умолчанию идёт в списке первым; перемещаем из него в Savings
+	w = serve(router, "GET", "/wallets", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	var list []storages.Wallet
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &list))
+	require.Len(t, list, 2)
+	main := "/wallets/" + strconv.FormatInt(list[0].ID, 10)
+
+	w = serve(router, "POST", main+"/move", `{"to_wallet_id": `+strconv.FormatInt(savings.ID, 10)+`, "currency": "USD", "amount": 40}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	w = serve(router, "POST", main+"/move", `{"to_wallet_id": `+strconv.FormatInt(list[0].ID, 10)+`, "currency": "USD", "amount": 1}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code, w.Body.String())
+
+	w = serve(router, "GET", "/balance/USD", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"total":60.00`)
+	w = serve(router, "GET", path+"/balance/USD", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"total":40.00`)
+
+	w = serve(router, "GET", path, "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"name":"Savings"`)
+}
+
+func TestWalletsHandler_ForeignWallet(t *testing.T) {
+	storage, userID := newTestStorage(t, map[string]money.Decimal{"USD": money.New(100, 0)})
+	otherID, err := storage.CreateUser(t.Context(), "other@example.com", "hash")
+	require.NoError(t, err)
+	foreign, err := storage.CreateWallet(t.Context(), otherID, "Travel")
+	require.NoError(t, err)
+	router := newTestRouter(t, storage, userID)
+	path := "/wallets/" + strconv.FormatInt(foreign.ID, 10)
+
+	w := serve(router, "GET", path, "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	w = serve(router, "POST", path+"/deposit", `{"currency": "USD", "amount": 1}`)
+	assert.Equal(t, http.StatusNotFound, w.Code, w.Body.String())
+	assert.Contains(t, w.Body.String(), `"code":"wallet_not_found"`)
+	w = serve(router, "GET", "/wallets/abc/balance/USD", "")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
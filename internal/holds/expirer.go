@@ -0,0 +1,31 @@
+package holds
+
+import (
+	"context"
+	"gw-currency-wallet/internal/storages"
+	"gw-currency-wallet/pkg/logging"
+	"time"
+)
+
+// RunExpirer раз в interval закрывает просроченные холды и возвращает их суммы
+// в доступный баланс. Блокируется до отмены ctx
+func RunExpirer(ctx context.Context, storage storages.Repository, interval time.Duration, logger *logging.Logger) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			expired, err := storage.ExpireHolds(ctx, now)
+			if err != nil {
+				logger.Errorf("failed to expire holds: %v", err)
+				continue
+			}
+			if expired > 0 {
+				logger.Infof("expired %d holds", expired)
+			}
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package holds
+
+import (
+	"context"
+	"gw-currency-wallet/internal/storages"
+	"gw-currency-wallet/internal/storages/memory"
+	"gw-currency-wallet/pkg/logging"
+	"gw-currency-wallet/pkg/money"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunExpirer(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	storage := memory.NewMemoryRepository()
+	userID, err := storage.CreateUser(ctx, "test@example.com", "hash")
+	require.NoError(t, err)
+	_, err = storage.Credit(ctx, userID, "USD", money.New(100, 0))
+	require.NoError(t, err)
+
+	hold, err := storage.PlaceHold(ctx, userID, "USD", money.New(40, 0), time.Now().Add(50*time.Millisecond))
+	require.NoError(t, err)
+
+	go RunExpirer(ctx, storage, 20*time.Millisecond, logging.GetLogger())
+
+	assert.Eventually(t, func() bool {
+		hold, err = storage.GetHold(ctx, userID, hold.ID)
+		return err == nil && hold.Status == storages.HoldExpired
+	}, time.Second, 10*time.Millisecond)
+
+	balances, err := storage.GetBalances(ctx, userID)
+	require.NoError(t, err)
+	for _, b := range balances {
+		assert.True(t, b.Held.IsZero(), b.Currency)
+	}
+}
@@ -2,7 +2,9 @@ package notifications
 
 import (
 	"context"
-	"encoding/json"
+	"gw-currency-wallet/internal/storages"
+	"gw-currency-wallet/pkg/money"
+	"strconv"
 	"time"
 
 	"github.com/segmentio/kafka-go"
@@ -13,37 +15,100 @@ type NotificationService struct {
 }
 
 type TransferEvent struct {
-	UserID    int64     `json:"user_id"`
-	Amount    float32   `json:"amount"`
-	Currency  string    `json:"currency"`
-	Timestamp time.Time `json:"timestamp"`
+	UserID    int64         `json:"user_id"`
+	Amount    money.Decimal `json:"amount"`
+	Currency  string        `json:"currency"`
+	Timestamp time.Time     `json:"timestamp"`
 }
 
-func NewNotificationService(broker, topic string) *NotificationService {
-	writer := &kafka.Writer{
-		Addr:                   kafka.TCP(broker),
-		Topic:                  topic,
-		Balancer:               &kafka.LeastBytes{},
-		AllowAutoTopicCreation: true, // создаёт топик, если не существует
-	}
-	return &NotificationService{writer: writer}
+// P2PTransferEvent — перевод между пользователями
+type P2PTransferEvent struct {
+	Type          string        `json:"type"`
+	TransactionID int64         `json:"transaction_id"`
+	FromUserID    int64         `json:"from_user_id"`
+	ToUserID      int64         `json:"to_user_id"`
+	Amount        money.Decimal `json:"amount"`
+	Currency      string        `json:"currency"`
+	Timestamp     time.Time     `json:"timestamp"`
+}
+
+// OrderFilledEvent — исполнение лимитной заявки
+type OrderFilledEvent struct {
+	Type          string        `json:"type"`
+	OrderID       int64         `json:"order_id"`
+	TransactionID int64         `json:"transaction_id"`
+	UserID        int64         `json:"user_id"`
+	FromCurrency  string        `json:"from_currency"`
+	ToCurrency    string        `json:"to_currency"`
+	Amount        money.Decimal `json:"amount"`
+	Received      money.Decimal `json:"received"`
+	Rate          money.Decimal `json:"rate"`
+	Timestamp     time.Time     `json:"timestamp"`
+}
+
+// SetTransactionID подставляет id операции при записи события в outbox
+func (e *P2PTransferEvent) SetTransactionID(id int64) {
+	e.TransactionID = id
+}
+
+func (e *OrderFilledEvent) SetTransactionID(id int64) {
+	e.TransactionID = id
 }
 
-func (ns *NotificationService) SendLargeTransfer(ctx context.Context, userID int64, amount float32, currency string) error {
-	event := TransferEvent{
+// LargeTransfer — событие крупного обмена
+func LargeTransfer(userID int64, amount money.Decimal, currency string) storages.OutboxEvent {
+	return storages.OutboxEvent{UserID: userID, Body: TransferEvent{
 		UserID:    userID,
 		Amount:    amount,
 		Currency:  currency,
 		Timestamp: time.Now().UTC(),
-	}
+	}}
+}
+
+// Transfer — событие перевода; id операции отправителя подставляет хранилище
+func Transfer(fromUserID, toUserID int64, amount money.Decimal, currency string) storages.OutboxEvent {
+	return storages.OutboxEvent{UserID: fromUserID, Body: &P2PTransferEvent{
+		Type:       "p2p_transfer",
+		FromUserID: fromUserID,
+		ToUserID:   toUserID,
+		Amount:     amount,
+		Currency:   currency,
+		Timestamp:  time.Now().UTC(),
+	}}
+}
 
-	data, err := json.Marshal(event)
-	if err != nil {
-		return err
+// OrderFilled — событие исполнения заявки; id операции обмена подставляет хранилище
+func OrderFilled(order storages.Order, received, rate money.Decimal) storages.OutboxEvent {
+	return storages.OutboxEvent{UserID: order.UserID, Body: &OrderFilledEvent{
+		Type:         "order_filled",
+		OrderID:      order.ID,
+		UserID:       order.UserID,
+		FromCurrency: order.FromCurrency,
+		ToCurrency:   order.ToCurrency,
+		Amount:       order.Amount,
+		Received:     received,
+		Rate:         rate,
+		Timestamp:    time.Now().UTC(),
+	}}
+}
+
+// NewNotificationService создаёт writer, который распределяет сообщения по партициям
+// по ключу — id пользователя, поэтому события одного пользователя читаются по порядку
+func NewNotificationService(broker, topic string) *NotificationService {
+	writer := &kafka.Writer{
+		Addr:                   kafka.TCP(broker),
+		Topic:                  topic,
+		Balancer:               &kafka.Hash{},
+		AllowAutoTopicCreation: true, // создаёт топик, если не существует
 	}
+	return &NotificationService{writer: writer}
+}
 
+// Publish отправляет событие из outbox и возвращает ошибку, если брокер его не принял
+func (ns *NotificationService) Publish(ctx context.Context, event storages.OutboxEvent) error {
 	return ns.writer.WriteMessages(ctx, kafka.Message{
-		Value: data,
+		Key:   []byte(strconv.FormatInt(event.UserID, 10)),
+		Value: event.Payload,
 	})
 }
 
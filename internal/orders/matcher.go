@@ -0,0 +1,151 @@
+package orders
+
+import (
+	"context"
+	"errors"
+	"gw-currency-wallet/internal/cache"
+	"gw-currency-wallet/internal/storages"
+	"gw-currency-wallet/internal/wallet"
+	"gw-currency-wallet/pkg/logging"
+	"gw-currency-wallet/pkg/money"
+	"time"
+)
+
+// batchSize — сколько заявок пары исполняется за один проход
+const batchSize = 100
+
+// RateFetcher — источник всех курсов (auth.Service). Полученные курсы попадают
+// в матчер через подписку, поэтому запросы /exchange/rates тоже запускают исполнение
+type RateFetcher interface {
+	FetchAndCacheAllRates() (map[string]money.Decimal, error)
+}
+
+// Matcher исполняет лимитные заявки, когда курс пары достигает лимита
+type Matcher struct {
+	storage storages.Repository
+	wallets *wallet.Service
+	routing cache.Routing
+	logger  *logging.Logger
+	rates   chan map[string]money.Decimal
+}
+
+func NewMatcher(storage storages.Repository, wallets *wallet.Service, routing cache.Routing, logger *logging.Logger) *Matcher {
+	return &Matcher{
+		storage: storage,
+		wallets: wallets,
+		routing: routing,
+		logger:  logger,
+		rates:   make(chan map[string]money.Decimal, 1),
+	}
+}
+
+// Feed передаёт свежие курсы матчеру. Не блокируется: если предыдущие курсы ещё
+// не обработаны, они заменяются новыми
+func (m *Matcher) Feed(rates map[string]money.Decimal) {
+	for {
+		select {
+		case m.rates <- rates:
+			return
+		default:
+		}
+		select {
+		case <-m.rates:
+		default:
+		}
+	}
+}
+
+// Run раз в interval запрашивает курсы у source и закрывает просроченные заявки,
+// а поступившие через Feed курсы сопоставляет с заявками. Блокируется до отмены ctx
+func (m *Matcher) Run(ctx context.Context, source RateFetcher, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			expired, err := m.storage.ExpireOrders(ctx, now)
+			if err != nil {
+				m.logger.Errorf("failed to expire orders: %v", err)
+			} else if expired > 0 {
+				m.logger.Infof("expired %d orders", expired)
+			}
+			if _, err = source.FetchAndCacheAllRates(); err != nil {
+				m.logger.Errorf("failed to fetch rates for orders: %v", err)
+			}
+		case rates := <-m.rates:
+			filled, err := m.Match(ctx, rates, time.Now())
+			if err != nil {
+				m.logger.Errorf("failed to match orders: %v", err)
+				continue
+			}
+			if filled > 0 {
+				m.logger.Infof("filled %d orders", filled)
+			}
+		}
+	}
+}
+
+// Match исполняет открытые заявки, лимит которых достигнут курсами rates (ключ — "FROM_TO"),
+// и возвращает количество исполненных. Курс каждой пары валют справочника выводится из rates
+// так же, как курс обмена: прямой, обратный или цепочкой по routing. Заявка исполняется
+// по рыночному курсу, а не по лимиту, с комиссией и в пределах лимитов, как обмен
+func (m *Matcher) Match(ctx context.Context, rates map[string]money.Decimal, now time.Time) (int, error) {
+	enabled, err := m.wallets.Catalog().Enabled(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	filled := 0
+	for _, from := range enabled {
+		for _, to := range enabled {
+			if from.Code == to.Code {
+				continue
+			}
+			path, ok := cache.FindPath(rates, from.Code, to.Code, m.routing)
+			if !ok {
+				continue
+			}
+			orders, err := m.storage.MatchingOrders(ctx, from.Code, to.Code, path.Rate, now, batchSize)
+			if err != nil {
+				return filled, err
+			}
+			for _, order := range orders {
+				if m.fill(ctx, order, path) {
+					filled++
+				}
+			}
+		}
+	}
+	return filled, nil
+}
+
+// fill исполняет одну заявку. Заявки, отменённые или исполненные параллельно, пропускаются;
+// заявки сверх лимитов и слишком малые для комиссии отклоняются с причиной, чтобы не
+// перебирать их на каждом курсе
+func (m *Matcher) fill(ctx context.Context, order storages.Order, path cache.RatePath) bool {
+	_, err := m.wallets.FillOrder(ctx, order, path)
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, storages.ErrOrderNotOpen):
+	case errors.Is(err, storages.ErrLimitExceeded), wallet.IsValidationError(err):
+		m.reject(ctx, order, err)
+	default:
+		m.logger.Errorf("failed to fill order %d: %v", order.ID, err)
+	}
+	return false
+}
+
+func (m *Matcher) reject(ctx context.Context, order storages.Order, reason error) {
+	_, err := m.storage.RejectOrder(ctx, order.ID, reason.Error())
+	switch {
+	case err == nil:
+		m.logger.Infof("order %d rejected: %v", order.ID, reason)
+	case errors.Is(err, storages.ErrOrderNotOpen):
+	default:
+		m.logger.Errorf("failed to reject order %d: %v", order.ID, err)
+	}
+}
@@ -0,0 +1,158 @@
+package orders
+
+import (
+	"context"
+	"fmt"
+	"gw-currency-wallet/internal/cache"
+	"gw-currency-wallet/internal/currencies"
+	"gw-currency-wallet/internal/storages"
+	"gw-currency-wallet/internal/storages/memory"
+	"gw-currency-wallet/internal/wallet"
+	"gw-currency-wallet/pkg/logging"
+	"gw-currency-wallet/pkg/money"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestMatcher — матчер с курсами через USD, как в конфигурации по умолчанию
+func newTestMatcher(storage *memory.Memory) *Matcher {
+	wallets := wallet.NewService(storage, currencies.NewCatalog(storage, time.Minute), nil, "USD", time.Minute)
+	return NewMatcher(storage, wallets, cache.Routing{Mode: cache.RoutingBase, Base: "USD"}, logging.GetLogger())
+}
+
+func TestMatcher_Match(t *testing.T) {
+	ctx := context.Background()
+	storage := memory.NewMemoryRepository()
+	matcher := newTestMatcher(storage)
+
+	userID, err := storage.CreateUser(ctx, "test@example.com", "hash")
+	require.NoError(t, err)
+	_, err = storage.Credit(ctx, userID, "USD", money.New(100, 0))
+	require.NoError(t, err)
+
+	order, err := storage.PlaceOrder(ctx, storages.Order{
+		UserID:       userID,
+		FromCurrency: "USD",
+		ToCurrency:   "RUB",
+		Amount:       money.New(100, 0),
+		LimitRate:    money.New(95, 0),
+		ExpiresAt:    time.Now().Add(time.Hour),
+	})
+	require.NoError(t, err)
+
+	// Курс ниже лимита и курсы других пар заявку не исполняют
+	filled, err := matcher.Match(ctx, map[string]money.Decimal{"USD_RUB": money.MustParse("94.5"), "USD_EUR": money.New(100, 0)}, time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, 0, filled)
+
+	filled, err = matcher.Match(ctx, map[string]money.Decimal{"USD_RUB": money.MustParse("96.25")}, time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, 1, filled)
+
+	order, err = storage.GetOrder(ctx, userID, order.ID)
+	require.NoError(t, err)
+	assert.Equal(t, storages.OrderFilled, order.Status)
+	assert.Equal(t, "96.25", order.Rate.String())
+	assert.Equal(t, "9625.00", order.Received.String())
+	assert.NotZero(t, order.TransactionID)
+
+	balances, err := storage.GetBalances(ctx, userID)
+	require.NoError(t, err)
+	for _, b := range balances {
+		assert.True(t, b.Held.IsZero(), b.Currency)
+		if b.Currency == "RUB" {
+			assert.Equal(t, "9625.00", b.Amount.String())
+		}
+	}
+
+	// Исполненная заявка повторно не исполняется
+	filled, err = matcher.Match(ctx, map[string]money.Decimal{"USD_RUB": money.New(100, 0)}, time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, 0, filled)
+}
+
+func TestMatcher_MatchWithFeesAndLimits(t *testing.T) {
+	ctx := context.Background()
+	storage := memory.NewMemoryRepository()
+	matcher := newTestMatcher(storage)
+	userID, err := storage.CreateUser(ctx, "test@example.com", "hash")
+	require.NoError(t, err)
+	_, err = storage.Credit(ctx, userID, "USD", money.New(200, 0))
+	require.NoError(t, err)
+	_, err = storage.Credit(ctx, userID, "RUB", money.New(9000, 0))
+	require.NoError(t, err)
+	require.NoError(t, storage.SetFeeRule(storages.FeeRule{Tier: storages.DefaultTier, Percent: money.New(1, 0)}))
+	daily := money.New(150, 0)
+	require.NoError(t, storage.SetLimit(storages.LimitRule{
+		UserID: userID, Operation: storages.OperationExchange, Currency: "USD", Daily: &daily,
+	}))
+
+	place := func(from, to string, amount int64, limitRate string) storages.Order {
+		order, err := storage.PlaceOrder(ctx, storages.Order{
+			UserID: userID, FromCurrency: from, ToCurrency: to, Amount: money.New(amount, 0),
+			LimitRate: money.MustParse(limitRate), ExpiresAt: time.Now().Add(time.Hour),
+		})
+		require.NoError(t, err)
+		return order
+	}
+	withinLimit := place("USD", "EUR", 100, "0.9")
+	overLimit := place("USD", "EUR", 100, "0.9")
+	// У exchanger нет пары RUB_EUR: курс выводится через USD из обратного USD_RUB и USD_EUR
+	cross := place("RUB", "EUR", 9000, "0.009")
+
+	filled, err := matcher.Match(ctx, map[string]money.Decimal{
+		"USD_EUR": money.MustParse("0.92"),
+		"USD_RUB": money.New(90, 0),
+	}, time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, 2, filled)
+
+	// Комиссия 1% удерживается так же, как при обмене: (100 − 1) × 0.92
+	order, err := storage.GetOrder(ctx, userID, withinLimit.ID)
+	require.NoError(t, err)
+	assert.Equal(t, storages.OrderFilled, order.Status)
+	assert.Equal(t, "91.08", order.Received.String())
+	entries, err := storage.GetTransactionEntries(ctx, order.TransactionID)
+	require.NoError(t, err)
+	var fee bool
+	for _, e := range entries {
+		fee = fee || e.Account == storages.AccountFees && e.Amount.String() == "1.00"
+	}
+	assert.True(t, fee)
+
+	// Второй ордер превысил бы лимит обмена: он отклоняется с причиной, резерв освобождается
+	order, err = storage.GetOrder(ctx, userID, overLimit.ID)
+	require.NoError(t, err)
+	assert.Equal(t, storages.OrderRejected, order.Status)
+	assert.Contains(t, order.Reason, "limit exceeded")
+	balances, err := storage.GetBalances(ctx, userID)
+	require.NoError(t, err)
+	for _, b := range balances {
+		assert.True(t, b.Held.IsZero(), b.Currency)
+	}
+	audit, err := storage.ListAudit(ctx, storages.AuditFilter{UserID: userID, Action: storages.AuditOrderReject, Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, audit, 1)
+	assert.Equal(t, fmt.Sprintf("order:%d", overLimit.ID), audit[0].Target)
+
+	// 9000 − 90 = 8910 RUB по курсу 1/90 × 0.92, округлённому к нулю
+	order, err = storage.GetOrder(ctx, userID, cross.ID)
+	require.NoError(t, err)
+	assert.Equal(t, storages.OrderFilled, order.Status)
+	assert.Equal(t, "0.01022222", order.Rate.String())
+	assert.Equal(t, "91.07", order.Received.String())
+}
+
+func TestMatcher_Feed(t *testing.T) {
+	matcher := newTestMatcher(memory.NewMemoryRepository())
+
+	// Feed не блокируется: необработанные курсы заменяются свежими
+	matcher.Feed(map[string]money.Decimal{"USD_RUB": money.New(90, 0)})
+	matcher.Feed(map[string]money.Decimal{"USD_RUB": money.New(91, 0)})
+
+	rates := <-matcher.rates
+	assert.Equal(t, "91", rates["USD_RUB"].String())
+}
@@ -0,0 +1,101 @@
+package outbox
+
+import (
+	"context"
+	"gw-currency-wallet/internal/storages"
+	"gw-currency-wallet/pkg/logging"
+	"time"
+)
+
+const (
+	// batchSize — сколько событий захватывается за один проход
+	batchSize = 100
+	// lease — на сколько захватываются события; если реле упало, по истечении
+	// захвата события доставит другое реле
+	lease = time.Minute
+	// minBackoff и maxBackoff ограничивают паузу перед повторной доставкой,
+	// которая удваивается с каждой неудачной попыткой
+	minBackoff = time.Second
+	maxBackoff = 5 * time.Minute
+)
+
+// Publisher доставляет событие брокеру (notifications.NotificationService)
+type Publisher interface {
+	Publish(ctx context.Context, event storages.OutboxEvent) error
+}
+
+// Run раз в interval доставляет события из outbox. Блокируется до отмены ctx.
+// Событие отмечается доставленным только после ответа брокера, поэтому при сбое
+// между публикацией и отметкой оно будет доставлено повторно (at-least-once)
+func Run(ctx context.Context, storage storages.Repository, publisher Publisher, interval time.Duration, logger *logging.Logger) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			if _, err := Relay(ctx, storage, publisher, now, logger); err != nil {
+				logger.Errorf("failed to relay outbox events: %v", err)
+			}
+		}
+	}
+}
+
+// Relay доставляет захваченные к now события и возвращает число доставленных. События
+// пользователя публикуются по порядку: после ошибки остальные его события пачки не
+// публикуются и ждут, пока не будет доставлено событие с ошибкой
+func Relay(ctx context.Context, storage storages.Repository, publisher Publisher, now time.Time, logger *logging.Logger) (int, error) {
+	sent := 0
+	for {
+		events, err := storage.ClaimOutbox(ctx, now, lease, batchSize)
+		if err != nil {
+			return sent, err
+		}
+
+		var delivered, postponed []int64
+		failed := make(map[int64]bool)
+		for _, event := range events {
+			if failed[event.UserID] {
+				postponed = append(postponed, event.ID)
+				continue
+			}
+			if err = publisher.Publish(ctx, event); err != nil {
+				failed[event.UserID] = true
+				logger.Warnf("failed to publish outbox event %d (attempt %d): %v", event.ID, event.Attempts+1, err)
+				if err = storage.RetryOutbox(ctx, event.ID, now.Add(Backoff(event.Attempts)), err.Error()); err != nil {
+					return sent, err
+				}
+				continue
+			}
+			delivered = append(delivered, event.ID)
+		}
+
+		if len(delivered) > 0 {
+			if err = storage.MarkOutboxSent(ctx, delivered, now); err != nil {
+				return sent, err
+			}
+		}
+		if len(postponed) > 0 {
+			if err = storage.ReleaseOutbox(ctx, postponed); err != nil {
+				return sent, err
+			}
+		}
+		sent += len(delivered)
+
+		// Повторный захват вернул бы те же отложенные события
+		if len(events) < batchSize || len(failed) > 0 {
+			return sent, nil
+		}
+	}
+}
+
+// Backoff — пауза перед следующей попыткой после attempts неудачных
+func Backoff(attempts int) time.Duration {
+	backoff := minBackoff
+	for i := 0; i < attempts && backoff < maxBackoff; i++ {
+		backoff *= 2
+	}
+	return min(backoff, maxBackoff)
+}
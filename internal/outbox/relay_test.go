@@ -0,0 +1,97 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"gw-currency-wallet/internal/notifications"
+	"gw-currency-wallet/internal/storages"
+	"gw-currency-wallet/internal/storages/memory"
+	"gw-currency-wallet/pkg/logging"
+	"gw-currency-wallet/pkg/money"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// flakyPublisher запоминает доставленные события и отклоняет события из fail по одному разу
+type flakyPublisher struct {
+	fail      map[int64]bool
+	published []storages.OutboxEvent
+}
+
+func (p *flakyPublisher) Publish(ctx context.Context, event storages.OutboxEvent) error {
+	if p.fail[event.ID] {
+		delete(p.fail, event.ID)
+		return errors.New("broker is unavailable")
+	}
+	p.published = append(p.published, event)
+	return nil
+}
+
+func TestRelay_OrderAndRetries(t *testing.T) {
+	ctx := context.Background()
+	storage := memory.NewMemoryRepository()
+	logger := logging.GetLogger()
+
+	senderID, err := storage.CreateUser(ctx, "sender@example.com", "hash")
+	require.NoError(t, err)
+	recipientID, err := storage.CreateUser(ctx, "recipient@example.com", "hash")
+	require.NoError(t, err)
+	_, err = storage.Credit(ctx, senderID, "USD", money.New(100, 0))
+	require.NoError(t, err)
+
+	first, err := storage.Transfer(ctx, senderID, recipientID, "USD", money.New(10, 0), storages.LimitCharge{Operation: storages.OperationWithdraw, Currency: "USD", Amount: money.New(10, 0)},
+		notifications.Transfer(senderID, recipientID, money.New(10, 0), "USD"))
+	require.NoError(t, err)
+	_, err = storage.Transfer(ctx, senderID, recipientID, "USD", money.New(20, 0), storages.LimitCharge{Operation: storages.OperationWithdraw, Currency: "USD", Amount: money.New(20, 0)},
+		notifications.Transfer(senderID, recipientID, money.New(20, 0), "USD"))
+	require.NoError(t, err)
+	base := money.New(30, 0)
+	_, err = storage.PostWithinLimits(ctx, recipientID, storages.OperationExchange,
+		storages.LimitCharge{Operation: storages.OperationExchange, Currency: "USD", Amount: money.New(30, 0), BaseAmount: &base},
+		[]storages.OutboxEvent{notifications.LargeTransfer(recipientID, money.New(30, 0), "USD")},
+		storages.Posting{Currency: "USD", Amount: money.New(-30, 0)},
+		storages.Posting{Currency: "EUR", Amount: money.New(27, 0)})
+	require.NoError(t, err)
+
+	// Первое событие отправителя не доставлено: второе ждёт его, событие получателя уходит сразу
+	publisher := &flakyPublisher{fail: map[int64]bool{1: true}}
+	now := time.Now()
+	sent, err := Relay(ctx, storage, publisher, now, logger)
+	require.NoError(t, err)
+	assert.Equal(t, 1, sent)
+	require.Len(t, publisher.published, 1)
+	assert.Equal(t, recipientID, publisher.published[0].UserID)
+
+	// До конца паузы события отправителя не захватываются
+	sent, err = Relay(ctx, storage, publisher, now.Add(Backoff(0)/2), logger)
+	require.NoError(t, err)
+	assert.Equal(t, 0, sent)
+
+	sent, err = Relay(ctx, storage, publisher, now.Add(Backoff(0)), logger)
+	require.NoError(t, err)
+	assert.Equal(t, 2, sent)
+	require.Len(t, publisher.published, 3)
+	assert.Equal(t, []int64{1, 2}, []int64{publisher.published[1].ID, publisher.published[2].ID})
+	assert.Equal(t, 1, publisher.published[1].Attempts)
+
+	// В событие перевода записан id операции отправителя
+	var event notifications.P2PTransferEvent
+	require.NoError(t, json.Unmarshal(publisher.published[1].Payload, &event))
+	assert.Equal(t, first.ID, event.TransactionID)
+	assert.Equal(t, "p2p_transfer", event.Type)
+
+	// Доставленные события больше не захватываются
+	sent, err = Relay(ctx, storage, publisher, now.Add(time.Hour), logger)
+	require.NoError(t, err)
+	assert.Equal(t, 0, sent)
+}
+
+func TestBackoff(t *testing.T) {
+	assert.Equal(t, time.Second, Backoff(0))
+	assert.Equal(t, 8*time.Second, Backoff(3))
+	assert.Equal(t, 5*time.Minute, Backoff(100))
+}
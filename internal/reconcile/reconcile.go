@@ -0,0 +1,172 @@
+package reconcile
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"gw-currency-wallet/internal/storages"
+	"gw-currency-wallet/pkg/logging"
+	"gw-currency-wallet/pkg/money"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// defaultBatchSize — сколько пользователей сверяется в одной транзакции чтения
+const defaultBatchSize = 500
+
+type Options struct {
+	// BatchSize — пользователей в пачке; 0 — defaultBatchSize
+	BatchSize int
+	// Freeze — замораживать счета с расхождениями
+	Freeze bool
+}
+
+// Report — результат сверки в машиночитаемом виде
+type Report struct {
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt time.Time  `json:"finished_at"`
+	Users      int        `json:"users_checked"`
+	Accounts   int        `json:"accounts_checked"`
+	Mismatches []Mismatch `json:"mismatches"`
+}
+
+// Mismatch — счёт с расхождением. Difference = balance - ledger, HeldDifference = held - expected_held
+type Mismatch struct {
+	storages.BalanceCheck
+	Difference     money.Decimal `json:"difference"`
+	HeldDifference money.Decimal `json:"held_difference"`
+	// FreezeError — почему не удалось заморозить счёт при Options.Freeze
+	FreezeError string `json:"freeze_error,omitempty"`
+}
+
+// Reconcile сверяет балансы всех пользователей с журналом пачками по BatchSize.
+// Каждая пачка читается из своего согласованного снимка без блокировок, поэтому сверка
+// идёт параллельно с операциями; расхождение внутри пачки не может быть вызвано гонкой
+func Reconcile(ctx context.Context, storage storages.Repository, opts Options) (Report, error) {
+	batchSize := opts.BatchSize
+	if batchSize <= 0 {
+		batchSize = defaultBatchSize
+	}
+
+	report := Report{StartedAt: time.Now(), Mismatches: []Mismatch{}}
+	// Заморозка действует на валюту во всех кошельках пользователя: при расхождениях в нескольких
+	// кошельках счёт замораживается один раз
+	frozen := make(map[account]bool)
+	var afterUserID int64
+	for {
+		checks, lastUserID, err := storage.CheckBalances(ctx, afterUserID, batchSize)
+		if err != nil {
+			return report, fmt.Errorf("failed to check balances after user %d: %w", afterUserID, err)
+		}
+		if lastUserID == 0 {
+			break
+		}
+
+		var previousUserID int64
+		for _, check := range checks {
+			if check.UserID != previousUserID {
+				report.Users++
+				previousUserID = check.UserID
+			}
+			report.Accounts++
+			if !check.Drifted() {
+				continue
+			}
+
+			mismatch, err := newMismatch(check)
+			if err != nil {
+				return report, err
+			}
+			key := account{userID: check.UserID, currency: check.Currency}
+			if opts.Freeze && !check.Frozen {
+				if frozen[key] {
+					mismatch.Frozen = true
+				} else if err = storage.SetAccountFrozen(ctx, check.UserID, check.Currency, true); err != nil {
+					mismatch.FreezeError = err.Error()
+				} else {
+					mismatch.Frozen = true
+					frozen[key] = true
+				}
+			}
+			report.Mismatches = append(report.Mismatches, mismatch)
+		}
+		afterUserID = lastUserID
+	}
+
+	report.FinishedAt = time.Now()
+	return report, nil
+}
+
+// account — счёт пользователя в валюте, как его замораживает SetAccountFrozen
+type account struct {
+	userID   int64
+	currency string
+}
+
+func newMismatch(check storages.BalanceCheck) (Mismatch, error) {
+	difference, err := check.Balance.Sub(check.Ledger)
+	if err != nil {
+		return Mismatch{}, err
+	}
+	heldDifference, err := check.Held.Sub(check.ExpectedHeld)
+	if err != nil {
+		return Mismatch{}, err
+	}
+	return Mismatch{BalanceCheck: check, Difference: difference, HeldDifference: heldDifference}, nil
+}
+
+// WriteReport пишет отчёт в JSON
+func WriteReport(w io.Writer, report Report) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(report)
+}
+
+// Run сверяет балансы раз в interval и сохраняет каждый отчёт в reportDir
+// как reconcile-<время начала>.json. Блокируется до отмены ctx
+func Run(ctx context.Context, storage storages.Repository, interval time.Duration, reportDir string, opts Options, logger *logging.Logger) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			report, err := Reconcile(ctx, storage, opts)
+			if err != nil {
+				logger.Errorf("failed to reconcile balances: %v", err)
+				continue
+			}
+
+			path, err := saveReport(reportDir, report)
+			if err != nil {
+				logger.Errorf("failed to save reconciliation report: %v", err)
+			}
+			if len(report.Mismatches) > 0 {
+				logger.Warnf("reconciliation found %d mismatched accounts, report: %s", len(report.Mismatches), path)
+			} else {
+				logger.Infof("reconciliation checked %d accounts, no mismatches", report.Accounts)
+			}
+		}
+	}
+}
+
+func saveReport(dir string, report Report) (string, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, "reconcile-"+report.StartedAt.UTC().Format("20060102T150405Z")+".json")
+	file, err := os.Create(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	if err = WriteReport(file, report); err != nil {
+		return "", err
+	}
+	return path, file.Close()
+}
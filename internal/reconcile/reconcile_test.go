@@ -0,0 +1,127 @@
+package reconcile
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"gw-currency-wallet/internal/storages"
+	"gw-currency-wallet/internal/storages/memory"
+	"gw-currency-wallet/pkg/money"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// driftingStorage портит сохранённый баланс одного пользователя в результатах сверки
+type driftingStorage struct {
+	*memory.Memory
+	userID int64
+}
+
+func (s driftingStorage) CheckBalances(ctx context.Context, afterUserID int64, limit int) ([]storages.BalanceCheck, int64, error) {
+	checks, lastUserID, err := s.Memory.CheckBalances(ctx, afterUserID, limit)
+	for i := range checks {
+		if checks[i].UserID == s.userID && checks[i].Currency == "USD" {
+			checks[i].Balance = money.MustParse("12.50")
+		}
+	}
+	return checks, lastUserID, err
+}
+
+func TestReconcile(t *testing.T) {
+	ctx := context.Background()
+	storage := memory.NewMemoryRepository()
+	var userIDs []int64
+	for i := 0; i < 5; i++ {
+		userID, err := storage.CreateUser(ctx, fmt.Sprintf("user%d@example.com", i), "hash")
+		require.NoError(t, err)
+		_, err = storage.Credit(ctx, userID, "USD", money.New(10, 0))
+		require.NoError(t, err)
+		userIDs = append(userIDs, userID)
+	}
+	drifting := driftingStorage{Memory: storage, userID: userIDs[3]}
+
+	// Пачки по 2 пользователя: сверка проходит всех
+	report, err := Reconcile(ctx, drifting, Options{BatchSize: 2, Freeze: true})
+	require.NoError(t, err)
+	assert.Equal(t, 5, report.Users)
+	require.Len(t, report.Mismatches, 1)
+
+	mismatch := report.Mismatches[0]
+	assert.Equal(t, userIDs[3], mismatch.UserID)
+	assert.Equal(t, "2.50", mismatch.Difference.String())
+	assert.True(t, mismatch.Frozen)
+	_, err = storage.Debit(ctx, userIDs[3], "USD", money.New(1, 0))
+	assert.ErrorIs(t, err, storages.ErrAccountFrozen)
+	_, err = storage.Debit(ctx, userIDs[2], "USD", money.New(1, 0))
+	assert.NoError(t, err)
+
+	var buf bytes.Buffer
+	require.NoError(t, WriteReport(&buf, report))
+	var decoded map[string]any
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &decoded))
+	assert.Len(t, decoded["mismatches"], 1)
+
+	// Без расхождений список пуст, а не null
+	report, err = Reconcile(ctx, storage, Options{})
+	require.NoError(t, err)
+	assert.NotNil(t, report.Mismatches)
+	assert.Empty(t, report.Mismatches)
+}
+
+// walletDriftStorage сдвигает сохранённые балансы USD в кошельках на drift и считает заморозки
+type walletDriftStorage struct {
+	*memory.Memory
+	drift   map[int64]money.Decimal
+	freezes *int
+}
+
+func (s walletDriftStorage) CheckBalances(ctx context.Context, afterUserID int64, limit int) ([]storages.BalanceCheck, int64, error) {
+	checks, lastUserID, err := s.Memory.CheckBalances(ctx, afterUserID, limit)
+	for i := range checks {
+		if drift, ok := s.drift[checks[i].WalletID]; ok && checks[i].Currency == "USD" {
+			checks[i].Balance, _ = checks[i].Balance.Add(drift)
+		}
+	}
+	return checks, lastUserID, err
+}
+
+func (s walletDriftStorage) SetAccountFrozen(ctx context.Context, userID int64, currency string, frozen bool) error {
+	*s.freezes++
+	return s.Memory.SetAccountFrozen(ctx, userID, currency, frozen)
+}
+
+func TestReconcile_PerWallet(t *testing.T) {
+	ctx := context.Background()
+	storage := memory.NewMemoryRepository()
+	userID, err := storage.CreateUser(ctx, "user@example.com", "hash")
+	require.NoError(t, err)
+	_, err = storage.Credit(ctx, userID, "USD", money.New(10, 0))
+	require.NoError(t, err)
+	savings, err := storage.CreateWallet(ctx, userID, "savings")
+	require.NoError(t, err)
+	_, err = storage.PostTransaction(ctx, userID, storages.OperationMove,
+		storages.Posting{Currency: "USD", Amount: money.New(-4, 0)},
+		storages.Posting{WalletID: savings.ID, Currency: "USD", Amount: money.New(4, 0)})
+	require.NoError(t, err)
+	main, err := storage.GetWallet(ctx, userID, 0)
+	require.NoError(t, err)
+
+	// +1 в одном кошельке и -1 в другом в сумме сходятся, но оба — расхождения; счёт замораживается один раз
+	freezes := 0
+	drifting := walletDriftStorage{Memory: storage, freezes: &freezes, drift: map[int64]money.Decimal{
+		main.ID:    money.MustParse("1.00"),
+		savings.ID: money.MustParse("-1.00"),
+	}}
+	report, err := Reconcile(ctx, drifting, Options{Freeze: true})
+	require.NoError(t, err)
+	require.Len(t, report.Mismatches, 2)
+	assert.Equal(t, main.ID, report.Mismatches[0].WalletID)
+	assert.Equal(t, "1.00", report.Mismatches[0].Difference.String())
+	assert.Equal(t, savings.ID, report.Mismatches[1].WalletID)
+	assert.Equal(t, "-1.00", report.Mismatches[1].Difference.String())
+	assert.True(t, report.Mismatches[1].Frozen)
+	assert.Equal(t, 1, freezes)
+}
@@ -0,0 +1,110 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"gw-currency-wallet/internal/storages"
+	"gw-currency-wallet/internal/wallet"
+	"gw-currency-wallet/pkg/logging"
+	"time"
+)
+
+const (
+	// batchSize — сколько срабатываний захватывается за один проход
+	batchSize = 100
+	// runLease — сколько срабатывание может оставаться pending. Дольше — значит, воркер упал
+	// между захватом и записью результата; такое срабатывание завершается ошибкой, а не повторяется,
+	// потому что операция могла быть проведена
+	runLease = 10 * time.Minute
+)
+
+// Run раз в interval выполняет наступившие срабатывания расписаний. Блокируется до отмены ctx.
+// Безопасен при нескольких экземплярах сервиса: срабатывание захватывается в хранилище
+// до выполнения, поэтому операция проводится не больше одного раза
+func Run(ctx context.Context, storage storages.Repository, wallets *wallet.Service, interval time.Duration, logger *logging.Logger) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			executed, err := RunDue(ctx, storage, wallets, now, logger)
+			if err != nil {
+				logger.Errorf("failed to run schedules: %v", err)
+				continue
+			}
+			if executed > 0 {
+				logger.Infof("executed %d scheduled operations", executed)
+			}
+		}
+	}
+}
+
+// RunDue выполняет срабатывания, наступившие к now, и возвращает их количество.
+// Ошибка операции (например, нехватка средств) записывается в срабатывание и не прерывает остальные.
+// Если результат срабатывания не сохранился, остальные срабатывания пачки всё равно выполняются,
+// а незавершённое срабатывание через runLease завершается ошибкой ErrRunInterrupted
+func RunDue(ctx context.Context, storage storages.Repository, wallets *wallet.Service, now time.Time, logger *logging.Logger) (int, error) {
+	stale, err := storage.FailStaleScheduleRuns(ctx, now.Add(-runLease))
+	if err != nil {
+		return 0, err
+	}
+	if stale > 0 {
+		logger.Warnf("failed %d interrupted scheduled runs", stale)
+	}
+
+	executed := 0
+	for {
+		runs, err := storage.ClaimDueSchedules(ctx, now, batchSize)
+		if err != nil {
+			return executed, err
+		}
+
+		var finishErr error
+		for _, run := range runs {
+			txn, err := execute(ctx, wallets, run.Schedule)
+			run.Status = storages.RunSucceeded
+			run.TransactionID = txn.ID
+			if err != nil {
+				run.Status = storages.RunFailed
+				run.Error = err.Error()
+				logger.Warnf("scheduled operation %d failed: %v", run.ScheduleID, err)
+			}
+			executed++
+			if err = storage.FinishScheduleRun(ctx, run); err != nil {
+				logger.Errorf("failed to finish scheduled run %d: %v", run.ID, err)
+				if finishErr == nil {
+					finishErr = err
+				}
+			}
+		}
+
+		if finishErr != nil {
+			return executed, finishErr
+		}
+		if len(runs) < batchSize {
+			return executed, nil
+		}
+	}
+}
+
+// execute проводит операцию расписания теми же путями, что и HTTP-обработчики, в кошельке расписания
+func execute(ctx context.Context, wallets *wallet.Service, s storages.Schedule) (storages.Transaction, error) {
+	switch s.Operation {
+	case storages.OperationDeposit:
+		return wallets.Deposit(ctx, s.UserID, s.WalletID, s.Currency, s.Amount)
+	case storages.OperationWithdraw:
+		return wallets.Withdraw(ctx, s.UserID, s.WalletID, s.Currency, s.Amount, wallet.IfMatch{})
+	case storages.OperationExchange:
+		result, err := wallets.Exchange(ctx, s.UserID, s.WalletID, s.Currency, s.ToCurrency, s.Amount, wallet.IfMatch{})
+		return result.Transaction, err
+	case storages.OperationTransfer:
+		return wallets.Transfer(ctx, s.UserID, s.ToUserID, s.Currency, s.Amount)
+	case storages.OperationMove:
+		return wallets.Move(ctx, s.UserID, s.WalletID, s.ToWalletID, s.Currency, s.Amount)
+	default:
+		return storages.Transaction{}, fmt.Errorf("unsupported scheduled operation %q", s.Operation)
+	}
+}
@@ -0,0 +1,248 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"gw-currency-wallet/internal/cache"
+	"gw-currency-wallet/internal/currencies"
+	"gw-currency-wallet/internal/storages"
+	"gw-currency-wallet/internal/storages/memory"
+	"gw-currency-wallet/internal/wallet"
+	"gw-currency-wallet/pkg/logging"
+	"gw-currency-wallet/pkg/money"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fixedRates struct{}
+
+func (fixedRates) GetExchangeRateWithCache(from, to string) (money.Decimal, error) {
+	return money.New(9, 1), nil
+}
+
+func (fixedRates) GetRatePath(from, to string) (cache.RatePath, error) {
+	return cache.DirectPath(from, to, money.New(9, 1)), nil
+}
+
+func TestRunDue(t *testing.T) {
+	ctx := context.Background()
+	storage := memory.NewMemoryRepository()
+	wallets := wallet.NewService(storage, currencies.NewCatalog(storage, time.Minute), fixedRates{}, "USD", time.Minute)
+
+	userID, err := storage.CreateUser(ctx, "test@example.com", "hash")
+	require.NoError(t, err)
+	_, err = storage.Credit(ctx, userID, "USD", money.New(150, 0))
+	require.NoError(t, err)
+
+	start := time.Date(2026, 1, 5, 9, 0, 0, 0, time.UTC)
+	schedule, err := storage.CreateSchedule(ctx, storages.Schedule{
+		UserID:     userID,
+		Operation:  storages.OperationExchange,
+		Currency:   "USD",
+		ToCurrency: "EUR",
+		Amount:     money.New(100, 0),
+		Recurrence: storages.RecurrenceWeekly,
+		StartAt:    start,
+	})
+	require.NoError(t, err)
+
+	// До StartAt ничего не выполняется
+	executed, err := RunDue(ctx, storage, wallets, start.Add(-time.Minute), logging.GetLogger())
+	require.NoError(t, err)
+	assert.Equal(t, 0, executed)
+
+	executed, err = RunDue(ctx, storage, wallets, start, logging.GetLogger())
+	require.NoError(t, err)
+	assert.Equal(t, 1, executed)
+
+	// Повторный проход в то же время не выполняет срабатывание ещё раз
+	executed, err = RunDue(ctx, storage, wallets, start, logging.GetLogger())
+	require.NoError(t, err)
+	assert.Equal(t, 0, executed)
+
+	// На второй неделе средств уже не хватает — ошибка записывается в срабатывание
+	executed, err = RunDue(ctx, storage, wallets, start.AddDate(0, 0, 7), logging.GetLogger())
+	require.NoError(t, err)
+	assert.Equal(t, 1, executed)
+
+	balances, err := storage.GetBalances(ctx, userID)
+	require.NoError(t, err)
+	for _, b := range balances {
+		switch b.Currency {
+		case "USD":
+			assert.True(t, b.Amount.Equal(money.New(50, 0)), b.Amount.String())
+		case "EUR":
+			assert.True(t, b.Amount.Equal(money.New(90, 0)), b.Amount.String())
+		}
+	}
+
+	runs, err := storage.ListScheduleRuns(ctx, userID, schedule.ID, 10)
+	require.NoError(t, err)
+	require.Len(t, runs, 2)
+	assert.Equal(t, storages.RunFailed, runs[0].Status)
+	assert.Contains(t, runs[0].Error, "insufficient funds")
+	assert.Equal(t, storages.RunSucceeded, runs[1].Status)
+	assert.NotZero(t, runs[1].TransactionID)
+
+	schedule, err = storage.GetSchedule(ctx, userID, schedule.ID)
+	require.NoError(t, err)
+	assert.Equal(t, runs[0].Error, schedule.LastError)
+	assert.Equal(t, start.AddDate(0, 0, 14), *schedule.NextRunAt)
+}
+
+func TestRunDue_Wallets(t *testing.T) {
+	ctx := context.Background()
+	storage := memory.NewMemoryRepository()
+	wallets := wallet.NewService(storage, currencies.NewCatalog(storage, time.Minute), fixedRates{}, "USD", time.Minute)
+
+	userID, err := storage.CreateUser(ctx, "test@example.com", "hash")
+	require.NoError(t, err)
+	_, err = storage.Credit(ctx, userID, "USD", money.New(150, 0))
+	require.NoError(t, err)
+	savings, err := storage.CreateWallet(ctx, userID, "savings")
+	require.NoError(t, err)
+
+	start := time.Date(2026, 1, 5, 9, 0, 0, 0, time.UTC)
+	// Перенос из кошелька по умолчанию в savings и пополнение savings — обе операции в одном проходе
+	_, err = storage.CreateSchedule(ctx, storages.Schedule{
+		UserID:     userID,
+		Operation:  storages.OperationMove,
+		ToWalletID: savings.ID,
+		Currency:   "USD",
+		Amount:     money.New(100, 0),
+		StartAt:    start,
+	})
+	require.NoError(t, err)
+	_, err = storage.CreateSchedule(ctx, storages.Schedule{
+		UserID:    userID,
+		Operation: storages.OperationDeposit,
+		WalletID:  savings.ID,
+		Currency:  "USD",
+		Amount:    money.New(20, 0),
+		StartAt:   start,
+	})
+	require.NoError(t, err)
+
+	executed, err := RunDue(ctx, storage, wallets, start, logging.GetLogger())
+	require.NoError(t, err)
+	assert.Equal(t, 2, executed)
+
+	amounts := func(walletID int64) map[string]string {
+		balances, err := storage.GetWalletBalances(ctx, userID, walletID)
+		require.NoError(t, err)
+		result := make(map[string]string)
+		for _, b := range balances {
+			if !b.Amount.IsZero() {
+				result[b.Currency] = b.Amount.String()
+			}
+		}
+		return result
+	}
+	assert.Equal(t, map[string]string{"USD": "50.00"}, amounts(0))
+	assert.Equal(t, map[string]string{"USD": "120.00"}, amounts(savings.ID))
+
+	schedules, err := storage.ListSchedules(ctx, userID)
+	require.NoError(t, err)
+	for _, s := range schedules {
+		assert.Empty(t, s.LastError, s.Operation)
+	}
+}
+
+func TestRunDue_FailsStaleRuns(t *testing.T) {
+	ctx := context.Background()
+	storage := memory.NewMemoryRepository()
+	wallets := wallet.NewService(storage, currencies.NewCatalog(storage, time.Minute), fixedRates{}, "USD", time.Minute)
+
+	userID, err := storage.CreateUser(ctx, "test@example.com", "hash")
+	require.NoError(t, err)
+	start := time.Date(2026, 1, 5, 9, 0, 0, 0, time.UTC)
+	schedule, err := storage.CreateSchedule(ctx, storages.Schedule{
+		UserID:    userID,
+		Operation: storages.OperationDeposit,
+		Currency:  "USD",
+		Amount:    money.New(10, 0),
+		StartAt:   start,
+	})
+	require.NoError(t, err)
+
+	// Воркер захватил срабатывание и упал, не выполнив операцию
+	runs, err := storage.ClaimDueSchedules(ctx, start, batchSize)
+	require.NoError(t, err)
+	require.Len(t, runs, 1)
+
+	// До истечения захвата срабатывание считается выполняющимся
+	_, err = RunDue(ctx, storage, wallets, start.Add(time.Minute), logging.GetLogger())
+	require.NoError(t, err)
+	runs, err = storage.ListScheduleRuns(ctx, userID, schedule.ID, 10)
+	require.NoError(t, err)
+	require.Len(t, runs, 1)
+	assert.Equal(t, storages.RunPending, runs[0].Status)
+
+	// После — завершается ошибкой, операция не повторяется
+	executed, err := RunDue(ctx, storage, wallets, start.Add(runLease+time.Minute), logging.GetLogger())
+	require.NoError(t, err)
+	assert.Equal(t, 0, executed)
+	runs, err = storage.ListScheduleRuns(ctx, userID, schedule.ID, 10)
+	require.NoError(t, err)
+	require.Len(t, runs, 1)
+	assert.Equal(t, storages.RunFailed, runs[0].Status)
+	assert.Equal(t, storages.ErrRunInterrupted.Error(), runs[0].Error)
+
+	schedule, err = storage.GetSchedule(ctx, userID, schedule.ID)
+	require.NoError(t, err)
+	assert.Equal(t, storages.ErrRunInterrupted.Error(), schedule.LastError)
+	balance, err := storage.GetBalance(ctx, userID, "USD")
+	require.NoError(t, err)
+	assert.True(t, balance.IsZero(), balance.String())
+}
+
+// failingFinish не сохраняет результат одного срабатывания
+type failingFinish struct {
+	*memory.Memory
+	runID int64
+}
+
+func (s failingFinish) FinishScheduleRun(ctx context.Context, run storages.ScheduleRun) error {
+	if run.ID == s.runID {
+		return errors.New("connection lost")
+	}
+	return s.Memory.FinishScheduleRun(ctx, run)
+}
+
+func TestRunDue_FinishErrorKeepsBatch(t *testing.T) {
+	ctx := context.Background()
+	storage := memory.NewMemoryRepository()
+	wallets := wallet.NewService(storage, currencies.NewCatalog(storage, time.Minute), fixedRates{}, "USD", time.Minute)
+
+	userID, err := storage.CreateUser(ctx, "test@example.com", "hash")
+	require.NoError(t, err)
+	start := time.Date(2026, 1, 5, 9, 0, 0, 0, time.UTC)
+	var schedules []storages.Schedule
+	for i := 0; i < 2; i++ {
+		schedule, err := storage.CreateSchedule(ctx, storages.Schedule{
+			UserID:    userID,
+			Operation: storages.OperationDeposit,
+			Currency:  "USD",
+			Amount:    money.New(10, 0),
+			StartAt:   start.Add(time.Duration(i) * time.Second),
+		})
+		require.NoError(t, err)
+		schedules = append(schedules, schedule)
+	}
+
+	// Результат первого срабатывания не сохраняется, второе всё равно выполняется
+	executed, err := RunDue(ctx, failingFinish{Memory: storage, runID: 1}, wallets, start.Add(time.Minute), logging.GetLogger())
+	assert.Error(t, err)
+	assert.Equal(t, 2, executed)
+
+	runs, err := storage.ListScheduleRuns(ctx, userID, schedules[1].ID, 10)
+	require.NoError(t, err)
+	require.Len(t, runs, 1)
+	assert.Equal(t, storages.RunSucceeded, runs[0].Status)
+	balance, err := storage.GetBalance(ctx, userID, "USD")
+	require.NoError(t, err)
+	assert.Equal(t, "20.00", balance.String())
+}
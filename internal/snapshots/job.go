@@ -0,0 +1,50 @@
+package snapshots
+
+import (
+	"context"
+	"gw-currency-wallet/internal/storages"
+	"gw-currency-wallet/pkg/logging"
+	"time"
+)
+
+// settleDelay — сколько ждать после полуночи по UTC, прежде чем снимать прошедший день:
+// операции, начатые до полуночи, успевают зафиксироваться
+const settleDelay = 5 * time.Minute
+
+// Run снимает остатки на конец прошедшего дня при старте и затем раз в interval.
+// Снимок за день пересчитывается при каждом запуске, поэтому частый запуск безопасен,
+// а пропущенные из-за простоя дни не нужны: остаток на их конец считается по более раннему снимку.
+// Блокируется до отмены ctx
+func Run(ctx context.Context, storage storages.Repository, interval time.Duration, logger *logging.Logger) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	now := time.Now()
+	for {
+		if _, err := SnapshotPreviousDay(ctx, storage, now, logger); err != nil {
+			logger.Errorf("failed to snapshot balances: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case now = <-ticker.C:
+		}
+	}
+}
+
+// SnapshotPreviousDay снимает остатки на конец последнего дня, закончившегося не позже
+// чем settleDelay до now
+func SnapshotPreviousDay(ctx context.Context, storage storages.Repository, now time.Time, logger *logging.Logger) (int, error) {
+	today, _ := storages.SnapshotDay(now.Add(-settleDelay))
+	day := today.AddDate(0, 0, -1)
+
+	written, err := storage.SnapshotBalances(ctx, day)
+	if err != nil {
+		return 0, err
+	}
+	if written > 0 {
+		logger.Infof("snapshotted %d balances for %s", written, day.Format(time.DateOnly))
+	}
+	return written, nil
+}
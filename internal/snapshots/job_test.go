@@ -0,0 +1,39 @@
+package snapshots
+
+import (
+	"context"
+	"gw-currency-wallet/internal/storages"
+	"gw-currency-wallet/internal/storages/memory"
+	"gw-currency-wallet/pkg/logging"
+	"gw-currency-wallet/pkg/money"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSnapshotPreviousDay(t *testing.T) {
+	ctx := context.Background()
+	storage := memory.NewMemoryRepository()
+	userID, err := storage.CreateUser(ctx, "test@example.com", "hash")
+	require.NoError(t, err)
+	_, err = storage.Credit(ctx, userID, "USD", money.New(100, 0))
+	require.NoError(t, err)
+
+	_, tomorrow := storages.SnapshotDay(time.Now())
+
+	// Сразу после полуночи прошедший день ещё не снимается
+	written, err := SnapshotPreviousDay(ctx, storage, tomorrow.Add(time.Minute), logging.GetLogger())
+	require.NoError(t, err)
+	assert.Equal(t, 0, written)
+
+	written, err = SnapshotPreviousDay(ctx, storage, tomorrow.Add(time.Hour), logging.GetLogger())
+	require.NoError(t, err)
+	assert.Equal(t, 1, written)
+
+	// Ответ на момент после снимка совпадает с текущим остатком
+	balances, err := storage.GetBalancesAsOf(ctx, userID, 0, tomorrow.Add(time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, "100.00", balances["USD"].String())
+}
@@ -0,0 +1,58 @@
+package statements
+
+import (
+	"encoding/csv"
+	"gw-currency-wallet/pkg/money"
+	"io"
+	"strconv"
+	"time"
+)
+
+// csvEncoder пишет по строке на движение; входящий и исходящий остатки — отдельные строки
+// с типом opening_balance и closing_balance
+type csvEncoder struct {
+	w      *csv.Writer
+	header Header
+}
+
+func newCSVEncoder(w io.Writer) *csvEncoder {
+	return &csvEncoder{w: csv.NewWriter(w)}
+}
+
+func (e *csvEncoder) start(h Header) error {
+	e.header = h
+	return e.w.Write([]string{"currency", "date", "transaction_id", "type", "counterparty_id", "amount", "balance"})
+}
+
+func (e *csvEncoder) openAccount(currency string, opening money.Decimal) error {
+	return e.w.Write([]string{currency, formatTime(e.header.From), "", "opening_balance", "", "", opening.String()})
+}
+
+func (e *csvEncoder) line(l Line) error {
+	counterparty := ""
+	if l.CounterpartyID != 0 {
+		counterparty = strconv.FormatInt(l.CounterpartyID, 10)
+	}
+	return e.w.Write([]string{
+		l.Currency,
+		formatTime(l.CreatedAt),
+		strconv.FormatInt(l.TransactionID, 10),
+		string(l.Type),
+		counterparty,
+		l.Amount.String(),
+		l.Balance.String(),
+	})
+}
+
+func (e *csvEncoder) closeAccount(currency string, closing money.Decimal) error {
+	return e.w.Write([]string{currency, formatTime(e.header.To), "", "closing_balance", "", "", closing.String()})
+}
+
+func (e *csvEncoder) finish() error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+func formatTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
@@ -0,0 +1,112 @@
+package statements
+
+import (
+	"encoding/json"
+	"gw-currency-wallet/internal/storages"
+	"gw-currency-wallet/pkg/money"
+	"io"
+	"time"
+)
+
+// jsonEncoder пишет документ по частям, не собирая его целиком:
+// {"user_id":..,"from":..,"to":..,"generated_at":..,"accounts":[{"currency":..,
+// "opening_balance":..,"entries":[..],"closing_balance":..}]}
+type jsonEncoder struct {
+	w        io.Writer
+	accounts int
+	entries  int
+	err      error
+}
+
+type jsonHeader struct {
+	UserID      int64     `json:"user_id"`
+	From        time.Time `json:"from"`
+	To          time.Time `json:"to"`
+	GeneratedAt time.Time `json:"generated_at"`
+}
+
+type jsonLine struct {
+	TransactionID  int64                  `json:"transaction_id"`
+	Type           storages.OperationType `json:"type"`
+	CounterpartyID int64                  `json:"counterparty_id,omitempty"`
+	Amount         money.Decimal          `json:"amount"`
+	Balance        money.Decimal          `json:"balance"`
+	CreatedAt      time.Time              `json:"created_at"`
+}
+
+func newJSONEncoder(w io.Writer) *jsonEncoder {
+	return &jsonEncoder{w: w}
+}
+
+func (e *jsonEncoder) start(h Header) error {
+	header, err := json.Marshal(jsonHeader{UserID: h.UserID, From: h.From, To: h.To, GeneratedAt: h.GeneratedAt})
+	if err != nil {
+		return err
+	}
+	// Поля заголовка — начало объекта, дальше дописывается массив счетов
+	e.write(header[:len(header)-1])
+	e.write([]byte(`,"accounts":[`))
+	return e.err
+}
+
+func (e *jsonEncoder) openAccount(currency string, opening money.Decimal) error {
+	if e.accounts > 0 {
+		e.write([]byte(","))
+	}
+	e.accounts++
+	e.entries = 0
+
+	e.write([]byte(`{"currency":`))
+	e.value(currency)
+	e.write([]byte(`,"opening_balance":`))
+	e.value(opening)
+	e.write([]byte(`,"entries":[`))
+	return e.err
+}
+
+func (e *jsonEncoder) line(l Line) error {
+	if e.entries > 0 {
+		e.write([]byte(","))
+	}
+	e.entries++
+	e.value(jsonLine{
+		TransactionID:  l.TransactionID,
+		Type:           l.Type,
+		CounterpartyID: l.CounterpartyID,
+		Amount:         l.Amount,
+		Balance:        l.Balance,
+		CreatedAt:      l.CreatedAt,
+	})
+	return e.err
+}
+
+func (e *jsonEncoder) closeAccount(currency string, closing money.Decimal) error {
+	e.write([]byte(`],"closing_balance":`))
+	e.value(closing)
+	e.write([]byte("}"))
+	return e.err
+}
+
+func (e *jsonEncoder) finish() error {
+	e.write([]byte("]}\n"))
+	return e.err
+}
+
+// write и value запоминают первую ошибку, чтобы не проверять каждый фрагмент
+func (e *jsonEncoder) write(p []byte) {
+	if e.err == nil {
+		_, e.err = e.w.Write(p)
+	}
+}
+
+func (e *jsonEncoder) value(v any) {
+	if e.err != nil {
+		return
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		e.err = err
+		return
+	}
+	e.write(data)
+}
@@ -0,0 +1,90 @@
+package statements
+
+import (
+	"encoding/xml"
+	"fmt"
+	"gw-currency-wallet/internal/storages"
+	"gw-currency-wallet/pkg/money"
+	"io"
+	"strings"
+	"time"
+)
+
+// ofxEncoder пишет OFX 2.2: по выписке STMTRS на каждую валюту. В OFX нет полей для входящего
+// и текущего остатка, поэтому выгружаются только движения и исходящий остаток (LEDGERBAL)
+type ofxEncoder struct {
+	w      io.Writer
+	header Header
+	err    error
+}
+
+func newOFXEncoder(w io.Writer) *ofxEncoder {
+	return &ofxEncoder{w: w}
+}
+
+func (e *ofxEncoder) start(h Header) error {
+	e.header = h
+	e.printf(`<?xml version="1.0" encoding="UTF-8" standalone="no"?>
+<?OFX OFXHEADER="200" VERSION="220" SECURITY="NONE" OLDFILEUID="NONE" NEWFILEUID="NONE"?>
+<OFX>
+<SIGNONMSGSRSV1><SONRS><STATUS><CODE>0</CODE><SEVERITY>INFO</SEVERITY></STATUS>`+
+		"<DTSERVER>%s</DTSERVER><LANGUAGE>ENG</LANGUAGE></SONRS></SIGNONMSGSRSV1>\n<BANKMSGSRSV1>\n",
+		ofxTime(h.GeneratedAt))
+	return e.err
+}
+
+func (e *ofxEncoder) openAccount(currency string, opening money.Decimal) error {
+	e.printf("<STMTTRNRS><TRNUID>%d-%s</TRNUID><STATUS><CODE>0</CODE><SEVERITY>INFO</SEVERITY></STATUS>\n", e.header.UserID, currency)
+	e.printf("<STMTRS><CURDEF>%s</CURDEF>", escapeXML(currency))
+	e.printf("<BANKACCTFROM><BANKID>gw-currency-wallet</BANKID><ACCTID>%d-%s</ACCTID><ACCTTYPE>CHECKING</ACCTTYPE></BANKACCTFROM>\n",
+		e.header.UserID, escapeXML(currency))
+	e.printf("<BANKTRANLIST><DTSTART>%s</DTSTART><DTEND>%s</DTEND>\n", ofxTime(e.header.From), ofxTime(e.header.To))
+	return e.err
+}
+
+func (e *ofxEncoder) line(l Line) error {
+	e.printf("<STMTTRN><TRNTYPE>%s</TRNTYPE><DTPOSTED>%s</DTPOSTED><TRNAMT>%s</TRNAMT><FITID>%d</FITID><NAME>%s</NAME><MEMO>transaction %d</MEMO></STMTTRN>\n",
+		ofxTransactionType(l), ofxTime(l.CreatedAt), l.Amount, l.EntryID, escapeXML(string(l.Type)), l.TransactionID)
+	return e.err
+}
+
+func (e *ofxEncoder) closeAccount(currency string, closing money.Decimal) error {
+	e.printf("</BANKTRANLIST>\n<LEDGERBAL><BALAMT>%s</BALAMT><DTASOF>%s</DTASOF></LEDGERBAL></STMTRS></STMTTRNRS>\n",
+		closing, ofxTime(e.header.To))
+	return e.err
+}
+
+func (e *ofxEncoder) finish() error {
+	e.printf("</BANKMSGSRSV1>\n</OFX>\n")
+	return e.err
+}
+
+func (e *ofxEncoder) printf(format string, args ...any) {
+	if e.err == nil {
+		_, e.err = fmt.Fprintf(e.w, format, args...)
+	}
+}
+
+// ofxTransactionType сопоставляет тип операции с TRNTYPE; остальное — по знаку суммы
+func ofxTransactionType(l Line) string {
+	switch {
+	case l.Type == storages.OperationDeposit:
+		return "DEP"
+	case l.Type == storages.OperationTransfer:
+		return "XFER"
+	case l.Amount.Sign() < 0:
+		return "DEBIT"
+	default:
+		return "CREDIT"
+	}
+}
+
+func ofxTime(t time.Time) string {
+	return t.UTC().Format("20060102150405.000") + "[0:GMT]"
+}
+
+func escapeXML(s string) string {
+	var b strings.Builder
+	_ = xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
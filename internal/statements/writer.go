@@ -0,0 +1,169 @@
+package statements
+
+import (
+	"bufio"
+	"fmt"
+	"gw-currency-wallet/internal/storages"
+	"gw-currency-wallet/pkg/money"
+	"io"
+	"sort"
+	"time"
+)
+
+// Format — формат выгрузки выписки
+type Format string
+
+const (
+	CSV  Format = "csv"
+	JSON Format = "json"
+	OFX  Format = "ofx"
+)
+
+// ContentType — тип содержимого ответа для формата
+func (f Format) ContentType() string {
+	switch f {
+	case CSV:
+		return "text/csv; charset=utf-8"
+	case OFX:
+		return "application/x-ofx"
+	default:
+		return "application/json; charset=utf-8"
+	}
+}
+
+// Header — реквизиты выписки: владелец и период [From, To)
+type Header struct {
+	UserID      int64
+	From        time.Time
+	To          time.Time
+	GeneratedAt time.Time
+}
+
+// Line — движение выписки с остатком после него
+type Line struct {
+	storages.StatementEntry
+	Balance money.Decimal
+}
+
+// encoder пишет выписку в конкретном формате. Вызовы идут в порядке: start, затем для
+// каждой валюты openAccount, line для каждого движения и closeAccount, затем finish
+type encoder interface {
+	start(h Header) error
+	openAccount(currency string, opening money.Decimal) error
+	line(l Line) error
+	closeAccount(currency string, closing money.Decimal) error
+	finish() error
+}
+
+// Writer превращает поток хранилища в выписку: считает текущий остаток по каждой валюте
+// и выводит разделы валют без движений за период. Реализует storages.StatementSink.
+// В памяти держится только текущая валюта, поэтому размер выписки не ограничен
+type Writer struct {
+	out *bufio.Writer
+	enc encoder
+
+	opening map[string]money.Decimal
+	pending []string // валюты, раздел которых ещё не выведен, по возрастанию
+
+	current string
+	balance money.Decimal
+	open    bool
+}
+
+func NewWriter(w io.Writer, format Format, header Header) (*Writer, error) {
+	out := bufio.NewWriter(w)
+
+	var enc encoder
+	switch format {
+	case CSV:
+		enc = newCSVEncoder(out)
+	case JSON:
+		enc = newJSONEncoder(out)
+	case OFX:
+		enc = newOFXEncoder(out)
+	default:
+		return nil, fmt.Errorf("unsupported statement format %q", format)
+	}
+
+	if err := enc.start(header); err != nil {
+		return nil, err
+	}
+	return &Writer{out: out, enc: enc}, nil
+}
+
+func (w *Writer) Begin(opening map[string]money.Decimal) error {
+	w.opening = opening
+	w.pending = make([]string, 0, len(opening))
+	for currency := range opening {
+		w.pending = append(w.pending, currency)
+	}
+	sort.Strings(w.pending)
+	return nil
+}
+
+func (w *Writer) Entry(e storages.StatementEntry) error {
+	if !w.open || e.Currency != w.current {
+		if err := w.closeAccount(); err != nil {
+			return err
+		}
+		// Валюты до текущей движений за период не имеют
+		for len(w.pending) > 0 && w.pending[0] < e.Currency {
+			if err := w.emptyAccount(w.pending[0]); err != nil {
+				return err
+			}
+		}
+		if err := w.openAccount(e.Currency); err != nil {
+			return err
+		}
+	}
+
+	balance, err := w.balance.Add(e.Amount)
+	if err != nil {
+		return err
+	}
+	w.balance = balance
+	return w.enc.line(Line{StatementEntry: e, Balance: balance})
+}
+
+// Close дописывает оставшиеся разделы и завершает документ. Без Close выписка неполная
+func (w *Writer) Close() error {
+	if err := w.closeAccount(); err != nil {
+		return err
+	}
+	for len(w.pending) > 0 {
+		if err := w.emptyAccount(w.pending[0]); err != nil {
+			return err
+		}
+	}
+	if err := w.enc.finish(); err != nil {
+		return err
+	}
+	return w.out.Flush()
+}
+
+func (w *Writer) openAccount(currency string) error {
+	if len(w.pending) > 0 && w.pending[0] == currency {
+		w.pending = w.pending[1:]
+	}
+	opening, ok := w.opening[currency]
+	if !ok {
+		return fmt.Errorf("no opening balance for %s", currency)
+	}
+	w.current, w.balance, w.open = currency, opening, true
+	return w.enc.openAccount(currency, opening)
+}
+
+func (w *Writer) closeAccount() error {
+	if !w.open {
+		return nil
+	}
+	w.open = false
+	return w.enc.closeAccount(w.current, w.balance)
+}
+
+func (w *Writer) emptyAccount(currency string) error {
+	if err := w.openAccount(currency); err != nil {
+		return err
+	}
+	return w.closeAccount()
+}
@@ -0,0 +1,111 @@
+package statements
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"gw-currency-wallet/internal/storages"
+	"gw-currency-wallet/pkg/money"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	periodFrom = time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	periodTo   = time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC)
+)
+
+// writeStatement проводит через Writer входящие остатки EUR 5, RUB 0, USD 100 и движения
+// по USD и RUB; у EUR движений за период нет
+func writeStatement(t *testing.T, format Format) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, format, Header{UserID: 7, From: periodFrom, To: periodTo, GeneratedAt: periodTo})
+	require.NoError(t, err)
+
+	require.NoError(t, w.Begin(map[string]money.Decimal{
+		"EUR": money.MustParse("5.00"),
+		"RUB": money.MustParse("0.00"),
+		"USD": money.MustParse("100.00"),
+	}))
+	at := periodFrom.Add(time.Hour)
+	for _, e := range []storages.StatementEntry{
+		{EntryID: 3, TransactionID: 2, Type: storages.OperationExchange, Currency: "RUB", Amount: money.MustParse("900.00"), CreatedAt: at},
+		{EntryID: 1, TransactionID: 2, Type: storages.OperationExchange, Currency: "USD", Amount: money.MustParse("-10.00"), CreatedAt: at},
+		{EntryID: 5, TransactionID: 3, Type: storages.OperationTransfer, CounterpartyID: 9, Currency: "USD", Amount: money.MustParse("25.50"), CreatedAt: at},
+	} {
+		require.NoError(t, w.Entry(e))
+	}
+	require.NoError(t, w.Close())
+	return buf.Bytes()
+}
+
+func TestWriter_CSV(t *testing.T) {
+	records, err := csv.NewReader(bytes.NewReader(writeStatement(t, CSV))).ReadAll()
+	require.NoError(t, err)
+
+	var rows [][]string
+	for _, r := range records[1:] {
+		rows = append(rows, []string{r[0], r[2], r[3], r[4], r[5], r[6]})
+	}
+	assert.Equal(t, [][]string{
+		{"EUR", "", "opening_balance", "", "", "5.00"},
+		{"EUR", "", "closing_balance", "", "", "5.00"},
+		{"RUB", "", "opening_balance", "", "", "0.00"},
+		{"RUB", "2", "exchange", "", "900.00", "900.00"},
+		{"RUB", "", "closing_balance", "", "", "900.00"},
+		{"USD", "", "opening_balance", "", "", "100.00"},
+		{"USD", "2", "exchange", "", "-10.00", "90.00"},
+		{"USD", "3", "transfer", "9", "25.50", "115.50"},
+		{"USD", "", "closing_balance", "", "", "115.50"},
+	}, rows)
+	assert.Equal(t, "2026-01-01T00:00:00Z", records[1][1])
+	assert.Equal(t, "2026-02-01T00:00:00Z", records[2][1])
+}
+
+func TestWriter_JSON(t *testing.T) {
+	var doc struct {
+		UserID   int64 `json:"user_id"`
+		Accounts []struct {
+			Currency       string          `json:"currency"`
+			OpeningBalance json.Number     `json:"opening_balance"`
+			ClosingBalance json.Number     `json:"closing_balance"`
+			Entries        []jsonLineCheck `json:"entries"`
+		} `json:"accounts"`
+	}
+	decoder := json.NewDecoder(bytes.NewReader(writeStatement(t, JSON)))
+	decoder.UseNumber()
+	require.NoError(t, decoder.Decode(&doc))
+
+	assert.Equal(t, int64(7), doc.UserID)
+	require.Len(t, doc.Accounts, 3)
+	assert.Empty(t, doc.Accounts[0].Entries)
+	usd := doc.Accounts[2]
+	assert.Equal(t, "USD", usd.Currency)
+	assert.Equal(t, json.Number("100.00"), usd.OpeningBalance)
+	assert.Equal(t, []jsonLineCheck{
+		{TransactionID: 2, Amount: "-10.00", Balance: "90.00"},
+		{TransactionID: 3, Amount: "25.50", Balance: "115.50"},
+	}, usd.Entries)
+	assert.Equal(t, json.Number("115.50"), usd.ClosingBalance)
+}
+
+type jsonLineCheck struct {
+	TransactionID int64       `json:"transaction_id"`
+	Amount        json.Number `json:"amount"`
+	Balance       json.Number `json:"balance"`
+}
+
+func TestWriter_OFX(t *testing.T) {
+	doc := string(writeStatement(t, OFX))
+
+	assert.Contains(t, doc, "<CURDEF>USD</CURDEF>")
+	assert.Contains(t, doc, "<TRNTYPE>DEBIT</TRNTYPE><DTPOSTED>20260101010000.000[0:GMT]</DTPOSTED><TRNAMT>-10.00</TRNAMT><FITID>1</FITID>")
+	assert.Contains(t, doc, "<TRNTYPE>XFER</TRNTYPE>")
+	assert.Contains(t, doc, "<LEDGERBAL><BALAMT>115.50</BALAMT>")
+	assert.Equal(t, 3, bytes.Count([]byte(doc), []byte("<STMTRS>")))
+	assert.Contains(t, doc, "</OFX>")
+}
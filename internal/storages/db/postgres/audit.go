@@ -0,0 +1,160 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gw-currency-wallet/internal/storages"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// auditLockID — ключ advisory-блокировки: записи запечатываются в цепочку по одной очереди
+const auditLockID = 7426312
+
+const auditColumns = `id, actor_id, user_id, action, target, ip, user_agent, request_id,
+	before_value, after_value, created_at, COALESCE(seq, 0), COALESCE(prev_hash, ''), COALESCE(hash, '')`
+
+// execer — общее у пула соединений и открытой транзакции для записи
+type execer interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+}
+
+// insertAudit добавляет незапечатанную запись; в транзакции операции она появится только вместе с ней
+func insertAudit(ctx context.Context, db execer, entry storages.AuditEntry) error {
+	entry = entry.WithMeta(ctx)
+	_, err := db.Exec(ctx,
+		`INSERT INTO audit_log (actor_id, user_id, action, target, ip, user_agent, request_id, before_value, after_value)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`,
+		entry.ActorID, entry.UserID, entry.Action, entry.Target, entry.IP, entry.UserAgent, entry.RequestID,
+		[]byte(entry.Before), []byte(entry.After),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to write audit entry: %w", err)
+	}
+	return nil
+}
+
+// balanceAudit — запись об изменении балансов операцией txn: счета перечислены
+// в порядке проводок, каждый один раз
+func balanceAudit(txn storages.Transaction, postings []storages.Posting, before, after map[walletCurrency]storages.AuditBalance) storages.AuditEntry {
+	var beforeList, afterList []storages.AuditBalance
+	seen := make(map[walletCurrency]bool, len(postings))
+	for _, posting := range postings {
+		key := walletCurrency{walletID: posting.WalletID, currency: posting.Currency}
+		if !seen[key] {
+			seen[key] = true
+			beforeList = append(beforeList, before[key])
+			afterList = append(afterList, after[key])
+		}
+	}
+	return storages.NewBalanceAudit(txn, beforeList, afterList)
+}
+
+func (p *Postgres) AppendAudit(ctx context.Context, entry storages.AuditEntry) error {
+	return insertAudit(ctx, p.Client, entry)
+}
+
+// ListAudit возвращает записи журнала, новые первыми
+func (p *Postgres) ListAudit(ctx context.Context, filter storages.AuditFilter) ([]storages.AuditEntry, error) {
+	var from, to *time.Time
+	if !filter.From.IsZero() {
+		from = &filter.From
+	}
+	if !filter.To.IsZero() {
+		to = &filter.To
+	}
+
+	rows, err := p.Client.Query(ctx,
+		`SELECT `+auditColumns+` FROM audit_log
+		WHERE ($1 = 0 OR user_id = $1 OR actor_id = $1)
+			AND ($2 = '' OR action = $2)
+			AND ($3::timestamptz IS NULL OR created_at >= $3)
+			AND ($4::timestamptz IS NULL OR created_at < $4)
+			AND ($5 = 0 OR id < $5)
+		ORDER BY id DESC
+		LIMIT $6`,
+		filter.UserID, string(filter.Action), from, to, filter.BeforeID, filter.Limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list audit entries: %w", err)
+	}
+	return collectAudit(rows)
+}
+
+// SealAudit включает до limit незапечатанных записей в цепочку в порядке id. Записи,
+// транзакции которых ещё не завершены, попадут в цепочку при следующем запуске
+func (p *Postgres) SealAudit(ctx context.Context, limit int) (int, error) {
+	tx, err := p.Client.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err = tx.Exec(ctx, "SELECT pg_advisory_xact_lock($1)", auditLockID); err != nil {
+		return 0, fmt.Errorf("failed to lock audit log: %w", err)
+	}
+
+	var seq int64
+	var head string
+	err = tx.QueryRow(ctx, "SELECT seq, hash FROM audit_log WHERE seq IS NOT NULL ORDER BY seq DESC LIMIT 1").Scan(&seq, &head)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return 0, fmt.Errorf("failed to get audit chain head: %w", err)
+	}
+
+	rows, err := tx.Query(ctx, `SELECT `+auditColumns+` FROM audit_log WHERE seq IS NULL ORDER BY id LIMIT $1`, limit)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get unsealed audit entries: %w", err)
+	}
+	entries, err := collectAudit(rows)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, e := range entries {
+		seq++
+		e.Seq, e.PrevHash = seq, head
+		e.Hash = e.ComputeHash()
+		_, err = tx.Exec(ctx, "UPDATE audit_log SET seq = $1, prev_hash = $2, hash = $3 WHERE id = $4", e.Seq, e.PrevHash, e.Hash, e.ID)
+		if err != nil {
+			return 0, fmt.Errorf("failed to seal audit entry: %w", err)
+		}
+		head = e.Hash
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return len(entries), nil
+}
+
+func (p *Postgres) AuditChain(ctx context.Context, afterSeq int64, limit int) ([]storages.AuditEntry, error) {
+	rows, err := p.Client.Query(ctx,
+		`SELECT `+auditColumns+` FROM audit_log WHERE seq > $1 ORDER BY seq LIMIT $2`,
+		afterSeq, limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get audit chain: %w", err)
+	}
+	return collectAudit(rows)
+}
+
+func collectAudit(rows pgx.Rows) ([]storages.AuditEntry, error) {
+	defer rows.Close()
+
+	var entries []storages.AuditEntry
+	for rows.Next() {
+		var e storages.AuditEntry
+		var before, after []byte
+		err := rows.Scan(&e.ID, &e.ActorID, &e.UserID, &e.Action, &e.Target, &e.IP, &e.UserAgent, &e.RequestID,
+			&before, &after, &e.CreatedAt, &e.Seq, &e.PrevHash, &e.Hash)
+		if err != nil {
+			return nil, err
+		}
+		e.Before, e.After = before, after
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
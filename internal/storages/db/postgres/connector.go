@@ -30,12 +30,11 @@ func NewPostgresRepository(ctx context.Context, cfg *config.StorageConfig, log *
 	maxAttempts := 3
 	var pool *pgxpool.Pool
 	err := repeatable.DoWithTries(func() error {
-		var cancel context.CancelFunc
-		ctx, cancel = context.WithTimeout(ctx, 5*time.Second)
+		attemptCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
 		defer cancel()
 
 		var err error
-		pool, err = pgxpool.New(ctx, dsn)
+		pool, err = pgxpool.New(attemptCtx, dsn)
 		if err != nil {
 			return err
 		}
@@ -48,9 +47,19 @@ func NewPostgresRepository(ctx context.Context, cfg *config.StorageConfig, log *
 
 	log.Info("connected to PostgreSQL")
 
-	return &Postgres{
+	storage := &Postgres{
 		Client: pool,
 		logger: log,
 		cfg:    *cfg,
-	}, pool.Close
+	}
+
+	// Устаревшая схема — повод упасть при деплое, а не на первом запросе
+	if cfg.CheckSchema {
+		if err = storage.CheckSchema(ctx); err != nil {
+			pool.Close()
+			log.Fatalf("schema check failed: %v", err)
+		}
+	}
+
+	return storage, pool.Close
 }
@@ -0,0 +1,44 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gw-currency-wallet/internal/storages"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// ListCurrencies возвращает справочник валют, включая отключённые
+func (p *Postgres) ListCurrencies(ctx context.Context) ([]storages.Currency, error) {
+	rows, err := p.Client.Query(ctx, "SELECT code, name, minor_units, enabled FROM currencies ORDER BY code")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list currencies: %w", err)
+	}
+	defer rows.Close()
+
+	var currencies []storages.Currency
+	for rows.Next() {
+		var c storages.Currency
+		if err = rows.Scan(&c.Code, &c.Name, &c.MinorUnits, &c.Enabled); err != nil {
+			return nil, err
+		}
+		currencies = append(currencies, c)
+	}
+	return currencies, rows.Err()
+}
+
+func (p *Postgres) GetCurrency(ctx context.Context, code string) (storages.Currency, error) {
+	var c storages.Currency
+	err := p.Client.QueryRow(ctx,
+		"SELECT code, name, minor_units, enabled FROM currencies WHERE code = $1",
+		code,
+	).Scan(&c.Code, &c.Name, &c.MinorUnits, &c.Enabled)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return c, fmt.Errorf("%w: %s", storages.ErrCurrencyNotFound, code)
+		}
+		return c, fmt.Errorf("failed to get currency: %w", err)
+	}
+	return c, nil
+}
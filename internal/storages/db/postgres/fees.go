@@ -0,0 +1,69 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gw-currency-wallet/internal/storages"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// GetFeeRule выбирает правило пользователя или его тарифа, подходящее к паре: правило
+// пользователя перекрывает тарифное, правило с точной валютой — правило для любой валюты
+func (p *Postgres) GetFeeRule(ctx context.Context, userID int64, fromCurrency, toCurrency string) (storages.FeeRule, error) {
+	var rule storages.FeeRule
+	err := p.Client.QueryRow(ctx,
+		`SELECT COALESCE(f.tier, ''), COALESCE(f.user_id, 0), COALESCE(f.from_currency, ''), COALESCE(f.to_currency, ''),
+			f.spread_percent, f.min_fee
+		FROM exchange_fees f JOIN users u ON u.id = $1
+		WHERE (f.user_id = u.id OR f.tier = u.tier)
+			AND (f.from_currency IS NULL OR f.from_currency = $2)
+			AND (f.to_currency IS NULL OR f.to_currency = $3)
+		ORDER BY f.user_id NULLS LAST, f.from_currency NULLS LAST, f.to_currency NULLS LAST
+		LIMIT 1`,
+		userID, fromCurrency, toCurrency,
+	).Scan(&rule.Tier, &rule.UserID, &rule.FromCurrency, &rule.ToCurrency, &rule.Percent, &rule.MinFee)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return storages.FeeRule{}, nil
+		}
+		return rule, fmt.Errorf("failed to get fee rule: %w", err)
+	}
+	return rule, nil
+}
+
+func (p *Postgres) GetFeeRevenue(ctx context.Context, from, to time.Time) ([]storages.FeeRevenue, error) {
+	var fromAt, toAt *time.Time
+	if !from.IsZero() {
+		fromAt = &from
+	}
+	if !to.IsZero() {
+		toAt = &to
+	}
+
+	rows, err := p.Client.Query(ctx,
+		`SELECT currency, SUM(amount), COUNT(*)
+		FROM ledger_entries
+		WHERE account = $1
+			AND ($2::timestamptz IS NULL OR created_at >= $2)
+			AND ($3::timestamptz IS NULL OR created_at < $3)
+		GROUP BY currency ORDER BY currency`,
+		storages.AccountFees, fromAt, toAt,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get fee revenue: %w", err)
+	}
+	defer rows.Close()
+
+	var revenue []storages.FeeRevenue
+	for rows.Next() {
+		var r storages.FeeRevenue
+		if err = rows.Scan(&r.Currency, &r.Amount, &r.Exchanges); err != nil {
+			return nil, err
+		}
+		revenue = append(revenue, r)
+	}
+	return revenue, rows.Err()
+}
@@ -0,0 +1,236 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gw-currency-wallet/internal/storages"
+	"gw-currency-wallet/pkg/money"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+const holdColumns = "id, user_id, currency, amount, captured_amount, status, COALESCE(transaction_id, 0), expires_at, created_at, updated_at"
+
+// PlaceHold резервирует amount на балансе: held растёт, учётный баланс не меняется
+func (p *Postgres) PlaceHold(ctx context.Context, userID int64, currency string, amount money.Decimal, expiresAt time.Time) (storages.Hold, error) {
+	tx, err := p.Client.Begin(ctx)
+	if err != nil {
+		return storages.Hold{}, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if err = reserveHeld(ctx, tx, userID, currency, amount); err != nil {
+		return storages.Hold{}, err
+	}
+
+	hold, err := scanHold(tx.QueryRow(ctx,
+		`INSERT INTO holds (user_id, currency, amount, expires_at) VALUES ($1, $2, $3, $4)
+		RETURNING `+holdColumns,
+		userID, currency, amount, expiresAt,
+	))
+	if err != nil {
+		return hold, fmt.Errorf("failed to create hold: %w", err)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return hold, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return hold, nil
+}
+
+func (p *Postgres) GetHold(ctx context.Context, userID, holdID int64) (storages.Hold, error) {
+	hold, err := scanHold(p.Client.QueryRow(ctx,
+		"SELECT "+holdColumns+" FROM holds WHERE id = $1 AND user_id = $2",
+		holdID, userID,
+	))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return hold, storages.ErrHoldNotFound
+		}
+		return hold, fmt.Errorf("failed to get hold: %w", err)
+	}
+	return hold, nil
+}
+
+// CaptureHold списывает amount (не больше суммы холда) через журнал и освобождает остаток.
+// Списание расходует лимит charge
+func (p *Postgres) CaptureHold(ctx context.Context, userID, holdID int64, amount money.Decimal, charge storages.LimitCharge) (storages.Hold, error) {
+	tx, err := p.Client.Begin(ctx)
+	if err != nil {
+		return storages.Hold{}, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	now := time.Now()
+	if err = lockLimits(ctx, tx, userID, charge, now); err != nil {
+		return storages.Hold{}, err
+	}
+	hold, err := lockActiveHold(ctx, tx, userID, holdID)
+	if err != nil {
+		return hold, err
+	}
+	if amount.Cmp(hold.Amount) > 0 {
+		return hold, storages.ErrCaptureExceedsHold
+	}
+
+	// Сначала снимаем резерв, затем списываем — проверка средств увидит освобождённую сумму
+	if err = releaseHeld(ctx, tx, hold.UserID, hold.Currency, hold.Amount); err != nil {
+		return hold, err
+	}
+	txn, err := postInTx(ctx, tx, userID, storages.OperationCapture, 0,
+		[]storages.Posting{{Currency: hold.Currency, Amount: amount.Neg()}})
+	if err != nil {
+		return hold, err
+	}
+	if err = recordLimitUsage(ctx, tx, userID, charge, now); err != nil {
+		return hold, err
+	}
+
+	hold, err = scanHold(tx.QueryRow(ctx,
+		`UPDATE holds SET status = $1, captured_amount = $2, transaction_id = $3, updated_at = now()
+		WHERE id = $4 RETURNING `+holdColumns,
+		storages.HoldCaptured, amount, txn.ID, hold.ID,
+	))
+	if err != nil {
+		return hold, fmt.Errorf("failed to update hold: %w", err)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return hold, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return hold, nil
+}
+
+// VoidHold отменяет холд и возвращает зарезервированную сумму в доступный баланс
+func (p *Postgres) VoidHold(ctx context.Context, userID, holdID int64) (storages.Hold, error) {
+	tx, err := p.Client.Begin(ctx)
+	if err != nil {
+		return storages.Hold{}, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	hold, err := lockActiveHold(ctx, tx, userID, holdID)
+	if err != nil {
+		return hold, err
+	}
+	if err = releaseHeld(ctx, tx, hold.UserID, hold.Currency, hold.Amount); err != nil {
+		return hold, err
+	}
+
+	hold, err = scanHold(tx.QueryRow(ctx,
+		"UPDATE holds SET status = $1, updated_at = now() WHERE id = $2 RETURNING "+holdColumns,
+		storages.HoldVoided, hold.ID,
+	))
+	if err != nil {
+		return hold, fmt.Errorf("failed to update hold: %w", err)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return hold, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return hold, nil
+}
+
+// ExpireHolds переводит просроченные активные холды в expired и освобождает их суммы.
+// Холды, заблокированные параллельным capture/void, пропускаются до следующего запуска
+func (p *Postgres) ExpireHolds(ctx context.Context, now time.Time) (int, error) {
+	var expired int
+	err := p.Client.QueryRow(ctx,
+		`WITH expired AS (
+			UPDATE holds SET status = $1, updated_at = now()
+			WHERE id IN (
+				SELECT id FROM holds WHERE status = $2 AND expires_at <= $3
+				FOR UPDATE SKIP LOCKED
+			)
+			RETURNING user_id, currency, amount
+		), released AS (
+			UPDATE balances b SET held = b.held - e.amount, version = b.version + 1
+			FROM (SELECT user_id, currency, SUM(amount) AS amount FROM expired GROUP BY user_id, currency) e
+			JOIN wallets w ON w.user_id = e.user_id AND w.is_default
+			WHERE b.wallet_id = w.id AND b.currency = e.currency
+		)
+		SELECT count(*) FROM expired`,
+		storages.HoldExpired, storages.HoldActive, now,
+	).Scan(&expired)
+	if err != nil {
+		return 0, fmt.Errorf("failed to expire holds: %w", err)
+	}
+	return expired, nil
+}
+
+// lockActiveHold блокирует холд пользователя и проверяет, что его ещё можно закрыть.
+// Холды блокируются раньше балансов — тот же порядок, что в ExpireHolds
+func lockActiveHold(ctx context.Context, tx pgx.Tx, userID, holdID int64) (storages.Hold, error) {
+	hold, err := scanHold(tx.QueryRow(ctx,
+		"SELECT "+holdColumns+" FROM holds WHERE id = $1 AND user_id = $2 FOR UPDATE",
+		holdID, userID,
+	))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return hold, storages.ErrHoldNotFound
+		}
+		return hold, fmt.Errorf("failed to get hold: %w", err)
+	}
+
+	if hold.Status != storages.HoldActive {
+		return hold, fmt.Errorf("%w: %s", storages.ErrHoldNotActive, hold.Status)
+	}
+	if !hold.ExpiresAt.After(time.Now()) {
+		return hold, storages.ErrHoldExpired
+	}
+	return hold, nil
+}
+
+// reserveHeld переносит amount из доступного баланса кошелька по умолчанию в held.
+// Общий механизм холдов и заявок
+func reserveHeld(ctx context.Context, tx pgx.Tx, userID int64, currency string, amount money.Decimal) error {
+	postings, err := resolveWallets(ctx, tx, userID, []storages.Posting{{Currency: currency, Amount: amount}})
+	if err != nil {
+		return err
+	}
+	balances, err := lockBalances(ctx, tx, userID, postings)
+	if err != nil {
+		return err
+	}
+	walletID := postings[0].WalletID
+	available, ok := balances[walletCurrency{walletID: walletID, currency: currency}]
+	if !ok {
+		return fmt.Errorf("%w: user %d, currency %s", storages.ErrBalanceNotFound, userID, currency)
+	}
+	if available.Cmp(amount) < 0 {
+		return storages.ErrInsufficientFunds
+	}
+
+	_, err = tx.Exec(ctx,
+		"UPDATE balances SET held = held + $1, version = version + 1 WHERE wallet_id = $2 AND currency = $3",
+		amount, walletID, currency,
+	)
+	if err != nil {
+		if isCheckViolation(err) {
+			return storages.ErrInsufficientFunds
+		}
+		return fmt.Errorf("failed to update balance: %w", err)
+	}
+	return nil
+}
+
+func releaseHeld(ctx context.Context, tx pgx.Tx, userID int64, currency string, amount money.Decimal) error {
+	_, err := tx.Exec(ctx,
+		`UPDATE balances SET held = held - $1, version = version + 1
+		WHERE wallet_id = (SELECT id FROM wallets WHERE user_id = $2 AND is_default) AND currency = $3`,
+		amount, userID, currency,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to release hold: %w", err)
+	}
+	return nil
+}
+
+func scanHold(row pgx.Row) (storages.Hold, error) {
+	var h storages.Hold
+	err := row.Scan(&h.ID, &h.UserID, &h.Currency, &h.Amount, &h.CapturedAmount, &h.Status,
+		&h.TransactionID, &h.ExpiresAt, &h.CreatedAt, &h.UpdatedAt)
+	return h, err
+}
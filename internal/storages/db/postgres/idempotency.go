@@ -0,0 +1,74 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gw-currency-wallet/internal/storages"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// ReserveIdempotencyKey занимает ключ за запросом до now+lease. Запись без ответа с истёкшим
+// захватом осталась от упавшего запроса и занимается заново. Если ключ занят, возвращает
+// существующую запись и false — вызывающий решает, повторить ответ или отклонить запрос
+func (p *Postgres) ReserveIdempotencyKey(ctx context.Context, userID int64, key, fingerprint string, now time.Time, lease time.Duration) (storages.IdempotencyRecord, bool, error) {
+	record := storages.IdempotencyRecord{UserID: userID, Key: key, Fingerprint: fingerprint, LockedUntil: now.Add(lease)}
+	err := p.Client.QueryRow(ctx,
+		`INSERT INTO idempotency_keys (user_id, key, fingerprint, locked_until) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (user_id, key) DO UPDATE
+			SET fingerprint = EXCLUDED.fingerprint, locked_until = EXCLUDED.locked_until, created_at = now()
+			WHERE idempotency_keys.status_code IS NULL
+				AND (idempotency_keys.locked_until IS NULL OR idempotency_keys.locked_until <= $5)
+		RETURNING created_at`,
+		userID, key, fingerprint, record.LockedUntil, now,
+	).Scan(&record.CreatedAt)
+	if err == nil {
+		return record, true, nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return record, false, fmt.Errorf("failed to reserve idempotency key: %w", err)
+	}
+
+	record = storages.IdempotencyRecord{UserID: userID, Key: key}
+	var statusCode *int
+	var lockedUntil *time.Time
+	err = p.Client.QueryRow(ctx,
+		"SELECT fingerprint, status_code, response, locked_until, created_at FROM idempotency_keys WHERE user_id = $1 AND key = $2",
+		userID, key,
+	).Scan(&record.Fingerprint, &statusCode, &record.Response, &lockedUntil, &record.CreatedAt)
+	if err != nil {
+		return record, false, fmt.Errorf("failed to get idempotency key: %w", err)
+	}
+	if statusCode != nil {
+		record.StatusCode = *statusCode
+	}
+	if lockedUntil != nil {
+		record.LockedUntil = *lockedUntil
+	}
+	return record, false, nil
+}
+
+func (p *Postgres) SaveIdempotentResponse(ctx context.Context, userID int64, key string, statusCode int, response []byte) error {
+	_, err := p.Client.Exec(ctx,
+		"UPDATE idempotency_keys SET status_code = $1, response = $2 WHERE user_id = $3 AND key = $4",
+		statusCode, response, userID, key,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save idempotent response: %w", err)
+	}
+	return nil
+}
+
+// ReleaseIdempotencyKey освобождает незавершённый ключ, чтобы запрос можно было повторить
+func (p *Postgres) ReleaseIdempotencyKey(ctx context.Context, userID int64, key string) error {
+	_, err := p.Client.Exec(ctx,
+		"DELETE FROM idempotency_keys WHERE user_id = $1 AND key = $2 AND status_code IS NULL",
+		userID, key,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to release idempotency key: %w", err)
+	}
+	return nil
+}
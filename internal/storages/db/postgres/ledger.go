@@ -0,0 +1,472 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gw-currency-wallet/internal/storages"
+	"gw-currency-wallet/pkg/money"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// balanceScale — масштаб колонок сумм DECIMAL(15,2)
+const balanceScale = 2
+
+// PostTransaction атомарно меняет балансы и записывает операцию в журнал.
+// Каждое изменение кошелька сопровождается встречной проводкой на служебный счёт,
+// поэтому сумма дебетов по операции всегда равна сумме кредитов
+func (p *Postgres) PostTransaction(ctx context.Context, userID int64, opType storages.OperationType, postings ...storages.Posting) (storages.Transaction, error) {
+	if len(postings) == 0 {
+		return storages.Transaction{UserID: userID, Type: opType}, fmt.Errorf("transaction has no postings")
+	}
+
+	tx, err := p.Client.Begin(ctx)
+	if err != nil {
+		return storages.Transaction{}, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	txn, err := postInTx(ctx, tx, userID, opType, 0, postings)
+	if err != nil {
+		return txn, err
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return txn, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return txn, nil
+}
+
+// postInTx записывает операцию в уже открытой транзакции БД. counterpartyID — второй
+// участник перевода, 0 для операций одного пользователя
+func postInTx(ctx context.Context, tx pgx.Tx, userID int64, opType storages.OperationType, counterpartyID int64, postings []storages.Posting) (storages.Transaction, error) {
+	txn := storages.Transaction{UserID: userID, Type: opType, CounterpartyID: counterpartyID}
+
+	var counterparty *int64
+	if counterpartyID != 0 {
+		counterparty = &counterpartyID
+	}
+	err := tx.QueryRow(ctx,
+		"INSERT INTO transactions (user_id, type, counterparty_id) VALUES ($1, $2, $3) RETURNING id, created_at",
+		userID, opType, counterparty,
+	).Scan(&txn.ID, &txn.CreatedAt)
+	if err != nil {
+		return txn, fmt.Errorf("failed to create transaction: %w", err)
+	}
+
+	postings, err = resolveWallets(ctx, tx, userID, postings)
+	if err != nil {
+		return txn, err
+	}
+	balances, err := lockBalances(ctx, tx, userID, postings)
+	if err != nil {
+		return txn, err
+	}
+
+	before := make(map[walletCurrency]storages.AuditBalance, len(postings))
+	after := make(map[walletCurrency]storages.AuditBalance, len(postings))
+	for _, posting := range postings {
+		key := walletCurrency{walletID: posting.WalletID, currency: posting.Currency}
+		current, ok := balances[key]
+		if !ok {
+			return txn, fmt.Errorf("%w: user %d, currency %s", storages.ErrBalanceNotFound, userID, posting.Currency)
+		}
+		// DECIMAL(15,2) молча округлил бы лишние знаки — такую проводку отклоняем
+		if _, err := posting.Amount.Rescale(balanceScale); err != nil {
+			return txn, err
+		}
+		updated, err := current.Add(posting.Amount)
+		if err != nil {
+			return txn, err
+		}
+		if updated.Sign() < 0 {
+			return txn, storages.ErrInsufficientFunds
+		}
+		balances[key] = updated
+		// Версия растёт один раз за операцию, даже если баланс изменён несколькими проводками
+		bump := 0
+		if _, ok = before[key]; !ok {
+			bump = 1
+			before[key] = storages.AuditBalance{WalletID: posting.WalletID, Currency: posting.Currency, Amount: current}
+		}
+		after[key] = storages.AuditBalance{WalletID: posting.WalletID, Currency: posting.Currency, Amount: updated}
+
+		_, err = tx.Exec(ctx,
+			"UPDATE balances SET amount = amount + $1, version = version + $4 WHERE wallet_id = $2 AND currency = $3",
+			posting.Amount, posting.WalletID, posting.Currency, bump,
+		)
+		if err != nil {
+			if isCheckViolation(err) {
+				return txn, storages.ErrInsufficientFunds
+			}
+			if isNumericOverflow(err) {
+				return txn, money.ErrOverflow
+			}
+			return txn, fmt.Errorf("failed to update balance: %w", err)
+		}
+
+		walletSide, contraSide := storages.Credit, storages.Debit
+		if posting.Amount.Sign() < 0 {
+			walletSide, contraSide = storages.Debit, storages.Credit
+		}
+
+		legs := []storages.Entry{
+			{Account: storages.AccountWallet, WalletID: posting.WalletID, Direction: walletSide},
+			{Account: posting.Contra(opType), Direction: contraSide},
+		}
+		for _, entry := range legs {
+			entry.TransactionID = txn.ID
+			entry.UserID = userID
+			entry.Currency = posting.Currency
+			err = tx.QueryRow(ctx,
+				`INSERT INTO ledger_entries (transaction_id, account, user_id, wallet_id, currency, direction, amount)
+				VALUES ($1, $2, $3, NULLIF($4::bigint, 0), $5, $6, $7) RETURNING id, amount, created_at`,
+				txn.ID, entry.Account, userID, entry.WalletID, posting.Currency, entry.Direction, posting.Amount.Abs(),
+			).Scan(&entry.ID, &entry.Amount, &entry.CreatedAt)
+			if err != nil {
+				return txn, fmt.Errorf("failed to write ledger entry: %w", err)
+			}
+			txn.Entries = append(txn.Entries, entry)
+		}
+	}
+
+	if err = insertAudit(ctx, tx, balanceAudit(txn, postings, before, after)); err != nil {
+		return txn, err
+	}
+	return txn, nil
+}
+
+func (p *Postgres) Credit(ctx context.Context, userID int64, currency string, amount money.Decimal) (storages.Transaction, error) {
+	return p.PostTransaction(ctx, userID, storages.OperationDeposit, storages.Posting{Currency: currency, Amount: amount})
+}
+
+func (p *Postgres) Debit(ctx context.Context, userID int64, currency string, amount money.Decimal) (storages.Transaction, error) {
+	return p.PostTransaction(ctx, userID, storages.OperationWithdraw, storages.Posting{Currency: currency, Amount: amount.Neg()})
+}
+
+func (p *Postgres) ExecuteExchange(ctx context.Context, userID int64, fromCurrency, toCurrency string, amount, received money.Decimal) (storages.Transaction, error) {
+	return p.PostTransaction(ctx, userID, storages.OperationExchange,
+		storages.Posting{Currency: fromCurrency, Amount: amount.Neg()},
+		storages.Posting{Currency: toCurrency, Amount: received},
+	)
+}
+
+// Transfer переводит amount от fromUserID к toUserID. Списание и зачисление записываются
+// двумя связанными операциями — у каждого участника своя — в одной транзакции БД вместе
+// с events. Перевод расходует лимит отправителя charge. Возвращает операцию отправителя
+func (p *Postgres) Transfer(ctx context.Context, fromUserID, toUserID int64, currency string, amount money.Decimal, charge storages.LimitCharge, events ...storages.OutboxEvent) (storages.Transaction, error) {
+	if fromUserID == toUserID {
+		return storages.Transaction{}, storages.ErrSelfTransfer
+	}
+
+	tx, err := p.Client.Begin(ctx)
+	if err != nil {
+		return storages.Transaction{}, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var exists bool
+	if err = tx.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM users WHERE id = $1)", toUserID).Scan(&exists); err != nil {
+		return storages.Transaction{}, fmt.Errorf("failed to get recipient: %w", err)
+	}
+	if !exists {
+		return storages.Transaction{}, fmt.Errorf("%w: recipient %d", storages.ErrUserNotFound, toUserID)
+	}
+
+	now := time.Now()
+	if err = lockLimits(ctx, tx, fromUserID, charge, now); err != nil {
+		return storages.Transaction{}, err
+	}
+
+	// Блокируем балансы участников в порядке id, чтобы встречные переводы не взаимоблокировались.
+	// Перевод идёт между кошельками по умолчанию
+	posting := []storages.Posting{{Currency: currency, Amount: amount}}
+	first, second := fromUserID, toUserID
+	if first > second {
+		first, second = second, first
+	}
+	for _, userID := range []int64{first, second} {
+		resolved, err := resolveWallets(ctx, tx, userID, posting)
+		if err != nil {
+			return storages.Transaction{}, err
+		}
+		if _, err = lockBalances(ctx, tx, userID, resolved); err != nil {
+			return storages.Transaction{}, err
+		}
+	}
+
+	txn, err := postInTx(ctx, tx, fromUserID, storages.OperationTransfer, toUserID,
+		[]storages.Posting{{Currency: currency, Amount: amount.Neg()}})
+	if err != nil {
+		return txn, err
+	}
+	if _, err = postInTx(ctx, tx, toUserID, storages.OperationTransfer, fromUserID, posting); err != nil {
+		return txn, err
+	}
+	if err = recordLimitUsage(ctx, tx, fromUserID, charge, now); err != nil {
+		return txn, err
+	}
+	if err = insertOutbox(ctx, tx, events, txn.ID); err != nil {
+		return txn, err
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return txn, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return txn, nil
+}
+
+func (p *Postgres) GetTransactionEntries(ctx context.Context, transactionID int64) ([]storages.Entry, error) {
+	return p.queryEntries(ctx,
+		`SELECT id, transaction_id, account, user_id, COALESCE(wallet_id, 0), currency, direction, amount, created_at
+		FROM ledger_entries WHERE transaction_id = $1 ORDER BY id`,
+		transactionID,
+	)
+}
+
+func (p *Postgres) queryEntries(ctx context.Context, sql string, args ...any) ([]storages.Entry, error) {
+	rows, err := p.Client.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get ledger entries: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []storages.Entry
+	for rows.Next() {
+		var e storages.Entry
+		if err = rows.Scan(&e.ID, &e.TransactionID, &e.Account, &e.UserID, &e.WalletID, &e.Currency, &e.Direction, &e.Amount, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+// GetLedgerBalance считает баланс кошелька по журналу — для сверки с таблицей balances
+func (p *Postgres) GetLedgerBalance(ctx context.Context, userID int64, currency string) (money.Decimal, error) {
+	var amount money.Decimal
+	err := p.Client.QueryRow(ctx,
+		`SELECT COALESCE(SUM(CASE WHEN direction = 'credit' THEN amount ELSE -amount END), 0)
+		FROM ledger_entries WHERE user_id = $1 AND currency = $2 AND account = $3`,
+		userID, currency, storages.AccountWallet,
+	).Scan(&amount)
+	if err != nil {
+		return amount, fmt.Errorf("failed to get ledger balance: %w", err)
+	}
+	return amount, nil
+}
+
+// ListTransactions возвращает операции пользователя от новых к старым. Пагинация — по курсору
+// из id: новые операции не сдвигают уже выданные страницы
+func (p *Postgres) ListTransactions(ctx context.Context, userID int64, filter storages.TransactionFilter) ([]storages.Transaction, error) {
+	if filter.WalletID != 0 {
+		if _, err := p.GetWallet(ctx, userID, filter.WalletID); err != nil {
+			return nil, err
+		}
+	}
+
+	var from, to *time.Time
+	if !filter.From.IsZero() {
+		from = &filter.From
+	}
+	if !filter.To.IsZero() {
+		to = &filter.To
+	}
+
+	rows, err := p.Client.Query(ctx,
+		`SELECT t.id, t.user_id, t.type, COALESCE(t.counterparty_id, 0), t.created_at FROM transactions t
+		WHERE ($8 = 0 AND t.user_id = $1 OR $8 <> 0 AND EXISTS (
+				SELECT 1 FROM ledger_entries w
+				WHERE w.transaction_id = t.id AND w.account = 'wallet' AND w.wallet_id = $8
+			))
+			AND ($2 = '' OR EXISTS (
+				SELECT 1 FROM ledger_entries e
+				WHERE e.transaction_id = t.id AND e.account = 'wallet' AND e.currency = $2
+					AND ($8 = 0 OR e.wallet_id = $8)
+			))
+			AND ($3 = '' OR t.type = $3)
+			AND ($4::timestamptz IS NULL OR t.created_at >= $4)
+			AND ($5::timestamptz IS NULL OR t.created_at < $5)
+			AND ($6 = 0 OR t.id < $6)
+		ORDER BY t.id DESC
+		LIMIT $7`,
+		userID, filter.Currency, string(filter.Type), from, to, filter.BeforeID, filter.Limit, filter.WalletID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list transactions: %w", err)
+	}
+	defer rows.Close()
+
+	var txns []storages.Transaction
+	index := make(map[int64]int)
+	ids := make([]int64, 0, filter.Limit)
+	for rows.Next() {
+		var t storages.Transaction
+		if err = rows.Scan(&t.ID, &t.UserID, &t.Type, &t.CounterpartyID, &t.CreatedAt); err != nil {
+			return nil, err
+		}
+		index[t.ID] = len(txns)
+		ids = append(ids, t.ID)
+		txns = append(txns, t)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(ids) == 0 {
+		return txns, nil
+	}
+
+	// В списке показываем только движения по кошельку пользователя, а в выборке по кошельку — по нему
+	entries, err := p.Client.Query(ctx,
+		`SELECT id, transaction_id, account, user_id, COALESCE(wallet_id, 0), currency, direction, amount, created_at
+		FROM ledger_entries WHERE transaction_id = ANY($1) AND account = $2 AND ($3 = 0 OR wallet_id = $3) ORDER BY id`,
+		ids, storages.AccountWallet, filter.WalletID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get ledger entries: %w", err)
+	}
+	defer entries.Close()
+
+	for entries.Next() {
+		var e storages.Entry
+		if err = entries.Scan(&e.ID, &e.TransactionID, &e.Account, &e.UserID, &e.WalletID, &e.Currency, &e.Direction, &e.Amount, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		i := index[e.TransactionID]
+		txns[i].Entries = append(txns[i].Entries, e)
+	}
+	return txns, entries.Err()
+}
+
+// GetTransaction возвращает операцию пользователя со всеми проводками. Операцию по кошельку,
+// доступному пользователю (свой или общий, где он участник), видно и без авторства — как в истории
+// кошелька, с проводками только по доступным кошелькам
+func (p *Postgres) GetTransaction(ctx context.Context, userID, transactionID int64) (storages.Transaction, error) {
+	var t storages.Transaction
+	err := p.Client.QueryRow(ctx,
+		`SELECT t.id, t.user_id, t.type, COALESCE(t.counterparty_id, 0), t.created_at FROM transactions t
+		WHERE t.id = $2 AND (t.user_id = $1 OR EXISTS (
+			SELECT 1 FROM ledger_entries e
+			WHERE e.transaction_id = t.id AND e.account = 'wallet' AND e.wallet_id IN (SELECT w.id`+walletAccess+`)
+		))`,
+		userID, transactionID,
+	).Scan(&t.ID, &t.UserID, &t.Type, &t.CounterpartyID, &t.CreatedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return t, storages.ErrTransactionNotFound
+		}
+		return t, fmt.Errorf("failed to get transaction: %w", err)
+	}
+
+	if t.UserID == userID {
+		t.Entries, err = p.GetTransactionEntries(ctx, t.ID)
+		return t, err
+	}
+	t.Entries, err = p.queryEntries(ctx,
+		`SELECT id, transaction_id, account, user_id, COALESCE(wallet_id, 0), currency, direction, amount, created_at
+		FROM ledger_entries
+		WHERE transaction_id = $2 AND account = 'wallet' AND wallet_id IN (SELECT w.id`+walletAccess+`)
+		ORDER BY id`,
+		userID, t.ID,
+	)
+	return t, err
+}
+
+// lockBalances блокирует строки балансов операции (SELECT ... FOR UPDATE) в порядке кошельков
+// и валют и возвращает доступные балансы,
+// чтобы параллельные операции не прошли проверку средств одновременно и не взаимоблокировались.
+// Кошельки проводок уже должны быть разрешены resolveWallets.
+// Замороженный счёт — ErrAccountFrozen, версия не равна Posting.IfVersion — ErrVersionMismatch.
+// Счета во включённых валютах, добавленных после открытия кошелька, открываются здесь же
+func lockBalances(ctx context.Context, tx pgx.Tx, userID int64, postings []storages.Posting) (map[walletCurrency]money.Decimal, error) {
+	walletIDs := make([]int64, 0, len(postings))
+	currencies := make([]string, 0, len(postings))
+	expected := make(map[walletCurrency]int64)
+	for _, posting := range postings {
+		walletIDs = append(walletIDs, posting.WalletID)
+		currencies = append(currencies, posting.Currency)
+		if posting.IfVersion != 0 {
+			expected[walletCurrency{walletID: posting.WalletID, currency: posting.Currency}] = posting.IfVersion
+		}
+	}
+
+	_, err := tx.Exec(ctx,
+		`INSERT INTO balances (user_id, wallet_id, currency, amount)
+		SELECT $1, a.wallet_id, c.code, 0
+		FROM unnest($2::bigint[], $3::text[]) AS a(wallet_id, currency)
+		JOIN currencies c ON c.code = a.currency AND c.enabled
+		ON CONFLICT (wallet_id, currency) DO NOTHING`,
+		userID, walletIDs, currencies,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open balances: %w", err)
+	}
+
+	// Проверка средств идёт по доступному балансу: зарезервированное холдами списать нельзя
+	rows, err := tx.Query(ctx,
+		`SELECT b.wallet_id, b.currency, b.amount - b.held, b.frozen, b.version
+		FROM balances b
+		WHERE (b.wallet_id, b.currency) IN (SELECT * FROM unnest($1::bigint[], $2::text[]))
+		ORDER BY b.wallet_id, b.currency FOR UPDATE`,
+		walletIDs, currencies,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock balances: %w", err)
+	}
+	defer rows.Close()
+
+	balances := make(map[walletCurrency]money.Decimal, len(postings))
+	for rows.Next() {
+		var key walletCurrency
+		var amount money.Decimal
+		var frozen bool
+		var version int64
+		if err = rows.Scan(&key.walletID, &key.currency, &amount, &frozen, &version); err != nil {
+			return nil, err
+		}
+		if frozen {
+			return nil, fmt.Errorf("%w: user %d, currency %s", storages.ErrAccountFrozen, userID, key.currency)
+		}
+		if want, ok := expected[key]; ok && want != version {
+			return nil, fmt.Errorf("%w: %s is at version %d, expected %d", storages.ErrVersionMismatch, key.currency, version, want)
+		}
+		balances[key] = amount
+	}
+	return balances, rows.Err()
+}
+
+// Коды ошибок PostgreSQL
+const (
+	checkViolation         = "23514"
+	foreignKeyViolation    = "23503"
+	uniqueViolation        = "23505"
+	numericValueOutOfRange = "22003"
+)
+
+// isCheckViolation — сработало ограничение CHECK (amount >= 0 или held <= amount)
+func isCheckViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == checkViolation
+}
+
+// isForeignKeyViolation — ссылка на несуществующую строку (например, пользователя)
+func isForeignKeyViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == foreignKeyViolation
+}
+
+// isUniqueViolation — строка с таким ключом уже есть
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == uniqueViolation
+}
+
+// isNumericOverflow — сумма не поместилась в DECIMAL(15,2)
+func isNumericOverflow(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == numericValueOutOfRange
+}
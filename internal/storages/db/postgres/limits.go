@@ -0,0 +1,196 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gw-currency-wallet/internal/storages"
+	"gw-currency-wallet/pkg/money"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// querier — общее у пула соединений и открытой транзакции
+type querier interface {
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+}
+
+// PostWithinLimits проверяет лимиты, проводит операцию, учитывает её сумму и записывает events
+// в outbox в одной транзакции.
+// Строка пользователя блокируется на время проверки, поэтому параллельные операции одного
+// пользователя не превысят лимит вместе
+func (p *Postgres) PostWithinLimits(ctx context.Context, userID int64, opType storages.OperationType, charge storages.LimitCharge, events []storages.OutboxEvent, postings ...storages.Posting) (storages.Transaction, error) {
+	if len(postings) == 0 {
+		return storages.Transaction{UserID: userID, Type: opType}, fmt.Errorf("transaction has no postings")
+	}
+
+	tx, err := p.Client.Begin(ctx)
+	if err != nil {
+		return storages.Transaction{}, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	txn, err := postWithinLimits(ctx, tx, userID, opType, charge, events, postings)
+	if err != nil {
+		return txn, err
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return txn, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return txn, nil
+}
+
+// postWithinLimits — PostWithinLimits в открытой транзакции tx
+func postWithinLimits(ctx context.Context, tx pgx.Tx, userID int64, opType storages.OperationType, charge storages.LimitCharge, events []storages.OutboxEvent, postings []storages.Posting) (storages.Transaction, error) {
+	now := time.Now()
+	if err := lockLimits(ctx, tx, userID, charge, now); err != nil {
+		return storages.Transaction{}, err
+	}
+
+	txn, err := postInTx(ctx, tx, userID, opType, 0, postings)
+	if err != nil {
+		return txn, err
+	}
+	if err = recordLimitUsage(ctx, tx, userID, charge, now); err != nil {
+		return txn, err
+	}
+	if err = insertOutbox(ctx, tx, events, txn.ID); err != nil {
+		return txn, err
+	}
+	return txn, nil
+}
+
+// lockLimits блокирует строку пользователя до конца транзакции и проверяет charge по его лимитам.
+// FOR NO KEY UPDATE не мешает вставкам, которые ссылаются на пользователя
+func lockLimits(ctx context.Context, tx pgx.Tx, userID int64, charge storages.LimitCharge, now time.Time) error {
+	var locked int64
+	err := tx.QueryRow(ctx, "SELECT id FROM users WHERE id = $1 FOR NO KEY UPDATE", userID).Scan(&locked)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return storages.ErrUserNotFound
+		}
+		return fmt.Errorf("failed to lock user: %w", err)
+	}
+	return checkLimits(ctx, tx, userID, charge, now)
+}
+
+// recordLimitUsage учитывает проведённую сумму charge
+func recordLimitUsage(ctx context.Context, tx pgx.Tx, userID int64, charge storages.LimitCharge, now time.Time) error {
+	baseAmount := money.New(0, 2)
+	if charge.BaseAmount != nil {
+		baseAmount = *charge.BaseAmount
+	}
+	day, _ := storages.LimitPeriods(now)
+	_, err := tx.Exec(ctx,
+		`INSERT INTO limit_usage (user_id, operation, currency, day, amount, base_amount)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (user_id, operation, currency, day) DO UPDATE
+		SET amount = limit_usage.amount + EXCLUDED.amount, base_amount = limit_usage.base_amount + EXCLUDED.base_amount`,
+		userID, charge.Operation, charge.Currency, day, charge.Amount, baseAmount,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to record limit usage: %w", err)
+	}
+	return nil
+}
+
+// GetLimitStatus возвращает действующие правила пользователя с израсходованными суммами
+func (p *Postgres) GetLimitStatus(ctx context.Context, userID int64, now time.Time) ([]storages.LimitStatus, error) {
+	rules, err := effectiveLimits(ctx, p.Client, userID, "")
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]storages.LimitStatus, 0, len(rules))
+	for _, rule := range rules {
+		daily, monthly, err := limitUsage(ctx, p.Client, userID, rule, now)
+		if err != nil {
+			return nil, err
+		}
+		statuses = append(statuses, rule.Status(daily, monthly))
+	}
+	return statuses, nil
+}
+
+func checkLimits(ctx context.Context, tx pgx.Tx, userID int64, charge storages.LimitCharge, now time.Time) error {
+	rules, err := effectiveLimits(ctx, tx, userID, charge.Operation)
+	if err != nil {
+		return err
+	}
+
+	for _, rule := range rules {
+		amount := charge.Amount
+		switch {
+		case rule.Currency == "" && charge.BaseAmount == nil:
+			return fmt.Errorf("base amount is required for %s limits", rule.Operation)
+		case rule.Currency == "":
+			amount = *charge.BaseAmount
+		case rule.Currency != charge.Currency:
+			continue
+		}
+
+		daily, monthly, err := limitUsage(ctx, tx, userID, rule, now)
+		if err != nil {
+			return err
+		}
+		if err = rule.Check(amount, daily, monthly); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// effectiveLimits выбирает правила пользователя и его тарифа; правило пользователя
+// перекрывает тарифное с теми же операцией и валютой. Пустой operation — все операции
+func effectiveLimits(ctx context.Context, q querier, userID int64, operation storages.OperationType) ([]storages.LimitRule, error) {
+	rows, err := q.Query(ctx,
+		`SELECT DISTINCT ON (l.operation, COALESCE(l.currency, ''))
+			COALESCE(l.tier, ''), COALESCE(l.user_id, 0), l.operation, COALESCE(l.currency, ''),
+			l.per_transaction, l.daily, l.monthly
+		FROM limits l JOIN users u ON u.id = $1
+		WHERE (l.user_id = u.id OR l.tier = u.tier) AND ($2 = '' OR l.operation = $2)
+		ORDER BY l.operation, COALESCE(l.currency, ''), l.user_id NULLS LAST`,
+		userID, string(operation),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get limits: %w", err)
+	}
+	defer rows.Close()
+
+	var rules []storages.LimitRule
+	for rows.Next() {
+		var r storages.LimitRule
+		if err = rows.Scan(&r.Tier, &r.UserID, &r.Operation, &r.Currency, &r.PerTransaction, &r.Daily, &r.Monthly); err != nil {
+			return nil, err
+		}
+		rules = append(rules, r)
+	}
+	return rules, rows.Err()
+}
+
+// limitUsage — израсходованные по правилу суммы за текущие сутки и месяц
+func limitUsage(ctx context.Context, q querier, userID int64, rule storages.LimitRule, now time.Time) (money.Decimal, money.Decimal, error) {
+	day, month := storages.LimitPeriods(now)
+	rows, err := q.Query(ctx,
+		`SELECT
+			COALESCE(SUM(CASE WHEN $3 = '' THEN base_amount ELSE amount END) FILTER (WHERE day >= $4), 0),
+			COALESCE(SUM(CASE WHEN $3 = '' THEN base_amount ELSE amount END), 0)
+		FROM limit_usage
+		WHERE user_id = $1 AND operation = $2 AND ($3 = '' OR currency = $3) AND day >= $5`,
+		userID, rule.Operation, rule.Currency, day, month,
+	)
+	if err != nil {
+		return money.Decimal{}, money.Decimal{}, fmt.Errorf("failed to get limit usage: %w", err)
+	}
+	defer rows.Close()
+
+	var daily, monthly money.Decimal
+	if rows.Next() {
+		if err = rows.Scan(&daily, &monthly); err != nil {
+			return daily, monthly, err
+		}
+	}
+	return daily, monthly, rows.Err()
+}
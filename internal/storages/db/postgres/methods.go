@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"gw-currency-wallet/internal/storages"
+	"gw-currency-wallet/pkg/money"
 
 	"github.com/jackc/pgx/v5"
 )
@@ -13,38 +14,50 @@ import (
 func (p *Postgres) CreateUser(ctx context.Context, email, passwordHash string) (int64, error) {
 	var userID int64
 	err := p.Client.QueryRow(ctx,
-		"INSERT INTO users (email, password_hash) VALUES ($1, $2) RETURNING id",
-		email, passwordHash,
+		`WITH u AS (
+			INSERT INTO users (email, password_hash) VALUES ($1, $2) RETURNING id
+		), w AS (
+			INSERT INTO wallets (user_id, name, is_default) SELECT id, $3, true FROM u
+		)
+		SELECT id FROM u`,
+		email, passwordHash, storages.DefaultWalletName,
 	).Scan(&userID)
 
 	if err != nil {
 		return 0, fmt.Errorf("failed to create user: %w", err)
 	}
 
-	// Инициализируем балансы по умолчанию (0 для всех валют)
-	currencies := []string{"USD", "RUB", "EUR"}
-	for _, currency := range currencies {
-		_, err := p.Client.Exec(ctx,
-			"INSERT INTO balances (user_id, currency, amount) VALUES ($1, $2, 0)",
-			userID, currency,
-		)
-		if err != nil {
-			p.logger.Warn("failed to initialize balance for currency", "currency", currency, "error", err)
-		}
+	// Открываем нулевые счета во всех включённых валютах справочника
+	_, err = p.Client.Exec(ctx,
+		`INSERT INTO balances (user_id, wallet_id, currency, amount)
+		SELECT w.user_id, w.id, c.code, 0 FROM wallets w, currencies c
+		WHERE w.user_id = $1 AND w.is_default AND c.enabled`,
+		userID,
+	)
+	if err != nil {
+		p.logger.Warn("failed to initialize balances", "error", err)
 	}
 
 	return userID, nil
 }
 func (p *Postgres) GetUserByEmail(ctx context.Context, email string) (storages.User, error) {
+	return p.getUser(ctx, "email = $1", email)
+}
+
+func (p *Postgres) GetUser(ctx context.Context, userID int64) (storages.User, error) {
+	return p.getUser(ctx, "id = $1", userID)
+}
+
+func (p *Postgres) getUser(ctx context.Context, where string, arg any) (storages.User, error) {
 	var user storages.User
 	err := p.Client.QueryRow(ctx,
-		"SELECT id, email, password_hash FROM users WHERE email = $1",
-		email,
-	).Scan(&user.ID, &user.Email, &user.PasswordHash)
+		"SELECT id, email, password_hash, tier, is_admin FROM users WHERE "+where,
+		arg,
+	).Scan(&user.ID, &user.Email, &user.PasswordHash, &user.Tier, &user.Admin)
 
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
-			return user, fmt.Errorf("user not found")
+			return user, storages.ErrUserNotFound
 		}
 		return user, fmt.Errorf("failed to get user: %w", err)
 	}
@@ -52,35 +65,36 @@ func (p *Postgres) GetUserByEmail(ctx context.Context, email string) (storages.U
 	return user, nil
 }
 
-func (p *Postgres) GetBalance(ctx context.Context, userID int64, currency string) (float32, error) {
-	var amount float32
+func (p *Postgres) GetBalance(ctx context.Context, userID int64, currency string) (money.Decimal, error) {
+	var amount money.Decimal
 	err := p.Client.QueryRow(ctx,
-		"SELECT amount FROM balances WHERE user_id = $1 AND currency = $2",
+		`SELECT b.amount FROM balances b JOIN wallets w ON w.id = b.wallet_id AND w.is_default
+		WHERE b.user_id = $1 AND b.currency = $2`,
 		userID, currency,
 	).Scan(&amount)
 
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
-			return 0, fmt.Errorf("balance not found for currency %s", currency)
+			return amount, fmt.Errorf("%w for currency %s", storages.ErrBalanceNotFound, currency)
 		}
-		return 0, fmt.Errorf("failed to get balance: %w", err)
+		return amount, fmt.Errorf("failed to get balance: %w", err)
 	}
 
 	return amount, nil
 }
 
-func (p *Postgres) GetAllBalances(ctx context.Context, userID int64) (map[string]float32, error) {
-	sql := "SELECT currency, amount FROM balances WHERE user_id = $1"
+func (p *Postgres) GetAllBalances(ctx context.Context, userID int64) (map[string]money.Decimal, error) {
+	sql := "SELECT b.currency, b.amount FROM balances b JOIN wallets w ON w.id = b.wallet_id AND w.is_default WHERE b.user_id = $1"
 	rows, err := p.Client.Query(ctx, sql, userID)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	balances := make(map[string]float32)
+	balances := make(map[string]money.Decimal)
 	for rows.Next() {
 		var currency string
-		var amount float32
+		var amount money.Decimal
 		if err = rows.Scan(&currency, &amount); err != nil {
 			return nil, err
 		}
@@ -89,19 +103,18 @@ func (p *Postgres) GetAllBalances(ctx context.Context, userID int64) (map[string
 	return balances, nil
 }
 
-func (p *Postgres) UpdateBalance(ctx context.Context, userID int64, currency string, amount float32) error {
-	result, err := p.Client.Exec(ctx,
-		"UPDATE balances SET amount = amount + $1 WHERE user_id = $2 AND currency = $3",
-		amount, userID, currency,
-	)
-
-	if err != nil {
-		return fmt.Errorf("failed to update balance: %w", err)
-	}
+// GetBalances возвращает учётные балансы вместе с суммами активных холдов
+func (p *Postgres) GetBalances(ctx context.Context, userID int64) ([]storages.Balance, error) {
+	return p.GetWalletBalances(ctx, userID, 0)
+}
 
-	if result.RowsAffected() == 0 {
-		return fmt.Errorf("balance record not found for user %d and currency %s", userID, currency)
+// UpdateBalance проводит одиночное изменение баланса через журнал
+func (p *Postgres) UpdateBalance(ctx context.Context, userID int64, currency string, amount money.Decimal) error {
+	opType := storages.OperationDeposit
+	if amount.Sign() < 0 {
+		opType = storages.OperationWithdraw
 	}
 
-	return nil
+	_, err := p.PostTransaction(ctx, userID, opType, storages.Posting{Currency: currency, Amount: amount})
+	return err
 }
@@ -0,0 +1,25 @@
+package postgres
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMigrations_Embedded(t *testing.T) {
+	migrations, err := Migrations()
+	assert.NoError(t, err)
+	assert.NotEmpty(t, migrations)
+
+	// Версии идут подряд, у каждой миграции есть скрипт отката
+	for i, m := range migrations {
+		assert.Equal(t, i+1, m.Version)
+		assert.NotEmpty(t, m.Name)
+		assert.NotEmpty(t, m.Up)
+		assert.NotEmpty(t, m.Down, "migration %04d_%s", m.Version, m.Name)
+	}
+
+	latest, err := LatestSchemaVersion()
+	assert.NoError(t, err)
+	assert.Equal(t, migrations[len(migrations)-1].Version, latest)
+}
@@ -0,0 +1,2 @@
+ALTER TABLE balances DROP CONSTRAINT IF EXISTS balances_currency_fkey;
+DROP TABLE IF EXISTS currencies;
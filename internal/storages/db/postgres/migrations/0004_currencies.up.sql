@@ -0,0 +1,17 @@
+-- Справочник валют. Балансы хранятся в DECIMAL(15,2), поэтому валюты
+-- с тремя и более знаками после запятой не поддерживаются
+CREATE TABLE IF NOT EXISTS currencies(
+    code VARCHAR(3) PRIMARY KEY CHECK ( code ~ '^[A-Z]{3}$' ),
+    name TEXT NOT NULL,
+    minor_units SMALLINT NOT NULL CHECK ( minor_units BETWEEN 0 AND 2 ),
+    enabled BOOLEAN NOT NULL DEFAULT true
+);
+
+INSERT INTO currencies (code, name, minor_units) VALUES
+    ('USD', 'US Dollar', 2),
+    ('EUR', 'Euro', 2),
+    ('RUB', 'Russian Ruble', 2)
+ON CONFLICT (code) DO NOTHING;
+
+ALTER TABLE balances
+    ADD CONSTRAINT balances_currency_fkey FOREIGN KEY (currency) REFERENCES currencies(code);
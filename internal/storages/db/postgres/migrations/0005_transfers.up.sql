@@ -0,0 +1,3 @@
+-- Второй участник перевода. У каждого участника своя операция, ссылающаяся на другого
+ALTER TABLE transactions
+    ADD COLUMN IF NOT EXISTS counterparty_id BIGINT REFERENCES users(id) ON DELETE SET NULL;
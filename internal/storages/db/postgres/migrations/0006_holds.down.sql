@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS holds;
+ALTER TABLE balances
+    DROP CONSTRAINT IF EXISTS balances_held_check,
+    DROP COLUMN IF EXISTS held;
@@ -0,0 +1,20 @@
+-- held — сумма активных холдов. Списание ниже held нарушает CHECK так же, как уход в минус
+ALTER TABLE balances
+    ADD COLUMN IF NOT EXISTS held DECIMAL(15,2) NOT NULL DEFAULT 0,
+    ADD CONSTRAINT balances_held_check CHECK ( held >= 0 AND held <= amount );
+
+CREATE TABLE IF NOT EXISTS holds(
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    currency VARCHAR(3) NOT NULL,
+    amount DECIMAL(15,2) NOT NULL CHECK ( amount > 0 ),
+    captured_amount DECIMAL(15,2) NOT NULL DEFAULT 0 CHECK ( captured_amount >= 0 AND captured_amount <= amount ),
+    status VARCHAR(16) NOT NULL DEFAULT 'active' CHECK ( status IN ('active', 'captured', 'voided', 'expired') ),
+    transaction_id BIGINT REFERENCES transactions(id) ON DELETE SET NULL,
+    expires_at TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_holds_user ON holds(user_id, id DESC);
+CREATE INDEX IF NOT EXISTS idx_holds_active_expiry ON holds(expires_at) WHERE status = 'active';
@@ -0,0 +1,34 @@
+CREATE TABLE IF NOT EXISTS schedules(
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    operation VARCHAR(16) NOT NULL CHECK ( operation IN ('deposit', 'withdraw', 'exchange', 'transfer') ),
+    currency VARCHAR(3) NOT NULL,
+    to_currency VARCHAR(3),
+    to_user_id BIGINT REFERENCES users(id) ON DELETE CASCADE,
+    amount DECIMAL(15,2) NOT NULL CHECK ( amount > 0 ),
+    recurrence VARCHAR(8) NOT NULL CHECK ( recurrence IN ('once', 'daily', 'weekly', 'monthly') ),
+    start_at TIMESTAMPTZ NOT NULL,
+    next_run_at TIMESTAMPTZ,
+    status VARCHAR(16) NOT NULL DEFAULT 'active' CHECK ( status IN ('active', 'paused', 'completed') ),
+    last_run_at TIMESTAMPTZ,
+    last_error TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_schedules_user ON schedules(user_id, id);
+CREATE INDEX IF NOT EXISTS idx_schedules_due ON schedules(next_run_at) WHERE status = 'active';
+
+-- Уникальность (schedule_id, scheduled_for) не даёт выполнить одно срабатывание дважды
+CREATE TABLE IF NOT EXISTS schedule_runs(
+    id BIGSERIAL PRIMARY KEY,
+    schedule_id BIGINT NOT NULL REFERENCES schedules(id) ON DELETE CASCADE,
+    scheduled_for TIMESTAMPTZ NOT NULL,
+    status VARCHAR(16) NOT NULL DEFAULT 'pending' CHECK ( status IN ('pending', 'succeeded', 'failed') ),
+    transaction_id BIGINT REFERENCES transactions(id) ON DELETE SET NULL,
+    error TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    finished_at TIMESTAMPTZ,
+    UNIQUE (schedule_id, scheduled_for)
+);
+
+CREATE INDEX IF NOT EXISTS idx_schedule_runs_schedule ON schedule_runs(schedule_id, id DESC);
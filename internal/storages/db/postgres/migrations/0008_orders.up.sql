@@ -0,0 +1,21 @@
+-- Пока заявка открыта, amount входит в balances.held так же, как сумма активного холда
+CREATE TABLE IF NOT EXISTS orders(
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    from_currency VARCHAR(3) NOT NULL,
+    to_currency VARCHAR(3) NOT NULL,
+    amount DECIMAL(15,2) NOT NULL CHECK ( amount > 0 ),
+    limit_rate NUMERIC(20,8) NOT NULL CHECK ( limit_rate > 0 ),
+    status VARCHAR(16) NOT NULL DEFAULT 'open' CHECK ( status IN ('open', 'filled', 'cancelled', 'expired') ),
+    fill_rate NUMERIC(20,8),
+    received DECIMAL(15,2),
+    transaction_id BIGINT REFERENCES transactions(id) ON DELETE SET NULL,
+    expires_at TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    CHECK ( from_currency <> to_currency )
+);
+
+CREATE INDEX IF NOT EXISTS idx_orders_user ON orders(user_id, id DESC);
+CREATE INDEX IF NOT EXISTS idx_orders_open_pair ON orders(from_currency, to_currency, limit_rate) WHERE status = 'open';
+CREATE INDEX IF NOT EXISTS idx_orders_open_expiry ON orders(expires_at) WHERE status = 'open';
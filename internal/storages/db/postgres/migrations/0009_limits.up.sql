@@ -0,0 +1,36 @@
+-- Тариф пользователя определяет, какие лимиты к нему применяются
+ALTER TABLE users ADD COLUMN IF NOT EXISTS tier VARCHAR(32) NOT NULL DEFAULT 'standard';
+
+-- Правило задаётся либо для тарифа, либо для пользователя; правило пользователя перекрывает
+-- тарифное. currency IS NULL — суммы во всех валютах в базовой валюте лимитов, NULL в лимите — без ограничения
+CREATE TABLE IF NOT EXISTS limits(
+    id BIGSERIAL PRIMARY KEY,
+    tier VARCHAR(32),
+    user_id BIGINT REFERENCES users(id) ON DELETE CASCADE,
+    operation VARCHAR(16) NOT NULL CHECK ( operation IN ('deposit', 'withdraw', 'exchange') ),
+    currency VARCHAR(3) REFERENCES currencies(code),
+    per_transaction DECIMAL(15,2) CHECK ( per_transaction > 0 ),
+    daily DECIMAL(15,2) CHECK ( daily > 0 ),
+    monthly DECIMAL(15,2) CHECK ( monthly > 0 ),
+    CHECK ( (tier IS NULL) <> (user_id IS NULL) )
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_limits_scope
+    ON limits(COALESCE(tier, ''), COALESCE(user_id, 0), operation, COALESCE(currency, ''));
+
+-- Израсходованные суммы по дням: amount — в валюте операции, base_amount — в базовой валюте
+CREATE TABLE IF NOT EXISTS limit_usage(
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    operation VARCHAR(16) NOT NULL,
+    currency VARCHAR(3) NOT NULL,
+    day DATE NOT NULL,
+    amount DECIMAL(15,2) NOT NULL DEFAULT 0,
+    base_amount DECIMAL(15,2) NOT NULL DEFAULT 0,
+    PRIMARY KEY (user_id, operation, currency, day)
+);
+
+INSERT INTO limits (tier, operation, per_transaction, daily, monthly) VALUES
+    ('standard', 'deposit', 1000000, 1000000, 5000000),
+    ('standard', 'withdraw', 100000, 200000, 1000000),
+    ('standard', 'exchange', 500000, 1000000, 5000000)
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_ledger_entries_created;
+DROP TABLE IF EXISTS balance_snapshots;
@@ -0,0 +1,12 @@
+-- Остаток кошелька на конец дня по UTC. Строка пишется только за дни, в которые по валюте
+-- были движения: остаток на любой момент — ближайший предыдущий снимок плюс движения после него
+CREATE TABLE IF NOT EXISTS balance_snapshots(
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    currency VARCHAR(3) NOT NULL,
+    day DATE NOT NULL,
+    amount DECIMAL(15,2) NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (user_id, currency, day)
+);
+
+CREATE INDEX IF NOT EXISTS idx_ledger_entries_created ON ledger_entries(created_at) WHERE account = 'wallet';
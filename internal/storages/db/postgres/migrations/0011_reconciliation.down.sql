@@ -0,0 +1 @@
+ALTER TABLE balances DROP COLUMN IF EXISTS frozen;
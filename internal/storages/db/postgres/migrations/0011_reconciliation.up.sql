@@ -0,0 +1,2 @@
+-- frozen — счёт заморожен сверкой: операции по нему отклоняются, пока его не разморозят
+ALTER TABLE balances ADD COLUMN IF NOT EXISTS frozen BOOLEAN NOT NULL DEFAULT false;
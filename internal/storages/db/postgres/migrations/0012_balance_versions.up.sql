@@ -0,0 +1,3 @@
+-- version растёт при каждом изменении amount или held: клиент передаёт её в If-Match,
+-- чтобы операция не выполнилась по устаревшему балансу
+ALTER TABLE balances ADD COLUMN IF NOT EXISTS version BIGINT NOT NULL DEFAULT 1;
@@ -0,0 +1,19 @@
+-- Балансы дополнительных кошельков складываются в кошелёк по умолчанию. Перемещения между
+-- кошельками в журнале взаимно погашаются, поэтому балансы сходятся с журналом и после отката
+INSERT INTO balances (user_id, wallet_id, currency, amount, frozen)
+SELECT b.user_id, d.id, b.currency, SUM(b.amount), bool_or(b.frozen)
+FROM balances b
+JOIN wallets w ON w.id = b.wallet_id AND NOT w.is_default
+JOIN wallets d ON d.user_id = b.user_id AND d.is_default
+GROUP BY b.user_id, d.id, b.currency
+ON CONFLICT (wallet_id, currency) DO UPDATE
+SET amount = balances.amount + EXCLUDED.amount, frozen = balances.frozen OR EXCLUDED.frozen, version = balances.version + 1;
+
+DELETE FROM balances b USING wallets w WHERE w.id = b.wallet_id AND NOT w.is_default;
+
+ALTER TABLE balances
+    DROP CONSTRAINT IF EXISTS balances_pkey,
+    ADD PRIMARY KEY (user_id, currency),
+    DROP COLUMN IF EXISTS wallet_id;
+ALTER TABLE ledger_entries DROP COLUMN IF EXISTS wallet_id;
+DROP TABLE IF EXISTS wallets;
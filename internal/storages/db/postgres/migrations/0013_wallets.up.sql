@@ -0,0 +1,30 @@
+-- Именованные кошельки пользователя. Кошелёк по умолчанию создаётся при регистрации и не меняется:
+-- через него идут операции без id кошелька, переводы, холды и заявки
+CREATE TABLE IF NOT EXISTS wallets(
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(64) NOT NULL,
+    is_default BOOLEAN NOT NULL DEFAULT false,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    UNIQUE (user_id, name)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_wallets_default ON wallets(user_id) WHERE is_default;
+
+INSERT INTO wallets (user_id, name, is_default)
+SELECT id, 'Main', true FROM users
+ON CONFLICT DO NOTHING;
+
+-- Баланс принадлежит кошельку; существующие балансы переходят в кошелёк по умолчанию
+ALTER TABLE balances ADD COLUMN IF NOT EXISTS wallet_id BIGINT REFERENCES wallets(id) ON DELETE CASCADE;
+UPDATE balances b SET wallet_id = w.id
+FROM wallets w WHERE w.user_id = b.user_id AND w.is_default AND b.wallet_id IS NULL;
+ALTER TABLE balances
+    ALTER COLUMN wallet_id SET NOT NULL,
+    DROP CONSTRAINT IF EXISTS balances_pkey,
+    ADD PRIMARY KEY (wallet_id, currency);
+
+-- Кошелёк проводки по счёту wallet; у проводок служебных счетов NULL
+ALTER TABLE ledger_entries ADD COLUMN IF NOT EXISTS wallet_id BIGINT REFERENCES wallets(id) ON DELETE CASCADE;
+UPDATE ledger_entries e SET wallet_id = w.id
+FROM wallets w WHERE w.user_id = e.user_id AND w.is_default AND e.account = 'wallet' AND e.wallet_id IS NULL;
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS wallet_approvals;
+DROP TABLE IF EXISTS wallet_approval_rules;
+DROP TABLE IF EXISTS wallet_invitations;
+DROP TABLE IF EXISTS wallet_members;
@@ -0,0 +1,55 @@
+-- Участники общего кошелька. Владелец кошелька (wallets.user_id) в таблицу не входит: его роль
+-- всегда owner, а балансы и журнал общего кошелька остаются у него
+CREATE TABLE IF NOT EXISTS wallet_members(
+    wallet_id BIGINT NOT NULL REFERENCES wallets(id) ON DELETE CASCADE,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    role VARCHAR(16) NOT NULL CHECK ( role IN ('owner', 'spender', 'viewer') ),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (wallet_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_wallet_members_user ON wallet_members(user_id);
+
+-- Приглашение по email: пользователь может зарегистрироваться уже после приглашения
+CREATE TABLE IF NOT EXISTS wallet_invitations(
+    id BIGSERIAL PRIMARY KEY,
+    wallet_id BIGINT NOT NULL REFERENCES wallets(id) ON DELETE CASCADE,
+    email VARCHAR(255) NOT NULL,
+    role VARCHAR(16) NOT NULL CHECK ( role IN ('owner', 'spender', 'viewer') ),
+    invited_by BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    status VARCHAR(16) NOT NULL DEFAULT 'pending' CHECK ( status IN ('pending', 'accepted', 'declined') ),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_wallet_invitations_pending ON wallet_invitations(wallet_id, email) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS idx_wallet_invitations_email ON wallet_invitations(email) WHERE status = 'pending';
+
+-- Операции с кошельком в валюте currency на сумму больше threshold ждут подтверждения второго участника
+CREATE TABLE IF NOT EXISTS wallet_approval_rules(
+    wallet_id BIGINT NOT NULL REFERENCES wallets(id) ON DELETE CASCADE,
+    operation VARCHAR(16) NOT NULL CHECK ( operation IN ('withdraw', 'exchange', 'move') ),
+    currency VARCHAR(3) NOT NULL REFERENCES currencies(code),
+    threshold DECIMAL(15,2) NOT NULL CHECK ( threshold > 0 ),
+    PRIMARY KEY (wallet_id, operation, currency)
+);
+
+-- Запрос на операцию. Подтверждённый запрос проводится сразу: approved — проведён, failed — не прошёл
+CREATE TABLE IF NOT EXISTS wallet_approvals(
+    id BIGSERIAL PRIMARY KEY,
+    wallet_id BIGINT NOT NULL REFERENCES wallets(id) ON DELETE CASCADE,
+    requested_by BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    operation VARCHAR(16) NOT NULL CHECK ( operation IN ('withdraw', 'exchange', 'move') ),
+    currency VARCHAR(3) NOT NULL,
+    to_currency VARCHAR(3),
+    to_wallet_id BIGINT REFERENCES wallets(id) ON DELETE CASCADE,
+    amount DECIMAL(15,2) NOT NULL CHECK ( amount > 0 ),
+    status VARCHAR(16) NOT NULL DEFAULT 'pending' CHECK ( status IN ('pending', 'approved', 'rejected', 'failed') ),
+    decided_by BIGINT REFERENCES users(id) ON DELETE SET NULL,
+    transaction_id BIGINT REFERENCES transactions(id),
+    failure TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    decided_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_wallet_approvals_wallet ON wallet_approvals(wallet_id, id);
@@ -0,0 +1,47 @@
+ALTER TABLE users ADD COLUMN IF NOT EXISTS is_admin BOOLEAN NOT NULL DEFAULT false;
+
+-- Журнал аудита. У actor_id и user_id нет внешних ключей: записи переживают удаление пользователя.
+-- before/after хранятся как JSON, а не JSONB: хеш считается по записанному тексту.
+-- seq, prev_hash и hash заполняются один раз при запечатывании записи в цепочку
+CREATE TABLE IF NOT EXISTS audit_log(
+    id BIGSERIAL PRIMARY KEY,
+    actor_id BIGINT NOT NULL DEFAULT 0,
+    user_id BIGINT NOT NULL DEFAULT 0,
+    action VARCHAR(64) NOT NULL,
+    target VARCHAR(128) NOT NULL DEFAULT '',
+    ip VARCHAR(64) NOT NULL DEFAULT '',
+    user_agent TEXT NOT NULL DEFAULT '',
+    request_id VARCHAR(128) NOT NULL DEFAULT '',
+    before_value JSON,
+    after_value JSON,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    seq BIGINT UNIQUE,
+    prev_hash VARCHAR(64),
+    hash VARCHAR(64)
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_log_user ON audit_log(user_id, id);
+CREATE INDEX IF NOT EXISTS idx_audit_log_actor ON audit_log(actor_id, id);
+CREATE INDEX IF NOT EXISTS idx_audit_log_action ON audit_log(action, id);
+CREATE INDEX IF NOT EXISTS idx_audit_log_unsealed ON audit_log(id) WHERE seq IS NULL;
+
+-- Разрешено только запечатывание: однократное заполнение seq, prev_hash и hash без изменения данных
+CREATE OR REPLACE FUNCTION audit_log_append_only() RETURNS trigger AS $$
+BEGIN
+    IF TG_OP = 'UPDATE' AND OLD.seq IS NULL
+        AND (NEW.id, NEW.actor_id, NEW.user_id, NEW.action, NEW.target, NEW.ip, NEW.user_agent,
+             NEW.request_id, NEW.before_value::text, NEW.after_value::text, NEW.created_at)
+        IS NOT DISTINCT FROM
+            (OLD.id, OLD.actor_id, OLD.user_id, OLD.action, OLD.target, OLD.ip, OLD.user_agent,
+             OLD.request_id, OLD.before_value::text, OLD.after_value::text, OLD.created_at)
+    THEN
+        RETURN NEW;
+    END IF;
+    RAISE EXCEPTION 'audit_log is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER audit_log_append_only BEFORE UPDATE OR DELETE ON audit_log
+    FOR EACH ROW EXECUTE FUNCTION audit_log_append_only();
+CREATE TRIGGER audit_log_no_truncate BEFORE TRUNCATE ON audit_log
+    FOR EACH STATEMENT EXECUTE FUNCTION audit_log_append_only();
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_ledger_entries_fees;
+DROP TABLE IF EXISTS exchange_fees;
@@ -0,0 +1,19 @@
+-- Правило задаётся либо для тарифа, либо для пользователя. NULL в валюте — любая валюта.
+-- Комиссия — spread_percent процентов суммы списания, но не меньше min_fee в валюте списания
+CREATE TABLE IF NOT EXISTS exchange_fees(
+    id BIGSERIAL PRIMARY KEY,
+    tier VARCHAR(32),
+    user_id BIGINT REFERENCES users(id) ON DELETE CASCADE,
+    from_currency VARCHAR(3) REFERENCES currencies(code),
+    to_currency VARCHAR(3) REFERENCES currencies(code),
+    spread_percent DECIMAL(7,4) NOT NULL DEFAULT 0 CHECK ( spread_percent >= 0 AND spread_percent < 100 ),
+    min_fee DECIMAL(15,2) NOT NULL DEFAULT 0 CHECK ( min_fee >= 0 ),
+    CHECK ( (tier IS NULL) <> (user_id IS NULL) ),
+    CHECK ( min_fee = 0 OR from_currency IS NOT NULL )
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_exchange_fees_scope
+    ON exchange_fees(COALESCE(tier, ''), COALESCE(user_id, 0), COALESCE(from_currency, ''), COALESCE(to_currency, ''));
+
+-- Выручка от комиссий считается по проводкам счёта fees
+CREATE INDEX IF NOT EXISTS idx_ledger_entries_fees ON ledger_entries(created_at) WHERE account = 'fees';
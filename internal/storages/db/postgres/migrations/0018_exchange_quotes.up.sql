@@ -0,0 +1,21 @@
+-- Котировка фиксирует курс и комиссию обмена до expires_at; used_at и transaction_id
+-- заполняются при обмене по ней, поэтому по котировке проводится не больше одного обмена
+CREATE TABLE IF NOT EXISTS exchange_quotes(
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    wallet_id BIGINT REFERENCES wallets(id) ON DELETE CASCADE,
+    from_currency VARCHAR(3) NOT NULL REFERENCES currencies(code),
+    to_currency VARCHAR(3) NOT NULL REFERENCES currencies(code),
+    amount DECIMAL(15,2) NOT NULL CHECK ( amount > 0 ),
+    fee DECIMAL(15,2) NOT NULL DEFAULT 0 CHECK ( fee >= 0 ),
+    fee_percent DECIMAL(7,4) NOT NULL DEFAULT 0,
+    received DECIMAL(15,2) NOT NULL CHECK ( received > 0 ),
+    rate NUMERIC(20,8) NOT NULL CHECK ( rate > 0 ),
+    expires_at TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    used_at TIMESTAMPTZ,
+    transaction_id BIGINT REFERENCES transactions(id) ON DELETE SET NULL,
+    CHECK ( from_currency <> to_currency )
+);
+
+CREATE INDEX IF NOT EXISTS idx_exchange_quotes_unused ON exchange_quotes(user_id, expires_at) WHERE used_at IS NULL;
@@ -0,0 +1,13 @@
+-- Все курсы, полученные от exchanger: source — каким запросом (список курсов или одна пара).
+-- По истории строятся свечи и проверяется, какой курс действовал в момент обмена
+CREATE TABLE IF NOT EXISTS rate_history(
+    id BIGSERIAL PRIMARY KEY,
+    from_currency VARCHAR(3) NOT NULL REFERENCES currencies(code),
+    to_currency VARCHAR(3) NOT NULL REFERENCES currencies(code),
+    rate NUMERIC(20,8) NOT NULL CHECK ( rate > 0 ),
+    source VARCHAR(32) NOT NULL,
+    fetched_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    CHECK ( from_currency <> to_currency )
+);
+
+CREATE INDEX IF NOT EXISTS idx_rate_history_pair ON rate_history(from_currency, to_currency, fetched_at);
@@ -0,0 +1 @@
+DROP INDEX IF EXISTS idx_ledger_entries_wallet;
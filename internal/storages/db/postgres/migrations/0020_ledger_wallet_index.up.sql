@@ -0,0 +1,2 @@
+-- История операций по кошельку: участники общего кошелька видят все операции по нему
+CREATE INDEX IF NOT EXISTS idx_ledger_entries_wallet ON ledger_entries(wallet_id, transaction_id DESC) WHERE account = 'wallet';
@@ -0,0 +1,7 @@
+DELETE FROM limits WHERE tier = 'standard' AND currency IN ('USD', 'EUR', 'RUB');
+
+INSERT INTO limits (tier, operation, per_transaction, daily, monthly) VALUES
+    ('standard', 'deposit', 1000000, 1000000, 5000000),
+    ('standard', 'withdraw', 100000, 200000, 1000000),
+    ('standard', 'exchange', 500000, 1000000, 5000000)
+ON CONFLICT DO NOTHING;
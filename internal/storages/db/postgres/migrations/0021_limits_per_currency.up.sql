@@ -0,0 +1,15 @@
+-- Тарифные лимиты задаются в валюте операции: лимит без валюты требует курса exchanger,
+-- и без него нельзя было бы пополнить или снять деньги в валюте, отличной от базовой
+DELETE FROM limits WHERE tier = 'standard' AND currency IS NULL;
+
+INSERT INTO limits (tier, operation, currency, per_transaction, daily, monthly) VALUES
+    ('standard', 'deposit', 'USD', 1000000, 1000000, 5000000),
+    ('standard', 'deposit', 'EUR', 1000000, 1000000, 5000000),
+    ('standard', 'deposit', 'RUB', 100000000, 100000000, 500000000),
+    ('standard', 'withdraw', 'USD', 100000, 200000, 1000000),
+    ('standard', 'withdraw', 'EUR', 100000, 200000, 1000000),
+    ('standard', 'withdraw', 'RUB', 10000000, 20000000, 100000000),
+    ('standard', 'exchange', 'USD', 500000, 1000000, 5000000),
+    ('standard', 'exchange', 'EUR', 500000, 1000000, 5000000),
+    ('standard', 'exchange', 'RUB', 50000000, 100000000, 500000000)
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,2 @@
+DELETE FROM transactions WHERE id IN (SELECT transaction_id FROM ledger_openings);
+DROP TABLE IF EXISTS ledger_openings;
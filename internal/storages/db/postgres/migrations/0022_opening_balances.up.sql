@@ -0,0 +1,38 @@
+-- Балансы, появившиеся до журнала, не имеют проводок, и сверка считала бы их расхождением.
+-- Разницу между балансом кошелька и журналом записываем открывающей операцией: недостающую
+-- в журнале сумму — пополнением, лишнюю (баланс меньше суммы проводок) — списанием
+CREATE TABLE IF NOT EXISTS ledger_openings(
+    transaction_id BIGINT PRIMARY KEY REFERENCES transactions(id) ON DELETE CASCADE
+);
+
+DO $$
+DECLARE
+    opening RECORD;
+    txn_id BIGINT;
+    wallet_side VARCHAR(8);
+    external_side VARCHAR(8);
+BEGIN
+    FOR opening IN
+        SELECT b.user_id, b.wallet_id, b.currency, b.amount - COALESCE(SUM(
+            CASE WHEN e.direction = 'credit' THEN e.amount ELSE -e.amount END
+        ), 0) AS amount
+        FROM balances b
+        LEFT JOIN ledger_entries e ON e.wallet_id = b.wallet_id AND e.currency = b.currency AND e.account = 'wallet'
+        GROUP BY b.user_id, b.wallet_id, b.currency, b.amount
+        HAVING b.amount - COALESCE(SUM(CASE WHEN e.direction = 'credit' THEN e.amount ELSE -e.amount END), 0) <> 0
+    LOOP
+        IF opening.amount > 0 THEN
+            INSERT INTO transactions (user_id, type) VALUES (opening.user_id, 'deposit') RETURNING id INTO txn_id;
+            wallet_side := 'credit';
+            external_side := 'debit';
+        ELSE
+            INSERT INTO transactions (user_id, type) VALUES (opening.user_id, 'withdraw') RETURNING id INTO txn_id;
+            wallet_side := 'debit';
+            external_side := 'credit';
+        END IF;
+        INSERT INTO ledger_entries (transaction_id, account, user_id, wallet_id, currency, direction, amount) VALUES
+            (txn_id, 'wallet', opening.user_id, opening.wallet_id, opening.currency, wallet_side, abs(opening.amount)),
+            (txn_id, 'external', opening.user_id, NULL, opening.currency, external_side, abs(opening.amount));
+        INSERT INTO ledger_openings (transaction_id) VALUES (txn_id);
+    END LOOP;
+END $$;
@@ -0,0 +1,9 @@
+-- Без колонок расписания кошельков выполнялись бы в кошельке по умолчанию — удаляем их
+DELETE FROM schedules WHERE operation = 'move' OR wallet_id IS NOT NULL;
+ALTER TABLE schedules
+    DROP CONSTRAINT IF EXISTS schedules_move_check,
+    DROP CONSTRAINT IF EXISTS schedules_operation_check,
+    ADD CONSTRAINT schedules_operation_check
+        CHECK ( operation IN ('deposit', 'withdraw', 'exchange', 'transfer') ),
+    DROP COLUMN IF EXISTS to_wallet_id,
+    DROP COLUMN IF EXISTS wallet_id;
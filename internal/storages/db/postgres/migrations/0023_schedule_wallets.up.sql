@@ -0,0 +1,9 @@
+-- Расписание выполняется в выбранном кошельке (NULL — кошелёк по умолчанию); move перемещает
+-- сумму в кошелёк to_wallet_id того же владельца
+ALTER TABLE schedules
+    ADD COLUMN IF NOT EXISTS wallet_id BIGINT REFERENCES wallets(id) ON DELETE CASCADE,
+    ADD COLUMN IF NOT EXISTS to_wallet_id BIGINT REFERENCES wallets(id) ON DELETE CASCADE,
+    DROP CONSTRAINT IF EXISTS schedules_operation_check,
+    ADD CONSTRAINT schedules_operation_check
+        CHECK ( operation IN ('deposit', 'withdraw', 'exchange', 'transfer', 'move') ),
+    ADD CONSTRAINT schedules_move_check CHECK ( (operation = 'move') = (to_wallet_id IS NOT NULL) );
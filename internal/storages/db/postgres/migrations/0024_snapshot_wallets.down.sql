@@ -0,0 +1,7 @@
+DELETE FROM balance_snapshots;
+
+ALTER TABLE balance_snapshots
+    DROP CONSTRAINT IF EXISTS balance_snapshots_pkey,
+    DROP COLUMN IF EXISTS wallet_id,
+    ADD COLUMN IF NOT EXISTS user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    ADD PRIMARY KEY (user_id, currency, day);
@@ -0,0 +1,9 @@
+-- Снимок хранит остаток кошелька, а не сумму по всем кошелькам пользователя. Снимки — производные
+-- данные: без них остаток считается по журналу, а задача снимков пересоздаёт их при следующем запуске
+DELETE FROM balance_snapshots;
+
+ALTER TABLE balance_snapshots
+    DROP CONSTRAINT IF EXISTS balance_snapshots_pkey,
+    DROP COLUMN IF EXISTS user_id,
+    ADD COLUMN IF NOT EXISTS wallet_id BIGINT NOT NULL REFERENCES wallets(id) ON DELETE CASCADE,
+    ADD PRIMARY KEY (wallet_id, currency, day);
@@ -0,0 +1,3 @@
+-- Ключ без ответа удерживается запросом до locked_until; после этого ключ, оставшийся от упавшего
+-- процесса, занимает следующий запрос. У незавершённых записей до миграции захвата нет — они свободны
+ALTER TABLE idempotency_keys ADD COLUMN IF NOT EXISTS locked_until TIMESTAMPTZ;
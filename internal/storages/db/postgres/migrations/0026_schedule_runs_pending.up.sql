@@ -0,0 +1,2 @@
+-- Воркер ищет срабатывания, оставшиеся pending после падения, по времени захвата
+CREATE INDEX IF NOT EXISTS idx_schedule_runs_pending ON schedule_runs(created_at) WHERE status = 'pending';
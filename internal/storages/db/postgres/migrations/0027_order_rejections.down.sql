@@ -0,0 +1,5 @@
+UPDATE orders SET status = 'cancelled' WHERE status = 'rejected';
+ALTER TABLE orders DROP CONSTRAINT IF EXISTS orders_status_check;
+ALTER TABLE orders ADD CONSTRAINT orders_status_check
+    CHECK ( status IN ('open', 'filled', 'cancelled', 'expired') );
+ALTER TABLE orders DROP COLUMN IF EXISTS reason;
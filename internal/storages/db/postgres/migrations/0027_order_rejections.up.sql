@@ -0,0 +1,5 @@
+-- Заявку, которую нельзя исполнить (лимиты, сумма меньше комиссии), матчер отклоняет с причиной
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS reason TEXT NOT NULL DEFAULT '';
+ALTER TABLE orders DROP CONSTRAINT IF EXISTS orders_status_check;
+ALTER TABLE orders ADD CONSTRAINT orders_status_check
+    CHECK ( status IN ('open', 'filled', 'cancelled', 'expired', 'rejected') );
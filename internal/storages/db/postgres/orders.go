@@ -0,0 +1,294 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gw-currency-wallet/internal/storages"
+	"gw-currency-wallet/pkg/money"
+	"sort"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+const orderColumns = `id, user_id, from_currency, to_currency, amount, limit_rate, status, fill_rate, received,
+	COALESCE(transaction_id, 0), reason, expires_at, created_at, updated_at`
+
+// PlaceOrder создаёт заявку и резервирует её сумму на балансе
+func (p *Postgres) PlaceOrder(ctx context.Context, order storages.Order) (storages.Order, error) {
+	tx, err := p.Client.Begin(ctx)
+	if err != nil {
+		return storages.Order{}, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if err = reserveHeld(ctx, tx, order.UserID, order.FromCurrency, order.Amount); err != nil {
+		return storages.Order{}, err
+	}
+
+	created, err := scanOrder(tx.QueryRow(ctx,
+		`INSERT INTO orders (user_id, from_currency, to_currency, amount, limit_rate, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6) RETURNING `+orderColumns,
+		order.UserID, order.FromCurrency, order.ToCurrency, order.Amount, order.LimitRate, order.ExpiresAt,
+	))
+	if err != nil {
+		return created, fmt.Errorf("failed to create order: %w", err)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return created, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return created, nil
+}
+
+func (p *Postgres) GetOrder(ctx context.Context, userID, orderID int64) (storages.Order, error) {
+	order, err := scanOrder(p.Client.QueryRow(ctx,
+		"SELECT "+orderColumns+" FROM orders WHERE id = $1 AND user_id = $2",
+		orderID, userID,
+	))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return order, storages.ErrOrderNotFound
+		}
+		return order, fmt.Errorf("failed to get order: %w", err)
+	}
+	return order, nil
+}
+
+// ListOrders возвращает заявки пользователя от новых к старым
+func (p *Postgres) ListOrders(ctx context.Context, userID int64) ([]storages.Order, error) {
+	rows, err := p.Client.Query(ctx,
+		"SELECT "+orderColumns+" FROM orders WHERE user_id = $1 ORDER BY id DESC",
+		userID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list orders: %w", err)
+	}
+	return collectOrders(rows)
+}
+
+// CancelOrder отменяет открытую заявку и возвращает сумму в доступный баланс
+func (p *Postgres) CancelOrder(ctx context.Context, userID, orderID int64) (storages.Order, error) {
+	tx, err := p.Client.Begin(ctx)
+	if err != nil {
+		return storages.Order{}, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	order, err := lockOpenOrder(ctx, tx, "id = $1 AND user_id = $2", orderID, userID)
+	if err != nil {
+		return order, err
+	}
+	if err = releaseHeld(ctx, tx, order.UserID, order.FromCurrency, order.Amount); err != nil {
+		return order, err
+	}
+
+	order, err = scanOrder(tx.QueryRow(ctx,
+		"UPDATE orders SET status = $1, updated_at = now() WHERE id = $2 RETURNING "+orderColumns,
+		storages.OrderCancelled, order.ID,
+	))
+	if err != nil {
+		return order, fmt.Errorf("failed to update order: %w", err)
+	}
+	if err = insertAudit(ctx, tx, storages.NewOrderAudit(order)); err != nil {
+		return order, err
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return order, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return order, nil
+}
+
+func (p *Postgres) MatchingOrders(ctx context.Context, fromCurrency, toCurrency string, rate money.Decimal, now time.Time, limit int) ([]storages.Order, error) {
+	rows, err := p.Client.Query(ctx,
+		`SELECT `+orderColumns+` FROM orders
+		WHERE status = $1 AND from_currency = $2 AND to_currency = $3 AND limit_rate <= $4 AND expires_at > $5
+		ORDER BY id LIMIT $6`,
+		storages.OrderOpen, fromCurrency, toCurrency, rate, now, limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get matching orders: %w", err)
+	}
+	return collectOrders(rows)
+}
+
+// FillOrder исполняет заявку по курсу rate: резерв снимается, обмен postings проводится через журнал
+// в пределах лимита charge, заявка закрывается, events записываются в outbox — всё в одной
+// транзакции. Отменённая или уже исполненная параллельно заявка возвращает ErrOrderNotOpen
+func (p *Postgres) FillOrder(ctx context.Context, orderID int64, rate, received money.Decimal, charge storages.LimitCharge, events []storages.OutboxEvent, postings ...storages.Posting) (storages.Order, error) {
+	tx, err := p.Client.Begin(ctx)
+	if err != nil {
+		return storages.Order{}, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	order, err := lockOpenOrder(ctx, tx, "id = $1", orderID)
+	if err != nil {
+		return order, err
+	}
+	if rate.Cmp(order.LimitRate) < 0 {
+		return order, storages.ErrRateBelowLimit
+	}
+
+	now := time.Now()
+	if err = lockLimits(ctx, tx, order.UserID, charge, now); err != nil {
+		return order, err
+	}
+
+	if err = releaseHeld(ctx, tx, order.UserID, order.FromCurrency, order.Amount); err != nil {
+		return order, err
+	}
+	txn, err := postInTx(ctx, tx, order.UserID, storages.OperationExchange, 0, postings)
+	if err != nil {
+		return order, err
+	}
+	if err = recordLimitUsage(ctx, tx, order.UserID, charge, now); err != nil {
+		return order, err
+	}
+
+	order, err = scanOrder(tx.QueryRow(ctx,
+		`UPDATE orders SET status = $1, fill_rate = $2, received = $3, transaction_id = $4, updated_at = now()
+		WHERE id = $5 RETURNING `+orderColumns,
+		storages.OrderFilled, rate, received, txn.ID, order.ID,
+	))
+	if err != nil {
+		return order, fmt.Errorf("failed to update order: %w", err)
+	}
+	if err = insertOutbox(ctx, tx, events, txn.ID); err != nil {
+		return order, err
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return order, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return order, nil
+}
+
+// ExpireOrders закрывает просроченные открытые заявки, освобождает их суммы и пишет аудит —
+// всё в одной транзакции. Заявки, заблокированные параллельным исполнением или отменой,
+// пропускаются до следующего запуска
+func (p *Postgres) ExpireOrders(ctx context.Context, now time.Time) (int, error) {
+	tx, err := p.Client.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	rows, err := tx.Query(ctx,
+		`UPDATE orders SET status = $1, updated_at = now()
+		WHERE id IN (
+			SELECT id FROM orders WHERE status = $2 AND expires_at <= $3
+			ORDER BY id
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING `+orderColumns,
+		storages.OrderExpired, storages.OrderOpen, now,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to expire orders: %w", err)
+	}
+	expired, err := collectOrders(rows)
+	if err != nil {
+		return 0, fmt.Errorf("failed to expire orders: %w", err)
+	}
+
+	// Балансы блокируются в порядке (user_id, currency), чтобы параллельные запуски не взаимоблокировались
+	sort.Slice(expired, func(i, j int) bool {
+		if expired[i].UserID != expired[j].UserID {
+			return expired[i].UserID < expired[j].UserID
+		}
+		if expired[i].FromCurrency != expired[j].FromCurrency {
+			return expired[i].FromCurrency < expired[j].FromCurrency
+		}
+		return expired[i].ID < expired[j].ID
+	})
+	for _, order := range expired {
+		if err = releaseHeld(ctx, tx, order.UserID, order.FromCurrency, order.Amount); err != nil {
+			return 0, err
+		}
+		if err = insertAudit(ctx, tx, storages.NewOrderAudit(order)); err != nil {
+			return 0, err
+		}
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return len(expired), nil
+}
+
+// RejectOrder отклоняет открытую заявку с причиной reason и возвращает сумму в доступный баланс
+func (p *Postgres) RejectOrder(ctx context.Context, orderID int64, reason string) (storages.Order, error) {
+	tx, err := p.Client.Begin(ctx)
+	if err != nil {
+		return storages.Order{}, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	order, err := lockOpenOrder(ctx, tx, "id = $1", orderID)
+	if err != nil {
+		return order, err
+	}
+	if err = releaseHeld(ctx, tx, order.UserID, order.FromCurrency, order.Amount); err != nil {
+		return order, err
+	}
+
+	order, err = scanOrder(tx.QueryRow(ctx,
+		"UPDATE orders SET status = $1, reason = $2, updated_at = now() WHERE id = $3 RETURNING "+orderColumns,
+		storages.OrderRejected, reason, order.ID,
+	))
+	if err != nil {
+		return order, fmt.Errorf("failed to update order: %w", err)
+	}
+	if err = insertAudit(ctx, tx, storages.NewOrderAudit(order)); err != nil {
+		return order, err
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return order, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return order, nil
+}
+
+// lockOpenOrder блокирует заявку и проверяет, что она ещё открыта и не просрочена.
+// Заявки блокируются раньше балансов — тот же порядок, что в ExpireOrders
+func lockOpenOrder(ctx context.Context, tx pgx.Tx, where string, args ...any) (storages.Order, error) {
+	order, err := scanOrder(tx.QueryRow(ctx,
+		"SELECT "+orderColumns+" FROM orders WHERE "+where+" FOR UPDATE",
+		args...,
+	))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return order, storages.ErrOrderNotFound
+		}
+		return order, fmt.Errorf("failed to get order: %w", err)
+	}
+
+	if order.Status != storages.OrderOpen || !order.ExpiresAt.After(time.Now()) {
+		return order, fmt.Errorf("%w: %s", storages.ErrOrderNotOpen, order.Status)
+	}
+	return order, nil
+}
+
+func collectOrders(rows pgx.Rows) ([]storages.Order, error) {
+	defer rows.Close()
+
+	var orders []storages.Order
+	for rows.Next() {
+		order, err := scanOrder(rows)
+		if err != nil {
+			return nil, err
+		}
+		orders = append(orders, order)
+	}
+	return orders, rows.Err()
+}
+
+func scanOrder(row pgx.Row) (storages.Order, error) {
+	var o storages.Order
+	err := row.Scan(&o.ID, &o.UserID, &o.FromCurrency, &o.ToCurrency, &o.Amount, &o.LimitRate, &o.Status,
+		&o.Rate, &o.Received, &o.TransactionID, &o.Reason, &o.ExpiresAt, &o.CreatedAt, &o.UpdatedAt)
+	return o, err
+}
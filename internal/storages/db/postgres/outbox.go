@@ -0,0 +1,111 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"gw-currency-wallet/internal/storages"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// outboxLockID — ключ advisory-блокировки: захват событий выполняется реле по очереди,
+// поэтому два реле не получат события одного пользователя одновременно
+const outboxLockID = 7426311
+
+// insertOutbox записывает события операции transactionID в транзакции tx
+func insertOutbox(ctx context.Context, tx pgx.Tx, events []storages.OutboxEvent, transactionID int64) error {
+	encoded, err := storages.EncodeOutbox(events, transactionID)
+	if err != nil {
+		return err
+	}
+	for _, e := range encoded {
+		if _, err = tx.Exec(ctx, "INSERT INTO outbox (user_id, payload) VALUES ($1, $2)", e.UserID, e.Payload); err != nil {
+			return fmt.Errorf("failed to write outbox event: %w", err)
+		}
+	}
+	return nil
+}
+
+// ClaimOutbox захватывает недоставленные события пользователей, у которых нет захваченных
+// событий, а первое недоставленное событие не ждёт повтора
+func (p *Postgres) ClaimOutbox(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]storages.OutboxEvent, error) {
+	tx, err := p.Client.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err = tx.Exec(ctx, "SELECT pg_advisory_xact_lock($1)", outboxLockID); err != nil {
+		return nil, fmt.Errorf("failed to lock outbox: %w", err)
+	}
+
+	rows, err := tx.Query(ctx,
+		`SELECT id, user_id, payload, attempts, created_at FROM outbox
+		WHERE sent_at IS NULL AND user_id IN (
+			SELECT user_id FROM outbox WHERE sent_at IS NULL
+			GROUP BY user_id
+			HAVING bool_and(locked_until IS NULL OR locked_until <= $1)
+				AND (array_agg(next_attempt_at ORDER BY id))[1] <= $1
+		)
+		ORDER BY id LIMIT $2`,
+		now, limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get outbox events: %w", err)
+	}
+	var events []storages.OutboxEvent
+	var ids []int64
+	for rows.Next() {
+		var e storages.OutboxEvent
+		if err = rows.Scan(&e.ID, &e.UserID, &e.Payload, &e.Attempts, &e.CreatedAt); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		events = append(events, e)
+		ids = append(ids, e.ID)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(ids) > 0 {
+		_, err = tx.Exec(ctx, "UPDATE outbox SET locked_until = $1 WHERE id = ANY($2)", now.Add(lease), ids)
+		if err != nil {
+			return nil, fmt.Errorf("failed to claim outbox events: %w", err)
+		}
+	}
+	if err = tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return events, nil
+}
+
+func (p *Postgres) MarkOutboxSent(ctx context.Context, ids []int64, now time.Time) error {
+	_, err := p.Client.Exec(ctx, "UPDATE outbox SET sent_at = $1, locked_until = NULL WHERE id = ANY($2)", now, ids)
+	if err != nil {
+		return fmt.Errorf("failed to mark outbox events sent: %w", err)
+	}
+	return nil
+}
+
+func (p *Postgres) RetryOutbox(ctx context.Context, id int64, nextAttemptAt time.Time, lastError string) error {
+	_, err := p.Client.Exec(ctx,
+		`UPDATE outbox SET attempts = attempts + 1, last_error = $1, next_attempt_at = $2, locked_until = NULL
+		WHERE id = $3`,
+		lastError, nextAttemptAt, id,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to reschedule outbox event: %w", err)
+	}
+	return nil
+}
+
+func (p *Postgres) ReleaseOutbox(ctx context.Context, ids []int64) error {
+	_, err := p.Client.Exec(ctx, "UPDATE outbox SET locked_until = NULL WHERE id = ANY($1)", ids)
+	if err != nil {
+		return fmt.Errorf("failed to release outbox events: %w", err)
+	}
+	return nil
+}
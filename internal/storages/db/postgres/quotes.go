@@ -0,0 +1,105 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gw-currency-wallet/internal/storages"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+const quoteColumns = `id, user_id, COALESCE(wallet_id, 0), from_currency, to_currency, amount, fee, fee_percent,
+	received, rate, expires_at, created_at, used_at, COALESCE(transaction_id, 0)`
+
+func (p *Postgres) CreateQuote(ctx context.Context, quote storages.Quote) (storages.Quote, error) {
+	_, err := p.Client.Exec(ctx,
+		"DELETE FROM exchange_quotes WHERE user_id = $1 AND used_at IS NULL AND expires_at < now()",
+		quote.UserID,
+	)
+	if err != nil {
+		return quote, fmt.Errorf("failed to delete expired quotes: %w", err)
+	}
+
+	var walletID *int64
+	if quote.WalletID != 0 {
+		walletID = &quote.WalletID
+	}
+	created, err := scanQuote(p.Client.QueryRow(ctx,
+		`INSERT INTO exchange_quotes (user_id, wallet_id, from_currency, to_currency, amount, fee, fee_percent, received, rate, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10) RETURNING `+quoteColumns,
+		quote.UserID, walletID, quote.FromCurrency, quote.ToCurrency, quote.Amount, quote.Fee, quote.FeePercent,
+		quote.Received, quote.Rate, quote.ExpiresAt,
+	))
+	if err != nil {
+		return created, fmt.Errorf("failed to create quote: %w", err)
+	}
+	return created, nil
+}
+
+func (p *Postgres) GetQuote(ctx context.Context, userID, quoteID int64) (storages.Quote, error) {
+	quote, err := scanQuote(p.Client.QueryRow(ctx,
+		"SELECT "+quoteColumns+" FROM exchange_quotes WHERE id = $1 AND user_id = $2",
+		quoteID, userID,
+	))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return quote, storages.ErrQuoteNotFound
+		}
+		return quote, fmt.Errorf("failed to get quote: %w", err)
+	}
+	return quote, nil
+}
+
+// ExecuteQuote блокирует котировку до конца транзакции, поэтому параллельные обмены по одной
+// котировке не пройдут оба
+func (p *Postgres) ExecuteQuote(ctx context.Context, quoteID, userID int64, charge storages.LimitCharge, events []storages.OutboxEvent, postings ...storages.Posting) (storages.Transaction, error) {
+	tx, err := p.Client.Begin(ctx)
+	if err != nil {
+		return storages.Transaction{}, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var expiresAt time.Time
+	var usedAt *time.Time
+	err = tx.QueryRow(ctx, "SELECT expires_at, used_at FROM exchange_quotes WHERE id = $1 FOR UPDATE", quoteID).
+		Scan(&expiresAt, &usedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return storages.Transaction{}, storages.ErrQuoteNotFound
+		}
+		return storages.Transaction{}, fmt.Errorf("failed to lock quote: %w", err)
+	}
+	now := time.Now()
+	switch {
+	case usedAt != nil:
+		return storages.Transaction{}, storages.ErrQuoteUsed
+	case !now.Before(expiresAt):
+		return storages.Transaction{}, storages.ErrQuoteExpired
+	}
+
+	txn, err := postWithinLimits(ctx, tx, userID, storages.OperationExchange, charge, events, postings)
+	if err != nil {
+		return txn, err
+	}
+	_, err = tx.Exec(ctx,
+		"UPDATE exchange_quotes SET used_at = $2, transaction_id = $3 WHERE id = $1",
+		quoteID, now, txn.ID,
+	)
+	if err != nil {
+		return txn, fmt.Errorf("failed to use quote: %w", err)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return txn, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return txn, nil
+}
+
+func scanQuote(row pgx.Row) (storages.Quote, error) {
+	var q storages.Quote
+	err := row.Scan(&q.ID, &q.UserID, &q.WalletID, &q.FromCurrency, &q.ToCurrency, &q.Amount, &q.Fee, &q.FeePercent,
+		&q.Received, &q.Rate, &q.ExpiresAt, &q.CreatedAt, &q.UsedAt, &q.TransactionID)
+	return q, err
+}
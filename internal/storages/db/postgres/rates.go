@@ -0,0 +1,75 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"gw-currency-wallet/internal/storages"
+	"time"
+)
+
+func (p *Postgres) SaveRates(ctx context.Context, samples []storages.RateSample) error {
+	if len(samples) == 0 {
+		return nil
+	}
+
+	fromCurrencies := make([]string, 0, len(samples))
+	toCurrencies := make([]string, 0, len(samples))
+	rates := make([]string, 0, len(samples))
+	sources := make([]string, 0, len(samples))
+	fetchedAt := make([]time.Time, 0, len(samples))
+	for _, s := range samples {
+		fromCurrencies = append(fromCurrencies, s.FromCurrency)
+		toCurrencies = append(toCurrencies, s.ToCurrency)
+		rates = append(rates, s.Rate.String())
+		sources = append(sources, s.Source)
+		fetchedAt = append(fetchedAt, s.FetchedAt)
+	}
+
+	_, err := p.Client.Exec(ctx,
+		`INSERT INTO rate_history (from_currency, to_currency, rate, source, fetched_at)
+		SELECT r.from_currency, r.to_currency, r.rate::numeric, r.source, r.fetched_at
+		FROM unnest($1::text[], $2::text[], $3::text[], $4::text[], $5::timestamptz[])
+			AS r(from_currency, to_currency, rate, source, fetched_at)`,
+		fromCurrencies, toCurrencies, rates, sources, fetchedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save rates: %w", err)
+	}
+	return nil
+}
+
+// GetRateCandles считает свечи в БД: открытие и закрытие — первый и последний курс интервала
+// по времени получения, при равном времени — по порядку записи
+func (p *Postgres) GetRateCandles(ctx context.Context, fromCurrency, toCurrency string, from, to time.Time, interval time.Duration) ([]storages.RateCandle, error) {
+	seconds := int64(interval / time.Second)
+	if seconds <= 0 {
+		return nil, fmt.Errorf("candle interval must be at least a second")
+	}
+
+	rows, err := p.Client.Query(ctx,
+		`SELECT to_timestamp(floor(extract(epoch FROM fetched_at) / $5) * $5) AS start,
+			(array_agg(rate ORDER BY fetched_at, id))[1],
+			MAX(rate), MIN(rate),
+			(array_agg(rate ORDER BY fetched_at DESC, id DESC))[1],
+			COUNT(*)
+		FROM rate_history
+		WHERE from_currency = $1 AND to_currency = $2 AND fetched_at >= $3 AND fetched_at < $4
+		GROUP BY start ORDER BY start`,
+		fromCurrency, toCurrency, from, to, seconds,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get rate candles: %w", err)
+	}
+	defer rows.Close()
+
+	var candles []storages.RateCandle
+	for rows.Next() {
+		var c storages.RateCandle
+		if err = rows.Scan(&c.Start, &c.Open, &c.High, &c.Low, &c.Close, &c.Samples); err != nil {
+			return nil, err
+		}
+		c.Start = c.Start.UTC()
+		candles = append(candles, c)
+	}
+	return candles, rows.Err()
+}
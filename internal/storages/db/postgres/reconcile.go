@@ -0,0 +1,112 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"gw-currency-wallet/internal/storages"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// CheckBalances читает балансы кошельков пачки пользователей и пересчитывает каждый по журналу
+// кошелька, а резерв кошелька по умолчанию — по холдам и заявкам, в одной транзакции REPEATABLE READ:
+// операции меняют баланс и журнал вместе, поэтому в снимке они всегда согласованы. Балансы
+// сверяются по кошелькам, чтобы расхождения в разных кошельках не гасили друг друга.
+// Блокировок сверка не берёт
+func (p *Postgres) CheckBalances(ctx context.Context, afterUserID int64, limit int) ([]storages.BalanceCheck, int64, error) {
+	tx, err := p.Client.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.RepeatableRead, AccessMode: pgx.ReadOnly})
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var lastUserID int64
+	err = tx.QueryRow(ctx,
+		"SELECT COALESCE(MAX(id), 0) FROM (SELECT id FROM users WHERE id > $1 ORDER BY id LIMIT $2) batch",
+		afterUserID, limit,
+	).Scan(&lastUserID)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to check balances: %w", err)
+	}
+	if lastUserID == 0 {
+		return nil, 0, nil
+	}
+
+	rows, err := tx.Query(ctx,
+		`WITH batch AS (
+			SELECT id FROM users WHERE id > $1 ORDER BY id LIMIT $2
+		), owned AS (
+			SELECT w.id, w.user_id, w.is_default FROM wallets w JOIN batch ON batch.id = w.user_id
+		), stored AS (
+			SELECT b.wallet_id, b.currency, b.amount, b.held, b.frozen
+			FROM balances b JOIN owned ON owned.id = b.wallet_id
+		), ledger AS (
+			SELECT e.wallet_id, e.currency, SUM(CASE WHEN e.direction = 'credit' THEN e.amount ELSE -e.amount END) AS amount
+			FROM ledger_entries e JOIN owned ON owned.id = e.wallet_id
+			WHERE e.account = 'wallet'
+			GROUP BY e.wallet_id, e.currency
+		), reserved AS (
+			-- Холды и заявки резервируют средства кошелька по умолчанию
+			SELECT owned.id AS wallet_id, r.currency, SUM(r.amount) AS amount FROM (
+				SELECT h.user_id, h.currency, h.amount
+				FROM holds h JOIN batch ON batch.id = h.user_id WHERE h.status = 'active'
+				UNION ALL
+				SELECT o.user_id, o.from_currency, o.amount
+				FROM orders o JOIN batch ON batch.id = o.user_id WHERE o.status = 'open'
+			) r
+			JOIN owned ON owned.user_id = r.user_id AND owned.is_default
+			GROUP BY owned.id, r.currency
+		)
+		SELECT owned.user_id, owned.id, COALESCE(s.currency, l.currency),
+			COALESCE(s.amount, 0.00), COALESCE(l.amount, 0.00),
+			COALESCE(s.held, 0.00), COALESCE(r.amount, 0.00), COALESCE(s.frozen, false)
+		FROM stored s
+		FULL JOIN ledger l ON l.wallet_id = s.wallet_id AND l.currency = s.currency
+		JOIN owned ON owned.id = COALESCE(s.wallet_id, l.wallet_id)
+		LEFT JOIN reserved r ON r.wallet_id = owned.id AND r.currency = COALESCE(s.currency, l.currency)
+		ORDER BY 1, 2, 3`,
+		afterUserID, limit,
+	)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to check balances: %w", err)
+	}
+	defer rows.Close()
+
+	var checks []storages.BalanceCheck
+	for rows.Next() {
+		var c storages.BalanceCheck
+		if err = rows.Scan(&c.UserID, &c.WalletID, &c.Currency, &c.Balance, &c.Ledger, &c.Held, &c.ExpectedHeld, &c.Frozen); err != nil {
+			return nil, 0, err
+		}
+		checks = append(checks, c)
+	}
+	return checks, lastUserID, rows.Err()
+}
+
+// SetAccountFrozen меняет заморозку счёта и записывает её в журнал аудита в одной транзакции
+func (p *Postgres) SetAccountFrozen(ctx context.Context, userID int64, currency string, frozen bool) error {
+	tx, err := p.Client.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	tag, err := tx.Exec(ctx,
+		"UPDATE balances SET frozen = $1 WHERE user_id = $2 AND currency = $3",
+		frozen, userID, currency,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update balance: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("%w: user %d, currency %s", storages.ErrBalanceNotFound, userID, currency)
+	}
+	if err = insertAudit(ctx, tx, storages.NewFreezeAudit(userID, currency, frozen)); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
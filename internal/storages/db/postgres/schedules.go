@@ -0,0 +1,291 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gw-currency-wallet/internal/storages"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+const scheduleColumns = `id, user_id, operation, COALESCE(wallet_id, 0), COALESCE(to_wallet_id, 0), currency,
+	COALESCE(to_currency, ''), COALESCE(to_user_id, 0), amount,
+	recurrence, start_at, next_run_at, status, last_run_at, last_error, created_at`
+
+const scheduleRunColumns = "id, schedule_id, scheduled_for, status, COALESCE(transaction_id, 0), error, created_at, finished_at"
+
+func (p *Postgres) CreateSchedule(ctx context.Context, s storages.Schedule) (storages.Schedule, error) {
+	var toCurrency *string
+	if s.ToCurrency != "" {
+		toCurrency = &s.ToCurrency
+	}
+	var toUserID *int64
+	if s.ToUserID != 0 {
+		toUserID = &s.ToUserID
+	}
+
+	created, err := scanSchedule(p.Client.QueryRow(ctx,
+		`INSERT INTO schedules (user_id, operation, wallet_id, to_wallet_id, currency, to_currency, to_user_id, amount,
+			recurrence, start_at, next_run_at)
+		VALUES ($1, $2, NULLIF($3::bigint, 0), NULLIF($4::bigint, 0), $5, $6, $7, $8, $9, $10, $10)
+		RETURNING `+scheduleColumns,
+		s.UserID, s.Operation, s.WalletID, s.ToWalletID, s.Currency, toCurrency, toUserID, s.Amount, s.Recurrence, s.StartAt,
+	))
+	if err != nil {
+		// У перевода по расписанию нет кошельков, у остальных операций — получателя
+		if isForeignKeyViolation(err) && s.ToUserID != 0 {
+			return created, fmt.Errorf("%w: recipient %d", storages.ErrUserNotFound, s.ToUserID)
+		}
+		if isForeignKeyViolation(err) {
+			return created, fmt.Errorf("%w: wallet %d or %d", storages.ErrWalletNotFound, s.WalletID, s.ToWalletID)
+		}
+		return created, fmt.Errorf("failed to create schedule: %w", err)
+	}
+	return created, nil
+}
+
+func (p *Postgres) ListSchedules(ctx context.Context, userID int64) ([]storages.Schedule, error) {
+	rows, err := p.Client.Query(ctx,
+		"SELECT "+scheduleColumns+" FROM schedules WHERE user_id = $1 ORDER BY id",
+		userID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list schedules: %w", err)
+	}
+	defer rows.Close()
+
+	var schedules []storages.Schedule
+	for rows.Next() {
+		s, err := scanSchedule(rows)
+		if err != nil {
+			return nil, err
+		}
+		schedules = append(schedules, s)
+	}
+	return schedules, rows.Err()
+}
+
+func (p *Postgres) GetSchedule(ctx context.Context, userID, scheduleID int64) (storages.Schedule, error) {
+	s, err := scanSchedule(p.Client.QueryRow(ctx,
+		"SELECT "+scheduleColumns+" FROM schedules WHERE id = $1 AND user_id = $2",
+		scheduleID, userID,
+	))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return s, storages.ErrScheduleNotFound
+		}
+		return s, fmt.Errorf("failed to get schedule: %w", err)
+	}
+	return s, nil
+}
+
+// SetScheduleStatus ставит расписание на паузу или возобновляет его. При возобновлении
+// пропущенные за паузу срабатывания не выполняются: следующее считается от now
+func (p *Postgres) SetScheduleStatus(ctx context.Context, userID, scheduleID int64, status storages.ScheduleStatus, now time.Time) (storages.Schedule, error) {
+	tx, err := p.Client.Begin(ctx)
+	if err != nil {
+		return storages.Schedule{}, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	s, err := scanSchedule(tx.QueryRow(ctx,
+		"SELECT "+scheduleColumns+" FROM schedules WHERE id = $1 AND user_id = $2 FOR UPDATE",
+		scheduleID, userID,
+	))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return s, storages.ErrScheduleNotFound
+		}
+		return s, fmt.Errorf("failed to get schedule: %w", err)
+	}
+	if s.Status == storages.ScheduleCompleted {
+		return s, storages.ErrScheduleCompleted
+	}
+
+	next := s.NextRunAt
+	if status == storages.ScheduleActive && s.Status != storages.ScheduleActive {
+		if next = s.NextRun(now); next == nil {
+			status = storages.ScheduleCompleted
+		}
+	}
+
+	s, err = scanSchedule(tx.QueryRow(ctx,
+		"UPDATE schedules SET status = $1, next_run_at = $2 WHERE id = $3 RETURNING "+scheduleColumns,
+		status, next, s.ID,
+	))
+	if err != nil {
+		return s, fmt.Errorf("failed to update schedule: %w", err)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return s, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return s, nil
+}
+
+func (p *Postgres) DeleteSchedule(ctx context.Context, userID, scheduleID int64) error {
+	tag, err := p.Client.Exec(ctx, "DELETE FROM schedules WHERE id = $1 AND user_id = $2", scheduleID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to delete schedule: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return storages.ErrScheduleNotFound
+	}
+	return nil
+}
+
+// ListScheduleRuns возвращает последние срабатывания расписания пользователя, от новых к старым
+func (p *Postgres) ListScheduleRuns(ctx context.Context, userID, scheduleID int64, limit int) ([]storages.ScheduleRun, error) {
+	if _, err := p.GetSchedule(ctx, userID, scheduleID); err != nil {
+		return nil, err
+	}
+
+	rows, err := p.Client.Query(ctx,
+		"SELECT "+scheduleRunColumns+" FROM schedule_runs WHERE schedule_id = $1 ORDER BY id DESC LIMIT $2",
+		scheduleID, limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list schedule runs: %w", err)
+	}
+	defer rows.Close()
+
+	var runs []storages.ScheduleRun
+	for rows.Next() {
+		var r storages.ScheduleRun
+		if err = rows.Scan(&r.ID, &r.ScheduleID, &r.ScheduledFor, &r.Status, &r.TransactionID, &r.Error, &r.CreatedAt, &r.FinishedAt); err != nil {
+			return nil, err
+		}
+		runs = append(runs, r)
+	}
+	return runs, rows.Err()
+}
+
+// ClaimDueSchedules захватывает наступившие срабатывания. Строки расписаний блокируются
+// с SKIP LOCKED, поэтому несколько экземпляров сервиса разбирают разные расписания,
+// а уникальный ключ schedule_runs защищает от повторного выполнения того же срабатывания.
+// Пропущенные за время простоя срабатывания сводятся к одному
+func (p *Postgres) ClaimDueSchedules(ctx context.Context, now time.Time, limit int) ([]storages.ScheduleRun, error) {
+	tx, err := p.Client.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	rows, err := tx.Query(ctx,
+		`SELECT `+scheduleColumns+` FROM schedules
+		WHERE status = $1 AND next_run_at <= $2
+		ORDER BY next_run_at LIMIT $3
+		FOR UPDATE SKIP LOCKED`,
+		storages.ScheduleActive, now, limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get due schedules: %w", err)
+	}
+	var due []storages.Schedule
+	for rows.Next() {
+		s, err := scanSchedule(rows)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		due = append(due, s)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	runs := make([]storages.ScheduleRun, 0, len(due))
+	for _, s := range due {
+		run := storages.ScheduleRun{ScheduleID: s.ID, ScheduledFor: *s.NextRunAt, Status: storages.RunPending, Schedule: s}
+		err = tx.QueryRow(ctx,
+			`INSERT INTO schedule_runs (schedule_id, scheduled_for) VALUES ($1, $2)
+			ON CONFLICT (schedule_id, scheduled_for) DO NOTHING RETURNING id, created_at`,
+			s.ID, run.ScheduledFor,
+		).Scan(&run.ID, &run.CreatedAt)
+		claimed := err == nil
+		if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+			return nil, fmt.Errorf("failed to create schedule run: %w", err)
+		}
+
+		next := s.NextRun(now)
+		status := storages.ScheduleActive
+		if next == nil {
+			status = storages.ScheduleCompleted
+		}
+		_, err = tx.Exec(ctx,
+			"UPDATE schedules SET next_run_at = $1, status = $2, last_run_at = $3 WHERE id = $4",
+			next, status, now, s.ID,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to update schedule: %w", err)
+		}
+
+		if claimed {
+			runs = append(runs, run)
+		}
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return runs, nil
+}
+
+// FinishScheduleRun сохраняет результат срабатывания; ошибка видна и в самом расписании
+func (p *Postgres) FinishScheduleRun(ctx context.Context, run storages.ScheduleRun) error {
+	tx, err := p.Client.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var transactionID *int64
+	if run.TransactionID != 0 {
+		transactionID = &run.TransactionID
+	}
+	_, err = tx.Exec(ctx,
+		"UPDATE schedule_runs SET status = $1, transaction_id = $2, error = $3, finished_at = now() WHERE id = $4",
+		run.Status, transactionID, run.Error, run.ID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update schedule run: %w", err)
+	}
+	_, err = tx.Exec(ctx, "UPDATE schedules SET last_error = $1 WHERE id = $2", run.Error, run.ScheduleID)
+	if err != nil {
+		return fmt.Errorf("failed to update schedule: %w", err)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+func (p *Postgres) FailStaleScheduleRuns(ctx context.Context, staleBefore time.Time) (int, error) {
+	var failed int
+	err := p.Client.QueryRow(ctx,
+		`WITH failed AS (
+			UPDATE schedule_runs SET status = $1, error = $2, finished_at = now()
+			WHERE status = $3 AND created_at < $4
+			RETURNING schedule_id
+		), updated AS (
+			UPDATE schedules SET last_error = $2 WHERE id IN (SELECT schedule_id FROM failed)
+		)
+		SELECT count(*) FROM failed`,
+		storages.RunFailed, storages.ErrRunInterrupted.Error(), storages.RunPending, staleBefore,
+	).Scan(&failed)
+	if err != nil {
+		return 0, fmt.Errorf("failed to fail stale schedule runs: %w", err)
+	}
+	return failed, nil
+}
+
+func scanSchedule(row pgx.Row) (storages.Schedule, error) {
+	var s storages.Schedule
+	err := row.Scan(&s.ID, &s.UserID, &s.Operation, &s.WalletID, &s.ToWalletID, &s.Currency, &s.ToCurrency, &s.ToUserID, &s.Amount,
+		&s.Recurrence, &s.StartAt, &s.NextRunAt, &s.Status, &s.LastRunAt, &s.LastError, &s.CreatedAt)
+	return s, err
+}
@@ -0,0 +1,327 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gw-currency-wallet/internal/storages"
+
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	invitationColumns = "id, wallet_id, email, role, invited_by, status, created_at, updated_at"
+	approvalColumns   = "id, wallet_id, requested_by, operation, currency, COALESCE(to_currency, ''), COALESCE(to_wallet_id, 0), amount," +
+		" status, COALESCE(decided_by, 0), COALESCE(transaction_id, 0), COALESCE(failure, ''), created_at, decided_at"
+)
+
+// CreateInvitation приглашает email в кошелёк. Владелец, участник или уже приглашённый — ErrMemberExists
+func (p *Postgres) CreateInvitation(ctx context.Context, invitation storages.WalletInvitation) (storages.WalletInvitation, error) {
+	created, err := scanInvitation(p.Client.QueryRow(ctx,
+		`INSERT INTO wallet_invitations (wallet_id, email, role, invited_by)
+		SELECT $1, $2, $3, $4
+		WHERE NOT EXISTS (
+			SELECT 1 FROM users u
+			JOIN wallets w ON w.id = $1
+			LEFT JOIN wallet_members m ON m.wallet_id = w.id AND m.user_id = u.id
+			WHERE u.email = $2 AND (w.user_id = u.id OR m.user_id IS NOT NULL)
+		)
+		RETURNING `+invitationColumns,
+		invitation.WalletID, invitation.Email, invitation.Role, invitation.InvitedBy,
+	))
+	if err != nil {
+		switch {
+		case errors.Is(err, pgx.ErrNoRows), isUniqueViolation(err):
+			return created, fmt.Errorf("%w: %s", storages.ErrMemberExists, invitation.Email)
+		case isForeignKeyViolation(err):
+			return created, fmt.Errorf("%w: %d", storages.ErrWalletNotFound, invitation.WalletID)
+		}
+		return created, fmt.Errorf("failed to create invitation: %w", err)
+	}
+	return created, nil
+}
+
+// ListInvitations возвращает ожидающие ответа приглашения на email пользователя
+func (p *Postgres) ListInvitations(ctx context.Context, userID int64) ([]storages.WalletInvitation, error) {
+	rows, err := p.Client.Query(ctx,
+		`SELECT `+invitationColumns+` FROM wallet_invitations
+		WHERE status = 'pending' AND email = (SELECT email FROM users WHERE id = $1)
+		ORDER BY id`,
+		userID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list invitations: %w", err)
+	}
+	defer rows.Close()
+
+	var invitations []storages.WalletInvitation
+	for rows.Next() {
+		invitation, err := scanInvitation(rows)
+		if err != nil {
+			return nil, err
+		}
+		invitations = append(invitations, invitation)
+	}
+	return invitations, rows.Err()
+}
+
+// RespondInvitation принимает или отклоняет приглашение; принятое добавляет пользователя в участники
+// в той же транзакции
+func (p *Postgres) RespondInvitation(ctx context.Context, userID, invitationID int64, accept bool) (storages.WalletInvitation, error) {
+	tx, err := p.Client.Begin(ctx)
+	if err != nil {
+		return storages.WalletInvitation{}, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	invitation, err := scanInvitation(tx.QueryRow(ctx,
+		`SELECT i.id, i.wallet_id, i.email, i.role, i.invited_by, i.status, i.created_at, i.updated_at FROM wallet_invitations i
+		JOIN users u ON u.email = i.email AND u.id = $1
+		WHERE i.id = $2
+		FOR UPDATE OF i`,
+		userID, invitationID,
+	))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return invitation, storages.ErrInvitationNotFound
+		}
+		return invitation, fmt.Errorf("failed to get invitation: %w", err)
+	}
+	if invitation.Status != storages.InvitationPending {
+		return invitation, storages.ErrInvitationNotOpen
+	}
+
+	status := storages.InvitationDeclined
+	if accept {
+		status = storages.InvitationAccepted
+		_, err = tx.Exec(ctx,
+			"INSERT INTO wallet_members (wallet_id, user_id, role) VALUES ($1, $2, $3) ON CONFLICT DO NOTHING",
+			invitation.WalletID, userID, invitation.Role,
+		)
+		if err != nil {
+			return invitation, fmt.Errorf("failed to add wallet member: %w", err)
+		}
+	}
+
+	invitation, err = scanInvitation(tx.QueryRow(ctx,
+		"UPDATE wallet_invitations SET status = $1, updated_at = now() WHERE id = $2 RETURNING "+invitationColumns,
+		status, invitationID,
+	))
+	if err != nil {
+		return invitation, fmt.Errorf("failed to update invitation: %w", err)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return invitation, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return invitation, nil
+}
+
+// ListWalletMembers возвращает владельца кошелька и участников в порядке добавления
+func (p *Postgres) ListWalletMembers(ctx context.Context, walletID int64) ([]storages.WalletMember, error) {
+	rows, err := p.Client.Query(ctx,
+		`SELECT wallet_id, user_id, email, role, created_at FROM (
+			SELECT w.id AS wallet_id, w.user_id, u.email, 'owner' AS role, w.created_at, 0 AS holder_last
+			FROM wallets w JOIN users u ON u.id = w.user_id WHERE w.id = $1
+			UNION ALL
+			SELECT m.wallet_id, m.user_id, u.email, m.role, m.created_at, 1
+			FROM wallet_members m JOIN users u ON u.id = m.user_id WHERE m.wallet_id = $1
+		) members
+		ORDER BY holder_last, created_at, user_id`,
+		walletID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list wallet members: %w", err)
+	}
+	defer rows.Close()
+
+	var members []storages.WalletMember
+	for rows.Next() {
+		var m storages.WalletMember
+		if err = rows.Scan(&m.WalletID, &m.UserID, &m.Email, &m.Role, &m.CreatedAt); err != nil {
+			return nil, err
+		}
+		members = append(members, m)
+	}
+	return members, rows.Err()
+}
+
+func (p *Postgres) SetWalletMemberRole(ctx context.Context, walletID, userID int64, role storages.WalletRole) error {
+	tag, err := p.Client.Exec(ctx,
+		"UPDATE wallet_members SET role = $1 WHERE wallet_id = $2 AND user_id = $3",
+		role, walletID, userID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update wallet member: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("%w: wallet %d, user %d", storages.ErrMemberNotFound, walletID, userID)
+	}
+	return nil
+}
+
+func (p *Postgres) RemoveWalletMember(ctx context.Context, walletID, userID int64) error {
+	tag, err := p.Client.Exec(ctx,
+		"DELETE FROM wallet_members WHERE wallet_id = $1 AND user_id = $2",
+		walletID, userID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to remove wallet member: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("%w: wallet %d, user %d", storages.ErrMemberNotFound, walletID, userID)
+	}
+	return nil
+}
+
+// SetApprovalRule создаёт правило или меняет порог существующего
+func (p *Postgres) SetApprovalRule(ctx context.Context, rule storages.ApprovalRule) error {
+	_, err := p.Client.Exec(ctx,
+		`INSERT INTO wallet_approval_rules (wallet_id, operation, currency, threshold) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (wallet_id, operation, currency) DO UPDATE SET threshold = EXCLUDED.threshold`,
+		rule.WalletID, rule.Operation, rule.Currency, rule.Threshold,
+	)
+	if err != nil {
+		if isForeignKeyViolation(err) {
+			return fmt.Errorf("%w: %s", storages.ErrCurrencyNotFound, rule.Currency)
+		}
+		return fmt.Errorf("failed to set approval rule: %w", err)
+	}
+	return nil
+}
+
+func (p *Postgres) DeleteApprovalRule(ctx context.Context, walletID int64, operation storages.OperationType, currency string) error {
+	tag, err := p.Client.Exec(ctx,
+		"DELETE FROM wallet_approval_rules WHERE wallet_id = $1 AND operation = $2 AND currency = $3",
+		walletID, operation, currency,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to delete approval rule: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("%w: %s %s", storages.ErrApprovalRuleMissing, operation, currency)
+	}
+	return nil
+}
+
+func (p *Postgres) ListApprovalRules(ctx context.Context, walletID int64) ([]storages.ApprovalRule, error) {
+	rows, err := p.Client.Query(ctx,
+		"SELECT wallet_id, operation, currency, threshold FROM wallet_approval_rules WHERE wallet_id = $1 ORDER BY operation, currency",
+		walletID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list approval rules: %w", err)
+	}
+	defer rows.Close()
+
+	var rules []storages.ApprovalRule
+	for rows.Next() {
+		var r storages.ApprovalRule
+		if err = rows.Scan(&r.WalletID, &r.Operation, &r.Currency, &r.Threshold); err != nil {
+			return nil, err
+		}
+		rules = append(rules, r)
+	}
+	return rules, rows.Err()
+}
+
+func (p *Postgres) CreateApproval(ctx context.Context, approval storages.Approval) (storages.Approval, error) {
+	created, err := scanApproval(p.Client.QueryRow(ctx,
+		`INSERT INTO wallet_approvals (wallet_id, requested_by, operation, currency, to_currency, to_wallet_id, amount)
+		VALUES ($1, $2, $3, $4, NULLIF($5, ''), NULLIF($6::bigint, 0), $7)
+		RETURNING `+approvalColumns,
+		approval.WalletID, approval.RequestedBy, approval.Operation, approval.Currency,
+		approval.ToCurrency, approval.ToWalletID, approval.Amount,
+	))
+	if err != nil {
+		return created, fmt.Errorf("failed to create approval: %w", err)
+	}
+	return created, nil
+}
+
+// ListApprovals возвращает запросы кошелька, новые первыми
+func (p *Postgres) ListApprovals(ctx context.Context, walletID int64) ([]storages.Approval, error) {
+	rows, err := p.Client.Query(ctx,
+		"SELECT "+approvalColumns+" FROM wallet_approvals WHERE wallet_id = $1 ORDER BY id DESC",
+		walletID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list approvals: %w", err)
+	}
+	defer rows.Close()
+
+	var approvals []storages.Approval
+	for rows.Next() {
+		approval, err := scanApproval(rows)
+		if err != nil {
+			return nil, err
+		}
+		approvals = append(approvals, approval)
+	}
+	return approvals, rows.Err()
+}
+
+// DecideApproval подтверждает или отклоняет запрос одним условным UPDATE, поэтому из
+// параллельных решений проходит одно. Автор может отклонить свой запрос, но не подтвердить
+func (p *Postgres) DecideApproval(ctx context.Context, walletID, approvalID, userID int64, status storages.ApprovalStatus) (storages.Approval, error) {
+	approval, err := scanApproval(p.Client.QueryRow(ctx,
+		`UPDATE wallet_approvals SET status = $4, decided_by = $3, decided_at = now()
+		WHERE id = $2 AND wallet_id = $1 AND status = 'pending' AND ($4 = 'rejected' OR requested_by <> $3)
+		RETURNING `+approvalColumns,
+		walletID, approvalID, userID, status,
+	))
+	if err == nil {
+		return approval, nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return approval, fmt.Errorf("failed to decide approval: %w", err)
+	}
+
+	var current storages.ApprovalStatus
+	var requestedBy int64
+	err = p.Client.QueryRow(ctx,
+		"SELECT status, requested_by FROM wallet_approvals WHERE id = $1 AND wallet_id = $2",
+		approvalID, walletID,
+	).Scan(&current, &requestedBy)
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+		return approval, storages.ErrApprovalNotFound
+	case err != nil:
+		return approval, fmt.Errorf("failed to get approval: %w", err)
+	case current != storages.ApprovalPending:
+		return approval, storages.ErrApprovalNotPending
+	}
+	return approval, storages.ErrSelfApproval
+}
+
+// FinishApproval записывает итог проводки подтверждённого запроса: операцию или причину отказа
+func (p *Postgres) FinishApproval(ctx context.Context, approvalID, transactionID int64, failure string) (storages.Approval, error) {
+	approval, err := scanApproval(p.Client.QueryRow(ctx,
+		`UPDATE wallet_approvals
+		SET status = CASE WHEN $3 = '' THEN 'approved' ELSE 'failed' END,
+			transaction_id = NULLIF($2::bigint, 0), failure = NULLIF($3, '')
+		WHERE id = $1 AND status = 'approved' AND transaction_id IS NULL
+		RETURNING `+approvalColumns,
+		approvalID, transactionID, failure,
+	))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return approval, storages.ErrApprovalNotFound
+		}
+		return approval, fmt.Errorf("failed to finish approval: %w", err)
+	}
+	return approval, nil
+}
+
+func scanInvitation(row pgx.Row) (storages.WalletInvitation, error) {
+	var i storages.WalletInvitation
+	err := row.Scan(&i.ID, &i.WalletID, &i.Email, &i.Role, &i.InvitedBy, &i.Status, &i.CreatedAt, &i.UpdatedAt)
+	return i, err
+}
+
+func scanApproval(row pgx.Row) (storages.Approval, error) {
+	var a storages.Approval
+	err := row.Scan(&a.ID, &a.WalletID, &a.RequestedBy, &a.Operation, &a.Currency, &a.ToCurrency, &a.ToWalletID, &a.Amount,
+		&a.Status, &a.DecidedBy, &a.TransactionID, &a.Failure, &a.CreatedAt, &a.DecidedAt)
+	return a, err
+}
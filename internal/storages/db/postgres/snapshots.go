@@ -0,0 +1,87 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"gw-currency-wallet/internal/storages"
+	"gw-currency-wallet/pkg/money"
+	"time"
+)
+
+// SnapshotBalances записывает остатки на конец дня day по парам кошелёк-валюта,
+// у которых с предыдущего снимка были движения: предыдущий снимок плюс движения после него.
+// Повторный вызов за тот же день пересчитывает снимок, поэтому задачу можно запускать чаще раза в сутки.
+// Читаются только движения после последнего снятого дня: у пар, не попавших в тот снимок,
+// движений с их собственного снимка не было
+func (p *Postgres) SnapshotBalances(ctx context.Context, day time.Time) (int, error) {
+	day, end := storages.SnapshotDay(day)
+
+	tag, err := p.Client.Exec(ctx,
+		`WITH last AS (
+			SELECT DISTINCT ON (wallet_id, currency) wallet_id, currency, day, amount
+			FROM balance_snapshots WHERE day < $1
+			ORDER BY wallet_id, currency, day DESC
+		), moves AS (
+			SELECT e.wallet_id, e.currency, SUM(CASE WHEN e.direction = 'credit' THEN e.amount ELSE -e.amount END) AS amount
+			FROM ledger_entries e
+			LEFT JOIN last l ON l.wallet_id = e.wallet_id AND l.currency = e.currency
+			WHERE e.account = 'wallet' AND e.created_at < $2
+				AND e.created_at >= COALESCE(
+					(SELECT (MAX(day) + 1)::timestamp AT TIME ZONE 'UTC' FROM balance_snapshots WHERE day < $1),
+					'-infinity')
+				AND (l.day IS NULL OR e.created_at >= (l.day + 1)::timestamp AT TIME ZONE 'UTC')
+			GROUP BY e.wallet_id, e.currency
+		)
+		INSERT INTO balance_snapshots (wallet_id, currency, day, amount)
+		SELECT m.wallet_id, m.currency, $1, COALESCE(l.amount, 0) + m.amount
+		FROM moves m LEFT JOIN last l ON l.wallet_id = m.wallet_id AND l.currency = m.currency
+		ON CONFLICT (wallet_id, currency, day) DO UPDATE SET amount = EXCLUDED.amount, created_at = now()`,
+		day, end,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to snapshot balances: %w", err)
+	}
+	return int(tag.RowsAffected()), nil
+}
+
+func (p *Postgres) GetBalancesAsOf(ctx context.Context, userID, walletID int64, asOf time.Time) (map[string]money.Decimal, error) {
+	wallet, err := p.GetWallet(ctx, userID, walletID)
+	if err != nil {
+		return nil, err
+	}
+	// Годится снимок дня, закончившегося не позже asOf
+	day, _ := storages.SnapshotDay(asOf)
+
+	rows, err := p.Client.Query(ctx,
+		`WITH last AS (
+			SELECT DISTINCT ON (currency) currency, day, amount
+			FROM balance_snapshots WHERE wallet_id = $1 AND day < $2
+			ORDER BY currency, day DESC
+		), moves AS (
+			SELECT e.currency, SUM(CASE WHEN e.direction = 'credit' THEN e.amount ELSE -e.amount END) AS amount
+			FROM ledger_entries e
+			LEFT JOIN last l ON l.currency = e.currency
+			WHERE e.wallet_id = $1 AND e.account = 'wallet' AND e.created_at < $3
+				AND (l.day IS NULL OR e.created_at >= (l.day + 1)::timestamp AT TIME ZONE 'UTC')
+			GROUP BY e.currency
+		)
+		SELECT COALESCE(l.currency, m.currency), COALESCE(l.amount, 0.00) + COALESCE(m.amount, 0.00)
+		FROM last l FULL JOIN moves m ON m.currency = l.currency`,
+		wallet.ID, day, asOf,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get balances as of %s: %w", asOf.Format(time.RFC3339), err)
+	}
+	defer rows.Close()
+
+	balances := make(map[string]money.Decimal)
+	for rows.Next() {
+		var currency string
+		var amount money.Decimal
+		if err = rows.Scan(&currency, &amount); err != nil {
+			return nil, err
+		}
+		balances[currency] = amount
+	}
+	return balances, rows.Err()
+}
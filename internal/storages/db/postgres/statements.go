@@ -0,0 +1,99 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"gw-currency-wallet/internal/storages"
+	"gw-currency-wallet/pkg/money"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// StreamStatement читает выписку в транзакции REPEATABLE READ, чтобы операции, записанные
+// во время выгрузки, не попали в движения, не попав во входящий остаток, и наоборот.
+// Движения читаются курсором построчно: большой период не буферизуется в памяти
+func (p *Postgres) StreamStatement(ctx context.Context, userID int64, filter storages.StatementFilter, sink storages.StatementSink) error {
+	tx, err := p.Client.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.RepeatableRead, AccessMode: pgx.ReadOnly})
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var from, to *time.Time
+	if !filter.From.IsZero() {
+		from = &filter.From
+	}
+	if !filter.To.IsZero() {
+		to = &filter.To
+	}
+
+	// Входящий остаток — сумма движений до начала периода. Валюты без движений
+	// до конца периода в выписку не попадают
+	rows, err := tx.Query(ctx,
+		`SELECT currency,
+			COALESCE(SUM(CASE WHEN $3::timestamptz IS NOT NULL AND created_at < $3
+				THEN CASE WHEN direction = 'credit' THEN amount ELSE -amount END
+				ELSE 0.00 END), 0.00)
+		FROM ledger_entries
+		WHERE user_id = $1 AND account = 'wallet'
+			AND ($2 = '' OR currency = $2)
+			AND ($4::timestamptz IS NULL OR created_at < $4)
+		GROUP BY currency`,
+		userID, filter.Currency, from, to,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to get opening balances: %w", err)
+	}
+	opening := make(map[string]money.Decimal)
+	for rows.Next() {
+		var currency string
+		var amount money.Decimal
+		if err = rows.Scan(&currency, &amount); err != nil {
+			rows.Close()
+			return err
+		}
+		opening[currency] = amount
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return fmt.Errorf("failed to get opening balances: %w", err)
+	}
+	if _, ok := opening[filter.Currency]; filter.Currency != "" && !ok {
+		opening[filter.Currency] = money.New(0, 2) // масштаб колонки balances
+	}
+
+	if err = sink.Begin(opening); err != nil {
+		return err
+	}
+
+	entries, err := tx.Query(ctx,
+		`SELECT e.id, e.transaction_id, t.type, COALESCE(t.counterparty_id, 0), e.currency,
+			CASE WHEN e.direction = 'credit' THEN e.amount ELSE -e.amount END, e.created_at
+		FROM ledger_entries e JOIN transactions t ON t.id = e.transaction_id
+		WHERE e.user_id = $1 AND e.account = 'wallet'
+			AND ($2 = '' OR e.currency = $2)
+			AND ($3::timestamptz IS NULL OR e.created_at >= $3)
+			AND ($4::timestamptz IS NULL OR e.created_at < $4)
+		ORDER BY e.currency, e.id`,
+		userID, filter.Currency, from, to,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to get statement entries: %w", err)
+	}
+	defer entries.Close()
+
+	for entries.Next() {
+		var e storages.StatementEntry
+		if err = entries.Scan(&e.EntryID, &e.TransactionID, &e.Type, &e.CounterpartyID, &e.Currency, &e.Amount, &e.CreatedAt); err != nil {
+			return err
+		}
+		if err = sink.Entry(e); err != nil {
+			return err
+		}
+	}
+	if err = entries.Err(); err != nil {
+		return fmt.Errorf("failed to get statement entries: %w", err)
+	}
+	return nil
+}
@@ -4,11 +4,16 @@ import (
 	"context"
 	"fmt"
 	"gw-currency-wallet/internal/config"
+	"gw-currency-wallet/internal/storages"
 	"gw-currency-wallet/pkg/logging"
+	"gw-currency-wallet/pkg/money"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestPostgresStorage(t *testing.T) {
@@ -25,56 +30,580 @@ func TestPostgresStorage(t *testing.T) {
 	}
 
 	repo, closeDB := NewPostgresRepository(context.Background(), cfg, logger)
-	assert.NotNil(t, repo)
+	require.NotNil(t, repo)
 	defer closeDB()
 
 	// Приведем к типу *Postgres для доступа к методам
 	storage := repo.(*Postgres)
 
-	// Создание структуры таблиц перед тестированием
-	_, err := storage.Client.Exec(context.Background(), `
-		CREATE TABLE IF NOT EXISTS users(
-			id SERIAL PRIMARY KEY,
-			email VARCHAR(255) UNIQUE NOT NULL,
-			password_hash TEXT NOT NULL
-		);
-
-		CREATE TABLE IF NOT EXISTS balances(
-			user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
-			currency VARCHAR(3) NOT NULL,
-			amount DECIMAL(15,2) NOT NULL CHECK ( amount >= 0 ),
-			PRIMARY KEY (user_id, currency)
-		);
-
-		CREATE INDEX IF NOT EXISTS idx_balances_user_currency ON balances(user_id, currency);
-	`)
-	assert.NoError(t, err)
+	// Пул подключается лениво: без запущенной БД тест пропускается, а не падает
+	if err := storage.Client.Ping(context.Background()); err != nil {
+		t.Skipf("test database is not available: %v", err)
+	}
+
+	// Приводим схему к актуальной версии миграциями
+	_, err := storage.MigrateUp(context.Background())
+	require.NoError(t, err)
+	require.NoError(t, storage.CheckSchema(context.Background()))
+
+	// Справочник валют заполнен миграцией
+	usd, err := storage.GetCurrency(context.Background(), "USD")
+	require.NoError(t, err)
+	assert.Equal(t, int32(2), usd.MinorUnits)
+	_, err = storage.GetCurrency(context.Background(), "XXX")
+	assert.ErrorIs(t, err, storages.ErrCurrencyNotFound)
 
 	// Используем уникальный email для каждого запуска теста
 	email := fmt.Sprintf("test_%d@example.com", time.Now().UnixNano())
 
 	// Создание пользователя
 	userID, err := storage.CreateUser(context.Background(), email, "hash")
-	assert.NoError(t, err)
+	require.NoError(t, err)
 	assert.Greater(t, userID, int64(0))
 
 	// Проверка баланса
 	balance, err := storage.GetBalance(context.Background(), userID, "USD")
-	assert.NoError(t, err)
-	assert.Equal(t, float32(0), balance)
+	require.NoError(t, err)
+	assert.Equal(t, "0.00", balance.String())
 
 	// Обновление баланса
-	err = storage.UpdateBalance(context.Background(), userID, "USD", float32(100.5))
-	assert.NoError(t, err)
+	err = storage.UpdateBalance(context.Background(), userID, "USD", money.MustParse("100.5"))
+	require.NoError(t, err)
 
 	balance, err = storage.GetBalance(context.Background(), userID, "USD")
-	assert.NoError(t, err)
-	assert.Equal(t, float32(100.5), balance)
+	require.NoError(t, err)
+	assert.Equal(t, "100.50", balance.String())
+
+	// Обмен проводится одной операцией журнала
+	txn, err := storage.PostTransaction(context.Background(), userID, storages.OperationExchange,
+		storages.Posting{Currency: "USD", Amount: money.New(-50, 0)},
+		storages.Posting{Currency: "RUB", Amount: money.New(4500, 0)},
+	)
+	require.NoError(t, err)
+	assert.Len(t, txn.Entries, 4)
+
+	entries, err := storage.GetTransactionEntries(context.Background(), txn.ID)
+	require.NoError(t, err)
+	assert.Len(t, entries, 4)
+
+	// Баланс по журналу совпадает с таблицей balances
+	for _, currency := range []string{"USD", "RUB"} {
+		balance, err = storage.GetBalance(context.Background(), userID, currency)
+		require.NoError(t, err)
+		ledger, err := storage.GetLedgerBalance(context.Background(), userID, currency)
+		require.NoError(t, err)
+		assert.True(t, balance.Equal(ledger))
+	}
+
+	// История: фильтр по типу и постраничная выдача по курсору
+	exchanges, err := storage.ListTransactions(context.Background(), userID, storages.TransactionFilter{
+		Type:  storages.OperationExchange,
+		Limit: 10,
+	})
+	require.NoError(t, err)
+	require.Len(t, exchanges, 1)
+	assert.Equal(t, txn.ID, exchanges[0].ID)
+
+	page, err := storage.ListTransactions(context.Background(), userID, storages.TransactionFilter{
+		Currency: "USD",
+		BeforeID: txn.ID,
+		Limit:    10,
+	})
+	require.NoError(t, err)
+	require.Len(t, page, 1)
+	assert.Equal(t, storages.OperationDeposit, page[0].Type)
+
+	// История по кошельку: только операции с его проводками; чужой кошелёк не найден
+	byWallet, err := storage.ListTransactions(context.Background(), userID, storages.TransactionFilter{
+		WalletID: exchanges[0].Entries[0].WalletID,
+		Limit:    10,
+	})
+	require.NoError(t, err)
+	assert.Len(t, byWallet, 2)
+	_, err = storage.ListTransactions(context.Background(), userID, storages.TransactionFilter{WalletID: -1, Limit: 10})
+	assert.ErrorIs(t, err, storages.ErrWalletNotFound)
+
+	detail, err := storage.GetTransaction(context.Background(), userID, txn.ID)
+	require.NoError(t, err)
+	assert.Len(t, detail.Entries, 4)
+
+	_, err = storage.GetTransaction(context.Background(), userID+1, txn.ID)
+	assert.ErrorIs(t, err, storages.ErrTransactionNotFound)
+
+	// Неудачная операция не оставляет ни изменений баланса, ни проводок
+	_, err = storage.PostTransaction(context.Background(), userID, storages.OperationExchange,
+		storages.Posting{Currency: "USD", Amount: money.New(-1000, 0)},
+		storages.Posting{Currency: "RUB", Amount: money.New(90000, 0)},
+	)
+	assert.Error(t, err)
+
+	balance, err = storage.GetBalance(context.Background(), userID, "RUB")
+	require.NoError(t, err)
+	assert.Equal(t, "4500.00", balance.String())
+
+	// Параллельные списания не уводят баланс в минус: из 50.5 USD проходят ровно пять по 10
+	var wg sync.WaitGroup
+	var succeeded atomic.Int32
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := storage.Debit(context.Background(), userID, "USD", money.New(10, 0))
+			if err == nil {
+				succeeded.Add(1)
+				return
+			}
+			assert.ErrorIs(t, err, storages.ErrInsufficientFunds)
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(5), succeeded.Load())
+
+	// Перевод записывается у обоих участников одной транзакцией БД
+	recipientID, err := storage.CreateUser(context.Background(), "recipient_"+email, "hash")
+	require.NoError(t, err)
+
+	transfer, err := storage.Transfer(context.Background(), userID, recipientID, "USD", money.MustParse("0.5"), storages.LimitCharge{Operation: storages.OperationWithdraw, Currency: "USD", Amount: money.MustParse("0.5")},
+		storages.OutboxEvent{UserID: userID, Body: map[string]any{"type": "p2p_transfer"}})
+	require.NoError(t, err)
+	assert.Equal(t, recipientID, transfer.CounterpartyID)
+
+	// Событие перевода записано в outbox той же транзакцией и ещё не доставлено
+	var payload string
+	err = storage.Client.QueryRow(context.Background(),
+		"SELECT payload::text FROM outbox WHERE user_id = $1 AND sent_at IS NULL", userID).Scan(&payload)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"type": "p2p_transfer"}`, payload)
+
+	balance, err = storage.GetBalance(context.Background(), recipientID, "USD")
+	require.NoError(t, err)
+	assert.Equal(t, "0.50", balance.String())
+
+	incoming, err := storage.ListTransactions(context.Background(), recipientID, storages.TransactionFilter{Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, incoming, 1)
+	assert.Equal(t, userID, incoming[0].CounterpartyID)
+
+	_, err = storage.Transfer(context.Background(), userID, recipientID, "USD", money.MustParse("0.01"), storages.LimitCharge{Operation: storages.OperationWithdraw, Currency: "USD", Amount: money.MustParse("0.01")})
+	assert.ErrorIs(t, err, storages.ErrInsufficientFunds)
+	_, err = storage.Transfer(context.Background(), userID, userID, "USD", money.MustParse("0.01"), storages.LimitCharge{Operation: storages.OperationWithdraw, Currency: "USD", Amount: money.MustParse("0.01")})
+	assert.ErrorIs(t, err, storages.ErrSelfTransfer)
+	_, err = storage.Transfer(context.Background(), userID, -1, "USD", money.MustParse("0.01"), storages.LimitCharge{Operation: storages.OperationWithdraw, Currency: "USD", Amount: money.MustParse("0.01")})
+	assert.ErrorIs(t, err, storages.ErrUserNotFound)
+
+	_, err = storage.Client.Exec(context.Background(), "DELETE FROM users WHERE id = $1", recipientID)
+	require.NoError(t, err)
+
+	// Холд уменьшает доступный баланс; списание по холду проходит через журнал
+	_, err = storage.Credit(context.Background(), userID, "USD", money.New(10, 0))
+	require.NoError(t, err)
+	hold, err := storage.PlaceHold(context.Background(), userID, "USD", money.New(6, 0), time.Now().Add(time.Hour))
+	require.NoError(t, err)
+	_, err = storage.Debit(context.Background(), userID, "USD", money.New(5, 0))
+	assert.ErrorIs(t, err, storages.ErrInsufficientFunds)
+
+	hold, err = storage.CaptureHold(context.Background(), userID, hold.ID, money.New(4, 0), storages.LimitCharge{Operation: storages.OperationWithdraw, Currency: "USD", Amount: money.New(4, 0)})
+	require.NoError(t, err)
+	assert.Equal(t, storages.HoldCaptured, hold.Status)
+	_, err = storage.VoidHold(context.Background(), userID, hold.ID)
+	assert.ErrorIs(t, err, storages.ErrHoldNotActive)
+
+	balance, err = storage.GetBalance(context.Background(), userID, "USD")
+	require.NoError(t, err)
+	assert.Equal(t, "6.00", balance.String())
+	ledger, err := storage.GetLedgerBalance(context.Background(), userID, "USD")
+	require.NoError(t, err)
+	assert.True(t, balance.Equal(ledger))
+
+	expiring, err := storage.PlaceHold(context.Background(), userID, "USD", money.New(6, 0), time.Now().Add(time.Minute))
+	require.NoError(t, err)
+	expired, err := storage.ExpireHolds(context.Background(), time.Now().Add(2*time.Minute))
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, expired, 1)
+	expiring, err = storage.GetHold(context.Background(), userID, expiring.ID)
+	require.NoError(t, err)
+	assert.Equal(t, storages.HoldExpired, expiring.Status)
+
+	// Выписка: входящий остаток на начало периода и движения за период
+	var statement statementRecorder
+	err = storage.StreamStatement(context.Background(), userID, storages.StatementFilter{
+		Currency: "USD", From: time.Now().Add(-time.Hour), To: time.Now().Add(time.Hour),
+	}, &statement)
+	require.NoError(t, err)
+	assert.Contains(t, statement.opening, "USD")
+	closing := statement.opening["USD"]
+	for _, e := range statement.entries {
+		assert.Equal(t, "USD", e.Currency)
+		closing, err = closing.Add(e.Amount)
+		require.NoError(t, err)
+	}
+	assert.True(t, closing.Equal(ledger))
+
+	// Остаток на момент: снимок на конец сегодняшнего дня плюс движения после него
+	_, err = storage.SnapshotBalances(context.Background(), time.Now())
+	require.NoError(t, err)
+	_, tomorrow := storages.SnapshotDay(time.Now())
+	asOf, err := storage.GetBalancesAsOf(context.Background(), userID, 0, tomorrow.Add(time.Hour))
+	require.NoError(t, err)
+	assert.True(t, asOf["USD"].Equal(ledger))
+	asOf, err = storage.GetBalancesAsOf(context.Background(), userID, 0, time.Now())
+	require.NoError(t, err)
+	assert.True(t, asOf["USD"].Equal(ledger))
+
+	// Сверка: сохранённые балансы и резервы совпадают с журналом, холдами и заявками
+	checks, lastUserID, err := storage.CheckBalances(context.Background(), userID-1, 1)
+	require.NoError(t, err)
+	assert.Equal(t, userID, lastUserID)
+	for _, check := range checks {
+		assert.False(t, check.Drifted(), check.Currency)
+	}
+	assert.NoError(t, storage.SetAccountFrozen(context.Background(), userID, "USD", true))
+	_, err = storage.Credit(context.Background(), userID, "USD", money.New(1, 0))
+	assert.ErrorIs(t, err, storages.ErrAccountFrozen)
+	assert.NoError(t, storage.SetAccountFrozen(context.Background(), userID, "USD", false))
+
+	// Проводка с IfVersion проходит только по неизменившемуся балансу и меняет версию
+	var version int64
+	balances, err := storage.GetBalances(context.Background(), userID)
+	require.NoError(t, err)
+	for _, b := range balances {
+		if b.Currency == "USD" {
+			version = b.Version
+		}
+	}
+	_, err = storage.PostTransaction(context.Background(), userID, storages.OperationDeposit,
+		storages.Posting{Currency: "USD", Amount: money.New(1, 0), IfVersion: version})
+	require.NoError(t, err)
+	_, err = storage.PostTransaction(context.Background(), userID, storages.OperationWithdraw,
+		storages.Posting{Currency: "USD", Amount: money.New(-1, 0), IfVersion: version})
+	assert.ErrorIs(t, err, storages.ErrVersionMismatch)
+	_, err = storage.PostTransaction(context.Background(), userID, storages.OperationWithdraw,
+		storages.Posting{Currency: "USD", Amount: money.New(-1, 0), IfVersion: version + 1})
+	require.NoError(t, err)
+
+	// Перемещение между кошельками: баланс по умолчанию уменьшается, сверка по пользователю сходится
+	savings, err := storage.CreateWallet(context.Background(), userID, "Savings")
+	require.NoError(t, err)
+	_, err = storage.CreateWallet(context.Background(), userID, "Savings")
+	assert.ErrorIs(t, err, storages.ErrWalletExists)
+	before, err := storage.GetBalance(context.Background(), userID, "USD")
+	require.NoError(t, err)
+	move, err := storage.PostTransaction(context.Background(), userID, storages.OperationMove,
+		storages.Posting{Currency: "USD", Amount: money.New(-1, 0)},
+		storages.Posting{WalletID: savings.ID, Currency: "USD", Amount: money.New(1, 0)})
+	require.NoError(t, err)
+	after, err := storage.GetBalance(context.Background(), userID, "USD")
+	require.NoError(t, err)
+	moved, _ := before.Sub(after)
+	assert.Equal(t, "1.00", moved.String())
+	// Остаток на момент считается по тому же кошельку, что и текущий
+	asOf, err = storage.GetBalancesAsOf(context.Background(), userID, 0, time.Now())
+	require.NoError(t, err)
+	assert.True(t, asOf["USD"].Equal(after), asOf["USD"].String())
+	asOf, err = storage.GetBalancesAsOf(context.Background(), userID, savings.ID, time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, "1.00", asOf["USD"].String())
+	balances, err = storage.GetWalletBalances(context.Background(), userID, savings.ID)
+	require.NoError(t, err)
+	for _, b := range balances {
+		if b.Currency == "USD" {
+			assert.Equal(t, "1.00", b.Amount.String())
+		}
+	}
+	checks, _, err = storage.CheckBalances(context.Background(), userID-1, 1)
+	require.NoError(t, err)
+	for _, check := range checks {
+		assert.False(t, check.Drifted(), check.Currency)
+	}
+	_, err = storage.GetWalletBalances(context.Background(), userID+1, savings.ID)
+	assert.ErrorIs(t, err, storages.ErrWalletNotFound)
+
+	// Участник общего кошелька видит его со своей ролью; подтвердить запрос может только не автор
+	_, err = storage.CreateInvitation(context.Background(), storages.WalletInvitation{
+		WalletID: savings.ID, Email: email, Role: storages.RoleViewer, InvitedBy: userID})
+	assert.ErrorIs(t, err, storages.ErrMemberExists)
+	invitation, err := storage.CreateInvitation(context.Background(), storages.WalletInvitation{
+		WalletID: savings.ID, Email: "recipient_" + email, Role: storages.RoleSpender, InvitedBy: userID})
+	require.NoError(t, err)
+	_, err = storage.RespondInvitation(context.Background(), userID, invitation.ID, true)
+	assert.ErrorIs(t, err, storages.ErrInvitationNotFound)
+	invitation, err = storage.RespondInvitation(context.Background(), recipientID, invitation.ID, true)
+	require.NoError(t, err)
+	assert.Equal(t, storages.InvitationAccepted, invitation.Status)
+	shared, err := storage.GetWallet(context.Background(), recipientID, savings.ID)
+	require.NoError(t, err)
+	assert.Equal(t, storages.RoleSpender, shared.Role)
+	members, err := storage.ListWalletMembers(context.Background(), savings.ID)
+	require.NoError(t, err)
+	assert.Len(t, members, 2)
+	// Участник видит операцию по общему кошельку с проводками только по нему
+	seen, err := storage.GetTransaction(context.Background(), recipientID, move.ID)
+	require.NoError(t, err)
+	require.Len(t, seen.Entries, 1)
+	assert.Equal(t, savings.ID, seen.Entries[0].WalletID)
+
+	approval, err := storage.CreateApproval(context.Background(), storages.Approval{
+		WalletID: savings.ID, RequestedBy: recipientID, Operation: storages.OperationWithdraw, Currency: "USD", Amount: money.New(1, 0)})
+	require.NoError(t, err)
+	_, err = storage.DecideApproval(context.Background(), savings.ID, approval.ID, recipientID, storages.ApprovalApproved)
+	assert.ErrorIs(t, err, storages.ErrSelfApproval)
+	_, err = storage.DecideApproval(context.Background(), savings.ID, approval.ID, userID, storages.ApprovalApproved)
+	require.NoError(t, err)
+	_, err = storage.DecideApproval(context.Background(), savings.ID, approval.ID, userID, storages.ApprovalRejected)
+	assert.ErrorIs(t, err, storages.ErrApprovalNotPending)
+	approval, err = storage.FinishApproval(context.Background(), approval.ID, 0, "insufficient funds")
+	require.NoError(t, err)
+	assert.Equal(t, storages.ApprovalFailed, approval.Status)
+	assert.NoError(t, storage.RemoveWalletMember(context.Background(), savings.ID, recipientID))
+	_, err = storage.GetWallet(context.Background(), recipientID, savings.ID)
+	assert.ErrorIs(t, err, storages.ErrWalletNotFound)
+	_, err = storage.GetTransaction(context.Background(), recipientID, move.ID)
+	assert.ErrorIs(t, err, storages.ErrTransactionNotFound)
+
+	// Лимит пользователя проверяется и расходуется в транзакции операции
+	_, err = storage.Client.Exec(context.Background(),
+		"INSERT INTO limits (user_id, operation, currency, daily) VALUES ($1, 'withdraw', 'USD', 5)", userID)
+	require.NoError(t, err)
+	_, err = storage.Credit(context.Background(), userID, "USD", money.New(10, 0))
+	require.NoError(t, err)
+	baseAmount := money.New(4, 0)
+	charge := storages.LimitCharge{Operation: storages.OperationWithdraw, Currency: "USD", Amount: money.New(4, 0), BaseAmount: &baseAmount}
+	_, err = storage.PostWithinLimits(context.Background(), userID, storages.OperationWithdraw, charge, nil,
+		storages.Posting{Currency: "USD", Amount: money.New(-4, 0)})
+	require.NoError(t, err)
+	_, err = storage.PostWithinLimits(context.Background(), userID, storages.OperationWithdraw, charge, nil,
+		storages.Posting{Currency: "USD", Amount: money.New(-4, 0)})
+	assert.ErrorIs(t, err, storages.ErrLimitExceeded)
+	statuses, err := storage.GetLimitStatus(context.Background(), userID, time.Now())
+	require.NoError(t, err)
+	for _, status := range statuses {
+		if status.Operation == storages.OperationWithdraw && status.Currency == "USD" {
+			assert.Equal(t, "1.00", status.DailyRemaining.String())
+		}
+	}
+	_, err = storage.Debit(context.Background(), userID, "USD", money.New(6, 0))
+	require.NoError(t, err)
+
+	// Заявка резервирует сумму; исполнение снимает резерв и проводит обмен одной операцией
+	_, err = storage.Credit(context.Background(), userID, "USD", money.New(10, 0))
+	require.NoError(t, err)
+	order, err := storage.PlaceOrder(context.Background(), storages.Order{
+		UserID: userID, FromCurrency: "USD", ToCurrency: "EUR", Amount: money.New(10, 0),
+		LimitRate: money.MustParse("0.9"), ExpiresAt: time.Now().Add(time.Hour),
+	})
+	require.NoError(t, err)
+	_, err = storage.Debit(context.Background(), userID, "USD", money.New(1, 0))
+	assert.ErrorIs(t, err, storages.ErrInsufficientFunds)
+	fill := func(rate, received money.Decimal) (storages.Order, error) {
+		return storage.FillOrder(context.Background(), order.ID, rate, received,
+			storages.LimitCharge{Operation: storages.OperationExchange, Currency: "USD", Amount: order.Amount}, nil,
+			storages.Posting{Currency: "USD", Amount: order.Amount.Neg()},
+			storages.Posting{Currency: "EUR", Amount: received})
+	}
+	_, err = fill(money.MustParse("0.8"), money.New(8, 0))
+	assert.ErrorIs(t, err, storages.ErrRateBelowLimit)
+
+	matching, err := storage.MatchingOrders(context.Background(), "USD", "EUR", money.MustParse("0.95"), time.Now(), 10)
+	require.NoError(t, err)
+	assert.NotEmpty(t, matching)
+	order, err = fill(money.MustParse("0.95"), money.MustParse("9.5"))
+	require.NoError(t, err)
+	assert.Equal(t, storages.OrderFilled, order.Status)
+	assert.Equal(t, "9.50", order.Received.String())
+	_, err = storage.CancelOrder(context.Background(), userID, order.ID)
+	assert.ErrorIs(t, err, storages.ErrOrderNotOpen)
+
+	// Истечение и отклонение освобождают резерв и пишут аудит в той же транзакции
+	_, err = storage.Credit(context.Background(), userID, "USD", money.New(2, 0))
+	require.NoError(t, err)
+	placeOrder := func() storages.Order {
+		order, err := storage.PlaceOrder(context.Background(), storages.Order{
+			UserID: userID, FromCurrency: "USD", ToCurrency: "EUR", Amount: money.New(1, 0),
+			LimitRate: money.MustParse("0.9"), ExpiresAt: time.Now().Add(time.Minute),
+		})
+		require.NoError(t, err)
+		return order
+	}
+	stale, unfillable := placeOrder(), placeOrder()
+	unfillable, err = storage.RejectOrder(context.Background(), unfillable.ID, "limit exceeded")
+	require.NoError(t, err)
+	assert.Equal(t, storages.OrderRejected, unfillable.Status)
+	assert.Equal(t, "limit exceeded", unfillable.Reason)
+	expiredOrders, err := storage.ExpireOrders(context.Background(), time.Now().Add(2*time.Minute))
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, expiredOrders, 1)
+	stale, err = storage.GetOrder(context.Background(), userID, stale.ID)
+	require.NoError(t, err)
+	assert.Equal(t, storages.OrderExpired, stale.Status)
+	for _, action := range []storages.AuditAction{storages.AuditOrderExpire, storages.AuditOrderReject} {
+		audit, err := storage.ListAudit(context.Background(), storages.AuditFilter{UserID: userID, Action: action, Limit: 10})
+		require.NoError(t, err)
+		assert.NotEmpty(t, audit, action)
+	}
+	_, err = storage.Debit(context.Background(), userID, "USD", money.New(2, 0))
+	require.NoError(t, err)
+
+	// Срабатывание расписания захватывается один раз, следующее считается от StartAt
+	start := time.Now().Add(-time.Minute).UTC().Truncate(time.Second)
+	schedule, err := storage.CreateSchedule(context.Background(), storages.Schedule{
+		UserID: userID, Operation: storages.OperationDeposit, Currency: "USD",
+		Amount: money.New(1, 0), Recurrence: storages.RecurrenceDaily, StartAt: start,
+	})
+	require.NoError(t, err)
+	_, err = storage.CreateSchedule(context.Background(), storages.Schedule{
+		UserID: userID, Operation: storages.OperationTransfer, Currency: "USD", ToUserID: -1,
+		Amount: money.New(1, 0), Recurrence: storages.RecurrenceOnce, StartAt: start,
+	})
+	assert.ErrorIs(t, err, storages.ErrUserNotFound)
+
+	runs, err := storage.ClaimDueSchedules(context.Background(), time.Now(), 100)
+	require.NoError(t, err)
+	var claimed *storages.ScheduleRun
+	for i := range runs {
+		if runs[i].ScheduleID == schedule.ID {
+			claimed = &runs[i]
+		}
+	}
+	if assert.NotNil(t, claimed) {
+		assert.True(t, claimed.ScheduledFor.Equal(start))
+		claimed.Status = storages.RunFailed
+		claimed.Error = "insufficient funds"
+		assert.NoError(t, storage.FinishScheduleRun(context.Background(), *claimed))
+	}
+	schedule, err = storage.GetSchedule(context.Background(), userID, schedule.ID)
+	require.NoError(t, err)
+	assert.True(t, schedule.NextRunAt.Equal(start.AddDate(0, 0, 1)))
+	assert.Equal(t, "insufficient funds", schedule.LastError)
+	// Завершённое срабатывание не считается прерванным
+	_, err = storage.FailStaleScheduleRuns(context.Background(), time.Now().Add(time.Minute))
+	require.NoError(t, err)
+	schedule, err = storage.GetSchedule(context.Background(), userID, schedule.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "insufficient funds", schedule.LastError)
+
+	schedule, err = storage.SetScheduleStatus(context.Background(), userID, schedule.ID, storages.SchedulePaused, time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, storages.SchedulePaused, schedule.Status)
+	runList, err := storage.ListScheduleRuns(context.Background(), userID, schedule.ID, 10)
+	require.NoError(t, err)
+	assert.Len(t, runList, 1)
+	assert.NoError(t, storage.DeleteSchedule(context.Background(), userID, schedule.ID))
+	assert.ErrorIs(t, storage.DeleteSchedule(context.Background(), userID, schedule.ID), storages.ErrScheduleNotFound)
+
+	// Журнал аудита: изменение баланса записано операцией, записи запечатываются и не меняются
+	audits, err := storage.ListAudit(context.Background(), storages.AuditFilter{UserID: userID, Action: "balance.transfer", Limit: 10})
+	require.NoError(t, err)
+	assert.NotEmpty(t, audits)
+	_, err = storage.SealAudit(context.Background(), 1000)
+	require.NoError(t, err)
+	chain, err := storage.AuditChain(context.Background(), 0, 1)
+	require.NoError(t, err)
+	if assert.Len(t, chain, 1) {
+		assert.Equal(t, chain[0].ComputeHash(), chain[0].Hash)
+	}
+	_, err = storage.Client.Exec(context.Background(), "UPDATE audit_log SET target = 'x' WHERE user_id = $1", userID)
+	assert.Error(t, err)
+	_, err = storage.Client.Exec(context.Background(), "DELETE FROM audit_log WHERE user_id = $1", userID)
+	assert.Error(t, err)
+
+	// Комиссия обмена: правило пользователя перекрывает тарифное, комиссия проводится на счёт fees
+	_, err = storage.Client.Exec(context.Background(),
+		"INSERT INTO exchange_fees (user_id, from_currency, spread_percent, min_fee) VALUES ($1, 'USD', 1, 0.5)", userID)
+	require.NoError(t, err)
+	rule, err := storage.GetFeeRule(context.Background(), userID, "USD", "RUB")
+	require.NoError(t, err)
+	assert.Equal(t, userID, rule.UserID)
+	assert.Equal(t, "0.50", rule.MinFee.String())
+	revenueBefore, err := storage.GetFeeRevenue(context.Background(), time.Now().Add(-time.Minute), time.Time{})
+	require.NoError(t, err)
+	feeTxn, err := storage.PostTransaction(context.Background(), userID, storages.OperationExchange,
+		storages.Posting{Currency: "USD", Amount: money.New(-9, 0)},
+		storages.Posting{Currency: "USD", Amount: money.New(-1, 0), Account: storages.AccountFees},
+		storages.Posting{Currency: "RUB", Amount: money.New(810, 0)},
+	)
+	require.NoError(t, err)
+	if assert.Len(t, feeTxn.Entries, 6) {
+		assert.Equal(t, storages.AccountFees, feeTxn.Entries[3].Account)
+		assert.Equal(t, storages.Credit, feeTxn.Entries[3].Direction)
+	}
+	revenueAfter, err := storage.GetFeeRevenue(context.Background(), time.Now().Add(-time.Minute), time.Time{})
+	require.NoError(t, err)
+	feeTotal := func(revenue []storages.FeeRevenue) money.Decimal {
+		for _, r := range revenue {
+			if r.Currency == "USD" {
+				return r.Amount
+			}
+		}
+		return money.Decimal{}
+	}
+	earned, err := feeTotal(revenueAfter).Sub(feeTotal(revenueBefore))
+	require.NoError(t, err)
+	assert.Equal(t, "1.00", earned.String())
+
+	// По котировке проводится не больше одного обмена
+	quote, err := storage.CreateQuote(context.Background(), storages.Quote{
+		UserID: userID, FromCurrency: "USD", ToCurrency: "RUB", Amount: money.New(1, 0),
+		Received: money.New(90, 0), Rate: money.New(90, 0), ExpiresAt: time.Now().Add(time.Minute),
+	})
+	require.NoError(t, err)
+	quotePostings := []storages.Posting{
+		{Currency: "USD", Amount: money.New(-1, 0)},
+		{Currency: "RUB", Amount: money.New(90, 0)},
+	}
+	quoteCharge := storages.LimitCharge{Operation: storages.OperationExchange, Currency: "USD", Amount: money.New(1, 0), BaseAmount: &quote.Amount}
+	quoteTxn, err := storage.ExecuteQuote(context.Background(), quote.ID, userID, quoteCharge, nil, quotePostings...)
+	require.NoError(t, err)
+	_, err = storage.ExecuteQuote(context.Background(), quote.ID, userID, quoteCharge, nil, quotePostings...)
+	assert.ErrorIs(t, err, storages.ErrQuoteUsed)
+	quote, err = storage.GetQuote(context.Background(), userID, quote.ID)
+	require.NoError(t, err)
+	assert.Equal(t, quoteTxn.ID, quote.TransactionID)
+	assert.NotNil(t, quote.UsedAt)
+
+	// Свечи по истории курсов: открытие и закрытие — первый и последний курс часа
+	rateHour := time.Date(2001, 1, 1, 10, 0, 0, 0, time.UTC)
+	err = storage.SaveRates(context.Background(), []storages.RateSample{
+		{FromCurrency: "USD", ToCurrency: "RUB", Rate: money.New(90, 0), Source: storages.RateSourceList, FetchedAt: rateHour.Add(5 * time.Minute)},
+		{FromCurrency: "USD", ToCurrency: "RUB", Rate: money.New(92, 0), Source: storages.RateSourcePair, FetchedAt: rateHour.Add(20 * time.Minute)},
+		{FromCurrency: "USD", ToCurrency: "RUB", Rate: money.New(89, 0), Source: storages.RateSourceList, FetchedAt: rateHour.Add(40 * time.Minute)},
+		{FromCurrency: "USD", ToCurrency: "RUB", Rate: money.New(91, 0), Source: storages.RateSourceList, FetchedAt: rateHour.Add(70 * time.Minute)},
+	})
+	require.NoError(t, err)
+	candles, err := storage.GetRateCandles(context.Background(), "USD", "RUB", rateHour, rateHour.Add(2*time.Hour), time.Hour)
+	require.NoError(t, err)
+	if assert.Len(t, candles, 2) {
+		assert.True(t, candles[0].Start.Equal(rateHour))
+		assert.True(t, candles[0].Open.Equal(money.New(90, 0)))
+		assert.True(t, candles[0].High.Equal(money.New(92, 0)))
+		assert.True(t, candles[0].Low.Equal(money.New(89, 0)))
+		assert.True(t, candles[0].Close.Equal(money.New(89, 0)))
+		assert.Equal(t, int64(3), candles[0].Samples)
+	}
+	_, err = storage.Client.Exec(context.Background(), "DELETE FROM rate_history WHERE fetched_at < $1", rateHour.Add(2*time.Hour))
+	require.NoError(t, err)
 
 	// Очистка данных после теста
+	_, err = storage.Client.Exec(context.Background(), "DELETE FROM transactions WHERE user_id = $1", userID)
+	require.NoError(t, err)
+
 	_, err = storage.Client.Exec(context.Background(), "DELETE FROM balances WHERE user_id = $1", userID)
-	assert.NoError(t, err)
+	require.NoError(t, err)
 
 	_, err = storage.Client.Exec(context.Background(), "DELETE FROM users WHERE id = $1", userID)
-	assert.NoError(t, err)
+	require.NoError(t, err)
+}
+
+type statementRecorder struct {
+	opening map[string]money.Decimal
+	entries []storages.StatementEntry
+}
+
+func (r *statementRecorder) Begin(opening map[string]money.Decimal) error {
+	r.opening = opening
+	return nil
+}
+
+func (r *statementRecorder) Entry(e storages.StatementEntry) error {
+	r.entries = append(r.entries, e)
+	return nil
 }
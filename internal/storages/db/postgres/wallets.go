@@ -0,0 +1,179 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gw-currency-wallet/internal/storages"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// walletColumns и walletAccess выбирают кошельки, которыми пользователь $1 владеет или в которых участвует,
+// вместе с его ролью
+const (
+	walletColumns = "w.id, w.user_id, w.name, w.is_default, w.created_at, CASE WHEN w.user_id = $1 THEN 'owner' ELSE m.role END"
+	walletAccess  = " FROM wallets w LEFT JOIN wallet_members m ON m.wallet_id = w.id AND m.user_id = $1" +
+		" WHERE (w.user_id = $1 OR m.user_id IS NOT NULL)"
+)
+
+// CreateWallet создаёт кошелёк с нулевыми счетами во всех включённых валютах
+func (p *Postgres) CreateWallet(ctx context.Context, userID int64, name string) (storages.Wallet, error) {
+	tx, err := p.Client.Begin(ctx)
+	if err != nil {
+		return storages.Wallet{}, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	wallet, err := scanWallet(tx.QueryRow(ctx,
+		"INSERT INTO wallets (user_id, name) VALUES ($1, $2) RETURNING id, user_id, name, is_default, created_at, 'owner'",
+		userID, name,
+	))
+	if err != nil {
+		if isUniqueViolation(err) {
+			return wallet, fmt.Errorf("%w: %s", storages.ErrWalletExists, name)
+		}
+		if isForeignKeyViolation(err) {
+			return wallet, fmt.Errorf("%w: %d", storages.ErrUserNotFound, userID)
+		}
+		return wallet, fmt.Errorf("failed to create wallet: %w", err)
+	}
+
+	_, err = tx.Exec(ctx,
+		"INSERT INTO balances (user_id, wallet_id, currency, amount) SELECT $1, $2, code, 0 FROM currencies WHERE enabled",
+		userID, wallet.ID,
+	)
+	if err != nil {
+		return wallet, fmt.Errorf("failed to open balances: %w", err)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return wallet, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return wallet, nil
+}
+
+// ListWallets возвращает кошельки пользователя в порядке создания, первым — кошелёк по умолчанию,
+// а за ними общие кошельки, в которых он участник
+func (p *Postgres) ListWallets(ctx context.Context, userID int64) ([]storages.Wallet, error) {
+	rows, err := p.Client.Query(ctx,
+		"SELECT "+walletColumns+walletAccess+" ORDER BY w.user_id <> $1, w.id",
+		userID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list wallets: %w", err)
+	}
+	defer rows.Close()
+
+	var wallets []storages.Wallet
+	for rows.Next() {
+		wallet, err := scanWallet(rows)
+		if err != nil {
+			return nil, err
+		}
+		wallets = append(wallets, wallet)
+	}
+	return wallets, rows.Err()
+}
+
+func (p *Postgres) GetWallet(ctx context.Context, userID, walletID int64) (storages.Wallet, error) {
+	wallet, err := scanWallet(p.Client.QueryRow(ctx,
+		"SELECT "+walletColumns+walletAccess+" AND (w.id = $2 OR $2 = 0 AND w.is_default AND w.user_id = $1)",
+		userID, walletID,
+	))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return wallet, fmt.Errorf("%w: %d", storages.ErrWalletNotFound, walletID)
+		}
+		return wallet, fmt.Errorf("failed to get wallet: %w", err)
+	}
+	return wallet, nil
+}
+
+// GetWalletBalances возвращает балансы кошелька вместе с суммами активных холдов
+func (p *Postgres) GetWalletBalances(ctx context.Context, userID, walletID int64) ([]storages.Balance, error) {
+	wallet, err := p.GetWallet(ctx, userID, walletID)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := p.Client.Query(ctx,
+		"SELECT user_id, wallet_id, currency, amount, held, version FROM balances WHERE wallet_id = $1 ORDER BY currency",
+		wallet.ID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get balances: %w", err)
+	}
+	defer rows.Close()
+
+	var balances []storages.Balance
+	for rows.Next() {
+		var b storages.Balance
+		if err = rows.Scan(&b.UserID, &b.WalletID, &b.Currency, &b.Amount, &b.Held, &b.Version); err != nil {
+			return nil, err
+		}
+		balances = append(balances, b)
+	}
+	return balances, rows.Err()
+}
+
+// walletCurrency — строка баланса: кошелёк и валюта
+type walletCurrency struct {
+	walletID int64
+	currency string
+}
+
+// resolveWallets подставляет кошелёк по умолчанию в проводки без WalletID и проверяет,
+// что остальные кошельки принадлежат пользователю
+func resolveWallets(ctx context.Context, tx pgx.Tx, userID int64, postings []storages.Posting) ([]storages.Posting, error) {
+	ids := make([]int64, 0, len(postings))
+	for _, posting := range postings {
+		if posting.WalletID != 0 {
+			ids = append(ids, posting.WalletID)
+		}
+	}
+
+	rows, err := tx.Query(ctx,
+		"SELECT id, is_default FROM wallets WHERE user_id = $1 AND (is_default OR id = ANY($2))",
+		userID, ids,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get wallets: %w", err)
+	}
+	defer rows.Close()
+
+	owned := make(map[int64]struct{})
+	var defaultID int64
+	for rows.Next() {
+		var id int64
+		var isDefault bool
+		if err = rows.Scan(&id, &isDefault); err != nil {
+			return nil, err
+		}
+		owned[id] = struct{}{}
+		if isDefault {
+			defaultID = id
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	resolved := make([]storages.Posting, len(postings))
+	for i, posting := range postings {
+		if posting.WalletID == 0 {
+			posting.WalletID = defaultID
+		}
+		if _, ok := owned[posting.WalletID]; !ok {
+			return nil, fmt.Errorf("%w: user %d, wallet %d", storages.ErrWalletNotFound, userID, posting.WalletID)
+		}
+		resolved[i] = posting
+	}
+	return resolved, nil
+}
+
+func scanWallet(row pgx.Row) (storages.Wallet, error) {
+	var w storages.Wallet
+	err := row.Scan(&w.ID, &w.UserID, &w.Name, &w.Default, &w.CreatedAt, &w.Role)
+	return w, err
+}
@@ -0,0 +1,38 @@
+package storages
+
+import "errors"
+
+var (
+	ErrInsufficientFunds   = errors.New("insufficient funds")
+	ErrBalanceNotFound     = errors.New("balance not found")
+	ErrTransactionNotFound = errors.New("transaction not found")
+	ErrCurrencyNotFound    = errors.New("currency not found")
+	ErrUserNotFound        = errors.New("user not found")
+	ErrSelfTransfer        = errors.New("cannot transfer to yourself")
+	ErrHoldNotFound        = errors.New("hold not found")
+	ErrHoldNotActive       = errors.New("hold is not active")
+	ErrHoldExpired         = errors.New("hold has expired")
+	ErrCaptureExceedsHold  = errors.New("capture amount exceeds hold")
+	ErrLimitExceeded       = errors.New("limit exceeded")
+	ErrOrderNotFound       = errors.New("order not found")
+	ErrOrderNotOpen        = errors.New("order is not open")
+	ErrRateBelowLimit      = errors.New("rate is below order limit")
+	ErrScheduleNotFound    = errors.New("schedule not found")
+	ErrScheduleCompleted   = errors.New("schedule is completed")
+	ErrRunInterrupted      = errors.New("scheduled run was interrupted before its result was recorded")
+	ErrAccountFrozen       = errors.New("account is frozen")
+	ErrVersionMismatch     = errors.New("balance version mismatch")
+	ErrWalletNotFound      = errors.New("wallet not found")
+	ErrWalletExists        = errors.New("wallet with this name already exists")
+	ErrMemberExists        = errors.New("user is already a member or invited")
+	ErrMemberNotFound      = errors.New("wallet member not found")
+	ErrInvitationNotFound  = errors.New("invitation not found")
+	ErrInvitationNotOpen   = errors.New("invitation is not pending")
+	ErrApprovalRuleMissing = errors.New("approval rule not found")
+	ErrApprovalNotFound    = errors.New("approval not found")
+	ErrApprovalNotPending  = errors.New("approval is not pending")
+	ErrSelfApproval        = errors.New("approval must come from another member")
+	ErrQuoteNotFound       = errors.New("quote not found")
+	ErrQuoteExpired        = errors.New("quote has expired")
+	ErrQuoteUsed           = errors.New("quote has already been used")
+)
@@ -0,0 +1,110 @@
+package memory
+
+import (
+	"context"
+	"gw-currency-wallet/internal/storages"
+	"time"
+)
+
+func (m *Memory) GetUser(ctx context.Context, userID int64) (storages.User, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	user, ok := m.users[userID]
+	if !ok {
+		return storages.User{}, storages.ErrUserNotFound
+	}
+	return user, nil
+}
+
+// SetUserAdmin выдаёт или отзывает доступ к административному API
+func (m *Memory) SetUserAdmin(userID int64, admin bool) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	user, ok := m.users[userID]
+	if !ok {
+		return storages.ErrUserNotFound
+	}
+	user.Admin = admin
+	m.users[userID] = user
+	return nil
+}
+
+// appendAudit добавляет незапечатанную запись. Вызывается под m.mu
+func (m *Memory) appendAudit(ctx context.Context, entry storages.AuditEntry) {
+	entry = entry.WithMeta(ctx)
+	entry.ID = int64(len(m.audit)) + 1
+	entry.CreatedAt = time.Now()
+	entry.Seq, entry.PrevHash, entry.Hash = 0, "", ""
+	m.audit = append(m.audit, entry)
+}
+
+func (m *Memory) AppendAudit(ctx context.Context, entry storages.AuditEntry) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.appendAudit(ctx, entry)
+	return nil
+}
+
+// ListAudit возвращает записи журнала, новые первыми
+func (m *Memory) ListAudit(ctx context.Context, filter storages.AuditFilter) ([]storages.AuditEntry, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var entries []storages.AuditEntry
+	for i := len(m.audit) - 1; i >= 0 && len(entries) < filter.Limit; i-- {
+		e := m.audit[i]
+		if filter.UserID != 0 && e.UserID != filter.UserID && e.ActorID != filter.UserID ||
+			filter.Action != "" && e.Action != filter.Action ||
+			!filter.From.IsZero() && e.CreatedAt.Before(filter.From) ||
+			!filter.To.IsZero() && !e.CreatedAt.Before(filter.To) ||
+			filter.BeforeID != 0 && e.ID >= filter.BeforeID {
+			continue
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+// SealAudit включает до limit незапечатанных записей в цепочку в порядке id
+func (m *Memory) SealAudit(ctx context.Context, limit int) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var head string
+	sealed := 0
+	for i := range m.audit {
+		e := &m.audit[i]
+		if e.Seq != 0 {
+			head = e.Hash
+			continue
+		}
+		if sealed == limit {
+			break
+		}
+		e.Seq = int64(i) + 1
+		e.PrevHash = head
+		e.Hash = e.ComputeHash()
+		head = e.Hash
+		sealed++
+	}
+	return sealed, nil
+}
+
+func (m *Memory) AuditChain(ctx context.Context, afterSeq int64, limit int) ([]storages.AuditEntry, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var entries []storages.AuditEntry
+	for _, e := range m.audit {
+		if len(entries) == limit {
+			break
+		}
+		if e.Seq > afterSeq {
+			entries = append(entries, e)
+		}
+	}
+	return entries, nil
+}
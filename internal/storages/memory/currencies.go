@@ -0,0 +1,52 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"gw-currency-wallet/internal/storages"
+	"sort"
+)
+
+// defaultCurrencies повторяет начальное содержимое таблицы currencies
+var defaultCurrencies = []storages.Currency{
+	{Code: "USD", Name: "US Dollar", MinorUnits: 2, Enabled: true},
+	{Code: "EUR", Name: "Euro", MinorUnits: 2, Enabled: true},
+	{Code: "RUB", Name: "Russian Ruble", MinorUnits: 2, Enabled: true},
+}
+
+// SetCurrency добавляет валюту в справочник или меняет существующую.
+// В PostgreSQL то же делается строкой в таблице currencies
+func (m *Memory) SetCurrency(currency storages.Currency) error {
+	if currency.MinorUnits < 0 || currency.MinorUnits > balanceScale {
+		return fmt.Errorf("unsupported minor units %d for currency %s", currency.MinorUnits, currency.Code)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.currencies[currency.Code] = currency
+	return nil
+}
+
+func (m *Memory) ListCurrencies(ctx context.Context) ([]storages.Currency, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	currencies := make([]storages.Currency, 0, len(m.currencies))
+	for _, c := range m.currencies {
+		currencies = append(currencies, c)
+	}
+	sort.Slice(currencies, func(i, j int) bool { return currencies[i].Code < currencies[j].Code })
+	return currencies, nil
+}
+
+func (m *Memory) GetCurrency(ctx context.Context, code string) (storages.Currency, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	c, ok := m.currencies[code]
+	if !ok {
+		return storages.Currency{}, fmt.Errorf("%w: %s", storages.ErrCurrencyNotFound, code)
+	}
+	return c, nil
+}
@@ -0,0 +1,106 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"gw-currency-wallet/internal/storages"
+	"gw-currency-wallet/pkg/money"
+	"sort"
+	"time"
+)
+
+// SetFeeRule добавляет правило комиссии или заменяет правило с той же областью действия
+// (тариф или пользователь, пара валют). В PostgreSQL то же делается строкой в таблице exchange_fees
+func (m *Memory) SetFeeRule(rule storages.FeeRule) error {
+	if (rule.Tier == "") == (rule.UserID == 0) {
+		return fmt.Errorf("fee must be set for either a tier or a user")
+	}
+	if !rule.MinFee.IsZero() && rule.FromCurrency == "" {
+		return fmt.Errorf("minimum fee requires from currency")
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i, existing := range m.fees {
+		if existing.Tier == rule.Tier && existing.UserID == rule.UserID &&
+			existing.FromCurrency == rule.FromCurrency && existing.ToCurrency == rule.ToCurrency {
+			m.fees[i] = rule
+			return nil
+		}
+	}
+	m.fees = append(m.fees, rule)
+	return nil
+}
+
+// GetFeeRule выбирает правило так же, как PostgreSQL: правило пользователя перекрывает
+// тарифное, правило с точной валютой — правило для любой валюты
+func (m *Memory) GetFeeRule(ctx context.Context, userID int64, fromCurrency, toCurrency string) (storages.FeeRule, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	user, ok := m.users[userID]
+	if !ok {
+		return storages.FeeRule{}, nil
+	}
+
+	var best storages.FeeRule
+	bestRank := -1
+	for _, rule := range m.fees {
+		if rule.UserID != userID && (rule.UserID != 0 || rule.Tier != user.Tier) {
+			continue
+		}
+		if rule.FromCurrency != "" && rule.FromCurrency != fromCurrency || rule.ToCurrency != "" && rule.ToCurrency != toCurrency {
+			continue
+		}
+		rank := 0
+		if rule.UserID != 0 {
+			rank += 4
+		}
+		if rule.FromCurrency != "" {
+			rank += 2
+		}
+		if rule.ToCurrency != "" {
+			rank++
+		}
+		if rank > bestRank {
+			best, bestRank = rule, rank
+		}
+	}
+	return best, nil
+}
+
+func (m *Memory) GetFeeRevenue(ctx context.Context, from, to time.Time) ([]storages.FeeRevenue, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	totals := make(map[string]*storages.FeeRevenue)
+	for _, txn := range m.transactions {
+		if !from.IsZero() && txn.CreatedAt.Before(from) || !to.IsZero() && !txn.CreatedAt.Before(to) {
+			continue
+		}
+		for _, e := range txn.Entries {
+			if e.Account != storages.AccountFees {
+				continue
+			}
+			r, ok := totals[e.Currency]
+			if !ok {
+				r = &storages.FeeRevenue{Currency: e.Currency, Amount: money.New(0, balanceScale)}
+				totals[e.Currency] = r
+			}
+			amount, err := r.Amount.Add(e.Amount)
+			if err != nil {
+				return nil, err
+			}
+			r.Amount = amount
+			r.Exchanges++
+		}
+	}
+
+	revenue := make([]storages.FeeRevenue, 0, len(totals))
+	for _, r := range totals {
+		revenue = append(revenue, *r)
+	}
+	sort.Slice(revenue, func(i, j int) bool { return revenue[i].Currency < revenue[j].Currency })
+	return revenue, nil
+}
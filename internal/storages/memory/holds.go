@@ -0,0 +1,200 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"gw-currency-wallet/internal/storages"
+	"gw-currency-wallet/pkg/money"
+	"time"
+)
+
+// PlaceHold резервирует amount на балансе: held растёт, учётный баланс не меняется
+func (m *Memory) PlaceHold(ctx context.Context, userID int64, currency string, amount money.Decimal, expiresAt time.Time) (storages.Hold, error) {
+	amount, err := amount.Round(balanceScale, money.RoundHalfUp)
+	if err != nil {
+		return storages.Hold{}, err
+	}
+	if amount.Sign() <= 0 {
+		return storages.Hold{}, fmt.Errorf("failed to create hold: amount must be positive")
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err = m.reserveHeld(userID, currency, amount); err != nil {
+		return storages.Hold{}, err
+	}
+
+	now := time.Now()
+	hold := storages.Hold{
+		ID:             int64(len(m.holds)) + 1,
+		UserID:         userID,
+		Currency:       currency,
+		Amount:         amount,
+		CapturedAmount: money.New(0, balanceScale),
+		Status:         storages.HoldActive,
+		ExpiresAt:      expiresAt,
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	}
+	m.holds = append(m.holds, hold)
+	return hold, nil
+}
+
+func (m *Memory) GetHold(ctx context.Context, userID, holdID int64) (storages.Hold, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	hold, ok := m.hold(holdID)
+	if !ok || hold.UserID != userID {
+		return storages.Hold{}, storages.ErrHoldNotFound
+	}
+	return *hold, nil
+}
+
+// CaptureHold списывает amount (не больше суммы холда) через журнал и освобождает остаток.
+// Списание расходует лимит charge
+func (m *Memory) CaptureHold(ctx context.Context, userID, holdID int64, amount money.Decimal, charge storages.LimitCharge) (storages.Hold, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	hold, err := m.activeHold(userID, holdID)
+	if err != nil {
+		return storages.Hold{}, err
+	}
+	amount, err = amount.Round(balanceScale, money.RoundHalfUp)
+	if err != nil {
+		return *hold, err
+	}
+	if amount.Cmp(hold.Amount) > 0 {
+		return *hold, storages.ErrCaptureExceedsHold
+	}
+
+	now := time.Now()
+	if err = m.checkLimits(userID, charge, now); err != nil {
+		return *hold, err
+	}
+
+	// Проверяем списание с уже снятым резервом; при ошибке резерв возвращается
+	key := m.defaultBalance(userID, hold.Currency)
+	heldBefore, versionBefore := m.heldAmount(userID, hold.Currency), m.version(key)
+	if err = m.releaseHeld(hold.UserID, hold.Currency, hold.Amount); err != nil {
+		return *hold, err
+	}
+	p, err := m.prepare(userID, []storages.Posting{{Currency: hold.Currency, Amount: amount.Neg()}})
+	if err != nil {
+		m.held[userID][hold.Currency] = heldBefore
+		m.versions[key] = versionBefore
+		return *hold, err
+	}
+	txn := m.apply(ctx, p, storages.OperationCapture, 0)
+	m.recordLimitUsage(userID, charge, now)
+
+	hold.Status = storages.HoldCaptured
+	hold.CapturedAmount = amount
+	hold.TransactionID = txn.ID
+	hold.UpdatedAt = time.Now()
+	return *hold, nil
+}
+
+// VoidHold отменяет холд и возвращает зарезервированную сумму в доступный баланс
+func (m *Memory) VoidHold(ctx context.Context, userID, holdID int64) (storages.Hold, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	hold, err := m.activeHold(userID, holdID)
+	if err != nil {
+		return storages.Hold{}, err
+	}
+	if err = m.releaseHeld(hold.UserID, hold.Currency, hold.Amount); err != nil {
+		return *hold, err
+	}
+
+	hold.Status = storages.HoldVoided
+	hold.UpdatedAt = time.Now()
+	return *hold, nil
+}
+
+// ExpireHolds переводит просроченные активные холды в expired и освобождает их суммы
+func (m *Memory) ExpireHolds(ctx context.Context, now time.Time) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var expired int
+	for i := range m.holds {
+		hold := &m.holds[i]
+		if hold.Status != storages.HoldActive || hold.ExpiresAt.After(now) {
+			continue
+		}
+		if err := m.releaseHeld(hold.UserID, hold.Currency, hold.Amount); err != nil {
+			return expired, err
+		}
+		hold.Status = storages.HoldExpired
+		hold.UpdatedAt = time.Now()
+		expired++
+	}
+	return expired, nil
+}
+
+// activeHold возвращает холд пользователя, который ещё можно закрыть. Вызывается под m.mu
+func (m *Memory) activeHold(userID, holdID int64) (*storages.Hold, error) {
+	hold, ok := m.hold(holdID)
+	if !ok || hold.UserID != userID {
+		return nil, storages.ErrHoldNotFound
+	}
+	if hold.Status != storages.HoldActive {
+		return nil, fmt.Errorf("%w: %s", storages.ErrHoldNotActive, hold.Status)
+	}
+	if !hold.ExpiresAt.After(time.Now()) {
+		return nil, storages.ErrHoldExpired
+	}
+	return hold, nil
+}
+
+func (m *Memory) hold(id int64) (*storages.Hold, bool) {
+	if id < 1 || id > int64(len(m.holds)) {
+		return nil, false
+	}
+	return &m.holds[id-1], true
+}
+
+// reserveHeld переносит amount из доступного баланса кошелька по умолчанию в held.
+// Общий механизм холдов и заявок. Вызывается под m.mu
+func (m *Memory) reserveHeld(userID int64, currency string, amount money.Decimal) error {
+	key := m.defaultBalance(userID, currency)
+	if err := m.checkFrozen(key); err != nil {
+		return err
+	}
+	balance, ok := m.balances[key.walletID][currency]
+	if !ok {
+		return fmt.Errorf("%w: user %d, currency %s", storages.ErrBalanceNotFound, userID, currency)
+	}
+	held, err := m.heldAmount(userID, currency).Add(amount)
+	if err != nil {
+		return err
+	}
+	if held.Cmp(balance) > 0 {
+		return storages.ErrInsufficientFunds
+	}
+	m.held[userID][currency] = held
+	m.bumpVersion(m.defaultBalance(userID, currency))
+	return nil
+}
+
+func (m *Memory) releaseHeld(userID int64, currency string, amount money.Decimal) error {
+	held, err := m.heldAmount(userID, currency).Sub(amount)
+	if err != nil {
+		return err
+	}
+	m.held[userID][currency] = held
+	m.bumpVersion(m.defaultBalance(userID, currency))
+	return nil
+}
+
+// heldAmount — сумма активных холдов пользователя в валюте. Вызывается под m.mu
+func (m *Memory) heldAmount(userID int64, currency string) money.Decimal {
+	if held, ok := m.held[userID][currency]; ok {
+		return held
+	}
+	return money.New(0, balanceScale)
+}
@@ -0,0 +1,59 @@
+package memory
+
+import (
+	"context"
+	"gw-currency-wallet/internal/storages"
+	"time"
+)
+
+type idempotencyKey struct {
+	userID int64
+	key    string
+}
+
+// ReserveIdempotencyKey занимает ключ за запросом до now+lease. Если ключ занят запросом
+// с ответом или с неистёкшим захватом, возвращает существующую запись и false
+func (m *Memory) ReserveIdempotencyKey(ctx context.Context, userID int64, key, fingerprint string, now time.Time, lease time.Duration) (storages.IdempotencyRecord, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	id := idempotencyKey{userID: userID, key: key}
+	if record, ok := m.idempotency[id]; ok && (record.StatusCode != 0 || now.Before(record.LockedUntil)) {
+		return record, false, nil
+	}
+
+	record := storages.IdempotencyRecord{
+		UserID:      userID,
+		Key:         key,
+		Fingerprint: fingerprint,
+		LockedUntil: now.Add(lease),
+		CreatedAt:   time.Now(),
+	}
+	m.idempotency[id] = record
+	return record, true, nil
+}
+
+func (m *Memory) SaveIdempotentResponse(ctx context.Context, userID int64, key string, statusCode int, response []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	id := idempotencyKey{userID: userID, key: key}
+	if record, ok := m.idempotency[id]; ok {
+		record.StatusCode = statusCode
+		record.Response = append([]byte(nil), response...)
+		m.idempotency[id] = record
+	}
+	return nil
+}
+
+// ReleaseIdempotencyKey освобождает незавершённый ключ, чтобы запрос можно было повторить
+func (m *Memory) ReleaseIdempotencyKey(ctx context.Context, userID int64, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	id := idempotencyKey{userID: userID, key: key}
+	if record, ok := m.idempotency[id]; ok && record.StatusCode == 0 {
+		delete(m.idempotency, id)
+	}
+	return nil
+}
@@ -0,0 +1,415 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"gw-currency-wallet/internal/storages"
+	"gw-currency-wallet/pkg/money"
+	"sort"
+	"time"
+)
+
+// balanceLimit — первое значение, не помещающееся в DECIMAL(15,2)
+var balanceLimit = money.New(10_000_000_000_000, 0)
+
+// PostTransaction атомарно меняет балансы и записывает операцию в журнал.
+// Все проверки выполняются до первого изменения, поэтому ошибка не оставляет следов
+func (m *Memory) PostTransaction(ctx context.Context, userID int64, opType storages.OperationType, postings ...storages.Posting) (storages.Transaction, error) {
+	if len(postings) == 0 {
+		return storages.Transaction{UserID: userID, Type: opType}, fmt.Errorf("transaction has no postings")
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	p, err := m.prepare(userID, postings)
+	if err != nil {
+		return storages.Transaction{UserID: userID, Type: opType}, err
+	}
+	return cloneTransaction(m.apply(ctx, p, opType, 0)), nil
+}
+
+// pendingPostings — проверенные проводки одного пользователя, готовые к применению
+type pendingPostings struct {
+	userID   int64
+	postings []storages.Posting // суммы округлены до масштаба баланса, кошельки разрешены
+	balances map[walletCurrency]money.Decimal
+}
+
+// prepare считает новые балансы на копии, ничего не меняя. Вызывается под m.mu
+func (m *Memory) prepare(userID int64, postings []storages.Posting) (pendingPostings, error) {
+	p := pendingPostings{
+		userID:   userID,
+		postings: make([]storages.Posting, len(postings)),
+		balances: make(map[walletCurrency]money.Decimal, len(postings)),
+	}
+	for i, posting := range postings {
+		wallet, err := m.wallet(userID, posting.WalletID)
+		if err != nil {
+			return p, err
+		}
+		key := walletCurrency{walletID: wallet.ID, currency: posting.Currency}
+		if err = m.checkFrozen(key); err != nil {
+			return p, err
+		}
+		if posting.IfVersion != 0 {
+			if version := m.version(key); version != posting.IfVersion {
+				return p, fmt.Errorf("%w: %s is at version %d, expected %d", storages.ErrVersionMismatch, posting.Currency, version, posting.IfVersion)
+			}
+		}
+		current, ok := p.balances[key]
+		if !ok {
+			if current, ok = m.balances[wallet.ID][posting.Currency]; !ok {
+				// Счёт во включённой валюте, добавленной после открытия кошелька, открывается при первой операции
+				if !m.currencies[posting.Currency].Enabled {
+					return p, fmt.Errorf("%w: user %d, currency %s", storages.ErrBalanceNotFound, userID, posting.Currency)
+				}
+				current = money.New(0, balanceScale)
+			}
+		}
+
+		// Лишние знаки не округляются, а отклоняются, как в PostgreSQL
+		amount, err := posting.Amount.Rescale(balanceScale)
+		if err != nil {
+			return p, err
+		}
+		if amount.IsZero() {
+			return p, fmt.Errorf("failed to write ledger entry: zero amount")
+		}
+
+		next, err := current.Add(amount)
+		if err != nil {
+			return p, err
+		}
+		// Зарезервированное холдами списать нельзя; холды есть только в кошельке по умолчанию
+		available := next
+		if wallet.Default {
+			if available, err = next.Sub(m.heldAmount(userID, posting.Currency)); err != nil {
+				return p, err
+			}
+		}
+		if available.Sign() < 0 {
+			return p, storages.ErrInsufficientFunds
+		}
+		if next.Cmp(balanceLimit) >= 0 {
+			return p, money.ErrOverflow
+		}
+		p.balances[key] = next
+		p.postings[i] = storages.Posting{WalletID: wallet.ID, Currency: posting.Currency, Amount: amount, Account: posting.Account}
+	}
+	return p, nil
+}
+
+// apply записывает подготовленные проводки в балансы, журнал и аудит. Вызывается под m.mu
+func (m *Memory) apply(ctx context.Context, p pendingPostings, opType storages.OperationType, counterpartyID int64) storages.Transaction {
+	var before, after []storages.AuditBalance
+	seen := make(map[walletCurrency]bool, len(p.postings))
+	for _, posting := range p.postings {
+		key := walletCurrency{walletID: posting.WalletID, currency: posting.Currency}
+		if !seen[key] {
+			seen[key] = true
+			current, ok := m.balances[key.walletID][key.currency]
+			if !ok {
+				current = money.New(0, balanceScale)
+			}
+			before = append(before, storages.AuditBalance{WalletID: key.walletID, Currency: key.currency, Amount: current})
+			after = append(after, storages.AuditBalance{WalletID: key.walletID, Currency: key.currency, Amount: p.balances[key]})
+		}
+	}
+
+	for key, amount := range p.balances {
+		m.balances[key.walletID][key.currency] = amount
+		m.bumpVersion(key)
+	}
+
+	txn := storages.Transaction{
+		ID:             int64(len(m.transactions)) + 1,
+		UserID:         p.userID,
+		Type:           opType,
+		CounterpartyID: counterpartyID,
+		CreatedAt:      time.Now(),
+	}
+
+	for _, posting := range p.postings {
+		walletSide, contraSide := storages.Credit, storages.Debit
+		if posting.Amount.Sign() < 0 {
+			walletSide, contraSide = storages.Debit, storages.Credit
+		}
+
+		for _, leg := range []struct {
+			account   string
+			walletID  int64
+			direction storages.EntryDirection
+		}{
+			{storages.AccountWallet, posting.WalletID, walletSide},
+			{posting.Contra(opType), 0, contraSide},
+		} {
+			m.nextEntryID++
+			txn.Entries = append(txn.Entries, storages.Entry{
+				ID:            m.nextEntryID,
+				TransactionID: txn.ID,
+				Account:       leg.account,
+				UserID:        p.userID,
+				WalletID:      leg.walletID,
+				Currency:      posting.Currency,
+				Direction:     leg.direction,
+				Amount:        posting.Amount.Abs(),
+				CreatedAt:     txn.CreatedAt,
+			})
+		}
+	}
+
+	m.transactions = append(m.transactions, txn)
+	m.appendAudit(ctx, storages.NewBalanceAudit(txn, before, after))
+	return txn
+}
+
+func (m *Memory) Credit(ctx context.Context, userID int64, currency string, amount money.Decimal) (storages.Transaction, error) {
+	return m.PostTransaction(ctx, userID, storages.OperationDeposit, storages.Posting{Currency: currency, Amount: amount})
+}
+
+func (m *Memory) Debit(ctx context.Context, userID int64, currency string, amount money.Decimal) (storages.Transaction, error) {
+	return m.PostTransaction(ctx, userID, storages.OperationWithdraw, storages.Posting{Currency: currency, Amount: amount.Neg()})
+}
+
+// Transfer переводит amount от fromUserID к toUserID двумя связанными операциями
+// и записывает events в outbox. Перевод расходует лимит отправителя charge.
+// Возвращает операцию отправителя
+func (m *Memory) Transfer(ctx context.Context, fromUserID, toUserID int64, currency string, amount money.Decimal, charge storages.LimitCharge, events ...storages.OutboxEvent) (storages.Transaction, error) {
+	if fromUserID == toUserID {
+		return storages.Transaction{}, storages.ErrSelfTransfer
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.users[toUserID]; !ok {
+		return storages.Transaction{}, fmt.Errorf("%w: recipient %d", storages.ErrUserNotFound, toUserID)
+	}
+
+	now := time.Now()
+	if err := m.checkLimits(fromUserID, charge, now); err != nil {
+		return storages.Transaction{}, err
+	}
+
+	debit, err := m.prepare(fromUserID, []storages.Posting{{Currency: currency, Amount: amount.Neg()}})
+	if err != nil {
+		return storages.Transaction{}, err
+	}
+	credit, err := m.prepare(toUserID, []storages.Posting{{Currency: currency, Amount: amount}})
+	if err != nil {
+		return storages.Transaction{}, err
+	}
+	encoded, err := m.encodeOutbox(events)
+	if err != nil {
+		return storages.Transaction{}, err
+	}
+
+	txn := m.apply(ctx, debit, storages.OperationTransfer, toUserID)
+	m.apply(ctx, credit, storages.OperationTransfer, fromUserID)
+	m.appendOutbox(encoded)
+	m.recordLimitUsage(fromUserID, charge, now)
+	return cloneTransaction(txn), nil
+}
+
+func (m *Memory) ExecuteExchange(ctx context.Context, userID int64, fromCurrency, toCurrency string, amount, received money.Decimal) (storages.Transaction, error) {
+	return m.PostTransaction(ctx, userID, storages.OperationExchange,
+		storages.Posting{Currency: fromCurrency, Amount: amount.Neg()},
+		storages.Posting{Currency: toCurrency, Amount: received},
+	)
+}
+
+func (m *Memory) GetTransactionEntries(ctx context.Context, transactionID int64) ([]storages.Entry, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	txn, ok := m.transaction(transactionID)
+	if !ok {
+		return nil, nil
+	}
+	return cloneTransaction(txn).Entries, nil
+}
+
+// GetLedgerBalance считает баланс кошелька по журналу — для сверки с балансами
+func (m *Memory) GetLedgerBalance(ctx context.Context, userID int64, currency string) (money.Decimal, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	total := money.New(0, balanceScale)
+	for _, txn := range m.transactions {
+		for _, e := range txn.Entries {
+			if e.UserID != userID || e.Currency != currency || e.Account != storages.AccountWallet {
+				continue
+			}
+			amount := e.Amount
+			if e.Direction == storages.Debit {
+				amount = amount.Neg()
+			}
+			var err error
+			if total, err = total.Add(amount); err != nil {
+				return money.Decimal{}, err
+			}
+		}
+	}
+	return total, nil
+}
+
+// ListTransactions возвращает операции пользователя от новых к старым
+func (m *Memory) ListTransactions(ctx context.Context, userID int64, filter storages.TransactionFilter) ([]storages.Transaction, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if filter.WalletID != 0 {
+		if _, err := m.accessibleWallet(userID, filter.WalletID); err != nil {
+			return nil, err
+		}
+	}
+
+	var txns []storages.Transaction
+	for i := len(m.transactions) - 1; i >= 0 && len(txns) < filter.Limit; i-- {
+		txn := m.transactions[i]
+		if filter.WalletID == 0 && txn.UserID != userID ||
+			filter.BeforeID != 0 && txn.ID >= filter.BeforeID ||
+			filter.Type != "" && txn.Type != filter.Type ||
+			!filter.From.IsZero() && txn.CreatedAt.Before(filter.From) ||
+			!filter.To.IsZero() && !txn.CreatedAt.Before(filter.To) {
+			continue
+		}
+
+		// В списке показываем только движения по кошельку пользователя, а в выборке по кошельку — по нему
+		view := txn
+		view.Entries = nil
+		matchesCurrency := filter.Currency == ""
+		for _, e := range txn.Entries {
+			if e.Account != storages.AccountWallet || filter.WalletID != 0 && e.WalletID != filter.WalletID {
+				continue
+			}
+			view.Entries = append(view.Entries, e)
+			if e.Currency == filter.Currency {
+				matchesCurrency = true
+			}
+		}
+		if matchesCurrency && (filter.WalletID == 0 || len(view.Entries) > 0) {
+			txns = append(txns, view)
+		}
+	}
+	return txns, nil
+}
+
+// GetTransaction возвращает операцию пользователя со всеми проводками. Операцию по кошельку,
+// доступному пользователю, видно с проводками только по доступным кошелькам
+func (m *Memory) GetTransaction(ctx context.Context, userID, transactionID int64) (storages.Transaction, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	txn, ok := m.transaction(transactionID)
+	if !ok {
+		return storages.Transaction{}, storages.ErrTransactionNotFound
+	}
+	if txn.UserID == userID {
+		return cloneTransaction(txn), nil
+	}
+
+	view := txn
+	view.Entries = nil
+	for _, e := range txn.Entries {
+		if e.Account != storages.AccountWallet {
+			continue
+		}
+		if _, err := m.accessibleWallet(userID, e.WalletID); err == nil {
+			view.Entries = append(view.Entries, e)
+		}
+	}
+	if len(view.Entries) == 0 {
+		return storages.Transaction{}, storages.ErrTransactionNotFound
+	}
+	return view, nil
+}
+
+func (m *Memory) transaction(id int64) (storages.Transaction, bool) {
+	if id < 1 || id > int64(len(m.transactions)) {
+		return storages.Transaction{}, false
+	}
+	return m.transactions[id-1], true
+}
+
+// cloneTransaction отдаёт копию, чтобы вызывающий не мог изменить журнал
+func cloneTransaction(txn storages.Transaction) storages.Transaction {
+	txn.Entries = append([]storages.Entry(nil), txn.Entries...)
+	return txn
+}
+
+// StreamStatement собирает выписку под блокировкой на копии и отдаёт её в sink уже без
+// блокировки, чтобы медленный получатель не задерживал операции
+func (m *Memory) StreamStatement(ctx context.Context, userID int64, filter storages.StatementFilter, sink storages.StatementSink) error {
+	opening, entries, err := m.statement(userID, filter)
+	if err != nil {
+		return err
+	}
+
+	if err = sink.Begin(opening); err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if err = sink.Entry(e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *Memory) statement(userID int64, filter storages.StatementFilter) (map[string]money.Decimal, []storages.StatementEntry, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	opening := make(map[string]money.Decimal)
+	if filter.Currency != "" {
+		opening[filter.Currency] = money.New(0, balanceScale)
+	}
+	var entries []storages.StatementEntry
+	for _, txn := range m.transactions {
+		if !filter.To.IsZero() && !txn.CreatedAt.Before(filter.To) {
+			continue
+		}
+		for _, e := range txn.Entries {
+			if e.UserID != userID || e.Account != storages.AccountWallet ||
+				filter.Currency != "" && e.Currency != filter.Currency {
+				continue
+			}
+			amount := e.Amount
+			if e.Direction == storages.Debit {
+				amount = amount.Neg()
+			}
+
+			balance, ok := opening[e.Currency]
+			if !ok {
+				balance = money.New(0, balanceScale)
+			}
+			if !filter.From.IsZero() && txn.CreatedAt.Before(filter.From) {
+				var err error
+				if balance, err = balance.Add(amount); err != nil {
+					return nil, nil, err
+				}
+			} else {
+				entries = append(entries, storages.StatementEntry{
+					EntryID:        e.ID,
+					TransactionID:  txn.ID,
+					Type:           txn.Type,
+					CounterpartyID: txn.CounterpartyID,
+					Currency:       e.Currency,
+					Amount:         amount,
+					CreatedAt:      txn.CreatedAt,
+				})
+			}
+			opening[e.Currency] = balance
+		}
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		if entries[i].Currency != entries[j].Currency {
+			return entries[i].Currency < entries[j].Currency
+		}
+		return entries[i].EntryID < entries[j].EntryID
+	})
+	return opening, entries, nil
+}
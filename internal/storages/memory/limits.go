@@ -0,0 +1,227 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"gw-currency-wallet/internal/storages"
+	"gw-currency-wallet/pkg/money"
+	"sort"
+	"time"
+)
+
+type limitUsageKey struct {
+	userID    int64
+	operation storages.OperationType
+	currency  string
+	day       time.Time
+}
+
+type limitUsage struct {
+	amount     money.Decimal
+	baseAmount money.Decimal
+}
+
+// defaultLimits повторяет начальное содержимое таблицы limits: тарифные лимиты задаются
+// в валюте операции, в рублях — в сто раз больше
+func defaultLimits() []storages.LimitRule {
+	limit := func(v int64) *money.Decimal {
+		d := money.New(v, 0)
+		return &d
+	}
+	var rules []storages.LimitRule
+	for _, currency := range []string{"USD", "EUR", "RUB"} {
+		scale := int64(1)
+		if currency == "RUB" {
+			scale = 100
+		}
+		rules = append(rules,
+			storages.LimitRule{Tier: storages.DefaultTier, Operation: storages.OperationDeposit, Currency: currency,
+				PerTransaction: limit(1000000 * scale), Daily: limit(1000000 * scale), Monthly: limit(5000000 * scale)},
+			storages.LimitRule{Tier: storages.DefaultTier, Operation: storages.OperationWithdraw, Currency: currency,
+				PerTransaction: limit(100000 * scale), Daily: limit(200000 * scale), Monthly: limit(1000000 * scale)},
+			storages.LimitRule{Tier: storages.DefaultTier, Operation: storages.OperationExchange, Currency: currency,
+				PerTransaction: limit(500000 * scale), Daily: limit(1000000 * scale), Monthly: limit(5000000 * scale)},
+		)
+	}
+	return rules
+}
+
+// SetLimit добавляет правило лимита или заменяет правило с той же областью действия
+// (тариф или пользователь, операция, валюта). В PostgreSQL то же делается строкой в таблице limits
+func (m *Memory) SetLimit(rule storages.LimitRule) error {
+	if (rule.Tier == "") == (rule.UserID == 0) {
+		return fmt.Errorf("limit must be set for either a tier or a user")
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i, existing := range m.limits {
+		if existing.Tier == rule.Tier && existing.UserID == rule.UserID &&
+			existing.Operation == rule.Operation && existing.Currency == rule.Currency {
+			m.limits[i] = rule
+			return nil
+		}
+	}
+	m.limits = append(m.limits, rule)
+	return nil
+}
+
+// SetUserTier переводит пользователя на другой тариф
+func (m *Memory) SetUserTier(userID int64, tier string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	user, ok := m.users[userID]
+	if !ok {
+		return storages.ErrUserNotFound
+	}
+	user.Tier = tier
+	m.users[userID] = user
+	return nil
+}
+
+// PostWithinLimits проверяет лимиты, проводит операцию, учитывает её сумму и записывает events
+// в outbox под одной блокировкой
+func (m *Memory) PostWithinLimits(ctx context.Context, userID int64, opType storages.OperationType, charge storages.LimitCharge, events []storages.OutboxEvent, postings ...storages.Posting) (storages.Transaction, error) {
+	if len(postings) == 0 {
+		return storages.Transaction{UserID: userID, Type: opType}, fmt.Errorf("transaction has no postings")
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.postWithinLimits(ctx, userID, opType, charge, events, postings)
+}
+
+// postWithinLimits — PostWithinLimits под уже взятой блокировкой
+func (m *Memory) postWithinLimits(ctx context.Context, userID int64, opType storages.OperationType, charge storages.LimitCharge, events []storages.OutboxEvent, postings []storages.Posting) (storages.Transaction, error) {
+	if _, ok := m.users[userID]; !ok {
+		return storages.Transaction{}, storages.ErrUserNotFound
+	}
+
+	now := time.Now()
+	if err := m.checkLimits(userID, charge, now); err != nil {
+		return storages.Transaction{}, err
+	}
+
+	p, err := m.prepare(userID, postings)
+	if err != nil {
+		return storages.Transaction{UserID: userID, Type: opType}, err
+	}
+	encoded, err := m.encodeOutbox(events)
+	if err != nil {
+		return storages.Transaction{UserID: userID, Type: opType}, err
+	}
+	txn := m.apply(ctx, p, opType, 0)
+	m.appendOutbox(encoded)
+	m.recordLimitUsage(userID, charge, now)
+
+	return cloneTransaction(txn), nil
+}
+
+// checkLimits проверяет charge по действующим правилам пользователя. Вызывается под m.mu
+func (m *Memory) checkLimits(userID int64, charge storages.LimitCharge, now time.Time) error {
+	for _, rule := range m.effectiveLimits(userID, charge.Operation) {
+		amount := charge.Amount
+		switch {
+		case rule.Currency == "" && charge.BaseAmount == nil:
+			return fmt.Errorf("base amount is required for %s limits", rule.Operation)
+		case rule.Currency == "":
+			amount = *charge.BaseAmount
+		case rule.Currency != charge.Currency:
+			continue
+		}
+
+		daily, monthly := m.usage(userID, rule, now)
+		if err := rule.Check(amount, daily, monthly); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// recordLimitUsage учитывает проведённую сумму charge. Вызывается под m.mu
+func (m *Memory) recordLimitUsage(userID int64, charge storages.LimitCharge, now time.Time) {
+	day, _ := storages.LimitPeriods(now)
+	key := limitUsageKey{userID: userID, operation: charge.Operation, currency: charge.Currency, day: day}
+	usage := m.limitUsage[key]
+	usage.amount, _ = usage.amount.Add(charge.Amount)
+	if charge.BaseAmount != nil {
+		usage.baseAmount, _ = usage.baseAmount.Add(*charge.BaseAmount)
+	}
+	m.limitUsage[key] = usage
+}
+
+// GetLimitStatus возвращает действующие правила пользователя с израсходованными суммами
+func (m *Memory) GetLimitStatus(ctx context.Context, userID int64, now time.Time) ([]storages.LimitStatus, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	rules := m.effectiveLimits(userID, "")
+	statuses := make([]storages.LimitStatus, 0, len(rules))
+	for _, rule := range rules {
+		daily, monthly := m.usage(userID, rule, now)
+		statuses = append(statuses, rule.Status(daily, monthly))
+	}
+	return statuses, nil
+}
+
+// effectiveLimits — правила пользователя и его тарифа в порядке операции и валюты.
+// Вызывается под m.mu
+func (m *Memory) effectiveLimits(userID int64, operation storages.OperationType) []storages.LimitRule {
+	type scope struct {
+		operation storages.OperationType
+		currency  string
+	}
+	tier := m.users[userID].Tier
+	chosen := make(map[scope]storages.LimitRule)
+	for _, rule := range m.limits {
+		if operation != "" && rule.Operation != operation {
+			continue
+		}
+		if rule.UserID != userID && (rule.UserID != 0 || rule.Tier != tier) {
+			continue
+		}
+		key := scope{rule.Operation, rule.Currency}
+		if existing, ok := chosen[key]; ok && existing.UserID != 0 {
+			continue
+		}
+		chosen[key] = rule
+	}
+
+	rules := make([]storages.LimitRule, 0, len(chosen))
+	for _, rule := range chosen {
+		rules = append(rules, rule)
+	}
+	sort.Slice(rules, func(i, j int) bool {
+		if rules[i].Operation != rules[j].Operation {
+			return rules[i].Operation < rules[j].Operation
+		}
+		return rules[i].Currency < rules[j].Currency
+	})
+	return rules
+}
+
+// usage — израсходованные по правилу суммы за текущие сутки и месяц. Вызывается под m.mu
+func (m *Memory) usage(userID int64, rule storages.LimitRule, now time.Time) (money.Decimal, money.Decimal) {
+	day, month := storages.LimitPeriods(now)
+	daily, monthly := money.New(0, balanceScale), money.New(0, balanceScale)
+	for key, usage := range m.limitUsage {
+		if key.userID != userID || key.operation != rule.Operation || key.day.Before(month) {
+			continue
+		}
+		amount := usage.baseAmount
+		if rule.Currency != "" {
+			if key.currency != rule.Currency {
+				continue
+			}
+			amount = usage.amount
+		}
+		monthly, _ = monthly.Add(amount)
+		if !key.day.Before(day) {
+			daily, _ = daily.Add(amount)
+		}
+	}
+	return daily, monthly
+}
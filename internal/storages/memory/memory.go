@@ -0,0 +1,163 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"gw-currency-wallet/internal/storages"
+	"gw-currency-wallet/pkg/money"
+	"sync"
+)
+
+// balanceScale — масштаб колонки DECIMAL(15,2), который повторяет хранилище в памяти
+const balanceScale = 2
+
+// Memory — потокобезопасная реализация storages.Repository в памяти процесса.
+// Семантика совпадает с PostgreSQL: уникальный email, неотрицательные балансы,
+// атомарные операции журнала. Данные теряются при перезапуске
+type Memory struct {
+	mu sync.RWMutex
+
+	users      map[int64]storages.User
+	emails     map[string]int64
+	nextUserID int64
+
+	currencies map[string]storages.Currency
+
+	wallets        []storages.Wallet // wallets[i] — кошелёк с id i+1
+	defaultWallets map[int64]int64   // id кошелька по умолчанию по id пользователя
+
+	members       []storages.WalletMember     // участники общих кошельков, кроме владельцев
+	invitations   []storages.WalletInvitation // invitations[i] — приглашение с id i+1
+	approvalRules []storages.ApprovalRule
+	approvals     []storages.Approval // approvals[i] — запрос с id i+1
+
+	balances  map[int64]map[string]money.Decimal // по id кошелька
+	held      map[int64]map[string]money.Decimal // суммы активных холдов в кошельке по умолчанию
+	snapshots map[snapshotKey]money.Decimal      // остатки на конец дня
+	frozen    map[walletCurrency]struct{}        // балансы кошельков, замороженные сверкой
+	versions  map[walletCurrency]int64           // версии балансов; нет записи — версия 1
+
+	limits     []storages.LimitRule
+	limitUsage map[limitUsageKey]limitUsage
+	fees       []storages.FeeRule
+
+	quotes      map[int64]storages.Quote
+	nextQuoteID int64
+
+	rateHistory []storages.RateSample // в порядке записи
+
+	holds  []storages.Hold  // holds[i] — холд с id i+1
+	orders []storages.Order // orders[i] — заявка с id i+1
+
+	schedules      map[int64]*storages.Schedule
+	nextScheduleID int64
+	scheduleRuns   []storages.ScheduleRun // scheduleRuns[i] — срабатывание с id i+1
+	scheduleSlots  map[scheduleSlot]struct{}
+
+	// transactions[i] — операция с id i+1; Entries содержит все проводки
+	transactions []storages.Transaction
+	nextEntryID  int64
+
+	idempotency map[idempotencyKey]storages.IdempotencyRecord
+
+	outbox []outboxRecord // outbox[i] — событие с id i+1
+
+	// audit[i] — запись с id i+1; записи запечатываются по порядку, поэтому Seq == id
+	audit []storages.AuditEntry
+}
+
+var _ storages.Repository = (*Memory)(nil)
+
+func NewMemoryRepository() *Memory {
+	m := &Memory{
+		users:          make(map[int64]storages.User),
+		emails:         make(map[string]int64),
+		currencies:     make(map[string]storages.Currency, len(defaultCurrencies)),
+		defaultWallets: make(map[int64]int64),
+		balances:       make(map[int64]map[string]money.Decimal),
+		held:           make(map[int64]map[string]money.Decimal),
+		snapshots:      make(map[snapshotKey]money.Decimal),
+		frozen:         make(map[walletCurrency]struct{}),
+		versions:       make(map[walletCurrency]int64),
+		limits:         append([]storages.LimitRule(nil), defaultLimits()...),
+		limitUsage:     make(map[limitUsageKey]limitUsage),
+		quotes:         make(map[int64]storages.Quote),
+		schedules:      make(map[int64]*storages.Schedule),
+		scheduleSlots:  make(map[scheduleSlot]struct{}),
+		idempotency:    make(map[idempotencyKey]storages.IdempotencyRecord),
+	}
+	for _, c := range defaultCurrencies {
+		m.currencies[c.Code] = c
+	}
+	return m
+}
+
+// Users
+func (m *Memory) CreateUser(ctx context.Context, email, passwordHash string) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, exists := m.emails[email]; exists {
+		return 0, fmt.Errorf("failed to create user: email %s already exists", email)
+	}
+
+	m.nextUserID++
+	userID := m.nextUserID
+	m.users[userID] = storages.User{ID: userID, Email: email, PasswordHash: passwordHash, Tier: storages.DefaultTier}
+	m.emails[email] = userID
+
+	m.addWallet(userID, storages.DefaultWalletName, true)
+	m.held[userID] = make(map[string]money.Decimal)
+
+	return userID, nil
+}
+
+func (m *Memory) GetUserByEmail(ctx context.Context, email string) (storages.User, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	userID, ok := m.emails[email]
+	if !ok {
+		return storages.User{}, storages.ErrUserNotFound
+	}
+	return m.users[userID], nil
+}
+
+func (m *Memory) GetBalance(ctx context.Context, userID int64, currency string) (money.Decimal, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	amount, ok := m.balances[m.defaultWallets[userID]][currency]
+	if !ok {
+		return money.Decimal{}, fmt.Errorf("%w for currency %s", storages.ErrBalanceNotFound, currency)
+	}
+	return amount, nil
+}
+
+func (m *Memory) GetAllBalances(ctx context.Context, userID int64) (map[string]money.Decimal, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	walletID := m.defaultWallets[userID]
+	balances := make(map[string]money.Decimal, len(m.balances[walletID]))
+	for currency, amount := range m.balances[walletID] {
+		balances[currency] = amount
+	}
+	return balances, nil
+}
+
+// GetBalances возвращает учётные балансы кошелька по умолчанию вместе с суммами активных холдов
+func (m *Memory) GetBalances(ctx context.Context, userID int64) ([]storages.Balance, error) {
+	return m.GetWalletBalances(ctx, userID, 0)
+}
+
+// UpdateBalance проводит одиночное изменение баланса через журнал
+func (m *Memory) UpdateBalance(ctx context.Context, userID int64, currency string, amount money.Decimal) error {
+	opType := storages.OperationDeposit
+	if amount.Sign() < 0 {
+		opType = storages.OperationWithdraw
+	}
+
+	_, err := m.PostTransaction(ctx, userID, opType, storages.Posting{Currency: currency, Amount: amount})
+	return err
+}
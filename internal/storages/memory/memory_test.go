@@ -0,0 +1,824 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"gw-currency-wallet/internal/storages"
+	"gw-currency-wallet/pkg/money"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryStorage(t *testing.T) {
+	ctx := context.Background()
+	storage := NewMemoryRepository()
+
+	userID, err := storage.CreateUser(ctx, "test@example.com", "hash")
+	require.NoError(t, err)
+
+	// Email уникален
+	_, err = storage.CreateUser(ctx, "test@example.com", "hash")
+	assert.Error(t, err)
+
+	balance, err := storage.GetBalance(ctx, userID, "USD")
+	assert.NoError(t, err)
+	assert.Equal(t, "0.00", balance.String())
+
+	_, err = storage.GetBalance(ctx, userID, "GBP")
+	assert.ErrorIs(t, err, storages.ErrBalanceNotFound)
+
+	err = storage.UpdateBalance(ctx, userID, "USD", money.MustParse("100.5"))
+	assert.NoError(t, err)
+
+	balance, err = storage.GetBalance(ctx, userID, "USD")
+	assert.NoError(t, err)
+	assert.Equal(t, "100.50", balance.String())
+
+	// Сумма с лишними знаками отклоняется, а не округляется
+	_, err = storage.Credit(ctx, userID, "USD", money.MustParse("1.005"))
+	assert.ErrorIs(t, err, money.ErrPrecision)
+
+	txn, err := storage.ExecuteExchange(ctx, userID, "USD", "RUB", money.New(50, 0), money.New(4500, 0))
+	assert.NoError(t, err)
+	assert.Len(t, txn.Entries, 4)
+
+	for _, currency := range []string{"USD", "RUB"} {
+		balance, err = storage.GetBalance(ctx, userID, currency)
+		assert.NoError(t, err)
+		ledger, err := storage.GetLedgerBalance(ctx, userID, currency)
+		assert.NoError(t, err)
+		assert.True(t, balance.Equal(ledger), currency)
+	}
+
+	// Неудачная операция не оставляет ни изменений баланса, ни проводок
+	_, err = storage.ExecuteExchange(ctx, userID, "USD", "RUB", money.New(1000, 0), money.New(90000, 0))
+	assert.ErrorIs(t, err, storages.ErrInsufficientFunds)
+
+	balance, err = storage.GetBalance(ctx, userID, "RUB")
+	assert.NoError(t, err)
+	assert.Equal(t, "4500.00", balance.String())
+
+	txns, err := storage.ListTransactions(ctx, userID, storages.TransactionFilter{Limit: 10})
+	assert.NoError(t, err)
+	assert.Len(t, txns, 2)
+	assert.Len(t, txns[0].Entries, 2, "list shows wallet entries only")
+
+	txns, err = storage.ListTransactions(ctx, userID, storages.TransactionFilter{Currency: "RUB", Limit: 10})
+	assert.NoError(t, err)
+	assert.Len(t, txns, 1)
+
+	_, err = storage.GetTransaction(ctx, userID+1, txn.ID)
+	assert.ErrorIs(t, err, storages.ErrTransactionNotFound)
+}
+
+func TestMemoryStorage_ConcurrentDebits(t *testing.T) {
+	ctx := context.Background()
+	storage := NewMemoryRepository()
+
+	userID, err := storage.CreateUser(ctx, "test@example.com", "hash")
+	require.NoError(t, err)
+	_, err = storage.Credit(ctx, userID, "USD", money.MustParse("50.5"))
+	require.NoError(t, err)
+
+	// Из 50.5 USD проходят ровно пять списаний по 10
+	var wg sync.WaitGroup
+	var succeeded atomic.Int32
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := storage.Debit(ctx, userID, "USD", money.New(10, 0))
+			if err == nil {
+				succeeded.Add(1)
+				return
+			}
+			assert.ErrorIs(t, err, storages.ErrInsufficientFunds)
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(5), succeeded.Load())
+
+	balance, err := storage.GetBalance(ctx, userID, "USD")
+	assert.NoError(t, err)
+	assert.Equal(t, "0.50", balance.String())
+}
+
+func TestMemoryStorage_Idempotency(t *testing.T) {
+	ctx := context.Background()
+	storage := NewMemoryRepository()
+	now := time.Now()
+
+	_, reserved, err := storage.ReserveIdempotencyKey(ctx, 1, "key", "fp", now, time.Minute)
+	assert.NoError(t, err)
+	assert.True(t, reserved)
+
+	record, reserved, err := storage.ReserveIdempotencyKey(ctx, 1, "key", "fp", now.Add(time.Second), time.Minute)
+	assert.NoError(t, err)
+	assert.False(t, reserved)
+	assert.Equal(t, 0, record.StatusCode)
+
+	// Захват упавшего запроса истёк — ключ занимает повтор
+	_, reserved, err = storage.ReserveIdempotencyKey(ctx, 1, "key", "fp", now.Add(time.Minute), time.Minute)
+	assert.NoError(t, err)
+	assert.True(t, reserved)
+
+	assert.NoError(t, storage.SaveIdempotentResponse(ctx, 1, "key", 200, []byte(`{}`)))
+	assert.NoError(t, storage.ReleaseIdempotencyKey(ctx, 1, "key"))
+
+	record, reserved, err = storage.ReserveIdempotencyKey(ctx, 1, "key", "fp", now.Add(time.Hour), time.Minute)
+	assert.NoError(t, err)
+	assert.False(t, reserved, "completed keys are not released")
+	assert.Equal(t, 200, record.StatusCode)
+}
+
+func TestMemoryStorage_CurrencyCatalog(t *testing.T) {
+	ctx := context.Background()
+	storage := NewMemoryRepository()
+
+	userID, err := storage.CreateUser(ctx, "test@example.com", "hash")
+	require.NoError(t, err)
+
+	// Отключённая валюта не получает счёт
+	require.NoError(t, storage.SetCurrency(storages.Currency{Code: "CNY", Name: "Yuan Renminbi", MinorUnits: 2}))
+	_, err = storage.Credit(ctx, userID, "CNY", money.New(10, 0))
+	assert.ErrorIs(t, err, storages.ErrBalanceNotFound)
+
+	// Валюта, добавленная после регистрации, открывается при первой операции
+	require.NoError(t, storage.SetCurrency(storages.Currency{Code: "GBP", Name: "Pound Sterling", MinorUnits: 2, Enabled: true}))
+	_, err = storage.Credit(ctx, userID, "GBP", money.MustParse("12.50"))
+	require.NoError(t, err)
+
+	balance, err := storage.GetBalance(ctx, userID, "GBP")
+	assert.NoError(t, err)
+	assert.Equal(t, "12.50", balance.String())
+
+	// Новые пользователи получают счета во всех включённых валютах
+	otherID, err := storage.CreateUser(ctx, "other@example.com", "hash")
+	require.NoError(t, err)
+	balances, err := storage.GetAllBalances(ctx, otherID)
+	assert.NoError(t, err)
+	assert.Len(t, balances, 4)
+	assert.NotContains(t, balances, "CNY")
+
+	currency, err := storage.GetCurrency(ctx, "XXX")
+	assert.ErrorIs(t, err, storages.ErrCurrencyNotFound)
+	assert.Empty(t, currency.Code)
+
+	assert.Error(t, storage.SetCurrency(storages.Currency{Code: "KWD", MinorUnits: 3, Enabled: true}))
+}
+
+func TestMemoryStorage_Transfer(t *testing.T) {
+	ctx := context.Background()
+	storage := NewMemoryRepository()
+
+	senderID, err := storage.CreateUser(ctx, "sender@example.com", "hash")
+	require.NoError(t, err)
+	recipientID, err := storage.CreateUser(ctx, "recipient@example.com", "hash")
+	require.NoError(t, err)
+	_, err = storage.Credit(ctx, senderID, "EUR", money.New(50, 0))
+	require.NoError(t, err)
+
+	txn, err := storage.Transfer(ctx, senderID, recipientID, "EUR", money.MustParse("20.5"), withdrawal("EUR", money.MustParse("20.5")))
+	require.NoError(t, err)
+	assert.Equal(t, storages.OperationTransfer, txn.Type)
+	assert.Equal(t, recipientID, txn.CounterpartyID)
+
+	// Обе стороны сходятся с журналом
+	for userID, expected := range map[int64]string{senderID: "29.50", recipientID: "20.50"} {
+		balance, err := storage.GetBalance(ctx, userID, "EUR")
+		assert.NoError(t, err)
+		assert.Equal(t, expected, balance.String())
+		ledger, err := storage.GetLedgerBalance(ctx, userID, "EUR")
+		assert.NoError(t, err)
+		assert.True(t, balance.Equal(ledger))
+	}
+
+	_, err = storage.Transfer(ctx, senderID, senderID, "EUR", money.New(1, 0), withdrawal("EUR", money.New(1, 0)))
+	assert.ErrorIs(t, err, storages.ErrSelfTransfer)
+	_, err = storage.Transfer(ctx, senderID, 999, "EUR", money.New(1, 0), withdrawal("EUR", money.New(1, 0)))
+	assert.ErrorIs(t, err, storages.ErrUserNotFound)
+	_, err = storage.Transfer(ctx, senderID, recipientID, "EUR", money.New(30, 0), withdrawal("EUR", money.New(30, 0)))
+	assert.ErrorIs(t, err, storages.ErrInsufficientFunds)
+
+	// Переполнение у получателя не списывает средства у отправителя
+	_, err = storage.Credit(ctx, recipientID, "USD", money.MustParse("9999999999999.99"))
+	require.NoError(t, err)
+	_, err = storage.Credit(ctx, senderID, "USD", money.New(1, 0))
+	require.NoError(t, err)
+	_, err = storage.Transfer(ctx, senderID, recipientID, "USD", money.New(1, 0), withdrawal("USD", money.New(1, 0)))
+	assert.ErrorIs(t, err, money.ErrOverflow)
+	balance, _ := storage.GetBalance(ctx, senderID, "USD")
+	assert.Equal(t, "1.00", balance.String())
+}
+
+func TestMemoryStorage_Holds(t *testing.T) {
+	ctx := context.Background()
+	storage := NewMemoryRepository()
+
+	userID, err := storage.CreateUser(ctx, "test@example.com", "hash")
+	require.NoError(t, err)
+	_, err = storage.Credit(ctx, userID, "USD", money.New(100, 0))
+	require.NoError(t, err)
+
+	hold, err := storage.PlaceHold(ctx, userID, "USD", money.New(60, 0), time.Now().Add(time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, storages.HoldActive, hold.Status)
+
+	// Холд уменьшает доступный баланс, но не учётный
+	balance, err := storage.GetBalance(ctx, userID, "USD")
+	assert.NoError(t, err)
+	assert.Equal(t, "100.00", balance.String())
+	_, err = storage.Debit(ctx, userID, "USD", money.New(50, 0))
+	assert.ErrorIs(t, err, storages.ErrInsufficientFunds)
+	_, err = storage.PlaceHold(ctx, userID, "USD", money.New(41, 0), time.Now().Add(time.Hour))
+	assert.ErrorIs(t, err, storages.ErrInsufficientFunds)
+
+	// Частичное списание освобождает остаток холда
+	_, err = storage.CaptureHold(ctx, userID, hold.ID, money.New(61, 0), withdrawal("USD", money.New(61, 0)))
+	assert.ErrorIs(t, err, storages.ErrCaptureExceedsHold)
+	hold, err = storage.CaptureHold(ctx, userID, hold.ID, money.New(25, 0), withdrawal("USD", money.New(25, 0)))
+	require.NoError(t, err)
+	assert.Equal(t, storages.HoldCaptured, hold.Status)
+	assert.Equal(t, "25.00", hold.CapturedAmount.String())
+	assert.NotZero(t, hold.TransactionID)
+
+	balances, err := storage.GetBalances(ctx, userID)
+	require.NoError(t, err)
+	for _, b := range balances {
+		if b.Currency == "USD" {
+			assert.Equal(t, "75.00", b.Amount.String())
+			assert.True(t, b.Held.IsZero())
+		}
+	}
+	ledger, err := storage.GetLedgerBalance(ctx, userID, "USD")
+	assert.NoError(t, err)
+	assert.Equal(t, "75.00", ledger.String())
+
+	_, err = storage.CaptureHold(ctx, userID, hold.ID, money.New(1, 0), withdrawal("USD", money.New(1, 0)))
+	assert.ErrorIs(t, err, storages.ErrHoldNotActive)
+
+	// Отмена и истечение возвращают сумму в доступный баланс
+	voided, err := storage.PlaceHold(ctx, userID, "USD", money.New(75, 0), time.Now().Add(time.Hour))
+	require.NoError(t, err)
+	voided, err = storage.VoidHold(ctx, userID, voided.ID)
+	require.NoError(t, err)
+	assert.Equal(t, storages.HoldVoided, voided.Status)
+
+	expiring, err := storage.PlaceHold(ctx, userID, "USD", money.New(75, 0), time.Now().Add(time.Minute))
+	require.NoError(t, err)
+	_, err = storage.VoidHold(ctx, userID+1, expiring.ID)
+	assert.ErrorIs(t, err, storages.ErrHoldNotFound)
+
+	expired, err := storage.ExpireHolds(ctx, time.Now().Add(2*time.Minute))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, expired)
+	expiring, err = storage.GetHold(ctx, userID, expiring.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, storages.HoldExpired, expiring.Status)
+
+	_, err = storage.Debit(ctx, userID, "USD", money.New(75, 0))
+	assert.NoError(t, err)
+}
+
+func TestMemoryStorage_Limits(t *testing.T) {
+	ctx := context.Background()
+	storage := NewMemoryRepository()
+	userID, err := storage.CreateUser(ctx, "test@example.com", "hash")
+	require.NoError(t, err)
+	_, err = storage.Credit(ctx, userID, "RUB", money.New(100000, 0))
+	require.NoError(t, err)
+
+	limit := func(v int64) *money.Decimal {
+		d := money.New(v, 0)
+		return &d
+	}
+	require.NoError(t, storage.SetLimit(storages.LimitRule{
+		Tier: storages.DefaultTier, Operation: storages.OperationWithdraw, Currency: "RUB", Daily: limit(5000),
+	}))
+	require.NoError(t, storage.SetLimit(storages.LimitRule{
+		Tier: storages.DefaultTier, Operation: storages.OperationWithdraw, Monthly: limit(1000000),
+	}))
+	// Правило пользователя перекрывает тарифное с той же операцией и валютой
+	require.NoError(t, storage.SetLimit(storages.LimitRule{
+		UserID: userID, Operation: storages.OperationWithdraw, Currency: "RUB", PerTransaction: limit(3000), Daily: limit(4000),
+	}))
+
+	withdraw := func(amount, base int64) error {
+		baseAmount := money.New(base, 0)
+		_, err := storage.PostWithinLimits(ctx, userID, storages.OperationWithdraw,
+			storages.LimitCharge{Operation: storages.OperationWithdraw, Currency: "RUB", Amount: money.New(amount, 0), BaseAmount: &baseAmount}, nil,
+			storages.Posting{Currency: "RUB", Amount: money.New(-amount, 0)})
+		return err
+	}
+
+	var limitErr *storages.LimitError
+	err = withdraw(3500, 35)
+	require.ErrorAs(t, err, &limitErr)
+	assert.Equal(t, storages.LimitPerTransaction, limitErr.Period)
+
+	require.NoError(t, withdraw(3000, 30))
+	err = withdraw(1500, 15)
+	require.ErrorAs(t, err, &limitErr)
+	assert.ErrorIs(t, err, storages.ErrLimitExceeded)
+	assert.Equal(t, storages.LimitDaily, limitErr.Period)
+	assert.Equal(t, "1000.00", limitErr.Remaining.String())
+
+	// Отклонённая операция не меняет баланс и не расходует лимит
+	balance, err := storage.GetBalance(ctx, userID, "RUB")
+	require.NoError(t, err)
+	assert.Equal(t, "97000.00", balance.String())
+
+	statuses, err := storage.GetLimitStatus(ctx, userID, time.Now())
+	require.NoError(t, err)
+	var rub, normalized *storages.LimitStatus
+	for i := range statuses {
+		if statuses[i].Operation != storages.OperationWithdraw {
+			continue
+		}
+		switch {
+		case statuses[i].Normalized:
+			normalized = &statuses[i]
+		case statuses[i].Currency == "RUB":
+			rub = &statuses[i]
+		}
+	}
+	require.NotNil(t, rub)
+	require.NotNil(t, normalized)
+	assert.Equal(t, "3000.00", rub.DailyUsed.String())
+	assert.Equal(t, "1000.00", rub.DailyRemaining.String())
+	assert.Equal(t, "30.00", normalized.MonthlyUsed.String())
+
+	// Лимит в базовой валюте требует пересчитанную сумму
+	_, err = storage.PostWithinLimits(ctx, userID, storages.OperationWithdraw,
+		storages.LimitCharge{Operation: storages.OperationWithdraw, Currency: "RUB", Amount: money.New(1, 0)}, nil,
+		storages.Posting{Currency: "RUB", Amount: money.New(-1, 0)})
+	assert.Error(t, err)
+}
+
+func TestMemoryStorage_Orders(t *testing.T) {
+	ctx := context.Background()
+	storage := NewMemoryRepository()
+	userID, err := storage.CreateUser(ctx, "test@example.com", "hash")
+	require.NoError(t, err)
+	_, err = storage.Credit(ctx, userID, "USD", money.New(100, 0))
+	require.NoError(t, err)
+
+	newOrder := func(amount int64, expiresAt time.Time) storages.Order {
+		return storages.Order{
+			UserID: userID, FromCurrency: "USD", ToCurrency: "EUR",
+			Amount: money.New(amount, 0), LimitRate: money.MustParse("0.9"), ExpiresAt: expiresAt,
+		}
+	}
+
+	// Заявка резервирует сумму так же, как холд
+	order, err := storage.PlaceOrder(ctx, newOrder(60, time.Now().Add(time.Hour)))
+	require.NoError(t, err)
+	assert.Equal(t, storages.OrderOpen, order.Status)
+	_, err = storage.PlaceHold(ctx, userID, "USD", money.New(41, 0), time.Now().Add(time.Hour))
+	assert.ErrorIs(t, err, storages.ErrInsufficientFunds)
+
+	matching, err := storage.MatchingOrders(ctx, "USD", "EUR", money.MustParse("0.89"), time.Now(), 10)
+	require.NoError(t, err)
+	assert.Empty(t, matching)
+	matching, err = storage.MatchingOrders(ctx, "USD", "EUR", money.MustParse("0.9"), time.Now(), 10)
+	require.NoError(t, err)
+	assert.Len(t, matching, 1)
+
+	fill := func(rate money.Decimal, received int64) error {
+		_, err := storage.FillOrder(ctx, order.ID, rate, money.New(received, 0),
+			storages.LimitCharge{Operation: storages.OperationExchange, Currency: "USD", Amount: order.Amount}, nil,
+			storages.Posting{Currency: "USD", Amount: order.Amount.Neg()},
+			storages.Posting{Currency: "EUR", Amount: money.New(received, 0)})
+		return err
+	}
+	assert.ErrorIs(t, fill(money.MustParse("0.8"), 48), storages.ErrRateBelowLimit)
+
+	order, err = storage.CancelOrder(ctx, userID, order.ID)
+	require.NoError(t, err)
+	assert.Equal(t, storages.OrderCancelled, order.Status)
+	assert.ErrorIs(t, fill(money.MustParse("0.95"), 57), storages.ErrOrderNotOpen)
+	_, err = storage.CancelOrder(ctx, userID+1, order.ID)
+	assert.ErrorIs(t, err, storages.ErrOrderNotFound)
+	_, err = storage.RejectOrder(ctx, order.ID, "limit exceeded")
+	assert.ErrorIs(t, err, storages.ErrOrderNotOpen)
+
+	expiring, err := storage.PlaceOrder(ctx, newOrder(100, time.Now().Add(time.Minute)))
+	require.NoError(t, err)
+	expired, err := storage.ExpireOrders(ctx, time.Now().Add(2*time.Minute))
+	require.NoError(t, err)
+	assert.Equal(t, 1, expired)
+	expiring, err = storage.GetOrder(ctx, userID, expiring.ID)
+	require.NoError(t, err)
+	assert.Equal(t, storages.OrderExpired, expiring.Status)
+	audit, err := storage.ListAudit(ctx, storages.AuditFilter{UserID: userID, Action: storages.AuditOrderExpire, Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, audit, 1)
+	assert.Equal(t, fmt.Sprintf("order:%d", expiring.ID), audit[0].Target)
+
+	_, err = storage.Debit(ctx, userID, "USD", money.New(100, 0))
+	assert.NoError(t, err)
+	orders, err := storage.ListOrders(ctx, userID)
+	require.NoError(t, err)
+	require.Len(t, orders, 2)
+	assert.Equal(t, expiring.ID, orders[0].ID)
+}
+
+func TestMemoryStorage_Schedules(t *testing.T) {
+	ctx := context.Background()
+	storage := NewMemoryRepository()
+	userID, err := storage.CreateUser(ctx, "test@example.com", "hash")
+	require.NoError(t, err)
+
+	start := time.Date(2026, 1, 31, 12, 0, 0, 0, time.UTC)
+	schedule, err := storage.CreateSchedule(ctx, storages.Schedule{
+		UserID: userID, Operation: storages.OperationDeposit, Currency: "USD",
+		Amount: money.New(10, 0), Recurrence: storages.RecurrenceMonthly, StartAt: start,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, storages.ScheduleActive, schedule.Status)
+	assert.Equal(t, start, *schedule.NextRunAt)
+
+	_, err = storage.CreateSchedule(ctx, storages.Schedule{
+		UserID: userID, Operation: storages.OperationTransfer, Currency: "USD", ToUserID: userID + 1,
+		Amount: money.New(10, 0), Recurrence: storages.RecurrenceOnce, StartAt: start,
+	})
+	assert.ErrorIs(t, err, storages.ErrUserNotFound)
+
+	// Пропущенное за простой февральское срабатывание не выполняется отдельно: следующее — 31 марта
+	runs, err := storage.ClaimDueSchedules(ctx, start.AddDate(0, 1, 5), 10)
+	require.NoError(t, err)
+	require.Len(t, runs, 1)
+	assert.Equal(t, start, runs[0].ScheduledFor)
+	assert.Equal(t, schedule.ID, runs[0].Schedule.ID)
+
+	schedule, err = storage.GetSchedule(ctx, userID, schedule.ID)
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2026, 3, 31, 12, 0, 0, 0, time.UTC), *schedule.NextRunAt)
+
+	runs, err = storage.ClaimDueSchedules(ctx, start.AddDate(0, 1, 5), 10)
+	require.NoError(t, err)
+	assert.Empty(t, runs)
+
+	// Пауза останавливает срабатывания, возобновление пропускает пропущенные
+	_, err = storage.SetScheduleStatus(ctx, userID, schedule.ID, storages.SchedulePaused, start)
+	require.NoError(t, err)
+	runs, err = storage.ClaimDueSchedules(ctx, start.AddDate(0, 3, 0), 10)
+	require.NoError(t, err)
+	assert.Empty(t, runs)
+	schedule, err = storage.SetScheduleStatus(ctx, userID, schedule.ID, storages.ScheduleActive, time.Date(2026, 5, 1, 0, 0, 0, 0, time.UTC))
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2026, 5, 31, 12, 0, 0, 0, time.UTC), *schedule.NextRunAt)
+
+	_, err = storage.GetSchedule(ctx, userID+1, schedule.ID)
+	assert.ErrorIs(t, err, storages.ErrScheduleNotFound)
+	require.NoError(t, storage.DeleteSchedule(ctx, userID, schedule.ID))
+	_, err = storage.ListScheduleRuns(ctx, userID, schedule.ID, 10)
+	assert.ErrorIs(t, err, storages.ErrScheduleNotFound)
+}
+
+func TestMemoryStorage_Snapshots(t *testing.T) {
+	ctx := context.Background()
+	storage := NewMemoryRepository()
+	userID, err := storage.CreateUser(ctx, "test@example.com", "hash")
+	require.NoError(t, err)
+
+	// Операции 1, 2 и 3 марта: время операций в памяти проставляется текущее, поэтому сдвигаем его
+	march := func(day, hour int) time.Time { return time.Date(2026, 3, day, hour, 0, 0, 0, time.UTC) }
+	for _, op := range []struct {
+		at       time.Time
+		currency string
+		amount   money.Decimal
+	}{
+		{march(1, 10), "USD", money.New(100, 0)},
+		{march(2, 10), "USD", money.New(-30, 0)},
+		{march(3, 10), "EUR", money.New(5, 0)},
+	} {
+		txn, err := storage.PostTransaction(ctx, userID, storages.OperationDeposit, storages.Posting{Currency: op.currency, Amount: op.amount})
+		require.NoError(t, err)
+		backdate(storage, txn.ID, op.at)
+	}
+
+	written, err := storage.SnapshotBalances(ctx, march(1, 0))
+	require.NoError(t, err)
+	assert.Equal(t, 1, written)
+	written, err = storage.SnapshotBalances(ctx, march(2, 23))
+	require.NoError(t, err)
+	assert.Equal(t, 1, written)
+	// 3 марта пропущено: снимок 4 марта учитывает EUR, USD без движений не переписывается
+	written, err = storage.SnapshotBalances(ctx, march(4, 0))
+	require.NoError(t, err)
+	assert.Equal(t, 1, written)
+
+	for _, tc := range []struct {
+		asOf time.Time
+		want map[string]string
+	}{
+		{march(1, 9), map[string]string{}},
+		{march(2, 0), map[string]string{"USD": "100.00"}},
+		{march(2, 12), map[string]string{"USD": "70.00"}},
+		{march(3, 11), map[string]string{"USD": "70.00", "EUR": "5.00"}},
+		{march(10, 0), map[string]string{"USD": "70.00", "EUR": "5.00"}},
+	} {
+		balances, err := storage.GetBalancesAsOf(ctx, userID, 0, tc.asOf)
+		require.NoError(t, err)
+		got := make(map[string]string, len(balances))
+		for currency, amount := range balances {
+			got[currency] = amount.String()
+		}
+		assert.Equal(t, tc.want, got, tc.asOf)
+	}
+
+	// Остаток считается от снимка: поправка снимка видна в ответе
+	storage.snapshots[snapshotKey{walletID: storage.defaultWallets[userID], currency: "USD", day: march(2, 0)}] = money.MustParse("71.00")
+	balances, err := storage.GetBalancesAsOf(ctx, userID, 0, march(5, 0))
+	require.NoError(t, err)
+	assert.Equal(t, "71.00", balances["USD"].String())
+
+	// Снимок и остаток на момент ведутся по кошельку: подкошелёк снимается отдельно от кошелька по умолчанию
+	savings, err := storage.CreateWallet(ctx, userID, "savings")
+	require.NoError(t, err)
+	txn, err := storage.PostTransaction(ctx, userID, storages.OperationMove,
+		storages.Posting{Currency: "USD", Amount: money.New(-20, 0)},
+		storages.Posting{WalletID: savings.ID, Currency: "USD", Amount: money.New(20, 0)})
+	require.NoError(t, err)
+	backdate(storage, txn.ID, march(4, 10))
+	written, err = storage.SnapshotBalances(ctx, march(4, 0))
+	require.NoError(t, err)
+	assert.Equal(t, 3, written)
+	balances, err = storage.GetBalancesAsOf(ctx, userID, savings.ID, march(5, 0))
+	require.NoError(t, err)
+	assert.Equal(t, map[string]money.Decimal{"USD": money.MustParse("20.00")}, balances)
+	_, err = storage.GetBalancesAsOf(ctx, userID+1, savings.ID, march(5, 0))
+	assert.ErrorIs(t, err, storages.ErrWalletNotFound)
+	balances, err = storage.GetBalancesAsOf(ctx, userID, 0, march(5, 0))
+	require.NoError(t, err)
+	assert.Equal(t, "51.00", balances["USD"].String())
+}
+
+// backdate переносит операцию и её проводки на момент at
+func backdate(m *Memory, transactionID int64, at time.Time) {
+	txn := &m.transactions[transactionID-1]
+	txn.CreatedAt = at
+	for i := range txn.Entries {
+		txn.Entries[i].CreatedAt = at
+	}
+}
+
+func TestMemoryStorage_CheckBalances(t *testing.T) {
+	ctx := context.Background()
+	storage := NewMemoryRepository()
+	userID, err := storage.CreateUser(ctx, "test@example.com", "hash")
+	require.NoError(t, err)
+	_, err = storage.Credit(ctx, userID, "USD", money.New(100, 0))
+	require.NoError(t, err)
+	_, err = storage.PlaceHold(ctx, userID, "USD", money.New(10, 0), time.Now().Add(time.Hour))
+	require.NoError(t, err)
+	_, err = storage.PlaceOrder(ctx, storages.Order{UserID: userID, FromCurrency: "USD", ToCurrency: "EUR",
+		Amount: money.New(20, 0), LimitRate: money.MustParse("0.9"), ExpiresAt: time.Now().Add(time.Hour)})
+	require.NoError(t, err)
+
+	checks, lastUserID, err := storage.CheckBalances(ctx, 0, 10)
+	require.NoError(t, err)
+	assert.Equal(t, userID, lastUserID)
+	for _, check := range checks {
+		assert.False(t, check.Drifted(), check.Currency)
+	}
+
+	// Баланс, изменённый в обход журнала, и потерянный резерв видны как расхождения
+	storage.balances[storage.defaultWallets[userID]]["USD"] = money.MustParse("105.00")
+	storage.held[userID]["USD"] = money.MustParse("10.00")
+	checks, _, err = storage.CheckBalances(ctx, 0, 10)
+	require.NoError(t, err)
+	var usd storages.BalanceCheck
+	for _, check := range checks {
+		if check.Currency == "USD" {
+			usd = check
+		}
+	}
+	assert.True(t, usd.Drifted())
+	assert.Equal(t, "100.00", usd.Ledger.String())
+	assert.Equal(t, "30.00", usd.ExpectedHeld.String())
+
+	// Замороженный счёт не принимает операций и резервов
+	require.NoError(t, storage.SetAccountFrozen(ctx, userID, "USD", true))
+	_, err = storage.Credit(ctx, userID, "USD", money.New(1, 0))
+	assert.ErrorIs(t, err, storages.ErrAccountFrozen)
+	_, err = storage.PlaceHold(ctx, userID, "USD", money.New(1, 0), time.Now().Add(time.Hour))
+	assert.ErrorIs(t, err, storages.ErrAccountFrozen)
+	_, err = storage.Credit(ctx, userID, "EUR", money.New(1, 0))
+	assert.NoError(t, err)
+
+	// Заморозка относится к балансам кошельков, как строки balances в PostgreSQL:
+	// баланс в кошельке, открытый после заморозки, принимает операции
+	savings, err := storage.CreateWallet(ctx, userID, "Savings")
+	require.NoError(t, err)
+	_, err = storage.PostTransaction(ctx, userID, storages.OperationDeposit,
+		storages.Posting{WalletID: savings.ID, Currency: "USD", Amount: money.New(1, 0)})
+	assert.NoError(t, err)
+
+	// Расхождения в разных кошельках не гасят друг друга: +3 в одном и -3 в другом видны оба
+	storage.balances[storage.defaultWallets[userID]]["USD"] = money.MustParse("103.00")
+	storage.held[userID]["USD"] = money.MustParse("30.00")
+	storage.balances[savings.ID]["USD"] = money.MustParse("-2.00")
+	checks, _, err = storage.CheckBalances(ctx, 0, 10)
+	require.NoError(t, err)
+	drifted := make(map[int64]string)
+	for _, check := range checks {
+		if check.Drifted() {
+			drifted[check.WalletID] = check.Ledger.String()
+		}
+	}
+	assert.Equal(t, map[int64]string{storage.defaultWallets[userID]: "100.00", savings.ID: "1.00"}, drifted)
+
+	checks, lastUserID, err = storage.CheckBalances(ctx, userID, 10)
+	require.NoError(t, err)
+	assert.Empty(t, checks)
+	assert.Zero(t, lastUserID)
+}
+
+func TestSchedule_NextRun(t *testing.T) {
+	start := time.Date(2026, 1, 5, 9, 0, 0, 0, time.UTC)
+	once := storages.Schedule{Recurrence: storages.RecurrenceOnce, StartAt: start}
+	assert.Equal(t, start, *once.NextRun(start.Add(-time.Second)))
+	assert.Nil(t, once.NextRun(start))
+
+	weekly := storages.Schedule{Recurrence: storages.RecurrenceWeekly, StartAt: start}
+	assert.Equal(t, start.AddDate(0, 0, 7), *weekly.NextRun(start))
+	assert.Equal(t, start.AddDate(0, 0, 21), *weekly.NextRun(start.AddDate(0, 0, 15)))
+
+	daily := storages.Schedule{Recurrence: storages.RecurrenceDaily, StartAt: start}
+	assert.Equal(t, start.AddDate(0, 0, 3), *daily.NextRun(start.AddDate(0, 0, 2).Add(time.Hour)))
+}
+
+func TestMemoryStorage_BalanceVersions(t *testing.T) {
+	ctx := context.Background()
+	storage := NewMemoryRepository()
+	userID, err := storage.CreateUser(ctx, "test@example.com", "hash")
+	require.NoError(t, err)
+
+	version := func() int64 {
+		balances, err := storage.GetBalances(ctx, userID)
+		require.NoError(t, err)
+		for _, b := range balances {
+			if b.Currency == "USD" {
+				return b.Version
+			}
+		}
+		t.Fatal("USD balance not found")
+		return 0
+	}
+	require.Equal(t, int64(1), version())
+
+	_, err = storage.PostTransaction(ctx, userID, storages.OperationDeposit,
+		storages.Posting{Currency: "USD", Amount: money.New(100, 0), IfVersion: 1})
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), version())
+
+	// Резерв тоже меняет версию: доступная сумма уже другая
+	_, err = storage.PlaceHold(ctx, userID, "USD", money.New(10, 0), time.Now().Add(time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), version())
+
+	_, err = storage.PostTransaction(ctx, userID, storages.OperationWithdraw,
+		storages.Posting{Currency: "USD", Amount: money.New(-5, 0), IfVersion: 2})
+	assert.ErrorIs(t, err, storages.ErrVersionMismatch)
+	assert.Equal(t, int64(3), version())
+	balance, err := storage.GetBalance(ctx, userID, "USD")
+	require.NoError(t, err)
+	assert.Equal(t, "100.00", balance.String())
+}
+
+func TestMemoryStorage_Wallets(t *testing.T) {
+	ctx := context.Background()
+	storage := NewMemoryRepository()
+	userID, err := storage.CreateUser(ctx, "test@example.com", "hash")
+	require.NoError(t, err)
+	otherID, err := storage.CreateUser(ctx, "other@example.com", "hash")
+	require.NoError(t, err)
+
+	travel, err := storage.CreateWallet(ctx, userID, "Travel")
+	require.NoError(t, err)
+	_, err = storage.CreateWallet(ctx, userID, "Travel")
+	assert.ErrorIs(t, err, storages.ErrWalletExists)
+	wallets, err := storage.ListWallets(ctx, userID)
+	require.NoError(t, err)
+	require.Len(t, wallets, 2)
+	assert.True(t, wallets[0].Default)
+	assert.Equal(t, storages.DefaultWalletName, wallets[0].Name)
+
+	_, err = storage.Credit(ctx, userID, "USD", money.New(100, 0))
+	require.NoError(t, err)
+	_, err = storage.PlaceHold(ctx, userID, "USD", money.New(80, 0), time.Now().Add(time.Hour))
+	require.NoError(t, err)
+
+	// Холд лежит в кошельке по умолчанию: переместить можно только доступное
+	move := func(amount int64) error {
+		_, err := storage.PostTransaction(ctx, userID, storages.OperationMove,
+			storages.Posting{Currency: "USD", Amount: money.New(-amount, 0)},
+			storages.Posting{WalletID: travel.ID, Currency: "USD", Amount: money.New(amount, 0)})
+		return err
+	}
+	assert.ErrorIs(t, move(30), storages.ErrInsufficientFunds)
+	require.NoError(t, move(20))
+
+	balance, err := storage.GetBalance(ctx, userID, "USD")
+	require.NoError(t, err)
+	assert.Equal(t, "80.00", balance.String())
+	balances, err := storage.GetWalletBalances(ctx, userID, travel.ID)
+	require.NoError(t, err)
+	for _, b := range balances {
+		if b.Currency == "USD" {
+			assert.Equal(t, "20.00", b.Amount.String())
+			assert.True(t, b.Held.IsZero())
+		}
+	}
+
+	// Перемещение не меняет общий остаток пользователя, поэтому сверка сходится
+	ledger, err := storage.GetLedgerBalance(ctx, userID, "USD")
+	require.NoError(t, err)
+	assert.Equal(t, "100.00", ledger.String())
+	checks, _, err := storage.CheckBalances(ctx, 0, 10)
+	require.NoError(t, err)
+	for _, check := range checks {
+		assert.False(t, check.Drifted(), check.Currency)
+	}
+
+	_, err = storage.GetWalletBalances(ctx, otherID, travel.ID)
+	assert.ErrorIs(t, err, storages.ErrWalletNotFound)
+	_, err = storage.PostTransaction(ctx, otherID, storages.OperationWithdraw,
+		storages.Posting{WalletID: travel.ID, Currency: "USD", Amount: money.New(-1, 0)})
+	assert.ErrorIs(t, err, storages.ErrWalletNotFound)
+}
+
+func TestMemoryStorage_WalletSharing(t *testing.T) {
+	ctx := context.Background()
+	storage := NewMemoryRepository()
+	ownerID, err := storage.CreateUser(ctx, "owner@example.com", "hash")
+	require.NoError(t, err)
+	memberID, err := storage.CreateUser(ctx, "member@example.com", "hash")
+	require.NoError(t, err)
+	family, err := storage.CreateWallet(ctx, ownerID, "Family")
+	require.NoError(t, err)
+
+	_, err = storage.CreateInvitation(ctx, storages.WalletInvitation{WalletID: family.ID, Email: "owner@example.com", Role: storages.RoleViewer})
+	assert.ErrorIs(t, err, storages.ErrMemberExists)
+	invitation, err := storage.CreateInvitation(ctx, storages.WalletInvitation{WalletID: family.ID, Email: "member@example.com", Role: storages.RoleSpender})
+	require.NoError(t, err)
+	_, err = storage.CreateInvitation(ctx, storages.WalletInvitation{WalletID: family.ID, Email: "member@example.com", Role: storages.RoleViewer})
+	assert.ErrorIs(t, err, storages.ErrMemberExists)
+
+	// Принять приглашение может только пользователь с email из приглашения, и только один раз
+	_, err = storage.RespondInvitation(ctx, ownerID, invitation.ID, true)
+	assert.ErrorIs(t, err, storages.ErrInvitationNotFound)
+	_, err = storage.RespondInvitation(ctx, memberID, invitation.ID, true)
+	require.NoError(t, err)
+	_, err = storage.RespondInvitation(ctx, memberID, invitation.ID, false)
+	assert.ErrorIs(t, err, storages.ErrInvitationNotOpen)
+
+	wallets, err := storage.ListWallets(ctx, memberID)
+	require.NoError(t, err)
+	require.Len(t, wallets, 2)
+	assert.True(t, wallets[0].Default)
+	assert.Equal(t, family.ID, wallets[1].ID)
+	assert.Equal(t, storages.RoleSpender, wallets[1].Role)
+	balances, err := storage.GetWalletBalances(ctx, memberID, family.ID)
+	require.NoError(t, err)
+	assert.Equal(t, ownerID, balances[0].UserID)
+
+	// Проводки по общему кошельку идут от имени владельца, участник провести их не может
+	_, err = storage.PostTransaction(ctx, memberID, storages.OperationDeposit,
+		storages.Posting{WalletID: family.ID, Currency: "USD", Amount: money.New(1, 0)})
+	assert.ErrorIs(t, err, storages.ErrWalletNotFound)
+
+	approval, err := storage.CreateApproval(ctx, storages.Approval{
+		WalletID: family.ID, RequestedBy: memberID, Operation: storages.OperationWithdraw, Currency: "USD", Amount: money.New(5, 0)})
+	require.NoError(t, err)
+	_, err = storage.DecideApproval(ctx, family.ID, approval.ID, memberID, storages.ApprovalApproved)
+	assert.ErrorIs(t, err, storages.ErrSelfApproval)
+	_, err = storage.DecideApproval(ctx, family.ID+1, approval.ID, ownerID, storages.ApprovalApproved)
+	assert.ErrorIs(t, err, storages.ErrApprovalNotFound)
+	_, err = storage.DecideApproval(ctx, family.ID, approval.ID, ownerID, storages.ApprovalApproved)
+	require.NoError(t, err)
+	approval, err = storage.FinishApproval(ctx, approval.ID, 7, "")
+	require.NoError(t, err)
+	assert.Equal(t, storages.ApprovalApproved, approval.Status)
+	assert.Equal(t, int64(7), approval.TransactionID)
+	_, err = storage.FinishApproval(ctx, approval.ID, 8, "")
+	assert.ErrorIs(t, err, storages.ErrApprovalNotFound)
+
+	require.NoError(t, storage.SetWalletMemberRole(ctx, family.ID, memberID, storages.RoleViewer))
+	assert.ErrorIs(t, storage.SetWalletMemberRole(ctx, family.ID, ownerID, storages.RoleViewer), storages.ErrMemberNotFound)
+	require.NoError(t, storage.RemoveWalletMember(ctx, family.ID, memberID))
+	_, err = storage.GetWallet(ctx, memberID, family.ID)
+	assert.ErrorIs(t, err, storages.ErrWalletNotFound)
+}
+
+// withdrawal — списание для лимитов снятия
+func withdrawal(currency string, amount money.Decimal) storages.LimitCharge {
+	return storages.LimitCharge{Operation: storages.OperationWithdraw, Currency: currency, Amount: amount}
+}
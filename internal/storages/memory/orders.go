@@ -0,0 +1,208 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"gw-currency-wallet/internal/storages"
+	"gw-currency-wallet/pkg/money"
+	"time"
+)
+
+// PlaceOrder создаёт заявку и резервирует её сумму на балансе
+func (m *Memory) PlaceOrder(ctx context.Context, order storages.Order) (storages.Order, error) {
+	amount, err := order.Amount.Round(balanceScale, money.RoundHalfUp)
+	if err != nil {
+		return storages.Order{}, err
+	}
+	if amount.Sign() <= 0 || order.LimitRate.Sign() <= 0 {
+		return storages.Order{}, fmt.Errorf("failed to create order: amount and limit rate must be positive")
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err = m.reserveHeld(order.UserID, order.FromCurrency, amount); err != nil {
+		return storages.Order{}, err
+	}
+
+	now := time.Now()
+	order.ID = int64(len(m.orders)) + 1
+	order.Amount = amount
+	order.Status = storages.OrderOpen
+	order.Rate, order.Received, order.TransactionID = nil, nil, 0
+	order.CreatedAt, order.UpdatedAt = now, now
+	m.orders = append(m.orders, order)
+	return order, nil
+}
+
+func (m *Memory) GetOrder(ctx context.Context, userID, orderID int64) (storages.Order, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	order, ok := m.order(orderID)
+	if !ok || order.UserID != userID {
+		return storages.Order{}, storages.ErrOrderNotFound
+	}
+	return *order, nil
+}
+
+// ListOrders возвращает заявки пользователя от новых к старым
+func (m *Memory) ListOrders(ctx context.Context, userID int64) ([]storages.Order, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var orders []storages.Order
+	for i := len(m.orders) - 1; i >= 0; i-- {
+		if m.orders[i].UserID == userID {
+			orders = append(orders, m.orders[i])
+		}
+	}
+	return orders, nil
+}
+
+// CancelOrder отменяет открытую заявку и возвращает сумму в доступный баланс
+func (m *Memory) CancelOrder(ctx context.Context, userID, orderID int64) (storages.Order, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	order, ok := m.order(orderID)
+	if !ok || order.UserID != userID {
+		return storages.Order{}, storages.ErrOrderNotFound
+	}
+	if err := checkOpen(order); err != nil {
+		return *order, err
+	}
+	if err := m.releaseHeld(order.UserID, order.FromCurrency, order.Amount); err != nil {
+		return *order, err
+	}
+
+	order.Status = storages.OrderCancelled
+	order.UpdatedAt = time.Now()
+	m.appendAudit(ctx, storages.NewOrderAudit(*order))
+	return *order, nil
+}
+
+func (m *Memory) MatchingOrders(ctx context.Context, fromCurrency, toCurrency string, rate money.Decimal, now time.Time, limit int) ([]storages.Order, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var orders []storages.Order
+	for _, order := range m.orders {
+		if len(orders) == limit {
+			break
+		}
+		if order.Status == storages.OrderOpen && order.FromCurrency == fromCurrency && order.ToCurrency == toCurrency &&
+			order.LimitRate.Cmp(rate) <= 0 && order.ExpiresAt.After(now) {
+			orders = append(orders, order)
+		}
+	}
+	return orders, nil
+}
+
+// FillOrder исполняет заявку по курсу rate: резерв снимается, обмен postings проводится через журнал
+// в пределах лимита charge, заявка закрывается, events записываются в outbox. При ошибке резерв
+// остаётся на месте
+func (m *Memory) FillOrder(ctx context.Context, orderID int64, rate, received money.Decimal, charge storages.LimitCharge, events []storages.OutboxEvent, postings ...storages.Posting) (storages.Order, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	order, ok := m.order(orderID)
+	if !ok {
+		return storages.Order{}, storages.ErrOrderNotFound
+	}
+	if err := checkOpen(order); err != nil {
+		return *order, err
+	}
+	if rate.Cmp(order.LimitRate) < 0 {
+		return *order, storages.ErrRateBelowLimit
+	}
+	now := time.Now()
+	if err := m.checkLimits(order.UserID, charge, now); err != nil {
+		return *order, err
+	}
+
+	encoded, err := m.encodeOutbox(events)
+	if err != nil {
+		return *order, err
+	}
+	key := m.defaultBalance(order.UserID, order.FromCurrency)
+	heldBefore, versionBefore := m.heldAmount(order.UserID, order.FromCurrency), m.version(key)
+	if err := m.releaseHeld(order.UserID, order.FromCurrency, order.Amount); err != nil {
+		return *order, err
+	}
+	p, err := m.prepare(order.UserID, postings)
+	if err != nil {
+		m.held[order.UserID][order.FromCurrency] = heldBefore
+		m.versions[key] = versionBefore
+		return *order, err
+	}
+	txn := m.apply(ctx, p, storages.OperationExchange, 0)
+	m.appendOutbox(encoded)
+	m.recordLimitUsage(order.UserID, charge, now)
+
+	order.Status = storages.OrderFilled
+	order.Rate = &rate
+	order.Received = &received
+	order.TransactionID = txn.ID
+	order.UpdatedAt = now
+	return *order, nil
+}
+
+// ExpireOrders закрывает просроченные открытые заявки, освобождает их суммы и пишет аудит
+func (m *Memory) ExpireOrders(ctx context.Context, now time.Time) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var expired int
+	for i := range m.orders {
+		order := &m.orders[i]
+		if order.Status != storages.OrderOpen || order.ExpiresAt.After(now) {
+			continue
+		}
+		if err := m.releaseHeld(order.UserID, order.FromCurrency, order.Amount); err != nil {
+			return expired, err
+		}
+		order.Status = storages.OrderExpired
+		order.UpdatedAt = time.Now()
+		m.appendAudit(ctx, storages.NewOrderAudit(*order))
+		expired++
+	}
+	return expired, nil
+}
+
+// RejectOrder отклоняет открытую заявку с причиной reason и возвращает сумму в доступный баланс
+func (m *Memory) RejectOrder(ctx context.Context, orderID int64, reason string) (storages.Order, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	order, ok := m.order(orderID)
+	if !ok {
+		return storages.Order{}, storages.ErrOrderNotFound
+	}
+	if err := checkOpen(order); err != nil {
+		return *order, err
+	}
+	if err := m.releaseHeld(order.UserID, order.FromCurrency, order.Amount); err != nil {
+		return *order, err
+	}
+
+	order.Status = storages.OrderRejected
+	order.Reason = reason
+	order.UpdatedAt = time.Now()
+	m.appendAudit(ctx, storages.NewOrderAudit(*order))
+	return *order, nil
+}
+
+func (m *Memory) order(id int64) (*storages.Order, bool) {
+	if id < 1 || id > int64(len(m.orders)) {
+		return nil, false
+	}
+	return &m.orders[id-1], true
+}
+
+func checkOpen(order *storages.Order) error {
+	if order.Status != storages.OrderOpen || !order.ExpiresAt.After(time.Now()) {
+		return fmt.Errorf("%w: %s", storages.ErrOrderNotOpen, order.Status)
+	}
+	return nil
+}
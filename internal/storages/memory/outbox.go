@@ -0,0 +1,108 @@
+package memory
+
+import (
+	"context"
+	"gw-currency-wallet/internal/storages"
+	"time"
+)
+
+type outboxRecord struct {
+	event         storages.OutboxEvent
+	nextAttemptAt time.Time
+	lockedUntil   time.Time
+	lastError     string
+	sent          bool
+}
+
+// encodeOutbox сериализует события следующей операции до изменения состояния,
+// чтобы ошибка сериализации не оставила операцию без её событий
+func (m *Memory) encodeOutbox(events []storages.OutboxEvent) ([]storages.OutboxEvent, error) {
+	return storages.EncodeOutbox(events, int64(len(m.transactions))+1)
+}
+
+func (m *Memory) appendOutbox(events []storages.OutboxEvent) {
+	now := time.Now()
+	for _, e := range events {
+		e.ID = int64(len(m.outbox)) + 1
+		e.Body = nil
+		e.CreatedAt = now
+		m.outbox = append(m.outbox, outboxRecord{event: e, nextAttemptAt: now})
+	}
+}
+
+// ClaimOutbox захватывает недоставленные события пользователей, у которых нет захваченных
+// событий, а первое недоставленное событие не ждёт повтора
+func (m *Memory) ClaimOutbox(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]storages.OutboxEvent, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ready := make(map[int64]bool)
+	for _, r := range m.outbox {
+		if r.sent {
+			continue
+		}
+		userReady, seen := ready[r.event.UserID]
+		if !seen {
+			userReady = !r.nextAttemptAt.After(now)
+		}
+		ready[r.event.UserID] = userReady && !r.lockedUntil.After(now)
+	}
+
+	var events []storages.OutboxEvent
+	for i := range m.outbox {
+		r := &m.outbox[i]
+		if len(events) == limit {
+			break
+		}
+		if r.sent || !ready[r.event.UserID] {
+			continue
+		}
+		r.lockedUntil = now.Add(lease)
+		events = append(events, r.event)
+	}
+	return events, nil
+}
+
+func (m *Memory) MarkOutboxSent(ctx context.Context, ids []int64, now time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, r := range m.outboxRecords(ids) {
+		r.sent = true
+		r.lockedUntil = time.Time{}
+	}
+	return nil
+}
+
+func (m *Memory) RetryOutbox(ctx context.Context, id int64, nextAttemptAt time.Time, lastError string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, r := range m.outboxRecords([]int64{id}) {
+		r.event.Attempts++
+		r.lastError = lastError
+		r.nextAttemptAt = nextAttemptAt
+		r.lockedUntil = time.Time{}
+	}
+	return nil
+}
+
+func (m *Memory) ReleaseOutbox(ctx context.Context, ids []int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, r := range m.outboxRecords(ids) {
+		r.lockedUntil = time.Time{}
+	}
+	return nil
+}
+
+func (m *Memory) outboxRecords(ids []int64) []*outboxRecord {
+	records := make([]*outboxRecord, 0, len(ids))
+	for _, id := range ids {
+		if id >= 1 && id <= int64(len(m.outbox)) {
+			records = append(records, &m.outbox[id-1])
+		}
+	}
+	return records
+}
@@ -0,0 +1,64 @@
+package memory
+
+import (
+	"context"
+	"gw-currency-wallet/internal/storages"
+	"time"
+)
+
+func (m *Memory) CreateQuote(ctx context.Context, quote storages.Quote) (storages.Quote, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	for id, q := range m.quotes {
+		if q.UserID == quote.UserID && q.UsedAt == nil && q.ExpiresAt.Before(now) {
+			delete(m.quotes, id)
+		}
+	}
+
+	m.nextQuoteID++
+	quote.ID = m.nextQuoteID
+	quote.CreatedAt = now
+	quote.UsedAt, quote.TransactionID = nil, 0
+	m.quotes[quote.ID] = quote
+	return quote, nil
+}
+
+func (m *Memory) GetQuote(ctx context.Context, userID, quoteID int64) (storages.Quote, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	quote, ok := m.quotes[quoteID]
+	if !ok || quote.UserID != userID {
+		return storages.Quote{}, storages.ErrQuoteNotFound
+	}
+	return quote, nil
+}
+
+// ExecuteQuote проводит обмен и отмечает котировку под одной блокировкой
+func (m *Memory) ExecuteQuote(ctx context.Context, quoteID, userID int64, charge storages.LimitCharge, events []storages.OutboxEvent, postings ...storages.Posting) (storages.Transaction, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	quote, ok := m.quotes[quoteID]
+	if !ok {
+		return storages.Transaction{}, storages.ErrQuoteNotFound
+	}
+	now := time.Now()
+	switch {
+	case quote.UsedAt != nil:
+		return storages.Transaction{}, storages.ErrQuoteUsed
+	case !now.Before(quote.ExpiresAt):
+		return storages.Transaction{}, storages.ErrQuoteExpired
+	}
+
+	txn, err := m.postWithinLimits(ctx, userID, storages.OperationExchange, charge, events, postings)
+	if err != nil {
+		return txn, err
+	}
+	quote.UsedAt = &now
+	quote.TransactionID = txn.ID
+	m.quotes[quoteID] = quote
+	return txn, nil
+}
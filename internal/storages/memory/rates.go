@@ -0,0 +1,71 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"gw-currency-wallet/internal/storages"
+	"sort"
+	"time"
+)
+
+func (m *Memory) SaveRates(ctx context.Context, samples []storages.RateSample) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, s := range samples {
+		for _, code := range []string{s.FromCurrency, s.ToCurrency} {
+			if _, ok := m.currencies[code]; !ok {
+				return fmt.Errorf("failed to save rates: unknown currency %s", code)
+			}
+		}
+		if s.Rate.Sign() <= 0 {
+			return fmt.Errorf("failed to save rates: rate for %s_%s must be positive", s.FromCurrency, s.ToCurrency)
+		}
+	}
+	m.rateHistory = append(m.rateHistory, samples...)
+	return nil
+}
+
+// GetRateCandles группирует курсы так же, как PostgreSQL: при равном времени получения
+// открытие и закрытие определяются порядком записи
+func (m *Memory) GetRateCandles(ctx context.Context, fromCurrency, toCurrency string, from, to time.Time, interval time.Duration) ([]storages.RateCandle, error) {
+	seconds := int64(interval / time.Second)
+	if seconds <= 0 {
+		return nil, fmt.Errorf("candle interval must be at least a second")
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var samples []storages.RateSample
+	for _, s := range m.rateHistory {
+		if s.FromCurrency != fromCurrency || s.ToCurrency != toCurrency || s.FetchedAt.Before(from) || !s.FetchedAt.Before(to) {
+			continue
+		}
+		samples = append(samples, s)
+	}
+	sort.SliceStable(samples, func(i, j int) bool { return samples[i].FetchedAt.Before(samples[j].FetchedAt) })
+
+	var candles []storages.RateCandle
+	for _, s := range samples {
+		unix := s.FetchedAt.Unix()
+		start := time.Unix(unix-((unix%seconds)+seconds)%seconds, 0).UTC()
+
+		if n := len(candles); n > 0 && candles[n-1].Start.Equal(start) {
+			c := &candles[n-1]
+			if s.Rate.Cmp(c.High) > 0 {
+				c.High = s.Rate
+			}
+			if s.Rate.Cmp(c.Low) < 0 {
+				c.Low = s.Rate
+			}
+			c.Close = s.Rate
+			c.Samples++
+			continue
+		}
+		candles = append(candles, storages.RateCandle{
+			Start: start, Open: s.Rate, High: s.Rate, Low: s.Rate, Close: s.Rate, Samples: 1,
+		})
+	}
+	return candles, nil
+}
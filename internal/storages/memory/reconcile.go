@@ -0,0 +1,147 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"gw-currency-wallet/internal/storages"
+	"gw-currency-wallet/pkg/money"
+	"sort"
+)
+
+// CheckBalances сверяет счета кошельков пачки пользователей с журналом кошелька, а резерв
+// кошелька по умолчанию — с холдами и заявками
+func (m *Memory) CheckBalances(ctx context.Context, afterUserID int64, limit int) ([]storages.BalanceCheck, int64, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var userIDs []int64
+	for userID := range m.users {
+		if userID > afterUserID {
+			userIDs = append(userIDs, userID)
+		}
+	}
+	sort.Slice(userIDs, func(i, j int) bool { return userIDs[i] < userIDs[j] })
+	if len(userIDs) > limit {
+		userIDs = userIDs[:limit]
+	}
+	if len(userIDs) == 0 {
+		return nil, 0, nil
+	}
+
+	add := func(total *money.Decimal, amount money.Decimal) error {
+		sum, err := total.Add(amount)
+		*total = sum
+		return err
+	}
+
+	var checks []storages.BalanceCheck
+	for _, userID := range userIDs {
+		defaultWallet := m.defaultWallets[userID]
+		accounts := make(map[walletCurrency]*storages.BalanceCheck)
+		account := func(walletID int64, currency string) *storages.BalanceCheck {
+			key := walletCurrency{walletID: walletID, currency: currency}
+			if c, ok := accounts[key]; ok {
+				return c
+			}
+			zero := money.New(0, balanceScale)
+			c := &storages.BalanceCheck{UserID: userID, WalletID: walletID, Currency: currency,
+				Balance: zero, Ledger: zero, Held: zero, ExpectedHeld: zero}
+			accounts[key] = c
+			return c
+		}
+
+		// Счёт сверяется в каждом кошельке пользователя отдельно
+		owned := make(map[int64]struct{})
+		for _, wallet := range m.wallets {
+			if wallet.UserID != userID {
+				continue
+			}
+			owned[wallet.ID] = struct{}{}
+			for currency, amount := range m.balances[wallet.ID] {
+				c := account(wallet.ID, currency)
+				c.Balance = amount
+				if wallet.ID == defaultWallet {
+					c.Held = m.heldAmount(userID, currency)
+				}
+				_, c.Frozen = m.frozen[walletCurrency{walletID: wallet.ID, currency: currency}]
+			}
+		}
+		for _, txn := range m.transactions {
+			for _, e := range txn.Entries {
+				if _, ok := owned[e.WalletID]; !ok || e.Account != storages.AccountWallet {
+					continue
+				}
+				amount := e.Amount
+				if e.Direction == storages.Debit {
+					amount = amount.Neg()
+				}
+				if err := add(&account(e.WalletID, e.Currency).Ledger, amount); err != nil {
+					return nil, 0, err
+				}
+			}
+		}
+		for _, hold := range m.holds {
+			if hold.UserID == userID && hold.Status == storages.HoldActive {
+				if err := add(&account(defaultWallet, hold.Currency).ExpectedHeld, hold.Amount); err != nil {
+					return nil, 0, err
+				}
+			}
+		}
+		for _, order := range m.orders {
+			if order.UserID == userID && order.Status == storages.OrderOpen {
+				if err := add(&account(defaultWallet, order.FromCurrency).ExpectedHeld, order.Amount); err != nil {
+					return nil, 0, err
+				}
+			}
+		}
+
+		keys := make([]walletCurrency, 0, len(accounts))
+		for key := range accounts {
+			keys = append(keys, key)
+		}
+		sort.Slice(keys, func(i, j int) bool {
+			if keys[i].walletID != keys[j].walletID {
+				return keys[i].walletID < keys[j].walletID
+			}
+			return keys[i].currency < keys[j].currency
+		})
+		for _, key := range keys {
+			checks = append(checks, *accounts[key])
+		}
+	}
+	return checks, userIDs[len(userIDs)-1], nil
+}
+
+// SetAccountFrozen замораживает или размораживает баланс в валюте во всех кошельках пользователя,
+// где он открыт, как UPDATE по строкам balances в PostgreSQL. Баланс, открытый позже, не заморожен
+func (m *Memory) SetAccountFrozen(ctx context.Context, userID int64, currency string, frozen bool) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	found := false
+	for _, wallet := range m.wallets {
+		if _, ok := m.balances[wallet.ID][currency]; !ok || wallet.UserID != userID {
+			continue
+		}
+		found = true
+		key := walletCurrency{walletID: wallet.ID, currency: currency}
+		if frozen {
+			m.frozen[key] = struct{}{}
+		} else {
+			delete(m.frozen, key)
+		}
+	}
+	if !found {
+		return fmt.Errorf("%w: user %d, currency %s", storages.ErrBalanceNotFound, userID, currency)
+	}
+	m.appendAudit(ctx, storages.NewFreezeAudit(userID, currency, frozen))
+	return nil
+}
+
+// checkFrozen — ErrAccountFrozen, если баланс кошелька заморожен. Вызывается под m.mu
+func (m *Memory) checkFrozen(key walletCurrency) error {
+	if _, ok := m.frozen[key]; ok {
+		return fmt.Errorf("%w: wallet %d, currency %s", storages.ErrAccountFrozen, key.walletID, key.currency)
+	}
+	return nil
+}
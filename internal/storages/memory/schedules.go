@@ -0,0 +1,221 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"gw-currency-wallet/internal/storages"
+	"sort"
+	"time"
+)
+
+type scheduleSlot struct {
+	scheduleID   int64
+	scheduledFor time.Time
+}
+
+func (m *Memory) CreateSchedule(ctx context.Context, s storages.Schedule) (storages.Schedule, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if s.ToUserID != 0 {
+		if _, ok := m.users[s.ToUserID]; !ok {
+			return storages.Schedule{}, fmt.Errorf("%w: recipient %d", storages.ErrUserNotFound, s.ToUserID)
+		}
+	}
+	for _, walletID := range []int64{s.WalletID, s.ToWalletID} {
+		if walletID < 0 || walletID > int64(len(m.wallets)) {
+			return storages.Schedule{}, fmt.Errorf("%w: %d", storages.ErrWalletNotFound, walletID)
+		}
+	}
+
+	m.nextScheduleID++
+	s.ID = m.nextScheduleID
+	s.Status = storages.ScheduleActive
+	next := s.StartAt
+	s.NextRunAt = &next
+	s.LastRunAt = nil
+	s.LastError = ""
+	s.CreatedAt = time.Now()
+	m.schedules[s.ID] = &s
+	return cloneSchedule(s), nil
+}
+
+func (m *Memory) ListSchedules(ctx context.Context, userID int64) ([]storages.Schedule, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var schedules []storages.Schedule
+	for _, s := range m.schedules {
+		if s.UserID == userID {
+			schedules = append(schedules, cloneSchedule(*s))
+		}
+	}
+	sort.Slice(schedules, func(i, j int) bool { return schedules[i].ID < schedules[j].ID })
+	return schedules, nil
+}
+
+func (m *Memory) GetSchedule(ctx context.Context, userID, scheduleID int64) (storages.Schedule, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	s, ok := m.schedules[scheduleID]
+	if !ok || s.UserID != userID {
+		return storages.Schedule{}, storages.ErrScheduleNotFound
+	}
+	return cloneSchedule(*s), nil
+}
+
+// SetScheduleStatus ставит расписание на паузу или возобновляет его. При возобновлении
+// пропущенные за паузу срабатывания не выполняются: следующее считается от now
+func (m *Memory) SetScheduleStatus(ctx context.Context, userID, scheduleID int64, status storages.ScheduleStatus, now time.Time) (storages.Schedule, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s, ok := m.schedules[scheduleID]
+	if !ok || s.UserID != userID {
+		return storages.Schedule{}, storages.ErrScheduleNotFound
+	}
+	if s.Status == storages.ScheduleCompleted {
+		return cloneSchedule(*s), storages.ErrScheduleCompleted
+	}
+
+	if status == storages.ScheduleActive && s.Status != storages.ScheduleActive {
+		if s.NextRunAt = s.NextRun(now); s.NextRunAt == nil {
+			status = storages.ScheduleCompleted
+		}
+	}
+	s.Status = status
+	return cloneSchedule(*s), nil
+}
+
+func (m *Memory) DeleteSchedule(ctx context.Context, userID, scheduleID int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s, ok := m.schedules[scheduleID]
+	if !ok || s.UserID != userID {
+		return storages.ErrScheduleNotFound
+	}
+	delete(m.schedules, scheduleID)
+	return nil
+}
+
+// ListScheduleRuns возвращает последние срабатывания расписания пользователя, от новых к старым
+func (m *Memory) ListScheduleRuns(ctx context.Context, userID, scheduleID int64, limit int) ([]storages.ScheduleRun, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	s, ok := m.schedules[scheduleID]
+	if !ok || s.UserID != userID {
+		return nil, storages.ErrScheduleNotFound
+	}
+
+	var runs []storages.ScheduleRun
+	for i := len(m.scheduleRuns) - 1; i >= 0 && len(runs) < limit; i-- {
+		if run := m.scheduleRuns[i]; run.ScheduleID == scheduleID {
+			run.Schedule = storages.Schedule{}
+			runs = append(runs, run)
+		}
+	}
+	return runs, nil
+}
+
+// ClaimDueSchedules захватывает наступившие срабатывания и сдвигает расписания.
+// Пропущенные за время простоя срабатывания сводятся к одному
+func (m *Memory) ClaimDueSchedules(ctx context.Context, now time.Time, limit int) ([]storages.ScheduleRun, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var due []*storages.Schedule
+	for _, s := range m.schedules {
+		if s.Status == storages.ScheduleActive && s.NextRunAt != nil && !s.NextRunAt.After(now) {
+			due = append(due, s)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool { return due[i].NextRunAt.Before(*due[j].NextRunAt) })
+	if len(due) > limit {
+		due = due[:limit]
+	}
+
+	runs := make([]storages.ScheduleRun, 0, len(due))
+	for _, s := range due {
+		slot := scheduleSlot{scheduleID: s.ID, scheduledFor: *s.NextRunAt}
+		_, done := m.scheduleSlots[slot]
+		if !done {
+			m.scheduleSlots[slot] = struct{}{}
+			run := storages.ScheduleRun{
+				ID:           int64(len(m.scheduleRuns)) + 1,
+				ScheduleID:   s.ID,
+				ScheduledFor: slot.scheduledFor,
+				Status:       storages.RunPending,
+				CreatedAt:    now,
+				Schedule:     cloneSchedule(*s),
+			}
+			m.scheduleRuns = append(m.scheduleRuns, run)
+			runs = append(runs, run)
+		}
+
+		lastRun := now
+		s.LastRunAt = &lastRun
+		if s.NextRunAt = s.NextRun(now); s.NextRunAt == nil {
+			s.Status = storages.ScheduleCompleted
+		}
+	}
+	return runs, nil
+}
+
+// FinishScheduleRun сохраняет результат срабатывания; ошибка видна и в самом расписании
+func (m *Memory) FinishScheduleRun(ctx context.Context, run storages.ScheduleRun) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if run.ID < 1 || run.ID > int64(len(m.scheduleRuns)) {
+		return fmt.Errorf("schedule run %d not found", run.ID)
+	}
+	stored := &m.scheduleRuns[run.ID-1]
+	finishedAt := time.Now()
+	stored.Status = run.Status
+	stored.TransactionID = run.TransactionID
+	stored.Error = run.Error
+	stored.FinishedAt = &finishedAt
+
+	if s, ok := m.schedules[run.ScheduleID]; ok {
+		s.LastError = run.Error
+	}
+	return nil
+}
+
+func (m *Memory) FailStaleScheduleRuns(ctx context.Context, staleBefore time.Time) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	failed := 0
+	finishedAt := time.Now()
+	for i := range m.scheduleRuns {
+		run := &m.scheduleRuns[i]
+		if run.Status != storages.RunPending || !run.CreatedAt.Before(staleBefore) {
+			continue
+		}
+		run.Status = storages.RunFailed
+		run.Error = storages.ErrRunInterrupted.Error()
+		run.FinishedAt = &finishedAt
+		if s, ok := m.schedules[run.ScheduleID]; ok {
+			s.LastError = run.Error
+		}
+		failed++
+	}
+	return failed, nil
+}
+
+// cloneSchedule отдаёт копию без общих указателей на время
+func cloneSchedule(s storages.Schedule) storages.Schedule {
+	if s.NextRunAt != nil {
+		next := *s.NextRunAt
+		s.NextRunAt = &next
+	}
+	if s.LastRunAt != nil {
+		last := *s.LastRunAt
+		s.LastRunAt = &last
+	}
+	return s
+}
@@ -0,0 +1,262 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"gw-currency-wallet/internal/storages"
+	"gw-currency-wallet/pkg/money"
+	"sort"
+	"time"
+)
+
+// CreateInvitation приглашает email в кошелёк. Владелец, участник или уже приглашённый — ErrMemberExists
+func (m *Memory) CreateInvitation(ctx context.Context, invitation storages.WalletInvitation) (storages.WalletInvitation, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if invitation.WalletID < 1 || invitation.WalletID > int64(len(m.wallets)) {
+		return storages.WalletInvitation{}, fmt.Errorf("%w: %d", storages.ErrWalletNotFound, invitation.WalletID)
+	}
+	if userID, ok := m.emails[invitation.Email]; ok {
+		if _, member := m.walletRole(userID, m.wallets[invitation.WalletID-1]); member {
+			return storages.WalletInvitation{}, fmt.Errorf("%w: %s", storages.ErrMemberExists, invitation.Email)
+		}
+	}
+	for _, i := range m.invitations {
+		if i.WalletID == invitation.WalletID && i.Email == invitation.Email && i.Status == storages.InvitationPending {
+			return storages.WalletInvitation{}, fmt.Errorf("%w: %s", storages.ErrMemberExists, invitation.Email)
+		}
+	}
+
+	now := time.Now()
+	invitation.ID = int64(len(m.invitations)) + 1
+	invitation.Status = storages.InvitationPending
+	invitation.CreatedAt, invitation.UpdatedAt = now, now
+	m.invitations = append(m.invitations, invitation)
+	return invitation, nil
+}
+
+// ListInvitations возвращает ожидающие ответа приглашения на email пользователя
+func (m *Memory) ListInvitations(ctx context.Context, userID int64) ([]storages.WalletInvitation, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var invitations []storages.WalletInvitation
+	for _, i := range m.invitations {
+		if i.Status == storages.InvitationPending && i.Email == m.users[userID].Email {
+			invitations = append(invitations, i)
+		}
+	}
+	return invitations, nil
+}
+
+// RespondInvitation принимает или отклоняет приглашение; принятое добавляет пользователя в участники
+func (m *Memory) RespondInvitation(ctx context.Context, userID, invitationID int64, accept bool) (storages.WalletInvitation, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	user, ok := m.users[userID]
+	if !ok || invitationID < 1 || invitationID > int64(len(m.invitations)) || m.invitations[invitationID-1].Email != user.Email {
+		return storages.WalletInvitation{}, storages.ErrInvitationNotFound
+	}
+	invitation := &m.invitations[invitationID-1]
+	if invitation.Status != storages.InvitationPending {
+		return *invitation, storages.ErrInvitationNotOpen
+	}
+
+	invitation.Status = storages.InvitationDeclined
+	if accept {
+		invitation.Status = storages.InvitationAccepted
+		if _, member := m.walletRole(userID, m.wallets[invitation.WalletID-1]); !member {
+			m.members = append(m.members, storages.WalletMember{
+				WalletID:  invitation.WalletID,
+				UserID:    userID,
+				Email:     user.Email,
+				Role:      invitation.Role,
+				CreatedAt: time.Now(),
+			})
+		}
+	}
+	invitation.UpdatedAt = time.Now()
+	return *invitation, nil
+}
+
+// ListWalletMembers возвращает владельца кошелька и участников в порядке добавления
+func (m *Memory) ListWalletMembers(ctx context.Context, walletID int64) ([]storages.WalletMember, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if walletID < 1 || walletID > int64(len(m.wallets)) {
+		return nil, nil
+	}
+	wallet := m.wallets[walletID-1]
+	members := []storages.WalletMember{{
+		WalletID:  wallet.ID,
+		UserID:    wallet.UserID,
+		Email:     m.users[wallet.UserID].Email,
+		Role:      storages.RoleOwner,
+		CreatedAt: wallet.CreatedAt,
+	}}
+	for _, member := range m.members {
+		if member.WalletID == walletID {
+			members = append(members, member)
+		}
+	}
+	return members, nil
+}
+
+func (m *Memory) SetWalletMemberRole(ctx context.Context, walletID, userID int64, role storages.WalletRole) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i := range m.members {
+		if m.members[i].WalletID == walletID && m.members[i].UserID == userID {
+			m.members[i].Role = role
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: wallet %d, user %d", storages.ErrMemberNotFound, walletID, userID)
+}
+
+func (m *Memory) RemoveWalletMember(ctx context.Context, walletID, userID int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i, member := range m.members {
+		if member.WalletID == walletID && member.UserID == userID {
+			m.members = append(m.members[:i], m.members[i+1:]...)
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: wallet %d, user %d", storages.ErrMemberNotFound, walletID, userID)
+}
+
+// SetApprovalRule создаёт правило или меняет порог существующего
+func (m *Memory) SetApprovalRule(ctx context.Context, rule storages.ApprovalRule) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.currencies[rule.Currency]; !ok {
+		return fmt.Errorf("%w: %s", storages.ErrCurrencyNotFound, rule.Currency)
+	}
+	threshold, err := rule.Threshold.Round(balanceScale, money.RoundHalfUp)
+	if err != nil {
+		return err
+	}
+	rule.Threshold = threshold
+
+	for i, r := range m.approvalRules {
+		if r.WalletID == rule.WalletID && r.Operation == rule.Operation && r.Currency == rule.Currency {
+			m.approvalRules[i] = rule
+			return nil
+		}
+	}
+	m.approvalRules = append(m.approvalRules, rule)
+	return nil
+}
+
+func (m *Memory) DeleteApprovalRule(ctx context.Context, walletID int64, operation storages.OperationType, currency string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i, r := range m.approvalRules {
+		if r.WalletID == walletID && r.Operation == operation && r.Currency == currency {
+			m.approvalRules = append(m.approvalRules[:i], m.approvalRules[i+1:]...)
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %s %s", storages.ErrApprovalRuleMissing, operation, currency)
+}
+
+func (m *Memory) ListApprovalRules(ctx context.Context, walletID int64) ([]storages.ApprovalRule, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var rules []storages.ApprovalRule
+	for _, r := range m.approvalRules {
+		if r.WalletID == walletID {
+			rules = append(rules, r)
+		}
+	}
+	sort.Slice(rules, func(i, j int) bool {
+		if rules[i].Operation != rules[j].Operation {
+			return rules[i].Operation < rules[j].Operation
+		}
+		return rules[i].Currency < rules[j].Currency
+	})
+	return rules, nil
+}
+
+func (m *Memory) CreateApproval(ctx context.Context, approval storages.Approval) (storages.Approval, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	amount, err := approval.Amount.Round(balanceScale, money.RoundHalfUp)
+	if err != nil {
+		return storages.Approval{}, err
+	}
+	approval.ID = int64(len(m.approvals)) + 1
+	approval.Amount = amount
+	approval.Status = storages.ApprovalPending
+	approval.CreatedAt = time.Now()
+	m.approvals = append(m.approvals, approval)
+	return approval, nil
+}
+
+// ListApprovals возвращает запросы кошелька, новые первыми
+func (m *Memory) ListApprovals(ctx context.Context, walletID int64) ([]storages.Approval, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var approvals []storages.Approval
+	for i := len(m.approvals) - 1; i >= 0; i-- {
+		if m.approvals[i].WalletID == walletID {
+			approvals = append(approvals, m.approvals[i])
+		}
+	}
+	return approvals, nil
+}
+
+// DecideApproval подтверждает или отклоняет запрос. Автор может отклонить свой запрос, но не подтвердить
+func (m *Memory) DecideApproval(ctx context.Context, walletID, approvalID, userID int64, status storages.ApprovalStatus) (storages.Approval, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if approvalID < 1 || approvalID > int64(len(m.approvals)) || m.approvals[approvalID-1].WalletID != walletID {
+		return storages.Approval{}, storages.ErrApprovalNotFound
+	}
+	approval := &m.approvals[approvalID-1]
+	if approval.Status != storages.ApprovalPending {
+		return storages.Approval{}, storages.ErrApprovalNotPending
+	}
+	if status != storages.ApprovalRejected && approval.RequestedBy == userID {
+		return storages.Approval{}, storages.ErrSelfApproval
+	}
+
+	now := time.Now()
+	approval.Status = status
+	approval.DecidedBy = userID
+	approval.DecidedAt = &now
+	return *approval, nil
+}
+
+// FinishApproval записывает итог проводки подтверждённого запроса: операцию или причину отказа
+func (m *Memory) FinishApproval(ctx context.Context, approvalID, transactionID int64, failure string) (storages.Approval, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if approvalID < 1 || approvalID > int64(len(m.approvals)) {
+		return storages.Approval{}, storages.ErrApprovalNotFound
+	}
+	approval := &m.approvals[approvalID-1]
+	if approval.Status != storages.ApprovalApproved || approval.TransactionID != 0 {
+		return storages.Approval{}, storages.ErrApprovalNotFound
+	}
+
+	approval.TransactionID = transactionID
+	approval.Failure = failure
+	if failure != "" {
+		approval.Status = storages.ApprovalFailed
+	}
+	return *approval, nil
+}
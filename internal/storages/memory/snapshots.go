@@ -0,0 +1,117 @@
+package memory
+
+import (
+	"context"
+	"gw-currency-wallet/internal/storages"
+	"gw-currency-wallet/pkg/money"
+	"time"
+)
+
+type snapshotKey struct {
+	walletID int64
+	currency string
+	day      time.Time // начало дня по UTC
+}
+
+// SnapshotBalances записывает остатки на конец дня day по парам кошелёк-валюта, у которых с предыдущего
+// снимка были движения. Повторный вызов за тот же день пересчитывает снимок
+func (m *Memory) SnapshotBalances(ctx context.Context, day time.Time) (int, error) {
+	day, end := storages.SnapshotDay(day)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	pairs := make(map[walletCurrency]struct{})
+	for _, txn := range m.transactions {
+		for _, e := range txn.Entries {
+			if e.Account == storages.AccountWallet && txn.CreatedAt.Before(end) {
+				pairs[walletCurrency{walletID: e.WalletID, currency: e.Currency}] = struct{}{}
+			}
+		}
+	}
+
+	written := 0
+	for pair := range pairs {
+		amount, moved, err := m.balanceAt(pair, day, end)
+		if err != nil {
+			return written, err
+		}
+		if moved {
+			m.snapshots[snapshotKey{walletID: pair.walletID, currency: pair.currency, day: day}] = amount
+			written++
+		}
+	}
+	return written, nil
+}
+
+func (m *Memory) GetBalancesAsOf(ctx context.Context, userID, walletID int64, asOf time.Time) (map[string]money.Decimal, error) {
+	// Годится снимок дня, закончившегося не позже asOf
+	day, _ := storages.SnapshotDay(asOf)
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	wallet, err := m.accessibleWallet(userID, walletID)
+	if err != nil {
+		return nil, err
+	}
+
+	currencies := make(map[string]struct{})
+	for key := range m.snapshots {
+		if key.walletID == wallet.ID && key.day.Before(day) {
+			currencies[key.currency] = struct{}{}
+		}
+	}
+	for _, txn := range m.transactions {
+		for _, e := range txn.Entries {
+			if e.WalletID == wallet.ID && e.Account == storages.AccountWallet && txn.CreatedAt.Before(asOf) {
+				currencies[e.Currency] = struct{}{}
+			}
+		}
+	}
+
+	balances := make(map[string]money.Decimal, len(currencies))
+	for currency := range currencies {
+		amount, _, err := m.balanceAt(walletCurrency{walletID: wallet.ID, currency: currency}, day, asOf)
+		if err != nil {
+			return nil, err
+		}
+		balances[currency] = amount
+	}
+	return balances, nil
+}
+
+// balanceAt считает остаток пары на момент until: последний снимок дня раньше day плюс
+// движения после конца этого дня. moved — были ли такие движения. Вызывается под m.mu
+func (m *Memory) balanceAt(pair walletCurrency, day, until time.Time) (money.Decimal, bool, error) {
+	amount := money.New(0, balanceScale)
+	var since time.Time
+	for key, snapshot := range m.snapshots {
+		if key.walletID == pair.walletID && key.currency == pair.currency && key.day.Before(day) && !key.day.Before(since) {
+			amount = snapshot
+			_, since = storages.SnapshotDay(key.day)
+		}
+	}
+
+	moved := false
+	for _, txn := range m.transactions {
+		if txn.CreatedAt.Before(since) || !txn.CreatedAt.Before(until) {
+			continue
+		}
+		for _, e := range txn.Entries {
+			if e.WalletID != pair.walletID || e.Currency != pair.currency || e.Account != storages.AccountWallet {
+				continue
+			}
+			change := e.Amount
+			if e.Direction == storages.Debit {
+				change = change.Neg()
+			}
+			var err error
+			if amount, err = amount.Add(change); err != nil {
+				return money.Decimal{}, false, err
+			}
+			moved = true
+		}
+	}
+	return amount, moved, nil
+}
@@ -0,0 +1,175 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"gw-currency-wallet/internal/storages"
+	"gw-currency-wallet/pkg/money"
+	"sort"
+	"time"
+)
+
+// walletCurrency — баланс кошелька в одной валюте
+type walletCurrency struct {
+	walletID int64
+	currency string
+}
+
+// CreateWallet создаёт кошелёк с нулевыми счетами во всех включённых валютах
+func (m *Memory) CreateWallet(ctx context.Context, userID int64, name string) (storages.Wallet, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.users[userID]; !ok {
+		return storages.Wallet{}, fmt.Errorf("%w: %d", storages.ErrUserNotFound, userID)
+	}
+	for _, w := range m.wallets {
+		if w.UserID == userID && w.Name == name {
+			return storages.Wallet{}, fmt.Errorf("%w: %s", storages.ErrWalletExists, name)
+		}
+	}
+	created := m.addWallet(userID, name, false)
+	created.Role = storages.RoleOwner
+	return created, nil
+}
+
+// ListWallets возвращает кошельки пользователя в порядке создания, первым — кошелёк по умолчанию,
+// а за ними общие кошельки, в которых он участник
+func (m *Memory) ListWallets(ctx context.Context, userID int64) ([]storages.Wallet, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var owned, shared []storages.Wallet
+	for _, w := range m.wallets {
+		role, ok := m.walletRole(userID, w)
+		if !ok {
+			continue
+		}
+		w.Role = role
+		if w.UserID == userID {
+			owned = append(owned, w)
+		} else {
+			shared = append(shared, w)
+		}
+	}
+	return append(owned, shared...), nil
+}
+
+func (m *Memory) GetWallet(ctx context.Context, userID, walletID int64) (storages.Wallet, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.accessibleWallet(userID, walletID)
+}
+
+// GetWalletBalances возвращает учётные балансы кошелька вместе с суммами активных холдов
+func (m *Memory) GetWalletBalances(ctx context.Context, userID, walletID int64) ([]storages.Balance, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	wallet, err := m.accessibleWallet(userID, walletID)
+	if err != nil {
+		return nil, err
+	}
+
+	balances := make([]storages.Balance, 0, len(m.balances[wallet.ID]))
+	for currency, amount := range m.balances[wallet.ID] {
+		held := money.New(0, balanceScale)
+		if wallet.Default {
+			held = m.heldAmount(wallet.UserID, currency)
+		}
+		balances = append(balances, storages.Balance{
+			UserID:   wallet.UserID,
+			WalletID: wallet.ID,
+			Currency: currency,
+			Amount:   amount,
+			Held:     held,
+			Version:  m.version(walletCurrency{walletID: wallet.ID, currency: currency}),
+		})
+	}
+	sort.Slice(balances, func(i, j int) bool { return balances[i].Currency < balances[j].Currency })
+	return balances, nil
+}
+
+// addWallet создаёт кошелёк с нулевыми счетами во всех включённых валютах справочника.
+// Вызывается под m.mu
+func (m *Memory) addWallet(userID int64, name string, isDefault bool) storages.Wallet {
+	wallet := storages.Wallet{
+		ID:        int64(len(m.wallets)) + 1,
+		UserID:    userID,
+		Name:      name,
+		Default:   isDefault,
+		CreatedAt: time.Now(),
+	}
+	m.wallets = append(m.wallets, wallet)
+	if isDefault {
+		m.defaultWallets[userID] = wallet.ID
+	}
+
+	balances := make(map[string]money.Decimal, len(m.currencies))
+	for code, c := range m.currencies {
+		if c.Enabled {
+			balances[code] = money.New(0, balanceScale)
+		}
+	}
+	m.balances[wallet.ID] = balances
+	return wallet
+}
+
+// wallet возвращает кошелёк пользователя; walletID 0 — кошелёк по умолчанию. Вызывается под m.mu
+func (m *Memory) wallet(userID, walletID int64) (storages.Wallet, error) {
+	if walletID == 0 {
+		walletID = m.defaultWallets[userID]
+	}
+	if walletID < 1 || walletID > int64(len(m.wallets)) || m.wallets[walletID-1].UserID != userID {
+		return storages.Wallet{}, fmt.Errorf("%w: user %d, wallet %d", storages.ErrWalletNotFound, userID, walletID)
+	}
+	return m.wallets[walletID-1], nil
+}
+
+// accessibleWallet — кошелёк, которым пользователь владеет или в котором участвует, с его ролью.
+// Вызывается под m.mu
+func (m *Memory) accessibleWallet(userID, walletID int64) (storages.Wallet, error) {
+	if walletID == 0 {
+		walletID = m.defaultWallets[userID]
+	}
+	if walletID >= 1 && walletID <= int64(len(m.wallets)) {
+		wallet := m.wallets[walletID-1]
+		if role, ok := m.walletRole(userID, wallet); ok {
+			wallet.Role = role
+			return wallet, nil
+		}
+	}
+	return storages.Wallet{}, fmt.Errorf("%w: user %d, wallet %d", storages.ErrWalletNotFound, userID, walletID)
+}
+
+// walletRole — роль пользователя в кошельке; владелец — всегда owner. Вызывается под m.mu
+func (m *Memory) walletRole(userID int64, wallet storages.Wallet) (storages.WalletRole, bool) {
+	if wallet.UserID == userID {
+		return storages.RoleOwner, true
+	}
+	for _, member := range m.members {
+		if member.WalletID == wallet.ID && member.UserID == userID {
+			return member.Role, true
+		}
+	}
+	return "", false
+}
+
+// defaultBalance — баланс кошелька по умолчанию, в котором лежат холды и заявки
+func (m *Memory) defaultBalance(userID int64, currency string) walletCurrency {
+	return walletCurrency{walletID: m.defaultWallets[userID], currency: currency}
+}
+
+// version — версия баланса. Вызывается под m.mu
+func (m *Memory) version(key walletCurrency) int64 {
+	if version, ok := m.versions[key]; ok {
+		return version
+	}
+	return 1
+}
+
+// bumpVersion отмечает изменение amount или held. Вызывается под m.mu
+func (m *Memory) bumpVersion(key walletCurrency) {
+	m.versions[key] = m.version(key) + 1
+}
@@ -1,13 +1,897 @@
 package storages
 
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"gw-currency-wallet/pkg/money"
+	"strconv"
+	"time"
+)
+
 type User struct {
 	ID           int64  `json:"id"`
 	Email        string `json:"email"`
 	PasswordHash string `json:"password_hash"`
+	Tier         string `json:"tier"`  // тариф, по которому выбираются лимиты
+	Admin        bool   `json:"admin"` // доступ к административному API (журнал аудита)
+}
+
+// Wallet — именованный кошелёк пользователя с балансами в любых валютах. Кошелёк по умолчанию
+// создаётся при регистрации: через него идут операции без id кошелька, переводы, холды и заявки.
+// UserID — владелец: балансы и журнал общего кошелька записываются на него
+type Wallet struct {
+	ID        int64     `json:"id"`
+	UserID    int64     `json:"user_id"`
+	Name      string    `json:"name"`
+	Default   bool      `json:"default"`
+	CreatedAt time.Time `json:"created_at"`
+	// Role — роль пользователя, запросившего кошелёк
+	Role WalletRole `json:"role,omitempty"`
+}
+
+// DefaultWalletName — имя кошелька по умолчанию
+const DefaultWalletName = "Main"
+
+// WalletRole — роль участника общего кошелька. owner управляет участниками и правилами
+// подтверждения, spender проводит операции, viewer только видит балансы и запросы
+type WalletRole string
+
+const (
+	RoleOwner   WalletRole = "owner"
+	RoleSpender WalletRole = "spender"
+	RoleViewer  WalletRole = "viewer"
+)
+
+var roleRanks = map[WalletRole]int{RoleViewer: 1, RoleSpender: 2, RoleOwner: 3}
+
+// Allows — роль даёт не меньше прав, чем required
+func (r WalletRole) Allows(required WalletRole) bool {
+	return roleRanks[r] > 0 && roleRanks[r] >= roleRanks[required]
+}
+
+// WalletMember — участник кошелька. Владелец кошелька тоже входит в список участников
+type WalletMember struct {
+	WalletID  int64      `json:"wallet_id"`
+	UserID    int64      `json:"user_id"`
+	Email     string     `json:"email"`
+	Role      WalletRole `json:"role"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// InvitationStatus — состояние приглашения. Из pending приглашение переходит в accepted или declined
+type InvitationStatus string
+
+const (
+	InvitationPending  InvitationStatus = "pending"
+	InvitationAccepted InvitationStatus = "accepted"
+	InvitationDeclined InvitationStatus = "declined"
+)
+
+// WalletInvitation — приглашение в кошелёк по email. Принять его может пользователь с этим email
+type WalletInvitation struct {
+	ID        int64            `json:"id"`
+	WalletID  int64            `json:"wallet_id"`
+	Email     string           `json:"email"`
+	Role      WalletRole       `json:"role"`
+	InvitedBy int64            `json:"invited_by"`
+	Status    InvitationStatus `json:"status"`
+	CreatedAt time.Time        `json:"created_at"`
+	UpdatedAt time.Time        `json:"updated_at"`
+}
+
+// ApprovalRule — операции Operation в валюте Currency на сумму больше Threshold
+// ждут подтверждения второго участника кошелька
+type ApprovalRule struct {
+	WalletID  int64         `json:"wallet_id"`
+	Operation OperationType `json:"operation"`
+	Currency  string        `json:"currency"`
+	Threshold money.Decimal `json:"threshold" swaggertype:"number"`
+}
+
+// ApprovalStatus — состояние запроса. Подтверждённый запрос проводится сразу:
+// approved — операция проведена, failed — не прошла (причина в Failure)
+type ApprovalStatus string
+
+const (
+	ApprovalPending  ApprovalStatus = "pending"
+	ApprovalApproved ApprovalStatus = "approved"
+	ApprovalRejected ApprovalStatus = "rejected"
+	ApprovalFailed   ApprovalStatus = "failed"
+)
+
+// Approval — операция с кошельком, ожидающая подтверждения. Currency — валюта списания;
+// ToCurrency — только у обмена, ToWalletID — только у перемещения
+type Approval struct {
+	ID            int64          `json:"id"`
+	WalletID      int64          `json:"wallet_id"`
+	RequestedBy   int64          `json:"requested_by"`
+	Operation     OperationType  `json:"operation"`
+	Currency      string         `json:"currency"`
+	ToCurrency    string         `json:"to_currency,omitempty"`
+	ToWalletID    int64          `json:"to_wallet_id,omitempty"`
+	Amount        money.Decimal  `json:"amount" swaggertype:"number"`
+	Status        ApprovalStatus `json:"status"`
+	DecidedBy     int64          `json:"decided_by,omitempty"`
+	TransactionID int64          `json:"transaction_id,omitempty"`
+	Failure       string         `json:"failure,omitempty"`
+	CreatedAt     time.Time      `json:"created_at"`
+	DecidedAt     *time.Time     `json:"decided_at,omitempty"`
 }
 
+// Balance — баланс кошелька в одной валюте. Amount — учётный баланс (сходится с журналом),
+// Held — сумма активных холдов, которая недоступна для списаний
 type Balance struct {
-	UserID   int64   `json:"user_id"`
-	Currency string  `json:"currency"`
-	Amount   float64 `json:"amount"`
+	UserID   int64         `json:"user_id"`
+	WalletID int64         `json:"wallet_id"`
+	Currency string        `json:"currency"`
+	Amount   money.Decimal `json:"amount" swaggertype:"number"`
+	Held     money.Decimal `json:"held" swaggertype:"number"`
+	// Version растёт при каждом изменении Amount или Held
+	Version int64 `json:"version"`
+}
+
+// Available — сумма, которую можно списать, зарезервировать или обменять
+func (b Balance) Available() (money.Decimal, error) {
+	return b.Amount.Sub(b.Held)
+}
+
+// Currency — валюта из справочника (ISO 4217)
+type Currency struct {
+	Code       string `json:"code"`
+	Name       string `json:"name"`
+	MinorUnits int32  `json:"minor_units"`
+	Enabled    bool   `json:"enabled"`
+}
+
+// OperationType — тип операции, изменившей баланс
+type OperationType string
+
+const (
+	OperationDeposit  OperationType = "deposit"
+	OperationWithdraw OperationType = "withdraw"
+	OperationExchange OperationType = "exchange"
+	OperationTransfer OperationType = "transfer"
+	OperationCapture  OperationType = "capture"
+	OperationMove     OperationType = "move" // перемещение между кошельками одного пользователя
+)
+
+// Счета журнала. Кошелёк пользователя всегда проводится против одного из служебных счетов
+const (
+	AccountWallet   = "wallet"   // кошелёк пользователя
+	AccountExternal = "external" // внешний мир: пополнения и выводы
+	AccountExchange = "exchange" // конверсионный счёт обменника
+	AccountTransfer = "transfer" // транзитный счёт переводов между пользователями
+	AccountInternal = "internal" // транзитный счёт перемещений между кошельками пользователя
+	AccountFees     = "fees"     // доходы от комиссий обмена
+)
+
+// EntryDirection — сторона проводки. Кредит увеличивает кошелёк, дебет уменьшает
+type EntryDirection string
+
+const (
+	Debit  EntryDirection = "debit"
+	Credit EntryDirection = "credit"
+)
+
+// Posting — изменение баланса кошелька в одной валюте (со знаком).
+// WalletID 0 — кошелёк по умолчанию; кошелёк другого пользователя — ErrWalletNotFound.
+// IfVersion != 0 — провести, только если версия баланса равна ей, иначе ErrVersionMismatch.
+// Account — служебный счёт встречной проводки, если он отличается от счёта типа операции
+// (так комиссия обмена проводится на AccountFees)
+type Posting struct {
+	WalletID  int64
+	Currency  string
+	Amount    money.Decimal
+	IfVersion int64
+	Account   string
+}
+
+// Contra возвращает служебный счёт, против которого проводится кошелёк
+func (p Posting) Contra(opType OperationType) string {
+	if p.Account != "" {
+		return p.Account
+	}
+	return ContraAccount(opType)
+}
+
+// Transaction — операция журнала, объединяющая сбалансированные проводки.
+// Для переводов CounterpartyID — второй участник, у которого записана парная операция
+type Transaction struct {
+	ID             int64         `json:"id"`
+	UserID         int64         `json:"user_id"`
+	Type           OperationType `json:"type"`
+	CounterpartyID int64         `json:"counterparty_id,omitempty"`
+	CreatedAt      time.Time     `json:"created_at"`
+	Entries        []Entry       `json:"entries,omitempty"`
+}
+
+// Entry — проводка журнала. Сумма всегда положительна, знак задаёт Direction
+type Entry struct {
+	ID            int64          `json:"id"`
+	TransactionID int64          `json:"transaction_id"`
+	Account       string         `json:"account"`
+	UserID        int64          `json:"user_id"`
+	WalletID      int64          `json:"wallet_id,omitempty"` // только у проводок по счёту wallet
+	Currency      string         `json:"currency"`
+	Direction     EntryDirection `json:"direction"`
+	Amount        money.Decimal  `json:"amount" swaggertype:"number"`
+	CreatedAt     time.Time      `json:"created_at"`
+}
+
+// TransactionFilter — условия выборки истории операций. Нулевые поля не ограничивают выборку
+type TransactionFilter struct {
+	Currency string
+	Type     OperationType
+	From     time.Time // включительно
+	To       time.Time // не включительно
+	BeforeID int64     // курсор: только операции с id меньше этого
+	Limit    int
+	// WalletID — только операции по этому кошельку, с его проводками. Кошелёк доступен владельцу
+	// и участникам; операции видны все, а не только проведённые самим пользователем
+	WalletID int64
+}
+
+// BalanceCheck — сверка счёта кошелька: сохранённые баланс и резерв против пересчитанных по журналу
+// кошелька, активным холдам и открытым заявкам (они резервируют кошелёк по умолчанию).
+// Счёт без строки баланса сверяется с нулём
+type BalanceCheck struct {
+	UserID       int64         `json:"user_id"`
+	WalletID     int64         `json:"wallet_id"`
+	Currency     string        `json:"currency"`
+	Balance      money.Decimal `json:"balance" swaggertype:"number"`
+	Ledger       money.Decimal `json:"ledger" swaggertype:"number"`
+	Held         money.Decimal `json:"held" swaggertype:"number"`
+	ExpectedHeld money.Decimal `json:"expected_held" swaggertype:"number"`
+	Frozen       bool          `json:"frozen"`
+}
+
+// Drifted — сохранённые значения расходятся с пересчитанными
+func (c BalanceCheck) Drifted() bool {
+	return !c.Balance.Equal(c.Ledger) || !c.Held.Equal(c.ExpectedHeld)
+}
+
+// SnapshotDay возвращает начало дня по UTC, в котором лежит t, и начало следующего дня
+func SnapshotDay(t time.Time) (day, end time.Time) {
+	t = t.UTC()
+	day = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	return day, day.AddDate(0, 0, 1)
+}
+
+// StatementFilter — период и валюта выписки. Нулевые поля не ограничивают выборку
+type StatementFilter struct {
+	Currency string
+	From     time.Time // включительно
+	To       time.Time // не включительно
+}
+
+// StatementEntry — движение по кошельку для выписки. Amount со знаком: зачисление положительно
+type StatementEntry struct {
+	EntryID        int64
+	TransactionID  int64
+	Type           OperationType
+	CounterpartyID int64
+	Currency       string
+	Amount         money.Decimal
+	CreatedAt      time.Time
+}
+
+// StatementSink получает выписку по мере чтения из хранилища: сначала входящие остатки
+// на начало периода, затем движения за период в порядке валюты и id.
+// Во входящих остатках есть каждая валюта, по которой будут движения, и запрошенная валюта
+type StatementSink interface {
+	Begin(opening map[string]money.Decimal) error
+	Entry(entry StatementEntry) error
+}
+
+// HoldStatus — состояние холда. Из active холд переходит ровно в одно конечное состояние
+type HoldStatus string
+
+const (
+	HoldActive   HoldStatus = "active"
+	HoldCaptured HoldStatus = "captured"
+	HoldVoided   HoldStatus = "voided"
+	HoldExpired  HoldStatus = "expired"
+)
+
+// Hold — резерв средств. Пока холд активен, Amount входит в Balance.Held.
+// При списании (capture) остаток сверх CapturedAmount освобождается
+type Hold struct {
+	ID             int64         `json:"id"`
+	UserID         int64         `json:"user_id"`
+	Currency       string        `json:"currency"`
+	Amount         money.Decimal `json:"amount" swaggertype:"number"`
+	CapturedAmount money.Decimal `json:"captured_amount" swaggertype:"number"`
+	Status         HoldStatus    `json:"status"`
+	TransactionID  int64         `json:"transaction_id,omitempty"` // операция журнала при capture
+	ExpiresAt      time.Time     `json:"expires_at"`
+	CreatedAt      time.Time     `json:"created_at"`
+	UpdatedAt      time.Time     `json:"updated_at"`
+}
+
+// DefaultTier — тариф пользователя при регистрации
+const DefaultTier = "standard"
+
+// LimitPeriod — за какой период считается лимит. Дни и месяцы — по UTC
+type LimitPeriod string
+
+const (
+	LimitPerTransaction LimitPeriod = "transaction"
+	LimitDaily          LimitPeriod = "daily"
+	LimitMonthly        LimitPeriod = "monthly"
+)
+
+// LimitRule — ограничение операций одного типа. Правило задаётся для тарифа (Tier) или
+// для пользователя (UserID); правило пользователя перекрывает тарифное с теми же Operation
+// и Currency. Пустой Currency — суммы во всех валютах, пересчитанные в базовую валюту лимитов.
+// nil — без ограничения за этот период
+type LimitRule struct {
+	Tier           string
+	UserID         int64
+	Operation      OperationType
+	Currency       string
+	PerTransaction *money.Decimal
+	Daily          *money.Decimal
+	Monthly        *money.Decimal
+}
+
+// Check сравнивает сумму операции с правилом при уже израсходованных за день и месяц суммах
+func (r LimitRule) Check(amount, dailyUsed, monthlyUsed money.Decimal) error {
+	if r.PerTransaction != nil && amount.Cmp(*r.PerTransaction) > 0 {
+		return &LimitError{Operation: r.Operation, Currency: r.Currency, Period: LimitPerTransaction,
+			Limit: *r.PerTransaction, Remaining: *r.PerTransaction}
+	}
+	for _, period := range []struct {
+		name  LimitPeriod
+		limit *money.Decimal
+		used  money.Decimal
+	}{
+		{LimitDaily, r.Daily, dailyUsed},
+		{LimitMonthly, r.Monthly, monthlyUsed},
+	} {
+		if period.limit == nil {
+			continue
+		}
+		remaining := remainingLimit(*period.limit, period.used)
+		if amount.Cmp(remaining) > 0 {
+			return &LimitError{Operation: r.Operation, Currency: r.Currency, Period: period.name,
+				Limit: *period.limit, Remaining: remaining}
+		}
+	}
+	return nil
+}
+
+// Status — правило вместе с израсходованными и оставшимися суммами
+func (r LimitRule) Status(dailyUsed, monthlyUsed money.Decimal) LimitStatus {
+	status := LimitStatus{
+		Operation:      r.Operation,
+		Currency:       r.Currency,
+		Normalized:     r.Currency == "",
+		PerTransaction: r.PerTransaction,
+		Daily:          r.Daily,
+		Monthly:        r.Monthly,
+		DailyUsed:      dailyUsed,
+		MonthlyUsed:    monthlyUsed,
+	}
+	if r.Daily != nil {
+		remaining := remainingLimit(*r.Daily, dailyUsed)
+		status.DailyRemaining = &remaining
+	}
+	if r.Monthly != nil {
+		remaining := remainingLimit(*r.Monthly, monthlyUsed)
+		status.MonthlyRemaining = &remaining
+	}
+	return status
+}
+
+// LimitPeriods возвращает начало текущих суток и месяца по UTC
+func LimitPeriods(now time.Time) (day, month time.Time) {
+	now = now.UTC()
+	day = time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	month = time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	return day, month
+}
+
+func remainingLimit(limit, used money.Decimal) money.Decimal {
+	remaining, err := limit.Sub(used)
+	if err != nil || remaining.Sign() < 0 {
+		return money.New(0, limit.Scale())
+	}
+	return remaining
+}
+
+// LimitCharge — сумма операции, которая учитывается в лимитах. BaseAmount — та же сумма
+// в базовой валюте; нужен, только если для операции есть правило без валюты
+type LimitCharge struct {
+	Operation  OperationType
+	Currency   string
+	Amount     money.Decimal
+	BaseAmount *money.Decimal
+}
+
+// FeeRule — комиссия обмена для тарифа (Tier) или пользователя (UserID). Пустые FromCurrency
+// и ToCurrency — любая валюта. Из правил, подходящих к обмену, действует правило пользователя,
+// затем правило с более точной парой. Комиссия — Percent процентов суммы списания, но не меньше
+// MinFee; MinFee задаётся в валюте списания, поэтому только в правилах с FromCurrency
+type FeeRule struct {
+	Tier         string
+	UserID       int64
+	FromCurrency string
+	ToCurrency   string
+	Percent      money.Decimal
+	MinFee       money.Decimal
+}
+
+// Fee считает комиссию с amount в валюте с units знаками после запятой. Процент округляется
+// до ближайшего, половина — в пользу обменника
+func (r FeeRule) Fee(amount money.Decimal, units int32) (money.Decimal, error) {
+	share, err := r.Percent.Mul(money.New(1, 2), r.Percent.Scale()+2, money.RoundDown)
+	if err != nil {
+		return money.Decimal{}, err
+	}
+	fee, err := amount.Mul(share, units, money.RoundHalfUp)
+	if err != nil {
+		return money.Decimal{}, err
+	}
+	if fee.Cmp(r.MinFee) < 0 {
+		return r.MinFee.Round(units, money.RoundHalfUp)
+	}
+	return fee, nil
+}
+
+// Quote — котировка обмена: курс Rate и комиссия Fee зафиксированы до ExpiresAt. По котировке
+// проводится не больше одного обмена (TransactionID) из того же кошелька (WalletID 0 — по умолчанию)
+type Quote struct {
+	ID            int64         `json:"quote_id"`
+	UserID        int64         `json:"user_id"`
+	WalletID      int64         `json:"wallet_id,omitempty"`
+	FromCurrency  string        `json:"from_currency"`
+	ToCurrency    string        `json:"to_currency"`
+	Amount        money.Decimal `json:"amount" swaggertype:"number"`
+	Fee           money.Decimal `json:"fee" swaggertype:"number"`
+	FeePercent    money.Decimal `json:"fee_percent" swaggertype:"number"`
+	Received      money.Decimal `json:"received_amount" swaggertype:"number"`
+	Rate          money.Decimal `json:"rate" swaggertype:"number"`
+	ExpiresAt     time.Time     `json:"expires_at"`
+	CreatedAt     time.Time     `json:"created_at"`
+	UsedAt        *time.Time    `json:"used_at,omitempty"`
+	TransactionID int64         `json:"transaction_id,omitempty"`
+}
+
+// FeeRevenue — комиссии обмена, зачисленные на AccountFees за период, в одной валюте
+type FeeRevenue struct {
+	Currency  string        `json:"currency"`
+	Amount    money.Decimal `json:"amount" swaggertype:"number"`
+	Exchanges int64         `json:"exchanges"`
+}
+
+// Источники сохранённых курсов: общий список exchanger (GetExchangeRates) и курс одной пары
+// (GetExchangeRateForCurrency)
+const (
+	RateSourceList = "exchanger_list"
+	RateSourcePair = "exchanger_pair"
+)
+
+// RateSample — курс пары, полученный от exchanger в момент FetchedAt
+type RateSample struct {
+	FromCurrency string        `json:"from_currency"`
+	ToCurrency   string        `json:"to_currency"`
+	Rate         money.Decimal `json:"rate" swaggertype:"number"`
+	Source       string        `json:"source"`
+	FetchedAt    time.Time     `json:"fetched_at"`
+}
+
+// RateCandle — курсы пары за интервал [Start, Start+interval): первый, наибольший, наименьший
+// и последний из Samples полученных
+type RateCandle struct {
+	Start   time.Time     `json:"start"`
+	Open    money.Decimal `json:"open" swaggertype:"number"`
+	High    money.Decimal `json:"high" swaggertype:"number"`
+	Low     money.Decimal `json:"low" swaggertype:"number"`
+	Close   money.Decimal `json:"close" swaggertype:"number"`
+	Samples int64         `json:"samples"`
+}
+
+// LimitStatus — действующее правило и израсходованная за текущие день и месяц сумма
+type LimitStatus struct {
+	Operation        OperationType  `json:"operation"`
+	Currency         string         `json:"currency"`
+	Normalized       bool           `json:"normalized"` // суммы всех валют в базовой валюте
+	PerTransaction   *money.Decimal `json:"per_transaction,omitempty" swaggertype:"number"`
+	Daily            *money.Decimal `json:"daily,omitempty" swaggertype:"number"`
+	Monthly          *money.Decimal `json:"monthly,omitempty" swaggertype:"number"`
+	DailyUsed        money.Decimal  `json:"daily_used" swaggertype:"number"`
+	MonthlyUsed      money.Decimal  `json:"monthly_used" swaggertype:"number"`
+	DailyRemaining   *money.Decimal `json:"daily_remaining,omitempty" swaggertype:"number"`
+	MonthlyRemaining *money.Decimal `json:"monthly_remaining,omitempty" swaggertype:"number"`
+}
+
+// LimitError — операция превышает лимит. errors.Is(err, ErrLimitExceeded) == true
+type LimitError struct {
+	Operation OperationType
+	Currency  string // пусто для лимита в базовой валюте
+	Period    LimitPeriod
+	Limit     money.Decimal
+	Remaining money.Decimal
+}
+
+func (e *LimitError) Error() string {
+	currency := e.Currency
+	if currency == "" {
+		currency = "base currency"
+	}
+	return fmt.Sprintf("%s %s limit exceeded: limit %s, remaining %s (%s)", e.Period, e.Operation, e.Limit, e.Remaining, currency)
+}
+
+func (e *LimitError) Is(target error) bool {
+	return target == ErrLimitExceeded
+}
+
+// OrderStatus — состояние лимитной заявки. Из open заявка переходит ровно в одно конечное состояние
+type OrderStatus string
+
+const (
+	OrderOpen      OrderStatus = "open"
+	OrderFilled    OrderStatus = "filled"
+	OrderCancelled OrderStatus = "cancelled"
+	OrderExpired   OrderStatus = "expired"
+	// OrderRejected — заявку нельзя исполнить (лимиты, сумма меньше комиссии); причина — в Reason
+	OrderRejected OrderStatus = "rejected"
+)
+
+// Order — лимитная заявка: продать Amount в FromCurrency за ToCurrency, когда курс
+// FromCurrency→ToCurrency не ниже LimitRate. Пока заявка открыта, Amount входит в Balance.Held.
+// Исполняется по рыночному курсу Rate, Received — зачисленная сумма
+type Order struct {
+	ID            int64          `json:"id"`
+	UserID        int64          `json:"user_id"`
+	FromCurrency  string         `json:"from_currency"`
+	ToCurrency    string         `json:"to_currency"`
+	Amount        money.Decimal  `json:"amount" swaggertype:"number"`
+	LimitRate     money.Decimal  `json:"limit_rate" swaggertype:"number"`
+	Status        OrderStatus    `json:"status"`
+	Rate          *money.Decimal `json:"rate,omitempty" swaggertype:"number"`
+	Received      *money.Decimal `json:"received,omitempty" swaggertype:"number"`
+	TransactionID int64          `json:"transaction_id,omitempty"` // операция обмена при исполнении
+	Reason        string         `json:"reason,omitempty"`         // почему заявка отклонена
+	ExpiresAt     time.Time      `json:"expires_at"`
+	CreatedAt     time.Time      `json:"created_at"`
+	UpdatedAt     time.Time      `json:"updated_at"`
+}
+
+// Recurrence — периодичность расписания. Срабатывания отсчитываются от StartAt,
+// поэтому пропуски и задержки воркера не сдвигают расписание
+type Recurrence string
+
+const (
+	RecurrenceOnce    Recurrence = "once"
+	RecurrenceDaily   Recurrence = "daily"
+	RecurrenceWeekly  Recurrence = "weekly"  // в тот же день недели, что StartAt
+	RecurrenceMonthly Recurrence = "monthly" // в тот же день месяца, что StartAt, или в последний день
+)
+
+type ScheduleStatus string
+
+const (
+	ScheduleActive    ScheduleStatus = "active"
+	SchedulePaused    ScheduleStatus = "paused"
+	ScheduleCompleted ScheduleStatus = "completed" // разовое расписание выполнено
+)
+
+// Schedule — регулярная операция пользователя. Operation — deposit, withdraw, exchange
+// или transfer; ToCurrency нужен для обмена, ToUserID — для перевода
+type Schedule struct {
+	ID        int64         `json:"id"`
+	UserID    int64         `json:"user_id"`
+	Operation OperationType `json:"operation"`
+	// WalletID — кошелёк операции, 0 — кошелёк по умолчанию. ToWalletID — кошелёк назначения move
+	WalletID   int64          `json:"wallet_id,omitempty"`
+	ToWalletID int64          `json:"to_wallet_id,omitempty"`
+	Currency   string         `json:"currency"`
+	ToCurrency string         `json:"to_currency,omitempty"`
+	ToUserID   int64          `json:"to_user_id,omitempty"`
+	Amount     money.Decimal  `json:"amount" swaggertype:"number"`
+	Recurrence Recurrence     `json:"recurrence"`
+	StartAt    time.Time      `json:"start_at"`
+	NextRunAt  *time.Time     `json:"next_run_at,omitempty"`
+	Status     ScheduleStatus `json:"status"`
+	LastRunAt  *time.Time     `json:"last_run_at,omitempty"`
+	LastError  string         `json:"last_error,omitempty"`
+	CreatedAt  time.Time      `json:"created_at"`
+}
+
+// NextRun возвращает первое срабатывание строго после after или nil, если срабатываний больше нет
+func (s Schedule) NextRun(after time.Time) *time.Time {
+	if after.Before(s.StartAt) {
+		next := s.StartAt
+		return &next
+	}
+
+	var step func(n int) time.Time
+	var n int
+	switch s.Recurrence {
+	case RecurrenceDaily:
+		step = func(n int) time.Time { return s.StartAt.AddDate(0, 0, n) }
+		n = int(after.Sub(s.StartAt) / (24 * time.Hour))
+	case RecurrenceWeekly:
+		step = func(n int) time.Time { return s.StartAt.AddDate(0, 0, 7*n) }
+		n = int(after.Sub(s.StartAt) / (7 * 24 * time.Hour))
+	case RecurrenceMonthly:
+		step = func(n int) time.Time { return addMonthsClamped(s.StartAt, n) }
+		n = (after.Year()-s.StartAt.Year())*12 + int(after.Month()-s.StartAt.Month())
+	default:
+		return nil
+	}
+
+	// n — оценка снизу; досчитываем до первого срабатывания после after
+	next := step(n)
+	for !next.After(after) {
+		n++
+		next = step(n)
+	}
+	return &next
+}
+
+// addMonthsClamped сдвигает t на n месяцев; 31-е число в коротком месяце становится последним днём
+func addMonthsClamped(t time.Time, n int) time.Time {
+	year, month := t.Year(), t.Month()+time.Month(n)
+	lastDay := time.Date(year, month+1, 0, 0, 0, 0, 0, t.Location()).Day()
+	day := min(t.Day(), lastDay)
+	return time.Date(year, month, day, t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), t.Location())
+}
+
+type ScheduleRunStatus string
+
+const (
+	RunPending   ScheduleRunStatus = "pending"
+	RunSucceeded ScheduleRunStatus = "succeeded"
+	RunFailed    ScheduleRunStatus = "failed"
+)
+
+// ScheduleRun — одно срабатывание расписания. Пара (ScheduleID, ScheduledFor) уникальна,
+// поэтому одно срабатывание не выполняется дважды даже при нескольких воркерах
+type ScheduleRun struct {
+	ID            int64             `json:"id"`
+	ScheduleID    int64             `json:"schedule_id"`
+	ScheduledFor  time.Time         `json:"scheduled_for"`
+	Status        ScheduleRunStatus `json:"status"`
+	TransactionID int64             `json:"transaction_id,omitempty"`
+	Error         string            `json:"error,omitempty"`
+	CreatedAt     time.Time         `json:"created_at"`
+	FinishedAt    *time.Time        `json:"finished_at,omitempty"`
+
+	// Schedule — состояние расписания на момент захвата срабатывания, для воркера
+	Schedule Schedule `json:"-"`
+}
+
+// IdempotencyRecord — сохранённый результат запроса с ключом идемпотентности.
+// StatusCode == 0 означает, что запрос ещё выполняется; до LockedUntil ключ за ним
+type IdempotencyRecord struct {
+	UserID      int64
+	Key         string
+	Fingerprint string
+	StatusCode  int
+	Response    []byte
+	LockedUntil time.Time
+	CreatedAt   time.Time
+}
+
+// OutboxEvent — уведомление, записанное в outbox в одной транзакции с операцией. Body
+// сериализуется в Payload при записи; Attempts — число неудачных попыток доставки
+type OutboxEvent struct {
+	ID        int64
+	UserID    int64
+	Body      any
+	Payload   []byte
+	Attempts  int
+	CreatedAt time.Time
+}
+
+// TransactionEvent — тело события, в которое при записи подставляется id операции
+type TransactionEvent interface {
+	SetTransactionID(id int64)
+}
+
+// EncodeOutbox сериализует тела событий операции transactionID в Payload
+func EncodeOutbox(events []OutboxEvent, transactionID int64) ([]OutboxEvent, error) {
+	encoded := make([]OutboxEvent, len(events))
+	for i, e := range events {
+		if body, ok := e.Body.(TransactionEvent); ok {
+			body.SetTransactionID(transactionID)
+		}
+		payload, err := json.Marshal(e.Body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode outbox event: %w", err)
+		}
+		e.Payload = payload
+		encoded[i] = e
+	}
+	return encoded, nil
+}
+
+// AuditAction — событие журнала аудита
+type AuditAction string
+
+const (
+	AuditRegister    AuditAction = "user.register"
+	AuditLogin       AuditAction = "user.login"
+	AuditLoginFailed AuditAction = "user.login_failed"
+	AuditFreeze      AuditAction = "account.freeze"
+	AuditUnfreeze    AuditAction = "account.unfreeze"
+	AuditOrderCancel AuditAction = "order.cancel"
+	AuditOrderExpire AuditAction = "order.expire"
+	AuditOrderReject AuditAction = "order.reject"
+)
+
+// AuditBalanceChange — событие изменения балансов операцией opType, например balance.withdraw
+func AuditBalanceChange(opType OperationType) AuditAction {
+	return AuditAction("balance." + string(opType))
+}
+
+// AuditEntry — запись журнала аудита. ActorID — кто выполнил действие (0 — система или
+// неаутентифицированный запрос), UserID — чей счёт или учётная запись затронуты.
+// Запись только добавляется; при запечатывании она получает номер Seq в цепочке и хеш,
+// в который входит хеш предыдущей записи, поэтому изменение любой записи заметно при проверке
+type AuditEntry struct {
+	ID        int64           `json:"id"`
+	ActorID   int64           `json:"actor_id,omitempty"`
+	UserID    int64           `json:"user_id,omitempty"`
+	Action    AuditAction     `json:"action"`
+	Target    string          `json:"target,omitempty"` // например, transaction:17 или user:5
+	IP        string          `json:"ip,omitempty"`
+	UserAgent string          `json:"user_agent,omitempty"`
+	RequestID string          `json:"request_id,omitempty"`
+	Before    json.RawMessage `json:"before,omitempty" swaggertype:"object"`
+	After     json.RawMessage `json:"after,omitempty" swaggertype:"object"`
+	CreatedAt time.Time       `json:"created_at"`
+	Seq       int64           `json:"seq,omitempty"` // 0 — запись ещё не запечатана
+	PrevHash  string          `json:"prev_hash,omitempty"`
+	Hash      string          `json:"hash,omitempty"`
+}
+
+// ComputeHash считает хеш записи по её данным и PrevHash. ID и Seq в хеш не входят:
+// порядок записей задаёт цепочка PrevHash
+func (e AuditEntry) ComputeHash() string {
+	h := sha256.New()
+	for _, field := range []string{
+		e.PrevHash,
+		strconv.FormatInt(e.ActorID, 10),
+		strconv.FormatInt(e.UserID, 10),
+		string(e.Action),
+		e.Target,
+		e.IP,
+		e.UserAgent,
+		e.RequestID,
+		string(e.Before),
+		string(e.After),
+		e.CreatedAt.UTC().Format(time.RFC3339Nano),
+	} {
+		h.Write([]byte(field))
+		h.Write([]byte{0x1f})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// AuditBalance — баланс кошелька до или после операции в записи аудита
+type AuditBalance struct {
+	WalletID int64         `json:"wallet_id"`
+	Currency string        `json:"currency"`
+	Amount   money.Decimal `json:"amount"`
+}
+
+// NewBalanceAudit — запись об изменении балансов операцией txn. Балансы всегда сериализуются
+// в JSON без ошибок, поэтому запись собирается без проверки
+func NewBalanceAudit(txn Transaction, before, after []AuditBalance) AuditEntry {
+	beforeJSON, _ := json.Marshal(before)
+	afterJSON, _ := json.Marshal(after)
+	return AuditEntry{
+		UserID: txn.UserID,
+		Action: AuditBalanceChange(txn.Type),
+		Target: fmt.Sprintf("transaction:%d", txn.ID),
+		Before: beforeJSON,
+		After:  afterJSON,
+	}
+}
+
+// NewFreezeAudit — запись о заморозке счёта пользователя в валюте или о снятии заморозки
+func NewFreezeAudit(userID int64, currency string, frozen bool) AuditEntry {
+	action := AuditFreeze
+	if !frozen {
+		action = AuditUnfreeze
+	}
+	after, _ := json.Marshal(map[string]any{"currency": currency, "frozen": frozen})
+	return AuditEntry{
+		UserID: userID,
+		Action: action,
+		Target: fmt.Sprintf("account:%d/%s", userID, currency),
+		After:  after,
+	}
+}
+
+// NewOrderAudit — запись о закрытии заявки без исполнения: резерв заявки возвращается
+// в доступный баланс. Исполнение записывается в аудит проводкой обмена
+func NewOrderAudit(order Order) AuditEntry {
+	action := AuditOrderCancel
+	switch order.Status {
+	case OrderExpired:
+		action = AuditOrderExpire
+	case OrderRejected:
+		action = AuditOrderReject
+	}
+	after, _ := json.Marshal(map[string]any{
+		"status":   order.Status,
+		"currency": order.FromCurrency,
+		"released": order.Amount,
+		"reason":   order.Reason,
+	})
+	return AuditEntry{
+		UserID: order.UserID,
+		Action: action,
+		Target: fmt.Sprintf("order:%d", order.ID),
+		After:  after,
+	}
+}
+
+// AuditFilter — условия выборки журнала аудита. UserID совпадает и с ActorID, и с UserID записи.
+// Нулевые поля не ограничивают выборку
+type AuditFilter struct {
+	UserID   int64
+	Action   AuditAction
+	From     time.Time // включительно
+	To       time.Time // не включительно
+	BeforeID int64     // курсор: только записи с id меньше этого
+	Limit    int
+}
+
+// AuditMeta — кто и откуда выполняет запрос. Передаётся через context и дополняет записи аудита
+type AuditMeta struct {
+	ActorID   int64
+	IP        string
+	UserAgent string
+	RequestID string
+}
+
+type auditMetaKey struct{}
+
+func WithAuditMeta(ctx context.Context, meta AuditMeta) context.Context {
+	return context.WithValue(ctx, auditMetaKey{}, meta)
+}
+
+func AuditMetaFrom(ctx context.Context) AuditMeta {
+	meta, _ := ctx.Value(auditMetaKey{}).(AuditMeta)
+	return meta
+}
+
+// WithMeta заполняет пустые поля записи данными запроса из ctx
+func (e AuditEntry) WithMeta(ctx context.Context) AuditEntry {
+	meta := AuditMetaFrom(ctx)
+	if e.ActorID == 0 {
+		e.ActorID = meta.ActorID
+	}
+	if e.IP == "" {
+		e.IP = meta.IP
+	}
+	if e.UserAgent == "" {
+		e.UserAgent = meta.UserAgent
+	}
+	if e.RequestID == "" {
+		e.RequestID = meta.RequestID
+	}
+	return e
+}
+
+// ContraAccount возвращает служебный счёт, против которого по умолчанию проводится кошелёк
+func ContraAccount(opType OperationType) string {
+	switch opType {
+	case OperationExchange:
+		return AccountExchange
+	case OperationTransfer:
+		return AccountTransfer
+	case OperationMove:
+		return AccountInternal
+	default:
+		return AccountExternal
+	}
 }
@@ -1,14 +1,171 @@
 package storages
 
-import "context"
+import (
+	"context"
+	"gw-currency-wallet/pkg/money"
+	"time"
+)
 
 type Repository interface {
 	//Users
 	CreateUser(ctx context.Context, email, passwordHash string) (int64, error)
 	GetUserByEmail(ctx context.Context, email string) (User, error)
+	GetUser(ctx context.Context, userID int64) (User, error)
 
 	//Currencies
-	GetBalance(ctx context.Context, userID int64, currency string) (float32, error)
-	GetAllBalances(ctx context.Context, userID int64) (map[string]float32, error)
-	UpdateBalance(ctx context.Context, userID int64, currency string, amount float32) error
+	ListCurrencies(ctx context.Context) ([]Currency, error)
+	GetCurrency(ctx context.Context, code string) (Currency, error)
+
+	//Balances кошелька по умолчанию
+	GetBalance(ctx context.Context, userID int64, currency string) (money.Decimal, error)
+	GetAllBalances(ctx context.Context, userID int64) (map[string]money.Decimal, error)
+	UpdateBalance(ctx context.Context, userID int64, currency string, amount money.Decimal) error
+	GetBalances(ctx context.Context, userID int64) ([]Balance, error)
+
+	//Wallets. walletID 0 — кошелёк по умолчанию; кошелёк, в котором пользователь не владелец
+	//и не участник, — ErrWalletNotFound. Чтение возвращает и общие кошельки с ролью пользователя в Wallet.Role.
+	//Деньги между кошельками перемещаются операцией OperationMove с проводками по обоим кошелькам
+	CreateWallet(ctx context.Context, userID int64, name string) (Wallet, error)
+	ListWallets(ctx context.Context, userID int64) ([]Wallet, error)
+	GetWallet(ctx context.Context, userID, walletID int64) (Wallet, error)
+	GetWalletBalances(ctx context.Context, userID, walletID int64) ([]Balance, error)
+
+	//Sharing. Проводки по общему кошельку выполняются от имени его владельца; роли участников
+	//проверяет wallet.Service. Приглашение принимает пользователь с email из приглашения
+	CreateInvitation(ctx context.Context, invitation WalletInvitation) (WalletInvitation, error)
+	ListInvitations(ctx context.Context, userID int64) ([]WalletInvitation, error)
+	RespondInvitation(ctx context.Context, userID, invitationID int64, accept bool) (WalletInvitation, error)
+	ListWalletMembers(ctx context.Context, walletID int64) ([]WalletMember, error)
+	SetWalletMemberRole(ctx context.Context, walletID, userID int64, role WalletRole) error
+	RemoveWalletMember(ctx context.Context, walletID, userID int64) error
+
+	//Approvals. DecideApproval переводит запрос из pending в approved или rejected ровно один раз;
+	//подтвердить запрос может только не автор (ErrSelfApproval). FinishApproval записывает итог проводки
+	SetApprovalRule(ctx context.Context, rule ApprovalRule) error
+	DeleteApprovalRule(ctx context.Context, walletID int64, operation OperationType, currency string) error
+	ListApprovalRules(ctx context.Context, walletID int64) ([]ApprovalRule, error)
+	CreateApproval(ctx context.Context, approval Approval) (Approval, error)
+	ListApprovals(ctx context.Context, walletID int64) ([]Approval, error)
+	DecideApproval(ctx context.Context, walletID, approvalID, userID int64, status ApprovalStatus) (Approval, error)
+	FinishApproval(ctx context.Context, approvalID, transactionID int64, failure string) (Approval, error)
+
+	//Reconciliation. CheckBalances сверяет счета пользователей с id больше afterUserID (не больше
+	//limit пользователей) по согласованному снимку данных, не блокируя строки.
+	//Счёт — валюта пользователя по всем его кошелькам. lastUserID — последний пользователь пачки,
+	//0 — пользователей больше нет
+	CheckBalances(ctx context.Context, afterUserID int64, limit int) (checks []BalanceCheck, lastUserID int64, err error)
+	// SetAccountFrozen замораживает счёт во всех кошельках или снимает заморозку; операции
+	// по замороженному счёту возвращают ErrAccountFrozen
+	SetAccountFrozen(ctx context.Context, userID int64, currency string, frozen bool) error
+
+	//Snapshots. Снимок — остаток кошелька на конец дня по UTC; день задаётся любым моментом внутри него
+	SnapshotBalances(ctx context.Context, day time.Time) (int, error)
+	// GetBalancesAsOf возвращает учётные остатки кошелька (0 — по умолчанию) на момент asOf
+	// (не включительно) по валютам, в которых были движения: ближайший снимок до asOf плюс движения после него
+	GetBalancesAsOf(ctx context.Context, userID, walletID int64, asOf time.Time) (map[string]money.Decimal, error)
+
+	//Ledger. Проводка с IfVersion выполняется, только если версия баланса не изменилась,
+	//иначе ErrVersionMismatch; версия растёт при каждом изменении amount или held
+	PostTransaction(ctx context.Context, userID int64, opType OperationType, postings ...Posting) (Transaction, error)
+	GetTransactionEntries(ctx context.Context, transactionID int64) ([]Entry, error)
+	GetLedgerBalance(ctx context.Context, userID int64, currency string) (money.Decimal, error)
+	ListTransactions(ctx context.Context, userID int64, filter TransactionFilter) ([]Transaction, error)
+	GetTransaction(ctx context.Context, userID, transactionID int64) (Transaction, error)
+	// StreamStatement передаёт выписку по всем кошелькам в sink построчно, не собирая её в памяти.
+	// Остатки и движения читаются из одного снимка данных
+	StreamStatement(ctx context.Context, userID int64, filter StatementFilter, sink StatementSink) error
+
+	//Operations. Проверка средств и все изменения выполняются в одной транзакции БД
+	Credit(ctx context.Context, userID int64, currency string, amount money.Decimal) (Transaction, error)
+	Debit(ctx context.Context, userID int64, currency string, amount money.Decimal) (Transaction, error)
+	//Transfer расходует лимит отправителя charge, как PostWithinLimits
+	Transfer(ctx context.Context, fromUserID, toUserID int64, currency string, amount money.Decimal, charge LimitCharge, events ...OutboxEvent) (Transaction, error)
+	ExecuteExchange(ctx context.Context, userID int64, fromCurrency, toCurrency string, amount, received money.Decimal) (Transaction, error)
+
+	//Limits. PostWithinLimits проверяет лимиты, проводит операцию, учитывает её сумму и записывает
+	//events в outbox в одной транзакции; превышение — *LimitError
+	PostWithinLimits(ctx context.Context, userID int64, opType OperationType, charge LimitCharge, events []OutboxEvent, postings ...Posting) (Transaction, error)
+	GetLimitStatus(ctx context.Context, userID int64, now time.Time) ([]LimitStatus, error)
+
+	//Fees. GetFeeRule возвращает правило комиссии, действующее для обмена пользователя;
+	//нет правила — нулевое FeeRule (без комиссии). GetFeeRevenue суммирует по валютам
+	//комиссии, зачисленные на AccountFees в [from, to); нулевые границы не ограничивают период
+	GetFeeRule(ctx context.Context, userID int64, fromCurrency, toCurrency string) (FeeRule, error)
+	GetFeeRevenue(ctx context.Context, from, to time.Time) ([]FeeRevenue, error)
+
+	//Quotes. CreateQuote сохраняет котировку и удаляет просроченные неиспользованные котировки
+	//пользователя. ExecuteQuote проводит обмен по котировке так же, как PostWithinLimits, от имени
+	//userID и в той же транзакции отмечает котировку использованной: просроченная — ErrQuoteExpired,
+	//уже использованная — ErrQuoteUsed
+	CreateQuote(ctx context.Context, quote Quote) (Quote, error)
+	GetQuote(ctx context.Context, userID, quoteID int64) (Quote, error)
+	ExecuteQuote(ctx context.Context, quoteID, userID int64, charge LimitCharge, events []OutboxEvent, postings ...Posting) (Transaction, error)
+
+	//Rates. SaveRates сохраняет полученные от exchanger курсы. GetRateCandles группирует курсы пары
+	//с момента from (включительно) до to (не включая) по интервалам interval, отсчитанным от начала
+	//эпохи Unix; интервалы без курсов пропускаются
+	SaveRates(ctx context.Context, samples []RateSample) error
+	GetRateCandles(ctx context.Context, fromCurrency, toCurrency string, from, to time.Time, interval time.Duration) ([]RateCandle, error)
+
+	//Holds. Холд уменьшает доступный баланс, но не учётный; журнал меняется только при capture,
+	//и только capture расходует лимит charge
+	PlaceHold(ctx context.Context, userID int64, currency string, amount money.Decimal, expiresAt time.Time) (Hold, error)
+	GetHold(ctx context.Context, userID, holdID int64) (Hold, error)
+	CaptureHold(ctx context.Context, userID, holdID int64, amount money.Decimal, charge LimitCharge) (Hold, error)
+	VoidHold(ctx context.Context, userID, holdID int64) (Hold, error)
+	ExpireHolds(ctx context.Context, now time.Time) (int, error)
+
+	//Orders. Открытая заявка резервирует сумму так же, как холд; исполнение снимает резерв
+	//и проводит обмен postings в одной транзакции, расходуя лимит charge
+	PlaceOrder(ctx context.Context, order Order) (Order, error)
+	GetOrder(ctx context.Context, userID, orderID int64) (Order, error)
+	ListOrders(ctx context.Context, userID int64) ([]Order, error)
+	CancelOrder(ctx context.Context, userID, orderID int64) (Order, error)
+	// MatchingOrders возвращает до limit открытых непросроченных заявок пары, лимит которых не выше rate
+	MatchingOrders(ctx context.Context, fromCurrency, toCurrency string, rate money.Decimal, now time.Time, limit int) ([]Order, error)
+	FillOrder(ctx context.Context, orderID int64, rate, received money.Decimal, charge LimitCharge, events []OutboxEvent, postings ...Posting) (Order, error)
+	ExpireOrders(ctx context.Context, now time.Time) (int, error)
+	// RejectOrder закрывает открытую заявку, которую нельзя исполнить, с причиной reason
+	// и освобождает её сумму
+	RejectOrder(ctx context.Context, orderID int64, reason string) (Order, error)
+
+	//Schedules
+	CreateSchedule(ctx context.Context, schedule Schedule) (Schedule, error)
+	ListSchedules(ctx context.Context, userID int64) ([]Schedule, error)
+	GetSchedule(ctx context.Context, userID, scheduleID int64) (Schedule, error)
+	SetScheduleStatus(ctx context.Context, userID, scheduleID int64, status ScheduleStatus, now time.Time) (Schedule, error)
+	DeleteSchedule(ctx context.Context, userID, scheduleID int64) error
+	ListScheduleRuns(ctx context.Context, userID, scheduleID int64, limit int) ([]ScheduleRun, error)
+	// ClaimDueSchedules захватывает до limit наступивших срабатываний и сдвигает расписания
+	// на следующее срабатывание. Параллельные вызовы не получают одно срабатывание дважды
+	ClaimDueSchedules(ctx context.Context, now time.Time, limit int) ([]ScheduleRun, error)
+	FinishScheduleRun(ctx context.Context, run ScheduleRun) error
+	// FailStaleScheduleRuns завершает ошибкой ErrRunInterrupted срабатывания, которые захвачены
+	// раньше staleBefore и так и не получили результат (процесс упал или не смог его сохранить)
+	FailStaleScheduleRuns(ctx context.Context, staleBefore time.Time) (int, error)
+
+	//Outbox. События пишутся в транзакции операции (Transfer, FillOrder, PostWithinLimits) и
+	//доставляются реле. ClaimOutbox захватывает до limit недоставленных событий на lease в порядке id,
+	//пропуская пользователей, чьи события уже захвачены или ждут повтора, — так события одного
+	//пользователя доставляются по порядку и не больше чем одним реле одновременно
+	ClaimOutbox(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]OutboxEvent, error)
+	MarkOutboxSent(ctx context.Context, ids []int64, now time.Time) error
+	// RetryOutbox снимает захват, увеличивает Attempts и откладывает событие до nextAttemptAt
+	RetryOutbox(ctx context.Context, id int64, nextAttemptAt time.Time, lastError string) error
+	ReleaseOutbox(ctx context.Context, ids []int64) error
+
+	//Audit. Записи нельзя изменить или удалить. Изменения балансов записываются в транзакции операции,
+	//остальные события — AppendAudit; поля запроса берутся из AuditMeta в ctx. SealAudit по очереди
+	//включает незапечатанные записи в цепочку хешей и возвращает их число; AuditChain отдаёт
+	//запечатанные записи с Seq больше afterSeq в порядке цепочки
+	AppendAudit(ctx context.Context, entry AuditEntry) error
+	ListAudit(ctx context.Context, filter AuditFilter) ([]AuditEntry, error)
+	SealAudit(ctx context.Context, limit int) (int, error)
+	AuditChain(ctx context.Context, afterSeq int64, limit int) ([]AuditEntry, error)
+
+	//Idempotency. Ключ занимается на lease: если запрос так и не сохранил ответ (процесс упал),
+	//по истечении захвата ключ занимает следующий запрос с ним
+	ReserveIdempotencyKey(ctx context.Context, userID int64, key, fingerprint string, now time.Time, lease time.Duration) (IdempotencyRecord, bool, error)
+	SaveIdempotentResponse(ctx context.Context, userID int64, key string, statusCode int, response []byte) error
+	ReleaseIdempotencyKey(ctx context.Context, userID int64, key string) error
 }
@@ -0,0 +1,562 @@
+package wallet
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gw-currency-wallet/internal/cache"
+	"gw-currency-wallet/internal/currencies"
+	"gw-currency-wallet/internal/notifications"
+	"gw-currency-wallet/internal/storages"
+	"gw-currency-wallet/pkg/money"
+	"time"
+)
+
+var (
+	ErrNonPositiveAmount = errors.New("amount must be positive")
+	ErrSameCurrency      = errors.New("currencies must differ")
+	ErrAmountTooSmall    = errors.New("amount is too small to exchange")
+	ErrRateUnavailable   = errors.New("failed to get exchange rate")
+	ErrSameWallet        = errors.New("wallets must differ")
+)
+
+// largeTransferThreshold — сумма, начиная с которой обмен сопровождается уведомлением
+var largeTransferThreshold = money.New(30000, 0)
+
+// RateSource — источник курсов обмена (auth.Service). GetRatePath дополнительно сообщает,
+// из каких пар exchanger получен курс
+type RateSource interface {
+	GetExchangeRateWithCache(from, to string) (money.Decimal, error)
+	GetRatePath(from, to string) (cache.RatePath, error)
+}
+
+// Service — операции с кошельком. Через него проходят и HTTP-запросы, и фоновые задачи,
+// поэтому проверки сумм, курсы и уведомления одинаковы для всех источников операций.
+// Уведомления записываются в outbox вместе с операцией и доставляются реле (internal/outbox)
+type Service struct {
+	storage storages.Repository
+	catalog *currencies.Catalog
+	rates   RateSource
+	// limitsBase — валюта, в которую пересчитываются суммы для лимитов без валюты
+	limitsBase string
+	// quoteTTL — сколько действует котировка обмена
+	quoteTTL time.Duration
+}
+
+func NewService(storage storages.Repository, catalog *currencies.Catalog, rates RateSource, limitsBase string, quoteTTL time.Duration) *Service {
+	return &Service{
+		storage:    storage,
+		catalog:    catalog,
+		rates:      rates,
+		limitsBase: limitsBase,
+		quoteTTL:   quoteTTL,
+	}
+}
+
+// ExchangeResult — итог обмена: списанная сумма Amount, из неё комиссия Fee (FeePercent процентов,
+// но не меньше минимальной) в валюте списания, остаток пересчитан по курсу Rate в Received
+type ExchangeResult struct {
+	Transaction  storages.Transaction
+	FromCurrency string
+	ToCurrency   string
+	Amount       money.Decimal
+	Fee          money.Decimal
+	FeePercent   money.Decimal
+	Received     money.Decimal
+	Rate         money.Decimal
+	// Path — пары exchanger, из которых получен Rate; пусто для обмена по котировке
+	Path cache.RatePath
+}
+
+// ValidateAmount проверяет, что валюта есть в справочнике, а сумма положительна
+// и точно представима в минимальных единицах этой валюты
+func ValidateAmount(ctx context.Context, catalog *currencies.Catalog, amount money.Decimal, currency string) (money.Decimal, error) {
+	c, err := catalog.Lookup(ctx, currency)
+	if err != nil {
+		return money.Decimal{}, err
+	}
+	if amount.Sign() <= 0 {
+		return money.Decimal{}, ErrNonPositiveAmount
+	}
+	return amount.ForMinorUnits(c.MinorUnits)
+}
+
+// IsValidationError — ошибка во входных данных операции, а не в хранилище или сервисе курсов
+func IsValidationError(err error) bool {
+	return errors.Is(err, currencies.ErrUnsupportedCurrency) ||
+		errors.Is(err, ErrNonPositiveAmount) ||
+		errors.Is(err, ErrSameCurrency) ||
+		errors.Is(err, ErrAmountTooSmall) ||
+		errors.Is(err, ErrSameWallet) ||
+		errors.Is(err, ErrDefaultWalletShared) ||
+		errors.Is(err, ErrWalletHolder) ||
+		errors.Is(err, money.ErrOverflow) ||
+		errors.Is(err, money.ErrPrecision)
+}
+
+func (s *Service) Catalog() *currencies.Catalog {
+	return s.catalog
+}
+
+// Limits возвращает действующие лимиты пользователя с остатками. У лимитов без валюты
+// Currency заполняется базовой валютой
+func (s *Service) Limits(ctx context.Context, userID int64) ([]storages.LimitStatus, error) {
+	statuses, err := s.storage.GetLimitStatus(ctx, userID, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	for i := range statuses {
+		if statuses[i].Normalized {
+			statuses[i].Currency = s.limitsBase
+		}
+	}
+	return statuses, nil
+}
+
+// Deposit — проверка лимитов и зачисление выполняются атомарно в хранилище.
+// walletID 0 — кошелёк по умолчанию, как и в остальных операциях. В общем кошельке
+// операции доступны ролям spender и owner и проводятся от имени владельца кошелька
+func (s *Service) Deposit(ctx context.Context, userID, walletID int64, currency string, amount money.Decimal) (storages.Transaction, error) {
+	wallet, err := s.authorize(ctx, userID, walletID, storages.RoleSpender)
+	if err != nil {
+		return storages.Transaction{}, err
+	}
+	amount, err = ValidateAmount(ctx, s.catalog, amount, currency)
+	if err != nil {
+		return storages.Transaction{}, err
+	}
+	charge, err := s.limitCharge(ctx, wallet.UserID, storages.OperationDeposit, currency, amount)
+	if err != nil {
+		return storages.Transaction{}, err
+	}
+	return s.storage.PostWithinLimits(ctx, wallet.UserID, storages.OperationDeposit, charge, nil,
+		storages.Posting{WalletID: wallet.ID, Currency: currency, Amount: amount})
+}
+
+// IfMatch — баланс из ETag GET /balance/{currency}, который клиент ожидает изменить.
+// Нулевое значение — версия не проверяется
+type IfMatch struct {
+	WalletID int64
+	Currency string
+	Version  int64
+}
+
+// matchVersion — версия для Posting.IfVersion. ETag другого кошелька или валюты не совпадает
+// с изменяемым балансом: storages.ErrVersionMismatch
+func (s *Service) matchVersion(ctx context.Context, wallet storages.Wallet, currency string, ifMatch IfMatch) (int64, error) {
+	if ifMatch.Version == 0 {
+		return 0, nil
+	}
+	// authorize не читает кошелёк по умолчанию: его id нужен только для сравнения с ETag
+	if wallet.ID == 0 {
+		resolved, err := s.storage.GetWallet(ctx, wallet.UserID, 0)
+		if err != nil {
+			return 0, err
+		}
+		wallet.ID = resolved.ID
+	}
+	if ifMatch.WalletID != wallet.ID || ifMatch.Currency != currency {
+		return 0, fmt.Errorf("%w: ETag is for wallet %d, currency %s", storages.ErrVersionMismatch, ifMatch.WalletID, ifMatch.Currency)
+	}
+	return ifMatch.Version, nil
+}
+
+// Withdraw — проверка лимитов, проверка средств и списание выполняются атомарно в хранилище.
+// С ifMatch списывает, только если баланс не изменился (storages.ErrVersionMismatch).
+// Сумма выше порога правила кошелька не списывается, а ждёт подтверждения (*ApprovalRequiredError)
+func (s *Service) Withdraw(ctx context.Context, userID, walletID int64, currency string, amount money.Decimal, ifMatch IfMatch) (storages.Transaction, error) {
+	wallet, err := s.authorize(ctx, userID, walletID, storages.RoleSpender)
+	if err != nil {
+		return storages.Transaction{}, err
+	}
+	ifVersion, err := s.matchVersion(ctx, wallet, currency, ifMatch)
+	if err != nil {
+		return storages.Transaction{}, err
+	}
+	amount, err = ValidateAmount(ctx, s.catalog, amount, currency)
+	if err != nil {
+		return storages.Transaction{}, err
+	}
+	err = s.requireApproval(ctx, wallet, storages.Approval{
+		RequestedBy: userID, Operation: storages.OperationWithdraw, Currency: currency, Amount: amount,
+	}, ifVersion)
+	if err != nil {
+		return storages.Transaction{}, err
+	}
+	return s.withdraw(ctx, wallet, currency, amount, ifVersion)
+}
+
+func (s *Service) withdraw(ctx context.Context, wallet storages.Wallet, currency string, amount money.Decimal, ifVersion int64) (storages.Transaction, error) {
+	charge, err := s.limitCharge(ctx, wallet.UserID, storages.OperationWithdraw, currency, amount)
+	if err != nil {
+		return storages.Transaction{}, err
+	}
+	return s.storage.PostWithinLimits(ctx, wallet.UserID, storages.OperationWithdraw, charge, nil,
+		storages.Posting{WalletID: wallet.ID, Currency: currency, Amount: amount.Neg(), IfVersion: ifVersion})
+}
+
+// Exchange пересчитывает amount по текущему курсу и проводит обе стороны обмена одной операцией.
+// ifMatch относится к балансу fromCurrency, как в Withdraw; подтверждение — тоже как в Withdraw
+func (s *Service) Exchange(ctx context.Context, userID, walletID int64, fromCurrency, toCurrency string, amount money.Decimal, ifMatch IfMatch) (ExchangeResult, error) {
+	if fromCurrency == toCurrency {
+		return ExchangeResult{}, ErrSameCurrency
+	}
+
+	wallet, err := s.authorize(ctx, userID, walletID, storages.RoleSpender)
+	if err != nil {
+		return ExchangeResult{}, err
+	}
+	ifVersion, err := s.matchVersion(ctx, wallet, fromCurrency, ifMatch)
+	if err != nil {
+		return ExchangeResult{}, err
+	}
+	amount, err = ValidateAmount(ctx, s.catalog, amount, fromCurrency)
+	if err != nil {
+		return ExchangeResult{}, err
+	}
+	to, err := s.catalog.Lookup(ctx, toCurrency)
+	if err != nil {
+		return ExchangeResult{}, err
+	}
+	err = s.requireApproval(ctx, wallet, storages.Approval{
+		RequestedBy: userID, Operation: storages.OperationExchange, Currency: fromCurrency, ToCurrency: toCurrency, Amount: amount,
+	}, ifVersion)
+	if err != nil {
+		return ExchangeResult{}, err
+	}
+	return s.exchange(ctx, wallet, fromCurrency, to, amount, ifVersion)
+}
+
+func (s *Service) exchange(ctx context.Context, wallet storages.Wallet, fromCurrency string, to storages.Currency, amount money.Decimal, ifVersion int64) (ExchangeResult, error) {
+	result, err := s.price(ctx, wallet.UserID, fromCurrency, to, amount)
+	if err != nil {
+		return result, err
+	}
+
+	charge, err := s.limitCharge(ctx, wallet.UserID, storages.OperationExchange, fromCurrency, amount)
+	if err != nil {
+		return ExchangeResult{}, err
+	}
+	postings, err := exchangePostings(wallet, fromCurrency, to.Code, result, ifVersion)
+	if err != nil {
+		return ExchangeResult{}, err
+	}
+	result.Transaction, err = s.storage.PostWithinLimits(ctx, wallet.UserID, storages.OperationExchange, charge,
+		exchangeEvents(wallet, fromCurrency, amount), postings...)
+	if err != nil {
+		return ExchangeResult{}, err
+	}
+	return result, nil
+}
+
+// Quote фиксирует курс и комиссию обмена amount на quoteTTL. Проверки те же, что у Exchange,
+// кроме подтверждения: оно понадобится при обмене по котировке. Вместе с котировкой возвращает
+// пары exchanger, из которых получен её курс
+func (s *Service) Quote(ctx context.Context, userID, walletID int64, fromCurrency, toCurrency string, amount money.Decimal) (storages.Quote, cache.RatePath, error) {
+	if fromCurrency == toCurrency {
+		return storages.Quote{}, cache.RatePath{}, ErrSameCurrency
+	}
+
+	wallet, err := s.authorize(ctx, userID, walletID, storages.RoleSpender)
+	if err != nil {
+		return storages.Quote{}, cache.RatePath{}, err
+	}
+	amount, err = ValidateAmount(ctx, s.catalog, amount, fromCurrency)
+	if err != nil {
+		return storages.Quote{}, cache.RatePath{}, err
+	}
+	to, err := s.catalog.Lookup(ctx, toCurrency)
+	if err != nil {
+		return storages.Quote{}, cache.RatePath{}, err
+	}
+	result, err := s.price(ctx, wallet.UserID, fromCurrency, to, amount)
+	if err != nil {
+		return storages.Quote{}, cache.RatePath{}, err
+	}
+
+	quote, err := s.storage.CreateQuote(ctx, storages.Quote{
+		UserID:       userID,
+		WalletID:     wallet.ID,
+		FromCurrency: fromCurrency,
+		ToCurrency:   toCurrency,
+		Amount:       result.Amount,
+		Fee:          result.Fee,
+		FeePercent:   result.FeePercent,
+		Received:     result.Received,
+		Rate:         result.Rate,
+		ExpiresAt:    time.Now().Add(s.quoteTTL),
+	})
+	return quote, result.Path, err
+}
+
+// ExchangeQuote проводит обмен точно по курсу и комиссии котировки. Котировка действует только
+// в кошельке, для которого получена; сумма выше порога подтверждения, как и в Exchange, ждёт
+// подтверждения, которое проводится по курсу на момент подтверждения
+func (s *Service) ExchangeQuote(ctx context.Context, userID, walletID, quoteID int64, ifMatch IfMatch) (ExchangeResult, error) {
+	quote, err := s.storage.GetQuote(ctx, userID, quoteID)
+	if err != nil {
+		return ExchangeResult{}, err
+	}
+	if quote.WalletID != walletID {
+		return ExchangeResult{}, fmt.Errorf("%w: quote %d is for another wallet", storages.ErrQuoteNotFound, quoteID)
+	}
+	switch {
+	case quote.UsedAt != nil:
+		return ExchangeResult{}, storages.ErrQuoteUsed
+	case !time.Now().Before(quote.ExpiresAt):
+		return ExchangeResult{}, storages.ErrQuoteExpired
+	}
+
+	wallet, err := s.authorize(ctx, userID, walletID, storages.RoleSpender)
+	if err != nil {
+		return ExchangeResult{}, err
+	}
+	ifVersion, err := s.matchVersion(ctx, wallet, quote.FromCurrency, ifMatch)
+	if err != nil {
+		return ExchangeResult{}, err
+	}
+	err = s.requireApproval(ctx, wallet, storages.Approval{
+		RequestedBy: userID, Operation: storages.OperationExchange, Currency: quote.FromCurrency, ToCurrency: quote.ToCurrency, Amount: quote.Amount,
+	}, ifVersion)
+	if err != nil {
+		return ExchangeResult{}, err
+	}
+
+	result := ExchangeResult{
+		FromCurrency: quote.FromCurrency,
+		ToCurrency:   quote.ToCurrency,
+		Amount:       quote.Amount,
+		Fee:          quote.Fee,
+		FeePercent:   quote.FeePercent,
+		Received:     quote.Received,
+		Rate:         quote.Rate,
+	}
+	charge, err := s.limitCharge(ctx, wallet.UserID, storages.OperationExchange, quote.FromCurrency, quote.Amount)
+	if err != nil {
+		return ExchangeResult{}, err
+	}
+	postings, err := exchangePostings(wallet, quote.FromCurrency, quote.ToCurrency, result, ifVersion)
+	if err != nil {
+		return ExchangeResult{}, err
+	}
+	result.Transaction, err = s.storage.ExecuteQuote(ctx, quote.ID, wallet.UserID, charge,
+		exchangeEvents(wallet, quote.FromCurrency, quote.Amount), postings...)
+	if err != nil {
+		return ExchangeResult{}, err
+	}
+	return result, nil
+}
+
+// FillOrder исполняет лимитную заявку по курсу path так же, как обмен: с комиссией по правилу
+// пользователя и в пределах его лимитов обмена. Заявка исполняется в кошельке по умолчанию
+func (s *Service) FillOrder(ctx context.Context, order storages.Order, path cache.RatePath) (storages.Order, error) {
+	to, err := s.catalog.Lookup(ctx, order.ToCurrency)
+	if err != nil {
+		return order, err
+	}
+	result, err := s.priceAt(ctx, order.UserID, order.FromCurrency, to, order.Amount, path)
+	if err != nil {
+		return order, err
+	}
+	charge, err := s.limitCharge(ctx, order.UserID, storages.OperationExchange, order.FromCurrency, order.Amount)
+	if err != nil {
+		return order, err
+	}
+	wallet := storages.Wallet{UserID: order.UserID}
+	postings, err := exchangePostings(wallet, order.FromCurrency, order.ToCurrency, result, 0)
+	if err != nil {
+		return order, err
+	}
+	events := append([]storages.OutboxEvent{notifications.OrderFilled(order, result.Received, result.Rate)},
+		exchangeEvents(wallet, order.FromCurrency, order.Amount)...)
+	return s.storage.FillOrder(ctx, order.ID, result.Rate, result.Received, charge, events, postings...)
+}
+
+// exchangePostings — проводки обмена: списание без комиссии, зачисление и комиссия отдельной
+// проводкой из кошелька на счёт доходов
+func exchangePostings(wallet storages.Wallet, fromCurrency, toCurrency string, result ExchangeResult, ifVersion int64) ([]storages.Posting, error) {
+	converted, err := result.Amount.Sub(result.Fee)
+	if err != nil {
+		return nil, err
+	}
+	postings := []storages.Posting{
+		{WalletID: wallet.ID, Currency: fromCurrency, Amount: converted.Neg(), IfVersion: ifVersion},
+		{WalletID: wallet.ID, Currency: toCurrency, Amount: result.Received},
+	}
+	if !result.Fee.IsZero() {
+		postings = append(postings, storages.Posting{
+			WalletID: wallet.ID, Currency: fromCurrency, Amount: result.Fee.Neg(), Account: storages.AccountFees,
+		})
+	}
+	return postings, nil
+}
+
+// exchangeEvents — крупный обмен (≥30 000) сопровождается уведомлением в Kafka
+func exchangeEvents(wallet storages.Wallet, fromCurrency string, amount money.Decimal) []storages.OutboxEvent {
+	if amount.Cmp(largeTransferThreshold) < 0 {
+		return nil
+	}
+	return []storages.OutboxEvent{notifications.LargeTransfer(wallet.UserID, amount, fromCurrency)}
+}
+
+// price считает комиссию по правилу пользователя и пересчитывает остаток суммы по текущему курсу.
+// Комиссия не меньше суммы обмена и нулевой пересчёт — ErrAmountTooSmall
+func (s *Service) price(ctx context.Context, userID int64, fromCurrency string, to storages.Currency, amount money.Decimal) (ExchangeResult, error) {
+	path, err := s.rates.GetRatePath(fromCurrency, to.Code)
+	if err != nil {
+		return ExchangeResult{}, fmt.Errorf("%w: %v", ErrRateUnavailable, err)
+	}
+	return s.priceAt(ctx, userID, fromCurrency, to, amount, path)
+}
+
+// priceAt — price по уже известному курсу path
+func (s *Service) priceAt(ctx context.Context, userID int64, fromCurrency string, to storages.Currency, amount money.Decimal, path cache.RatePath) (ExchangeResult, error) {
+	from, err := s.catalog.Lookup(ctx, fromCurrency)
+	if err != nil {
+		return ExchangeResult{}, err
+	}
+	rate := path.Rate
+	rule, err := s.storage.GetFeeRule(ctx, userID, fromCurrency, to.Code)
+	if err != nil {
+		return ExchangeResult{}, err
+	}
+	fee, err := rule.Fee(amount, from.MinorUnits)
+	if err != nil {
+		return ExchangeResult{}, err
+	}
+	if fee.Cmp(amount) >= 0 {
+		return ExchangeResult{}, ErrAmountTooSmall
+	}
+	converted, err := amount.Sub(fee)
+	if err != nil {
+		return ExchangeResult{}, err
+	}
+
+	received, err := money.Convert(converted, rate, to.MinorUnits)
+	if err != nil {
+		return ExchangeResult{}, err
+	}
+	if received.IsZero() {
+		return ExchangeResult{}, ErrAmountTooSmall
+	}
+	return ExchangeResult{
+		FromCurrency: fromCurrency,
+		ToCurrency:   to.Code,
+		Amount:       amount,
+		Fee:          fee,
+		FeePercent:   rule.Percent,
+		Received:     received,
+		Rate:         rate,
+		Path:         path,
+	}, nil
+}
+
+// Move перемещает amount между кошельками одного владельца одной операцией. Деньги не покидают
+// владельца, поэтому лимиты и уведомления к перемещению не применяются. Роль spender нужна
+// в обоих кошельках; перемещение из общего кошелька подчиняется его правилам подтверждения
+func (s *Service) Move(ctx context.Context, userID, fromWalletID, toWalletID int64, currency string, amount money.Decimal) (storages.Transaction, error) {
+	if fromWalletID == toWalletID {
+		return storages.Transaction{}, ErrSameWallet
+	}
+	from, err := s.authorize(ctx, userID, fromWalletID, storages.RoleSpender)
+	if err != nil {
+		return storages.Transaction{}, err
+	}
+	to, err := s.authorize(ctx, userID, toWalletID, storages.RoleSpender)
+	if err != nil {
+		return storages.Transaction{}, err
+	}
+	if from.UserID != to.UserID {
+		return storages.Transaction{}, fmt.Errorf("%w: wallet %d belongs to another holder", storages.ErrWalletNotFound, toWalletID)
+	}
+	amount, err = ValidateAmount(ctx, s.catalog, amount, currency)
+	if err != nil {
+		return storages.Transaction{}, err
+	}
+	err = s.requireApproval(ctx, from, storages.Approval{
+		RequestedBy: userID, Operation: storages.OperationMove, Currency: currency, ToWalletID: toWalletID, Amount: amount,
+	}, 0)
+	if err != nil {
+		return storages.Transaction{}, err
+	}
+	return s.move(ctx, from, toWalletID, currency, amount)
+}
+
+func (s *Service) move(ctx context.Context, from storages.Wallet, toWalletID int64, currency string, amount money.Decimal) (storages.Transaction, error) {
+	return s.storage.PostTransaction(ctx, from.UserID, storages.OperationMove,
+		storages.Posting{WalletID: from.ID, Currency: currency, Amount: amount.Neg()},
+		storages.Posting{WalletID: toWalletID, Currency: currency, Amount: amount},
+	)
+}
+
+// Transfer переводит amount другому пользователю между кошельками по умолчанию. Деньги покидают
+// отправителя, поэтому перевод расходует его лимит снятия. Возвращает операцию отправителя
+func (s *Service) Transfer(ctx context.Context, userID, toUserID int64, currency string, amount money.Decimal) (storages.Transaction, error) {
+	amount, err := ValidateAmount(ctx, s.catalog, amount, currency)
+	if err != nil {
+		return storages.Transaction{}, err
+	}
+	charge, err := s.limitCharge(ctx, userID, storages.OperationWithdraw, currency, amount)
+	if err != nil {
+		return storages.Transaction{}, err
+	}
+
+	return s.storage.Transfer(ctx, userID, toUserID, currency, amount, charge,
+		notifications.Transfer(userID, toUserID, amount, currency))
+}
+
+// CaptureHold списывает по холду amount, а без суммы — весь холд. Списание расходует лимит снятия
+func (s *Service) CaptureHold(ctx context.Context, userID, holdID int64, amount *money.Decimal) (storages.Hold, error) {
+	hold, err := s.storage.GetHold(ctx, userID, holdID)
+	if err != nil {
+		return hold, err
+	}
+	captured := hold.Amount
+	if amount != nil {
+		if captured, err = ValidateAmount(ctx, s.catalog, *amount, hold.Currency); err != nil {
+			return hold, err
+		}
+	}
+	charge, err := s.limitCharge(ctx, userID, storages.OperationWithdraw, hold.Currency, captured)
+	if err != nil {
+		return hold, err
+	}
+	return s.storage.CaptureHold(ctx, userID, holdID, captured, charge)
+}
+
+// limitCharge — сумма операции для лимитов. В базовую валюту она пересчитывается, только если
+// для операции действует лимит без валюты; без курса такую операцию провести нельзя
+func (s *Service) limitCharge(ctx context.Context, userID int64, operation storages.OperationType, currency string, amount money.Decimal) (storages.LimitCharge, error) {
+	charge := storages.LimitCharge{Operation: operation, Currency: currency, Amount: amount}
+
+	statuses, err := s.storage.GetLimitStatus(ctx, userID, time.Now())
+	if err != nil {
+		return charge, err
+	}
+	normalized := false
+	for _, status := range statuses {
+		normalized = normalized || status.Operation == operation && status.Normalized
+	}
+	if !normalized {
+		return charge, nil
+	}
+
+	if currency == s.limitsBase {
+		charge.BaseAmount = &amount
+		return charge, nil
+	}
+	base, err := s.catalog.Lookup(ctx, s.limitsBase)
+	if err != nil {
+		return charge, fmt.Errorf("failed to get limits base currency: %w", err)
+	}
+	rate, err := s.rates.GetExchangeRateWithCache(currency, s.limitsBase)
+	if err != nil {
+		return charge, fmt.Errorf("%w: %v", ErrRateUnavailable, err)
+	}
+	baseAmount, err := money.Convert(amount, rate, base.MinorUnits)
+	if err != nil {
+		return charge, err
+	}
+	charge.BaseAmount = &baseAmount
+	return charge, nil
+}
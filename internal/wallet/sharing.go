@@ -0,0 +1,224 @@
+package wallet
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gw-currency-wallet/internal/storages"
+)
+
+var (
+	ErrRoleForbidden       = errors.New("wallet role does not allow this operation")
+	ErrDefaultWalletShared = errors.New("default wallet cannot be shared")
+	ErrWalletHolder        = errors.New("wallet holder cannot be changed or removed")
+)
+
+// ApprovalRequiredError — операция превышает порог правила кошелька и не проведена:
+// создан запрос Approval, который должен подтвердить другой участник
+type ApprovalRequiredError struct {
+	Approval storages.Approval
+}
+
+func (e *ApprovalRequiredError) Error() string {
+	return fmt.Sprintf("%s of %s %s requires approval", e.Approval.Operation, e.Approval.Amount, e.Approval.Currency)
+}
+
+// authorize проверяет роль пользователя в кошельке. Проводки по возвращённому кошельку
+// выполняются от имени его владельца (Wallet.UserID). Кошелёк по умолчанию (0) не бывает
+// общим, поэтому принадлежит самому пользователю и не требует запроса к хранилищу
+func (s *Service) authorize(ctx context.Context, userID, walletID int64, required storages.WalletRole) (storages.Wallet, error) {
+	if walletID == 0 {
+		return storages.Wallet{UserID: userID, Default: true, Role: storages.RoleOwner}, nil
+	}
+	wallet, err := s.storage.GetWallet(ctx, userID, walletID)
+	if err != nil {
+		return wallet, err
+	}
+	if !wallet.Role.Allows(required) {
+		return wallet, fmt.Errorf("%w: %s required in wallet %d", ErrRoleForbidden, required, walletID)
+	}
+	return wallet, nil
+}
+
+// requireApproval создаёт запрос, если сумма операции выше порога правила кошелька, и возвращает
+// *ApprovalRequiredError. ifVersion проверяется сразу: подтверждённый запрос проводится без него
+func (s *Service) requireApproval(ctx context.Context, wallet storages.Wallet, approval storages.Approval, ifVersion int64) error {
+	if wallet.Default {
+		return nil
+	}
+	rules, err := s.storage.ListApprovalRules(ctx, wallet.ID)
+	if err != nil {
+		return err
+	}
+	for _, rule := range rules {
+		if rule.Operation != approval.Operation || rule.Currency != approval.Currency || approval.Amount.Cmp(rule.Threshold) <= 0 {
+			continue
+		}
+		if ifVersion != 0 {
+			if err = s.checkVersion(ctx, wallet, approval.Currency, ifVersion); err != nil {
+				return err
+			}
+		}
+
+		approval.WalletID = wallet.ID
+		created, err := s.storage.CreateApproval(ctx, approval)
+		if err != nil {
+			return err
+		}
+		return &ApprovalRequiredError{Approval: created}
+	}
+	return nil
+}
+
+func (s *Service) checkVersion(ctx context.Context, wallet storages.Wallet, currency string, version int64) error {
+	balances, err := s.storage.GetWalletBalances(ctx, wallet.UserID, wallet.ID)
+	if err != nil {
+		return err
+	}
+	for _, b := range balances {
+		if b.Currency == currency && b.Version == version {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: wallet %d, currency %s", storages.ErrVersionMismatch, wallet.ID, currency)
+}
+
+// Invite приглашает email в общий кошелёк с ролью role. Приглашать может owner
+func (s *Service) Invite(ctx context.Context, userID, walletID int64, email string, role storages.WalletRole) (storages.WalletInvitation, error) {
+	wallet, err := s.authorize(ctx, userID, walletID, storages.RoleOwner)
+	if err != nil {
+		return storages.WalletInvitation{}, err
+	}
+	if wallet.Default {
+		return storages.WalletInvitation{}, ErrDefaultWalletShared
+	}
+	return s.storage.CreateInvitation(ctx, storages.WalletInvitation{
+		WalletID:  wallet.ID,
+		Email:     email,
+		Role:      role,
+		InvitedBy: userID,
+	})
+}
+
+// Members возвращает участников кошелька; список виден любой роли
+func (s *Service) Members(ctx context.Context, userID, walletID int64) ([]storages.WalletMember, error) {
+	wallet, err := s.authorize(ctx, userID, walletID, storages.RoleViewer)
+	if err != nil {
+		return nil, err
+	}
+	return s.storage.ListWalletMembers(ctx, wallet.ID)
+}
+
+// SetMemberRole меняет роль участника. Роль владельца кошелька не меняется
+func (s *Service) SetMemberRole(ctx context.Context, userID, walletID, memberID int64, role storages.WalletRole) error {
+	wallet, err := s.authorize(ctx, userID, walletID, storages.RoleOwner)
+	if err != nil {
+		return err
+	}
+	if memberID == wallet.UserID {
+		return ErrWalletHolder
+	}
+	return s.storage.SetWalletMemberRole(ctx, wallet.ID, memberID, role)
+}
+
+// RemoveMember исключает участника; сам участник может выйти из кошелька с любой ролью
+func (s *Service) RemoveMember(ctx context.Context, userID, walletID, memberID int64) error {
+	required := storages.RoleOwner
+	if memberID == userID {
+		required = storages.RoleViewer
+	}
+	wallet, err := s.authorize(ctx, userID, walletID, required)
+	if err != nil {
+		return err
+	}
+	if memberID == wallet.UserID {
+		return ErrWalletHolder
+	}
+	return s.storage.RemoveWalletMember(ctx, wallet.ID, memberID)
+}
+
+func (s *Service) ApprovalRules(ctx context.Context, userID, walletID int64) ([]storages.ApprovalRule, error) {
+	wallet, err := s.authorize(ctx, userID, walletID, storages.RoleViewer)
+	if err != nil {
+		return nil, err
+	}
+	return s.storage.ListApprovalRules(ctx, wallet.ID)
+}
+
+// SetApprovalRule задаёт порог, выше которого операция ждёт подтверждения. Правила задаёт owner
+func (s *Service) SetApprovalRule(ctx context.Context, userID, walletID int64, rule storages.ApprovalRule) (storages.ApprovalRule, error) {
+	wallet, err := s.authorize(ctx, userID, walletID, storages.RoleOwner)
+	if err != nil {
+		return rule, err
+	}
+	if wallet.Default {
+		return rule, ErrDefaultWalletShared
+	}
+	rule.WalletID = wallet.ID
+	if rule.Threshold, err = ValidateAmount(ctx, s.catalog, rule.Threshold, rule.Currency); err != nil {
+		return rule, err
+	}
+	return rule, s.storage.SetApprovalRule(ctx, rule)
+}
+
+func (s *Service) DeleteApprovalRule(ctx context.Context, userID, walletID int64, operation storages.OperationType, currency string) error {
+	wallet, err := s.authorize(ctx, userID, walletID, storages.RoleOwner)
+	if err != nil {
+		return err
+	}
+	return s.storage.DeleteApprovalRule(ctx, wallet.ID, operation, currency)
+}
+
+func (s *Service) Approvals(ctx context.Context, userID, walletID int64) ([]storages.Approval, error) {
+	wallet, err := s.authorize(ctx, userID, walletID, storages.RoleViewer)
+	if err != nil {
+		return nil, err
+	}
+	return s.storage.ListApprovals(ctx, wallet.ID)
+}
+
+// Approve подтверждает запрос другого участника и сразу проводит операцию по текущему
+// курсу и балансу. Если проводка не прошла, запрос переходит в failed с причиной в Failure
+func (s *Service) Approve(ctx context.Context, userID, walletID, approvalID int64) (storages.Approval, error) {
+	wallet, err := s.authorize(ctx, userID, walletID, storages.RoleSpender)
+	if err != nil {
+		return storages.Approval{}, err
+	}
+	approval, err := s.storage.DecideApproval(ctx, wallet.ID, approvalID, userID, storages.ApprovalApproved)
+	if err != nil {
+		return approval, err
+	}
+
+	txn, err := s.execute(ctx, wallet, approval)
+	failure := ""
+	if err != nil {
+		failure = err.Error()
+	}
+	return s.storage.FinishApproval(ctx, approval.ID, txn.ID, failure)
+}
+
+// Reject отклоняет запрос; автор может так отменить свой запрос
+func (s *Service) Reject(ctx context.Context, userID, walletID, approvalID int64) (storages.Approval, error) {
+	wallet, err := s.authorize(ctx, userID, walletID, storages.RoleSpender)
+	if err != nil {
+		return storages.Approval{}, err
+	}
+	return s.storage.DecideApproval(ctx, wallet.ID, approvalID, userID, storages.ApprovalRejected)
+}
+
+func (s *Service) execute(ctx context.Context, wallet storages.Wallet, approval storages.Approval) (storages.Transaction, error) {
+	switch approval.Operation {
+	case storages.OperationWithdraw:
+		return s.withdraw(ctx, wallet, approval.Currency, approval.Amount, 0)
+	case storages.OperationExchange:
+		to, err := s.catalog.Lookup(ctx, approval.ToCurrency)
+		if err != nil {
+			return storages.Transaction{}, err
+		}
+		result, err := s.exchange(ctx, wallet, approval.Currency, to, approval.Amount, 0)
+		return result.Transaction, err
+	case storages.OperationMove:
+		return s.move(ctx, wallet, approval.ToWalletID, approval.Currency, approval.Amount)
+	}
+	return storages.Transaction{}, fmt.Errorf("unsupported approval operation %s", approval.Operation)
+}
@@ -0,0 +1,31 @@
+package money
+
+// RateScale — точность хранения курсов обмена
+const RateScale = 8
+
+// ForMinorUnits приводит сумму к минимальным единицам валюты (units знаков после запятой).
+// Лишние знаки после запятой и суммы, не помещающиеся в DECIMAL(15,2), считаются ошибкой,
+// а не округляются молча
+func (d Decimal) ForMinorUnits(units int32) (Decimal, error) {
+	amount, err := d.Rescale(units)
+	if err != nil {
+		return Decimal{}, err
+	}
+	if err = amount.checkBounds(); err != nil {
+		return Decimal{}, err
+	}
+	return amount, nil
+}
+
+// Convert пересчитывает сумму по курсу в валюту с units знаками после запятой. Результат
+// округляется к нулю, поэтому клиент никогда не получает больше, чем обменял
+func Convert(amount, rate Decimal, units int32) (Decimal, error) {
+	converted, err := amount.Mul(rate, units, RoundDown)
+	if err != nil {
+		return Decimal{}, err
+	}
+	if err = converted.checkBounds(); err != nil {
+		return Decimal{}, err
+	}
+	return converted, nil
+}
@@ -0,0 +1,314 @@
+package money
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+var (
+	ErrInvalid   = errors.New("invalid decimal")
+	ErrOverflow  = errors.New("decimal overflow")
+	ErrPrecision = errors.New("decimal precision exceeded")
+)
+
+// MaxIntegerDigits — целая часть суммы, которую вмещает колонка DECIMAL(15,2)
+const MaxIntegerDigits = 13
+
+// RoundingMode — правило округления при уменьшении масштаба
+type RoundingMode int
+
+const (
+	RoundHalfEven RoundingMode = iota // банковское округление
+	RoundHalfUp                       // до ближайшего, половина — от нуля
+	RoundDown                         // к нулю (отбрасывание)
+)
+
+// Decimal — десятичное число с фиксированной точкой: value * 10^-scale.
+// Нулевое значение — корректный ноль
+type Decimal struct {
+	value int64
+	scale int32
+}
+
+// New создаёт число value * 10^-scale
+func New(value int64, scale int32) Decimal {
+	return Decimal{value: value, scale: scale}
+}
+
+// Parse разбирает десятичную запись вида "-123.45". Экспонента не поддерживается
+func Parse(s string) (Decimal, error) {
+	str := strings.TrimSpace(s)
+	if str == "" {
+		return Decimal{}, fmt.Errorf("%w: %q", ErrInvalid, s)
+	}
+
+	negative := false
+	switch str[0] {
+	case '-':
+		negative = true
+		str = str[1:]
+	case '+':
+		str = str[1:]
+	}
+
+	intPart, fracPart, hasPoint := strings.Cut(str, ".")
+	if intPart == "" && fracPart == "" || hasPoint && fracPart == "" {
+		return Decimal{}, fmt.Errorf("%w: %q", ErrInvalid, s)
+	}
+
+	digits := strings.TrimLeft(intPart+fracPart, "0")
+	for _, r := range intPart + fracPart {
+		if r < '0' || r > '9' {
+			return Decimal{}, fmt.Errorf("%w: %q", ErrInvalid, s)
+		}
+	}
+
+	value := new(big.Int)
+	if digits != "" {
+		value.SetString(digits, 10)
+	}
+	if negative {
+		value.Neg(value)
+	}
+	return fromBig(value, int32(len(fracPart)))
+}
+
+// MustParse — Parse для констант; паникует на некорректной записи
+func MustParse(s string) Decimal {
+	d, err := Parse(s)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+// FromFloat32 переводит курс из gRPC (float32) в кратчайшую десятичную запись,
+// которая однозначно восстанавливает исходное значение
+func FromFloat32(f float32) (Decimal, error) {
+	return Parse(strconv.FormatFloat(float64(f), 'f', -1, 32))
+}
+
+// Scale — количество знаков после запятой
+func (d Decimal) Scale() int32 {
+	return d.scale
+}
+
+func (d Decimal) Sign() int {
+	switch {
+	case d.value > 0:
+		return 1
+	case d.value < 0:
+		return -1
+	}
+	return 0
+}
+
+func (d Decimal) IsZero() bool {
+	return d.value == 0
+}
+
+func (d Decimal) Neg() Decimal {
+	return Decimal{value: -d.value, scale: d.scale}
+}
+
+func (d Decimal) Abs() Decimal {
+	if d.value < 0 {
+		return d.Neg()
+	}
+	return d
+}
+
+// Cmp сравнивает числа: -1, 0 или 1
+func (d Decimal) Cmp(o Decimal) int {
+	a, b := align(d, o)
+	return a.Cmp(b)
+}
+
+// Equal — равенство по значению независимо от масштаба
+func (d Decimal) Equal(o Decimal) bool {
+	return d.Cmp(o) == 0
+}
+
+func (d Decimal) Add(o Decimal) (Decimal, error) {
+	a, b := align(d, o)
+	return fromBig(a.Add(a, b), max(d.scale, o.scale))
+}
+
+func (d Decimal) Sub(o Decimal) (Decimal, error) {
+	return d.Add(o.Neg())
+}
+
+// Mul умножает и округляет результат до scale знаков по правилу mode
+func (d Decimal) Mul(o Decimal, scale int32, mode RoundingMode) (Decimal, error) {
+	product := new(big.Int).Mul(big.NewInt(d.value), big.NewInt(o.value))
+	return rescale(product, d.scale+o.scale, scale, mode)
+}
+
+// Div делит и округляет частное до scale знаков по правилу mode. Деление на ноль — ErrInvalid
+func (d Decimal) Div(o Decimal, scale int32, mode RoundingMode) (Decimal, error) {
+	if o.IsZero() {
+		return Decimal{}, fmt.Errorf("%w: division by zero", ErrInvalid)
+	}
+	// d/o = (d.value * 10^(scale + o.scale - d.scale) / o.value) * 10^-scale
+	numerator := big.NewInt(d.value)
+	divisor := big.NewInt(o.value)
+	if shift := scale + o.scale - d.scale; shift >= 0 {
+		numerator.Mul(numerator, pow10(shift))
+	} else {
+		divisor.Mul(divisor, pow10(-shift))
+	}
+	if divisor.Sign() < 0 {
+		numerator.Neg(numerator)
+		divisor.Neg(divisor)
+	}
+	return fromBig(quotient(numerator, divisor, mode), scale)
+}
+
+// Round округляет до scale знаков после запятой
+func (d Decimal) Round(scale int32, mode RoundingMode) (Decimal, error) {
+	return rescale(big.NewInt(d.value), d.scale, scale, mode)
+}
+
+// Rescale меняет масштаб без потери точности, иначе возвращает ErrPrecision
+func (d Decimal) Rescale(scale int32) (Decimal, error) {
+	if scale < d.scale {
+		_, rem := new(big.Int).QuoRem(big.NewInt(d.value), pow10(d.scale-scale), new(big.Int))
+		if rem.Sign() != 0 {
+			return Decimal{}, fmt.Errorf("%w: %s has more than %d decimal places", ErrPrecision, d, scale)
+		}
+	}
+	return d.Round(scale, RoundDown)
+}
+
+// String возвращает каноническую запись с Scale знаками после запятой
+func (d Decimal) String() string {
+	digits := new(big.Int).Abs(big.NewInt(d.value)).String()
+	if d.scale > 0 {
+		if pad := int(d.scale) + 1 - len(digits); pad > 0 {
+			digits = strings.Repeat("0", pad) + digits
+		}
+		point := len(digits) - int(d.scale)
+		digits = digits[:point] + "." + digits[point:]
+	} else if d.scale < 0 && d.value != 0 {
+		digits += strings.Repeat("0", int(-d.scale))
+	}
+	if d.value < 0 {
+		return "-" + digits
+	}
+	return digits
+}
+
+// MarshalJSON пишет сумму JSON-числом без потери точности
+func (d Decimal) MarshalJSON() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+// UnmarshalJSON принимает JSON-число или строку с числом: 100.50 и "100.50".
+// Прочие значения, в том числе строки с лишними кавычками, — ErrInvalid
+func (d *Decimal) UnmarshalJSON(data []byte) error {
+	str := string(data)
+	if str == "null" {
+		return nil
+	}
+	if !json.Valid(data) {
+		return fmt.Errorf("%w: %s", ErrInvalid, str)
+	}
+	switch {
+	case str[0] == '"':
+		if err := json.Unmarshal(data, &str); err != nil {
+			return fmt.Errorf("%w: %s", ErrInvalid, data)
+		}
+	case str[0] != '-' && (str[0] < '0' || str[0] > '9'):
+		return fmt.Errorf("%w: %s", ErrInvalid, str)
+	}
+	parsed, err := Parse(str)
+	if err != nil {
+		return err
+	}
+	*d = parsed
+	return nil
+}
+
+// ScanNumeric — сканирование NUMERIC из pgx без промежуточного float
+func (d *Decimal) ScanNumeric(v pgtype.Numeric) error {
+	if !v.Valid {
+		return fmt.Errorf("%w: NULL", ErrInvalid)
+	}
+	if v.NaN || v.InfinityModifier != pgtype.Finite {
+		return fmt.Errorf("%w: non-finite numeric", ErrInvalid)
+	}
+
+	value := new(big.Int).Set(v.Int)
+	scale := -v.Exp
+	if scale < 0 {
+		value.Mul(value, pow10(-scale))
+		scale = 0
+	}
+	parsed, err := fromBig(value, scale)
+	if err != nil {
+		return err
+	}
+	*d = parsed
+	return nil
+}
+
+// NumericValue — кодирование в NUMERIC для pgx
+func (d Decimal) NumericValue() (pgtype.Numeric, error) {
+	return pgtype.Numeric{Int: big.NewInt(d.value), Exp: -d.scale, Valid: true}, nil
+}
+
+func (d Decimal) checkBounds() error {
+	limit := new(big.Int).Mul(pow10(MaxIntegerDigits), pow10(d.scale))
+	if new(big.Int).Abs(big.NewInt(d.value)).Cmp(limit) >= 0 {
+		return fmt.Errorf("%w: %s exceeds %d integer digits", ErrOverflow, d, MaxIntegerDigits)
+	}
+	return nil
+}
+
+// align приводит оба числа к общему масштабу для точной арифметики
+func align(a, b Decimal) (*big.Int, *big.Int) {
+	scale := max(a.scale, b.scale)
+	x := new(big.Int).Mul(big.NewInt(a.value), pow10(scale-a.scale))
+	y := new(big.Int).Mul(big.NewInt(b.value), pow10(scale-b.scale))
+	return x, y
+}
+
+func rescale(value *big.Int, from, to int32, mode RoundingMode) (Decimal, error) {
+	if to >= from {
+		return fromBig(new(big.Int).Mul(value, pow10(to-from)), to)
+	}
+
+	return fromBig(quotient(value, pow10(from-to), mode), to)
+}
+
+// quotient делит value на положительный divisor с округлением по правилу mode
+func quotient(value, divisor *big.Int, mode RoundingMode) *big.Int {
+	quo, rem := new(big.Int).QuoRem(value, divisor, new(big.Int))
+	if rem.Sign() != 0 && mode != RoundDown {
+		// Сравниваем удвоенный остаток с делителем, чтобы понять, больше ли он половины
+		half := new(big.Int).Abs(rem)
+		half.Lsh(half, 1)
+		cmp := half.Cmp(divisor)
+		if cmp > 0 || cmp == 0 && (mode == RoundHalfUp || quo.Bit(0) == 1) {
+			quo.Add(quo, big.NewInt(int64(value.Sign())))
+		}
+	}
+	return quo
+}
+
+func fromBig(value *big.Int, scale int32) (Decimal, error) {
+	if !value.IsInt64() {
+		return Decimal{}, fmt.Errorf("%w: %s at scale %d", ErrOverflow, value, scale)
+	}
+	return Decimal{value: value.Int64(), scale: scale}, nil
+}
+
+func pow10(n int32) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil)
+}
@@ -0,0 +1,138 @@
+package money
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	d, err := Parse("-123.45")
+	assert.NoError(t, err)
+	assert.Equal(t, "-123.45", d.String())
+
+	d, err = Parse("0.05")
+	assert.NoError(t, err)
+	assert.Equal(t, "0.05", d.String())
+
+	for _, bad := range []string{"", "-", ".", "1.", "1e5", "12a", "99999999999999999999"} {
+		_, err = Parse(bad)
+		assert.Error(t, err, bad)
+	}
+}
+
+func TestDecimal_Arithmetic(t *testing.T) {
+	sum, err := MustParse("0.1").Add(MustParse("0.2"))
+	assert.NoError(t, err)
+	assert.True(t, sum.Equal(MustParse("0.3")))
+
+	diff, err := MustParse("100.5").Sub(MustParse("100.50"))
+	assert.NoError(t, err)
+	assert.True(t, diff.IsZero())
+
+	_, err = New(1<<62, 0).Add(New(1<<62, 0))
+	assert.ErrorIs(t, err, ErrOverflow)
+}
+
+func TestDecimal_Div(t *testing.T) {
+	cases := []struct {
+		a, b string
+		mode RoundingMode
+		want string
+	}{
+		{"1", "90", RoundDown, "0.01111111"},
+		{"1", "0.9", RoundHalfUp, "1.11111111"},
+		{"2", "3", RoundHalfUp, "0.66666667"},
+		{"-2", "3", RoundDown, "-0.66666666"},
+		{"1", "-8", RoundHalfEven, "-0.12500000"},
+		{"0.00000025", "2", RoundHalfEven, "0.00000012"},
+	}
+	for _, c := range cases {
+		got, err := MustParse(c.a).Div(MustParse(c.b), 8, c.mode)
+		assert.NoError(t, err)
+		assert.Equal(t, c.want, got.String(), c.a+"/"+c.b)
+	}
+
+	_, err := New(1, 0).Div(Decimal{}, 8, RoundDown)
+	assert.ErrorIs(t, err, ErrInvalid)
+}
+
+func TestDecimal_Round(t *testing.T) {
+	cases := []struct {
+		in   string
+		mode RoundingMode
+		want string
+	}{
+		{"2.345", RoundHalfEven, "2.34"},
+		{"2.355", RoundHalfEven, "2.36"},
+		{"2.345", RoundHalfUp, "2.35"},
+		{"-2.345", RoundHalfUp, "-2.35"},
+		{"2.349", RoundDown, "2.34"},
+		{"-2.349", RoundDown, "-2.34"},
+	}
+	for _, c := range cases {
+		got, err := MustParse(c.in).Round(2, c.mode)
+		assert.NoError(t, err)
+		assert.Equal(t, c.want, got.String(), c.in)
+	}
+}
+
+func TestDecimal_ForMinorUnits(t *testing.T) {
+	amount, err := MustParse("100.5").ForMinorUnits(2)
+	assert.NoError(t, err)
+	assert.Equal(t, "100.50", amount.String())
+
+	_, err = MustParse("100.505").ForMinorUnits(2)
+	assert.ErrorIs(t, err, ErrPrecision)
+
+	_, err = MustParse("10.5").ForMinorUnits(0)
+	assert.ErrorIs(t, err, ErrPrecision)
+
+	_, err = MustParse("10000000000000").ForMinorUnits(2)
+	assert.ErrorIs(t, err, ErrOverflow)
+}
+
+func TestConvert(t *testing.T) {
+	rate, err := FromFloat32(0.9)
+	assert.NoError(t, err)
+	assert.Equal(t, "0.9", rate.String())
+
+	received, err := Convert(MustParse("33.33"), rate, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, "29.99", received.String())
+}
+
+func TestDecimal_JSON(t *testing.T) {
+	var req struct {
+		Amount Decimal `json:"amount"`
+	}
+	assert.NoError(t, json.Unmarshal([]byte(`{"amount": 100.10}`), &req))
+	assert.Equal(t, "100.10", req.Amount.String())
+
+	assert.NoError(t, json.Unmarshal([]byte(`{"amount": "0.07"}`), &req))
+	assert.Equal(t, "0.07", req.Amount.String())
+
+	data, err := json.Marshal(req)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"amount": 0.07}`, string(data))
+
+	// Только одна пара кавычек вокруг числа или число без кавычек
+	var d Decimal
+	for _, raw := range []string{`""1.5"`, `"1.5`, `1.5"`, `"1.5""`, `+1.5`, `.5`, `true`, `"abc"`, `""`} {
+		assert.ErrorIs(t, d.UnmarshalJSON([]byte(raw)), ErrInvalid, raw)
+	}
+	assert.NoError(t, d.UnmarshalJSON([]byte(`-1.5`)))
+	assert.Equal(t, "-1.5", d.String())
+}
+
+func TestDecimal_Numeric(t *testing.T) {
+	var d Decimal
+	n, err := MustParse("1234.50").NumericValue()
+	assert.NoError(t, err)
+	assert.NoError(t, d.ScanNumeric(n))
+	assert.Equal(t, "1234.50", d.String())
+
+	assert.Error(t, d.ScanNumeric(pgtype.Numeric{}))
+}